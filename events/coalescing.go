@@ -0,0 +1,175 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CoalescedEvent is the summary of the identical events collapsed within the window
+type CoalescedEvent struct {
+	// Event type, e.g. EventWorkerError
+	Event fmt.Stringer
+	// Source is the last collapsed event with all its fields (worker, payload, error)
+	Source interface{}
+	// Count of the occurrences within the window (including the first one, which was pushed as is)
+	Count uint64
+	// First and Last occurrence timestamps
+	First time.Time
+	Last  time.Time
+	// Window is the coalescing window
+	Window time.Duration
+}
+
+// String returns the summary, e.g. `EventWorkerError x42 in last 5s`
+func (ce CoalescedEvent) String() string {
+	return fmt.Sprintf("%s x%d in last %s", ce.Event.String(), ce.Count, ce.Window)
+}
+
+// coalescingKey identifies the identical events: the same type, the same worker and the same error
+type coalescingKey struct {
+	event fmt.Stringer
+	pid   int64
+	err   string
+}
+
+type coalesced struct {
+	source interface{}
+	count  uint64
+	first  time.Time
+	last   time.Time
+	timer  *time.Timer
+}
+
+// CoalescingHandler collapses identical events (by the event type, worker pid and error) within the window.
+// The first event is pushed as is, the rest are counted and pushed as a single CoalescedEvent when the window closes.
+type CoalescingHandler struct {
+	Handler
+	window time.Duration
+
+	mu      sync.Mutex
+	stopped bool
+	pending map[coalescingKey]*coalesced
+}
+
+// NewCoalescingHandler wraps the handler with the coalescing layer
+func NewCoalescingHandler(h Handler, window time.Duration) *CoalescingHandler {
+	return &CoalescingHandler{
+		Handler: h,
+		window:  window,
+		pending: make(map[coalescingKey]*coalesced, 2),
+	}
+}
+
+// Push pushes the event to the underlying handler, or counts it if an identical event was pushed within the window
+func (ch *CoalescingHandler) Push(e interface{}) {
+	key, ok := eventKey(e)
+	if !ok {
+		ch.Handler.Push(e)
+		return
+	}
+
+	now := time.Now()
+
+	ch.mu.Lock()
+	if ch.stopped {
+		ch.mu.Unlock()
+		ch.Handler.Push(e)
+		return
+	}
+
+	if c, ok := ch.pending[key]; ok {
+		c.count++
+		c.last = now
+		c.source = e
+		ch.mu.Unlock()
+		return
+	}
+
+	ch.pending[key] = &coalesced{
+		source: e,
+		count:  1,
+		first:  now,
+		last:   now,
+		timer: time.AfterFunc(ch.window, func() {
+			ch.flush(key)
+		}),
+	}
+	ch.mu.Unlock()
+
+	ch.Handler.Push(e)
+}
+
+// Stop stops the pending window timers and pushes the pending summaries, events pushed after the Stop are not coalesced
+func (ch *CoalescingHandler) Stop() {
+	ch.mu.Lock()
+	ch.stopped = true
+	pending := ch.pending
+	ch.pending = make(map[coalescingKey]*coalesced)
+	ch.mu.Unlock()
+
+	for key, c := range pending {
+		c.timer.Stop()
+		ch.push(key, c)
+	}
+}
+
+func (ch *CoalescingHandler) flush(key coalescingKey) {
+	ch.mu.Lock()
+	c := ch.pending[key]
+	delete(ch.pending, key)
+	ch.mu.Unlock()
+
+	ch.push(key, c)
+}
+
+func (ch *CoalescingHandler) push(key coalescingKey, c *coalesced) {
+	// single occurrence was already pushed as is
+	if c == nil || c.count < 2 {
+		return
+	}
+
+	ch.Handler.Push(CoalescedEvent{
+		Event:  key.event,
+		Source: c.source,
+		Count:  c.count,
+		First:  c.first,
+		Last:   c.last,
+		Window: ch.window,
+	})
+}
+
+// eventKey returns the key used to identify identical events, false for unknown events
+func eventKey(e interface{}) (coalescingKey, bool) {
+	switch ev := e.(type) {
+	case WorkerEvent:
+		key := coalescingKey{event: ev.Event, pid: pidOf(ev.Worker)}
+		if err, ok := ev.Payload.(error); ok {
+			key.err = err.Error()
+		}
+		return key, true
+	case PoolEvent:
+		return coalescingKey{event: ev.Event, pid: pidOf(ev.Payload), err: errString(ev.Error)}, true
+	case JobEvent:
+		return coalescingKey{event: ev.Event, err: errString(ev.Error)}, true
+	case GRPCEvent:
+		return coalescingKey{event: ev.Event, err: errString(ev.Error)}, true
+	default:
+		return coalescingKey{}, false
+	}
+}
+
+// pidOf returns the pid of the worker (if any) associated with the event
+func pidOf(w interface{}) int64 {
+	if p, ok := w.(interface{ Pid() int64 }); ok {
+		return p.Pid()
+	}
+	return 0
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescingHandler(t *testing.T) {
+	h := NewCoalescingHandler(NewEventsHandler(), time.Millisecond*200)
+
+	mu := sync.Mutex{}
+	received := make([]interface{}, 0, 4)
+	h.AddListener(func(event interface{}) {
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 42; i++ {
+		h.Push(WorkerEvent{Event: EventWorkerError})
+	}
+	h.Push(PoolEvent{Event: EventNoFreeWorkers})
+	// unknown events are not coalesced
+	h.Push("foo")
+	h.Push("foo")
+
+	time.Sleep(time.Millisecond * 400)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 5)
+	assert.Equal(t, WorkerEvent{Event: EventWorkerError}, received[0])
+	assert.Equal(t, PoolEvent{Event: EventNoFreeWorkers}, received[1])
+
+	ce, ok := received[4].(CoalescedEvent)
+	require.True(t, ok)
+	assert.Equal(t, uint64(42), ce.Count)
+	assert.False(t, ce.Last.Before(ce.First))
+	assert.Equal(t, "EventWorkerError x42 in last 200ms", ce.String())
+}
+
+type testWorker struct {
+	pid int64
+}
+
+func (tw *testWorker) Pid() int64 {
+	return tw.pid
+}
+
+func TestCoalescingHandler_Key(t *testing.T) {
+	h := NewCoalescingHandler(NewEventsHandler(), time.Millisecond*200)
+
+	mu := sync.Mutex{}
+	received := make([]interface{}, 0, 8)
+	h.AddListener(func(event interface{}) {
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	})
+
+	w1, w2 := &testWorker{pid: 1}, &testWorker{pid: 2}
+	// different workers and errors are not collapsed
+	h.Push(WorkerEvent{Event: EventWorkerError, Worker: w1, Payload: errors.New("foo")})
+	h.Push(WorkerEvent{Event: EventWorkerError, Worker: w2, Payload: errors.New("foo")})
+	h.Push(WorkerEvent{Event: EventWorkerError, Worker: w1, Payload: errors.New("bar")})
+	h.Push(WorkerEvent{Event: EventWorkerError, Worker: w1, Payload: errors.New("foo")})
+
+	mu.Lock()
+	require.Len(t, received, 3)
+	mu.Unlock()
+
+	time.Sleep(time.Millisecond * 400)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 4)
+	ce, ok := received[3].(CoalescedEvent)
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), ce.Count)
+	// the collapsed event fields are kept
+	src, ok := ce.Source.(WorkerEvent)
+	require.True(t, ok)
+	assert.Same(t, w1, src.Worker)
+	assert.EqualError(t, src.Payload.(error), "foo")
+}
+
+func TestCoalescingHandler_Stop(t *testing.T) {
+	h := NewCoalescingHandler(NewEventsHandler(), time.Millisecond*100)
+
+	mu := sync.Mutex{}
+	received := make([]interface{}, 0, 4)
+	h.AddListener(func(event interface{}) {
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	})
+
+	h.Push(PoolEvent{Event: EventNoFreeWorkers})
+	h.Push(PoolEvent{Event: EventNoFreeWorkers})
+	// pending summary is pushed on stop
+	h.Stop()
+
+	mu.Lock()
+	require.Len(t, received, 2)
+	assert.Equal(t, uint64(2), received[1].(CoalescedEvent).Count)
+	mu.Unlock()
+
+	// not coalesced after the stop, stopped timers don't push the summary again
+	h.Push(PoolEvent{Event: EventNoFreeWorkers})
+	time.Sleep(time.Millisecond * 200)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 3)
+}
@@ -1,5 +1,10 @@
 package events
 
+import (
+	"fmt"
+	"time"
+)
+
 const (
 	// EventWorkerConstruct thrown when new worker is spawned.
 	EventWorkerConstruct P = iota + 10000
@@ -10,10 +15,12 @@ const (
 	// EventSupervisorError triggered when supervisor can not complete work.
 	EventSupervisorError
 
-	// EventWorkerProcessExit triggered on process wait exit
+	// EventWorkerProcessExit triggered on process wait exit: the replacement can't be allocated or the worker is
+	// terminated by the fatal signal (Payload is the worker, Error is the worker.ExitError)
 	EventWorkerProcessExit
 
-	// EventNoFreeWorkers triggered when there are no free workers in the stack and timeout for worker allocate elapsed
+	// EventNoFreeWorkers triggered when there are no free workers in the stack and timeout for worker allocate elapsed.
+	// Payload is NoFreeWorkers.
 	EventNoFreeWorkers
 
 	// EventMaxMemory caused when worker consumes more memory than allowed.
@@ -30,6 +37,57 @@ const (
 
 	// EventPoolRestart triggered when pool restart is needed
 	EventPoolRestart
+
+	// EventExecCanceled triggered when in-flight request is canceled by the CancelAll
+	EventExecCanceled
+
+	// EventPoolFallback triggered when the request is routed to the fallback (secondary) pool
+	EventPoolFallback
+
+	// EventDestroyProgress triggered periodically while the pool is waiting for the workers on Destroy. Payload is DestroyProgress.
+	EventDestroyProgress
+
+	// EventAutoscalerError triggered when the autoscaler fails to change the number of workers
+	EventAutoscalerError
+
+	// EventPoolQuarantined triggered when the worker replacements are held after the consecutive worker failures
+	// (crash loop), and again on every failed probe. Payload is Quarantine.
+	EventPoolQuarantined
+
+	// EventPoolExhausted triggered when the last ready worker is taken from the container (all the workers are busy or gone)
+	EventPoolExhausted
+
+	// EventPoolRecovered triggered when the ready worker is back in the container after the EventPoolExhausted
+	EventPoolRecovered
+
+	// EventPoolIdle triggered when no worker has been taken during the idle timeout (low traffic), once per idle period.
+	// Payload is the idle time.Duration.
+	EventPoolIdle
+
+	// EventRelaySilence triggered when the working worker is killed after the MaxRelaySilence w/o the relay activity
+	EventRelaySilence
+
+	// EventStandbyUnhealthy triggered when the health check of the standby pool fails
+	EventStandbyUnhealthy
+
+	// EventStandbyActivated triggered when the standby pool is promoted to serve the requests
+	EventStandbyActivated
+
+	// EventWorkerNotReady triggered when the spawned worker is killed after not reaching the ready state
+	// within the ReadyTimeout
+	EventWorkerNotReady
+
+	// EventPoolLifetimeReached triggered when the pool reaches the MaxPoolLifetime or the MaxTotalExecs and is
+	// destroyed, the host should start the fresh one. Payload is PoolLifetime.
+	EventPoolLifetimeReached
+
+	// EventPoolBootRetry triggered when the initial workers allocation fails and is retried (BootRetries). Payload
+	// is the number of the failed attempts, Error is the failure.
+	EventPoolBootRetry
+
+	// EventPoolError triggered on the pool level failure not tied to a single worker, e.g. the failed Destroy.
+	// Error is the failure.
+	EventPoolError
 )
 
 type P int64
@@ -56,6 +114,36 @@ func (ev P) String() string {
 		return "EventExecTTL"
 	case EventPoolRestart:
 		return "EventPoolRestart"
+	case EventExecCanceled:
+		return "EventExecCanceled"
+	case EventPoolFallback:
+		return "EventPoolFallback"
+	case EventDestroyProgress:
+		return "EventDestroyProgress"
+	case EventAutoscalerError:
+		return "EventAutoscalerError"
+	case EventPoolQuarantined:
+		return "EventPoolQuarantined"
+	case EventPoolExhausted:
+		return "EventPoolExhausted"
+	case EventPoolRecovered:
+		return "EventPoolRecovered"
+	case EventPoolIdle:
+		return "EventPoolIdle"
+	case EventRelaySilence:
+		return "EventRelaySilence"
+	case EventStandbyUnhealthy:
+		return "EventStandbyUnhealthy"
+	case EventStandbyActivated:
+		return "EventStandbyActivated"
+	case EventWorkerNotReady:
+		return "EventWorkerNotReady"
+	case EventPoolLifetimeReached:
+		return "EventPoolLifetimeReached"
+	case EventPoolBootRetry:
+		return "EventPoolBootRetry"
+	case EventPoolError:
+		return "EventPoolError"
 	}
 	return UnknownEventType
 }
@@ -69,3 +157,54 @@ type PoolEvent struct {
 	Payload interface{}
 	Error   error
 }
+
+// DestroyProgress is the EventDestroyProgress payload
+type DestroyProgress struct {
+	// Working is the number of workers still processing the requests
+	Working int
+	// Idle is the number of workers waiting for the destroy
+	Idle int
+	// Total number of workers
+	Total int
+}
+
+func (dp DestroyProgress) String() string {
+	return fmt.Sprintf("draining %d/%d workers", dp.Working, dp.Total)
+}
+
+// NoFreeWorkers is the EventNoFreeWorkers payload
+type NoFreeWorkers struct {
+	// WaitingCallers is the number of the callers waiting for the free worker when the allocate timeout elapsed,
+	// including the one timed out
+	WaitingCallers int
+}
+
+func (nf NoFreeWorkers) String() string {
+	return fmt.Sprintf("no free workers, %d callers waiting", nf.WaitingCallers)
+}
+
+// Quarantine is the EventPoolQuarantined payload
+type Quarantine struct {
+	// Failures is the number of consecutive worker failures
+	Failures uint64
+	// Held is the number of the worker replacements held by the quarantine
+	Held uint64
+	// Until is the time of the next probe
+	Until time.Time
+}
+
+func (q Quarantine) String() string {
+	return fmt.Sprintf("%d consecutive worker failures, %d replacements held until %s", q.Failures, q.Held, q.Until.Format(time.RFC3339))
+}
+
+// PoolLifetime is the EventPoolLifetimeReached payload
+type PoolLifetime struct {
+	// Uptime of the pool
+	Uptime time.Duration
+	// Execs is the total number of the executions across all the workers
+	Execs uint64
+}
+
+func (pl PoolLifetime) String() string {
+	return fmt.Sprintf("pool lifetime reached after %s and %d executions", pl.Uptime, pl.Execs)
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"sync"
+)
+
+// DefaultRecentEvents is the number of the events retained by the RecentEvents if the size is not set
+const DefaultRecentEvents = 1000
+
+// RecentEvents is the listener retaining the last N events in the ring buffer (post-mortem of the incidents).
+// Older events are overwritten, memory is bounded by the size.
+type RecentEvents struct {
+	mu   sync.Mutex
+	buf  []interface{}
+	next int
+	full bool
+}
+
+// NewRecentEvents creates the ring buffer for the last n events, DefaultRecentEvents if n is 0
+func NewRecentEvents(n int) *RecentEvents {
+	if n <= 0 {
+		n = DefaultRecentEvents
+	}
+
+	return &RecentEvents{
+		buf: make([]interface{}, n),
+	}
+}
+
+// Listen records the event, should be added as the Listener (AddListener(re.Listen))
+func (re *RecentEvents) Listen(event interface{}) {
+	re.mu.Lock()
+	re.buf[re.next] = event
+	re.next++
+	if re.next == len(re.buf) {
+		re.next = 0
+		re.full = true
+	}
+	re.mu.Unlock()
+}
+
+// Snapshot returns the copy of the retained events, the oldest first
+func (re *RecentEvents) Snapshot() []interface{} {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	if !re.full {
+		res := make([]interface{}, re.next)
+		copy(res, re.buf[:re.next])
+		return res
+	}
+
+	res := make([]interface{}, 0, len(re.buf))
+	res = append(res, re.buf[re.next:]...)
+	res = append(res, re.buf[:re.next]...)
+	return res
+}
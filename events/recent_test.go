@@ -0,0 +1,46 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentEvents(t *testing.T) {
+	re := NewRecentEvents(3)
+	assert.Empty(t, re.Snapshot())
+
+	re.Listen(1)
+	re.Listen(2)
+	assert.Equal(t, []interface{}{1, 2}, re.Snapshot())
+
+	// the oldest events are overwritten
+	re.Listen(3)
+	re.Listen(4)
+	re.Listen(5)
+	assert.Equal(t, []interface{}{3, 4, 5}, re.Snapshot())
+
+	assert.Len(t, NewRecentEvents(0).buf, DefaultRecentEvents)
+}
+
+func TestRecentEvents_Concurrent(t *testing.T) {
+	re := NewRecentEvents(10)
+	h := NewEventsHandler()
+	h.AddListener(re.Listen)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Push(PoolEvent{Event: EventNoFreeWorkers})
+				_ = re.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, re.Snapshot(), 10)
+}
@@ -7,6 +7,10 @@ const (
 	EventWorkerLog
 	// EventWorkerStderr is the worker standard error output
 	EventWorkerStderr
+	// EventWorkerInconsistentState triggered when not ready worker found in the container (lenient Take mode)
+	EventWorkerInconsistentState
+	// EventWorkerStderrOverflow triggered when the worker stderr output exceeds the stderr buffer (once per worker)
+	EventWorkerStderrOverflow
 )
 
 type W int64
@@ -19,6 +23,10 @@ func (ev W) String() string {
 		return "EventWorkerLog"
 	case EventWorkerStderr:
 		return "EventWorkerStderr"
+	case EventWorkerInconsistentState:
+		return "EventWorkerInconsistentState"
+	case EventWorkerStderrOverflow:
+		return "EventWorkerStderrOverflow"
 	}
 	return UnknownEventType
 }
@@ -33,4 +41,7 @@ type WorkerEvent struct {
 
 	// Event specific payload.
 	Payload interface{}
+
+	// Labels of the worker triggered the event (if any).
+	Labels map[string]string
 }
@@ -16,6 +16,11 @@ type StopCommand struct {
 	Stop bool `json:"stop"`
 }
 
+// IntrospectCommand asks the worker to report its internal state (loaded extensions, memory, pending work)
+type IntrospectCommand struct {
+	Introspect bool `json:"introspect"`
+}
+
 type pidCommand struct {
 	Pid int `json:"pid"`
 }
@@ -113,3 +118,37 @@ func FetchPID(rl relay.Relay) (int64, error) {
 
 	return int64(link.Pid), nil
 }
+
+// FetchIntrospection sends the introspect control command and decodes the worker's diagnostic reply
+func FetchIntrospection(rl relay.Relay) (map[string]interface{}, error) {
+	const op = errors.Op("fetch_introspection")
+	err := SendControl(rl, IntrospectCommand{Introspect: true})
+	if err != nil {
+		return nil, errors.E(op, errors.Network, err)
+	}
+
+	fr := getFrame()
+	defer putFrame(fr)
+
+	err = rl.Receive(fr)
+	if err != nil {
+		return nil, errors.E(op, errors.Network, err)
+	}
+	if !fr.VerifyCRC(fr.Header()) {
+		return nil, errors.E(op, errors.Network, errors.Str("CRC mismatch"))
+	}
+
+	flags := fr.ReadFlags()
+
+	if flags&frame.CONTROL == 0 {
+		return nil, errors.E(op, errors.Str("unexpected response, header is missing, no CONTROL flag"))
+	}
+
+	diag := make(map[string]interface{})
+	err = json.Unmarshal(fr.Payload(), &diag)
+	if err != nil {
+		return nil, errors.E(op, errors.Decode, err)
+	}
+
+	return diag, nil
+}
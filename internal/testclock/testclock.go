@@ -0,0 +1,172 @@
+// Package testclock contains the manually advanced utils.Clock shared by the pool and the watcher tests.
+package testclock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/utils"
+)
+
+// Clock is the fake utils.Clock, the time moves only on Advance. After channels, timers and tickers fire when the
+// advanced time reaches their deadline, ticks are dropped if the previous one is not received (as the time.Ticker does).
+// AfterFunc functions are called in their own goroutines.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+type waiter struct {
+	deadline time.Time
+	// 0 - one-shot (After, timers)
+	period time.Duration
+	ch     chan time.Time
+	// called instead of the ch send (AfterFunc)
+	fn      func()
+	stopped bool
+	fired   bool
+}
+
+// New creates the fake clock starting at the time
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.add(d, 0, nil).ch
+}
+
+func (c *Clock) NewTicker(d time.Duration) utils.Ticker {
+	return &ticker{c: c, w: c.add(d, d, nil)}
+}
+
+func (c *Clock) NewTimer(d time.Duration) utils.Timer {
+	return &timer{c: c, w: c.add(d, 0, nil)}
+}
+
+func (c *Clock) AfterFunc(d time.Duration, f func()) utils.Timer {
+	return &timer{c: c, w: c.add(d, 0, f)}
+}
+
+// Advance moves the time forward and fires the After channels and the tickers which deadline is reached
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for i := 0; i < len(c.waiters); i++ {
+		w := c.waiters[i]
+		if w.stopped {
+			continue
+		}
+
+		if !w.deadline.After(c.now) {
+			if w.fn != nil {
+				go w.fn()
+			} else {
+				select {
+				case w.ch <- c.now:
+				default:
+				}
+			}
+
+			if w.period == 0 {
+				w.fired = true
+				continue
+			}
+
+			for !w.deadline.After(c.now) {
+				w.deadline = w.deadline.Add(w.period)
+			}
+		}
+
+		waiters = append(waiters, w)
+	}
+	c.waiters = waiters
+}
+
+// Waiters returns the number of the pending After channels and the running tickers, used by the tests to wait
+// for the code under the test to reach the timer before it's advanced
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for i := 0; i < len(c.waiters); i++ {
+		if !c.waiters[i].stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Clock) add(d, period time.Duration, fn func()) *waiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &waiter{deadline: c.now.Add(d), period: period, ch: make(chan time.Time, 1), fn: fn}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+type ticker struct {
+	c *Clock
+	w *waiter
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *ticker) Stop() {
+	t.c.mu.Lock()
+	t.w.stopped = true
+	t.c.mu.Unlock()
+}
+
+type timer struct {
+	c *Clock
+	w *waiter
+}
+
+func (t *timer) C() <-chan time.Time {
+	if t.w.fn != nil {
+		return nil
+	}
+	return t.w.ch
+}
+
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := !t.w.stopped && !t.w.fired
+	t.w.stopped = true
+	return active
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+
+	active := !t.w.stopped && !t.w.fired
+	if active {
+		t.w.deadline = t.c.now.Add(d)
+		return true
+	}
+
+	// fired or stopped waiters are removed on the next Advance, the channel is kept
+	t.w.stopped = true
+	w := &waiter{deadline: t.c.now.Add(d), ch: t.w.ch, fn: t.w.fn}
+	t.c.waiters = append(t.c.waiters, w)
+	t.w = w
+	return false
+}
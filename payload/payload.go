@@ -1,9 +1,25 @@
 package payload
 
 import (
+	"github.com/spiral/goridge/v3/pkg/frame"
 	"github.com/spiral/roadrunner/v2/utils"
 )
 
+// Codec flags declare the payload body encoding, travel with the frame flags
+const (
+	// CodecRaw - raw bytes (default, used when codec is not set)
+	CodecRaw byte = frame.CODEC_RAW
+	// CodecJSON - JSON encoded body
+	CodecJSON byte = frame.CODEC_JSON
+	// CodecMsgpack - msgpack encoded body
+	CodecMsgpack byte = frame.CODEC_MSGPACK
+	// CodecProto - protobuf encoded body
+	CodecProto byte = frame.CODEC_PROTO
+)
+
+// codecMask covers all supported codec flags
+const codecMask = CodecRaw | CodecJSON | CodecMsgpack | CodecProto
+
 // Payload carries binary header and body to stack and
 // back to the server.
 type Payload struct {
@@ -12,9 +28,51 @@ type Payload struct {
 
 	// body contains binary payload to be processed by WorkerProcess.
 	Body []byte
+
+	// Codec declares the body encoding (see Codec* flags), 0 means not declared (raw).
+	Codec byte
+
+	// Trailers carry the out-of-band response metadata (timing, cache status) sent by the worker
+	// (see worker.TrailersKey), nil when absent. Not sent to the worker.
+	Trailers map[string]string
+
+	// Idempotent marks the payload as safe to be executed more than once. Retries (pool.RetryPolicy) are
+	// applied only to the idempotent payloads, false (default) - the payload is never retried.
+	// Not sent to the worker.
+	Idempotent bool
+
+	// Control marks the synthetic payload (health check, warmup), its executions are not counted toward the worker
+	// MaxJobs, so the synthetic traffic doesn't recycle the workers. Used by the StandbyPool health check ping,
+	// the leader (WithLeaderPayload) and the shutdown (WithShutdownPayload) payloads. Not sent to the worker.
+	Control bool
 }
 
 // String returns payload body as string
 func (p *Payload) String() string {
 	return utils.AsString(p.Body)
 }
+
+// SetCodec declares the body encoding
+func (p *Payload) SetCodec(codec byte) *Payload {
+	p.Codec = codec & codecMask
+	return p
+}
+
+// GetCodec returns declared body encoding, CodecRaw if not declared
+func (p *Payload) GetCodec() byte {
+	if p.Codec == 0 {
+		return CodecRaw
+	}
+	return p.Codec
+}
+
+// Trailer returns the response trailer value
+func (p *Payload) Trailer(key string) (string, bool) {
+	v, ok := p.Trailers[key]
+	return v, ok
+}
+
+// CodecFromFlags extracts codec from the frame flags
+func CodecFromFlags(flags byte) byte {
+	return flags & codecMask
+}
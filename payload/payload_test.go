@@ -0,0 +1,69 @@
+package payload
+
+import (
+	"testing"
+
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayload_Codec(t *testing.T) {
+	p := &Payload{Body: []byte("{}")}
+	assert.Equal(t, CodecRaw, p.GetCodec())
+
+	p.SetCodec(CodecJSON)
+	assert.Equal(t, CodecJSON, p.GetCodec())
+
+	// non-codec flags are dropped
+	p.SetCodec(CodecMsgpack | frame.CONTROL)
+	assert.Equal(t, CodecMsgpack, p.GetCodec())
+
+	assert.Equal(t, CodecProto, CodecFromFlags(frame.ERROR|frame.CODEC_PROTO))
+	assert.Equal(t, byte(0), CodecFromFlags(frame.CONTROL))
+}
+
+func TestPayload_Trailer(t *testing.T) {
+	p := &Payload{}
+	_, ok := p.Trailer("cache")
+	assert.False(t, ok)
+
+	p.Trailers = map[string]string{"cache": "hit"}
+	v, ok := p.Trailer("cache")
+	assert.True(t, ok)
+	assert.Equal(t, "hit", v)
+}
+
+func TestPayload_Pool(t *testing.T) {
+	p := GetPayload()
+	p.Body = append(p.Body, "hello"...)
+	p.Context = append(p.Context, "ctx"...)
+	p.SetCodec(CodecJSON)
+	p.Trailers = map[string]string{"cache": "hit"}
+	p.Idempotent = true
+	PutPayload(p)
+
+	// reset on put
+	assert.Empty(t, p.Body)
+	assert.Empty(t, p.Context)
+	assert.Equal(t, CodecRaw, p.GetCodec())
+	assert.Nil(t, p.Trailers)
+	assert.False(t, p.Idempotent)
+
+	// nil is ignored
+	PutPayload(nil)
+
+	// oversized payloads are not pooled (and not reset)
+	big := &Payload{Body: make([]byte, maxPooledSize+1)}
+	PutPayload(big)
+	assert.Len(t, big.Body, maxPooledSize+1)
+}
+
+func TestPayload_Resize(t *testing.T) {
+	b := make([]byte, 2, 8)
+	r := Resize(b, 6)
+	assert.Len(t, r, 6)
+	assert.Equal(t, 8, cap(r))
+
+	r = Resize(b, 16)
+	assert.Len(t, r, 16)
+}
@@ -0,0 +1,50 @@
+package payload
+
+import (
+	"sync"
+)
+
+// maxPooledSize is the max capacity of the Body (or Context) kept by the pool, larger payloads are left to the GC
+const maxPooledSize = 1 << 20
+
+var payloadPool = sync.Pool{
+	New: func() interface{} {
+		return new(Payload)
+	},
+}
+
+// GetPayload returns the empty payload from the pool, Body and Context are empty but might keep the capacity
+// of the previous use. Used for the worker responses.
+//
+// Ownership contract: responses returned by the pool Exec methods are owned by the caller, the caller may return
+// them with the PutPayload once done (optional, not returned payloads are collected by the GC as usual).
+func GetPayload() *Payload {
+	return payloadPool.Get().(*Payload)
+}
+
+// PutPayload resets the payload and returns it to the pool. The caller gives up the ownership: the payload, its
+// Body and Context and everything referencing them w/o copying (e.g. the String() result) must not be used after
+// the call. Should not be called for the request payloads which might be still read by the worker (relay).
+func PutPayload(p *Payload) {
+	if p == nil || cap(p.Body) > maxPooledSize || cap(p.Context) > maxPooledSize {
+		return
+	}
+
+	p.Body = p.Body[:0]
+	p.Context = p.Context[:0]
+	p.Codec = 0
+	p.Trailers = nil
+	p.Idempotent = false
+	p.Control = false
+	payloadPool.Put(p)
+}
+
+// Resize returns the b resized to the n bytes, the b backing array is reused if it's large enough.
+// Content of the resized slice is not defined, should be overwritten.
+func Resize(b []byte, n int) []byte {
+	if cap(b) >= n {
+		return b[:n]
+	}
+
+	return make([]byte, n)
+}
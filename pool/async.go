@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"context"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// ExecResult is the result of the asynchronous execution
+type ExecResult struct {
+	Payload *payload.Payload
+	Err     error
+}
+
+// AsyncExecutor executes the payloads on the pool in the background, results are delivered via the channels.
+// The number of the concurrent executions is bounded by the limit, ExecAsync blocks when the limit is reached.
+type AsyncExecutor struct {
+	pool Pool
+	sem  chan struct{}
+}
+
+// NewAsyncExecutor creates the AsyncExecutor for the pool, limit 0 - the number of the pool workers
+func NewAsyncExecutor(p Pool, limit uint64) *AsyncExecutor {
+	if limit == 0 {
+		limit = uint64(len(p.Workers()))
+	}
+	if limit == 0 {
+		limit = 1
+	}
+
+	return &AsyncExecutor{
+		pool: p,
+		sem:  make(chan struct{}, limit),
+	}
+}
+
+// ExecAsync executes the payload in the background (pool Exec), the channel receives exactly one result
+func (ae *AsyncExecutor) ExecAsync(p *payload.Payload) <-chan ExecResult {
+	res := make(chan ExecResult, 1)
+	ae.sem <- struct{}{}
+
+	go func() {
+		defer func() { <-ae.sem }()
+		rsp, err := ae.pool.Exec(p)
+		res <- ExecResult{Payload: rsp, Err: err}
+	}()
+
+	return res
+}
+
+// ExecAsyncContext is the ExecAsync canceled by the ctx. The ctx bounds waiting for the concurrency slot and for
+// the result, the ctx deadline is passed to the ExecDeadline. Canceled execution is not interrupted (the worker
+// completes it), the ctx error is delivered instead of the result.
+func (ae *AsyncExecutor) ExecAsyncContext(ctx context.Context, p *payload.Payload) <-chan ExecResult {
+	const op = errors.Op("pool_exec_async")
+	res := make(chan ExecResult, 1)
+
+	select {
+	case ae.sem <- struct{}{}:
+	case <-ctx.Done():
+		res <- ExecResult{Err: errors.E(op, errors.TimeOut, ctx.Err())}
+		return res
+	}
+
+	done := make(chan ExecResult, 1)
+	go func() {
+		defer func() { <-ae.sem }()
+		var rsp *payload.Payload
+		var err error
+		if deadline, ok := ctx.Deadline(); ok {
+			rsp, err = ae.pool.ExecDeadline(deadline, p)
+		} else {
+			rsp, err = ae.pool.Exec(p)
+		}
+		done <- ExecResult{Payload: rsp, Err: err}
+	}()
+
+	go func() {
+		select {
+		case r := <-done:
+			res <- r
+		case <-ctx.Done():
+			res <- ExecResult{Err: errors.E(op, errors.TimeOut, ctx.Err())}
+		}
+	}()
+
+	return res
+}
@@ -0,0 +1,61 @@
+package pool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/pool/internal/testpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPool blocks the Exec until released
+type blockingPool struct {
+	*testpool.Pool
+	release chan struct{}
+}
+
+func (bp *blockingPool) Exec(p *payload.Payload) (*payload.Payload, error) {
+	<-bp.release
+	return bp.Pool.Exec(p)
+}
+
+func Test_ExecAsync(t *testing.T) {
+	p := testpool.New("hello", 2)
+	ae := pool.NewAsyncExecutor(p, 0)
+
+	r := <-ae.ExecAsync(&payload.Payload{Body: []byte("foo")})
+	require.NoError(t, r.Err)
+	assert.Equal(t, "hello", r.Payload.String())
+
+	p.Err = errors.E(errors.Op("test"), errors.NoFreeWorkers)
+	r = <-ae.ExecAsync(&payload.Payload{Body: []byte("foo")})
+	assert.True(t, errors.Is(errors.NoFreeWorkers, r.Err))
+}
+
+func Test_ExecAsyncLimit(t *testing.T) {
+	bp := &blockingPool{Pool: testpool.New("hello", 1), release: make(chan struct{})}
+	ae := pool.NewAsyncExecutor(bp, 1)
+
+	first := ae.ExecAsync(&payload.Payload{Body: []byte("foo")})
+
+	// the only slot is taken
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	r := <-ae.ExecAsyncContext(ctx, &payload.Payload{Body: []byte("foo")})
+	assert.True(t, errors.Is(errors.TimeOut, r.Err))
+
+	close(bp.release)
+	r = <-first
+	require.NoError(t, r.Err)
+	assert.Equal(t, "hello", r.Payload.String())
+
+	// slot is released, no deadline - Exec
+	r = <-ae.ExecAsyncContext(context.Background(), &payload.Payload{Body: []byte("foo")})
+	require.NoError(t, r.Err)
+	assert.Equal(t, "hello", r.Payload.String())
+}
@@ -0,0 +1,206 @@
+// Package autoscaler scales the number of the pool workers based on the queue depth and the pool throughput.
+package autoscaler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/pool"
+	priorityqueue "github.com/spiral/roadrunner/v2/priority_queue"
+)
+
+// Config .. Autoscaler config
+type Config struct {
+	// MinWorkers is the lower bound of the number of workers.
+	MinWorkers uint64 `mapstructure:"min_workers"`
+
+	// MaxWorkers is the upper bound of the number of workers. Should be less or equal to the pool ContainerCapacity
+	// (defaults to the pool MaxWorkers), workers beyond the capacity can't be pushed to the container.
+	MaxWorkers uint64 `mapstructure:"max_workers"`
+
+	// HighWaterMark - scale up when the queue length is greater than the mark.
+	HighWaterMark uint64 `mapstructure:"high_water_mark"`
+
+	// LowWaterMark - scale down when the queue length is less or equal to the mark (0 - the queue is empty). Should be less than the HighWaterMark,
+	// the gap between the marks is the hysteresis preventing the flapping.
+	LowWaterMark uint64 `mapstructure:"low_water_mark"`
+
+	// Step defines how many workers are added or removed at once. Defaults to 1.
+	Step uint64 `mapstructure:"step"`
+
+	// Interval defines how often the queue length is checked. Defaults to 1s.
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Cooldown defines the minimal pause between two scaling actions. Defaults to the Interval.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+
+	// ScaleDownThroughput - scale down only while the pool Throughput (execs/s) is below the value, so the pool
+	// draining the queue as fast as it fills up is not shrunk. 0 - the throughput is ignored (default).
+	ScaleDownThroughput float64 `mapstructure:"scale_down_throughput"`
+}
+
+// InitDefaults enables default config values.
+func (cfg *Config) InitDefaults() {
+	if cfg.MinWorkers == 0 {
+		cfg.MinWorkers = 1
+	}
+
+	if cfg.Step == 0 {
+		cfg.Step = 1
+	}
+
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = cfg.Interval
+	}
+}
+
+// Autoscaler observes the queue length and drives the pool SetNumWorkers between the configured bounds
+type Autoscaler struct {
+	cfg   *Config
+	pool  pool.Pool
+	queue priorityqueue.Queue
+
+	// current number of workers
+	current    uint64
+	lastScaled time.Time
+
+	events events.Handler
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Options .. Autoscaler options
+type Options func(a *Autoscaler)
+
+// WithEvents sets the events handler used to push the EventAutoscalerError
+func WithEvents(eh events.Handler) Options {
+	return func(a *Autoscaler) {
+		a.events = eh
+	}
+}
+
+// NewAutoscaler creates the autoscaler, use Start to start the observation
+func NewAutoscaler(p pool.Pool, q priorityqueue.Queue, cfg *Config, options ...Options) (*Autoscaler, error) {
+	const op = errors.Op("autoscaler_new")
+	cfg.InitDefaults()
+
+	if cfg.MaxWorkers < cfg.MinWorkers {
+		return nil, errors.E(op, errors.Errorf("max_workers (%d) should be greater or equal to the min_workers (%d)", cfg.MaxWorkers, cfg.MinWorkers))
+	}
+
+	if cfg.LowWaterMark >= cfg.HighWaterMark {
+		return nil, errors.E(op, errors.Errorf("low_water_mark (%d) should be less than the high_water_mark (%d)", cfg.LowWaterMark, cfg.HighWaterMark))
+	}
+
+	if cfg.ScaleDownThroughput < 0 {
+		return nil, errors.E(op, errors.Errorf("scale_down_throughput (%v) should not be negative", cfg.ScaleDownThroughput))
+	}
+
+	if pcfg := p.Config(); pcfg != nil && cfg.MaxWorkers > pcfg.ContainerCapacity {
+		return nil, errors.E(op, errors.Errorf("max_workers (%d) exceeds the pool container_capacity (%d)", cfg.MaxWorkers, pcfg.ContainerCapacity))
+	}
+
+	a := &Autoscaler{
+		cfg:     cfg,
+		pool:    p,
+		queue:   q,
+		current: uint64(len(p.Workers())),
+		events:  events.NewEventsHandler(),
+		stopCh:  make(chan struct{}),
+	}
+
+	for i := 0; i < len(options); i++ {
+		options[i](a)
+	}
+
+	return a, nil
+}
+
+// Start starts the observation in the background
+func (a *Autoscaler) Start() {
+	go func() {
+		tt := time.NewTicker(a.cfg.Interval)
+		defer tt.Stop()
+		for {
+			select {
+			case <-tt.C:
+				err := a.tick(time.Now())
+				if err != nil {
+					a.events.Push(events.PoolEvent{Event: events.EventAutoscalerError, Error: err})
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the observation, the number of workers is not changed
+func (a *Autoscaler) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.stopCh)
+	})
+}
+
+// tick checks the queue length and scales the pool if needed
+func (a *Autoscaler) tick(now time.Time) error {
+	const op = errors.Op("autoscaler_tick")
+	if now.Sub(a.lastScaled) < a.cfg.Cooldown {
+		return nil
+	}
+
+	target := a.target(a.queue.Len())
+	if target == a.current {
+		return nil
+	}
+
+	// the empty queue is kept empty by the busy workers
+	if target < a.current && a.current <= a.cfg.MaxWorkers && a.busy() {
+		return nil
+	}
+
+	err := a.pool.SetNumWorkers(target)
+	if err != nil {
+		// the pool might be scaled partially
+		a.current = uint64(len(a.pool.Workers()))
+		return errors.E(op, err)
+	}
+
+	a.current = target
+	a.lastScaled = now
+	return nil
+}
+
+// busy reports whether the pool throughput prevents the scale down (see ScaleDownThroughput)
+func (a *Autoscaler) busy() bool {
+	return a.cfg.ScaleDownThroughput > 0 && a.pool.Throughput() >= a.cfg.ScaleDownThroughput
+}
+
+// target returns the number of workers for the queue length
+func (a *Autoscaler) target(length uint64) uint64 {
+	switch {
+	case a.current < a.cfg.MinWorkers:
+		return a.cfg.MinWorkers
+	case a.current > a.cfg.MaxWorkers:
+		return a.cfg.MaxWorkers
+	case length > a.cfg.HighWaterMark:
+		if a.cfg.MaxWorkers-a.current < a.cfg.Step {
+			return a.cfg.MaxWorkers
+		}
+		return a.current + a.cfg.Step
+	case length <= a.cfg.LowWaterMark:
+		if a.current-a.cfg.MinWorkers < a.cfg.Step {
+			return a.cfg.MinWorkers
+		}
+		return a.current - a.cfg.Step
+	default:
+		return a.current
+	}
+}
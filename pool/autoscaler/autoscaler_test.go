@@ -0,0 +1,172 @@
+package autoscaler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/pool/internal/testpool"
+	priorityqueue "github.com/spiral/roadrunner/v2/priority_queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPool(num, limit uint64) *testpool.Pool {
+	p := testpool.New("", num)
+	p.Limit = limit
+	return p
+}
+
+type testQueue struct {
+	priorityqueue.Queue
+	len uint64
+}
+
+func (tq *testQueue) Len() uint64 {
+	return tq.len
+}
+
+func TestAutoscaler(t *testing.T) {
+	p := newTestPool(2, 0)
+	q := &testQueue{}
+
+	a, err := NewAutoscaler(p, q, &Config{
+		MinWorkers:    2,
+		MaxWorkers:    5,
+		HighWaterMark: 100,
+		LowWaterMark:  10,
+		Step:          2,
+		Cooldown:      time.Second,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	// backlog is growing
+	q.len = 200
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(4), p.NumWorkers())
+
+	// cooldown
+	require.NoError(t, a.tick(now.Add(time.Millisecond*500)))
+	assert.Equal(t, uint64(4), p.NumWorkers())
+
+	// upper bound
+	now = now.Add(time.Second)
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(5), p.NumWorkers())
+
+	// within the hysteresis band
+	q.len = 50
+	now = now.Add(time.Second)
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(5), p.NumWorkers())
+
+	// drained, lower bound
+	q.len = 0
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Second)
+		require.NoError(t, a.tick(now))
+	}
+	assert.Equal(t, uint64(2), p.NumWorkers())
+}
+
+func TestAutoscaler_ScaleDownThroughput(t *testing.T) {
+	p := newTestPool(3, 0)
+	q := &testQueue{}
+
+	_, err := NewAutoscaler(p, q, &Config{MinWorkers: 1, MaxWorkers: 5, HighWaterMark: 10, ScaleDownThroughput: -1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scale_down_throughput")
+
+	a, err := NewAutoscaler(p, q, &Config{MinWorkers: 1, MaxWorkers: 5, HighWaterMark: 10, ScaleDownThroughput: 100})
+	require.NoError(t, err)
+
+	// the empty queue is drained by the busy workers
+	now := time.Now()
+	p.SetRate(150)
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(3), p.NumWorkers())
+
+	// backlog scales up regardless of the throughput
+	q.len = 20
+	now = now.Add(time.Second)
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(4), p.NumWorkers())
+
+	// idle
+	q.len = 0
+	p.SetRate(10)
+	now = now.Add(time.Second)
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(3), p.NumWorkers())
+}
+
+func TestAutoscaler_DefaultLowWaterMark(t *testing.T) {
+	p := newTestPool(3, 0)
+	q := &testQueue{}
+
+	a, err := NewAutoscaler(p, q, &Config{MinWorkers: 1, MaxWorkers: 5, HighWaterMark: 10})
+	require.NoError(t, err)
+
+	// empty queue scales down with the default (0) low water mark
+	require.NoError(t, a.tick(time.Now()))
+	assert.Equal(t, uint64(2), p.NumWorkers())
+}
+
+func TestAutoscaler_PartialFailure(t *testing.T) {
+	p := newTestPool(2, 3)
+	q := &testQueue{len: 100}
+
+	a, err := NewAutoscaler(p, q, &Config{MinWorkers: 2, MaxWorkers: 6, HighWaterMark: 10, Step: 2})
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.Error(t, a.tick(now))
+	// current is re-read from the pool
+	assert.Equal(t, uint64(3), a.current)
+
+	p.Limit = 0
+	now = now.Add(time.Second)
+	require.NoError(t, a.tick(now))
+	assert.Equal(t, uint64(5), p.NumWorkers())
+}
+
+func TestAutoscaler_ErrorEvent(t *testing.T) {
+	p := newTestPool(1, 1)
+	q := &testQueue{len: 100}
+
+	errCh := make(chan error, 10)
+	eh := events.NewEventsHandler()
+	eh.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventAutoscalerError {
+			errCh <- ev.Error
+		}
+	})
+
+	a, err := NewAutoscaler(p, q, &Config{MaxWorkers: 2, HighWaterMark: 10, Interval: time.Millisecond * 10}, WithEvents(eh))
+	require.NoError(t, err)
+
+	a.Start()
+	defer a.Stop()
+
+	select {
+	case err := <-errCh:
+		assert.Error(t, err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("EventAutoscalerError expected")
+	}
+}
+
+func TestAutoscaler_Config(t *testing.T) {
+	_, err := NewAutoscaler(testpool.New("", 0), &testQueue{}, &Config{MinWorkers: 4, MaxWorkers: 2, HighWaterMark: 10})
+	assert.Error(t, err)
+
+	_, err = NewAutoscaler(testpool.New("", 0), &testQueue{}, &Config{MaxWorkers: 2, HighWaterMark: 10, LowWaterMark: 10})
+	assert.Error(t, err)
+
+	// can't grow beyond the container capacity
+	_, err = NewAutoscaler(&testpool.Pool{Cfg: &pool.Config{ContainerCapacity: 4}}, &testQueue{}, &Config{MaxWorkers: 8, HighWaterMark: 10})
+	assert.Error(t, err)
+}
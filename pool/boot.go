@@ -0,0 +1,61 @@
+package pool
+
+import (
+	"context"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// boot allocates the initial workers and adds them to the watcher. The failed boot is retried BootRetries times,
+// BootRetryDelay apart (EventPoolBootRetry), workers spawned by the failed attempt are killed before the next one.
+func (sp *StaticPool) boot(ctx context.Context) error {
+	const op = errors.Op("static_pool_boot")
+	for attempt := 0; ; attempt++ {
+		err := sp.bootAttempt()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= sp.cfg.BootRetries {
+			if attempt > 0 {
+				return errors.E(op, errors.WorkerAllocate, errors.Errorf("boot failed after %d attempts: %v", attempt+1, err))
+			}
+			return err
+		}
+
+		sp.events.Push(events.PoolEvent{Event: events.EventPoolBootRetry, Payload: attempt + 1, Error: err})
+
+		select {
+		case <-sp.clock.After(sp.cfg.BootRetryDelay):
+		case <-ctx.Done():
+			return errors.E(op, errors.TimeOut, errors.Errorf("boot canceled after %d attempts: %v", attempt+1, err))
+		}
+	}
+}
+
+// bootAttempt allocates the NumWorkers workers and watches them, nothing is left running on the failure
+func (sp *StaticPool) bootAttempt() error {
+	workers, err := sp.allocatePool(sp.cfg.NumWorkers)
+	if err != nil {
+		return err
+	}
+
+	err = sp.ww.Watch(workers)
+	if err != nil {
+		killWorkers(workers)
+		return err
+	}
+
+	return nil
+}
+
+// killWorkers kills the workers not watched yet and reaps the processes
+func killWorkers(workers []worker.BaseProcess) {
+	for i := 0; i < len(workers); i++ {
+		workers[i].State().Set(worker.StateDestroyed)
+		_ = workers[i].Kill()
+		_ = workers[i].Wait()
+	}
+}
@@ -0,0 +1,109 @@
+package pool
+
+import (
+	"context"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/utils"
+	"github.com/spiral/roadrunner/v2/worker"
+	workerWatcher "github.com/spiral/roadrunner/v2/worker_watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bootPool returns the pool allocating the sleeping workers, the allocations listed in the fail are failed
+func bootPool(t *testing.T, cfg *Config, fail ...int64) (*StaticPool, *[]worker.SyncWorker) {
+	var calls int64
+	var stopped int32
+	allocated := &[]worker.SyncWorker{}
+
+	sp := &StaticPool{cfg: cfg, events: events.NewEventsHandler(), clock: utils.SystemClock()}
+	sp.allocator = func() (worker.SyncWorker, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if atomic.LoadInt32(&stopped) == 1 {
+			return nil, errors.E(errors.Op("test_allocator"), errors.WatcherStopped, context.Canceled)
+		}
+		for i := 0; i < len(fail); i++ {
+			if fail[i] == n {
+				return nil, errors.Str("resource temporarily unavailable")
+			}
+		}
+
+		w, err := worker.InitBaseWorker(exec.Command("sleep", "10"))
+		require.NoError(t, err)
+		require.NoError(t, w.Start())
+		sw := worker.From(w)
+		*allocated = append(*allocated, sw)
+		return sw, nil
+	}
+	sp.ww = workerWatcher.NewSyncWorkerWatcher(sp.allocator, cfg.NumWorkers, sp.events, time.Second)
+
+	t.Cleanup(func() {
+		// watched workers are not replaced
+		atomic.StoreInt32(&stopped, 1)
+		for _, w := range sp.ww.List() {
+			w.State().Set(worker.StateDestroyed)
+			_ = w.Kill()
+		}
+	})
+
+	return sp, allocated
+}
+
+func Test_StaticPool_BootRetries(t *testing.T) {
+	retries := make(chan events.PoolEvent, 10)
+	sp, allocated := bootPool(t, &Config{NumWorkers: 2, BootRetries: 2, BootRetryDelay: time.Millisecond * 10}, 2, 4)
+	sp.addListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolBootRetry {
+			retries <- ev
+		}
+	})
+
+	require.NoError(t, sp.boot(context.Background()))
+	assert.Len(t, sp.ww.List(), 2)
+	require.Len(t, *allocated, 4)
+
+	// workers of the failed attempts are killed
+	for _, w := range (*allocated)[:2] {
+		assert.Equal(t, worker.StateDestroyed, w.State().Value())
+	}
+	for _, w := range (*allocated)[2:] {
+		assert.NotEqual(t, worker.StateDestroyed, w.State().Value())
+	}
+
+	require.Len(t, retries, 2)
+	ev := <-retries
+	assert.Equal(t, 1, ev.Payload)
+	assert.Contains(t, ev.Error.Error(), "resource temporarily unavailable")
+}
+
+func Test_StaticPool_BootRetriesExhausted(t *testing.T) {
+	sp, allocated := bootPool(t, &Config{NumWorkers: 2, BootRetries: 1, BootRetryDelay: time.Millisecond * 10}, 2, 3)
+
+	err := sp.boot(context.Background())
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.WorkerAllocate, err))
+	assert.Contains(t, err.Error(), "boot failed after 2 attempts")
+	assert.Len(t, sp.ww.List(), 0)
+	require.Len(t, *allocated, 1)
+	assert.Equal(t, worker.StateDestroyed, (*allocated)[0].State().Value())
+
+	// no retries by default
+	sp, _ = bootPool(t, &Config{NumWorkers: 1}, 1)
+	err = sp.boot(context.Background())
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "attempts")
+
+	// canceled during the delay
+	sp, _ = bootPool(t, &Config{NumWorkers: 1, BootRetries: 5, BootRetryDelay: time.Minute}, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err = sp.boot(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+}
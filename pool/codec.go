@@ -0,0 +1,62 @@
+package pool
+
+import (
+	j "github.com/json-iterator/go"
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// Codec serializes the typed Go values to the payload body and back, see ExecTyped.
+// Implement it to plug other wire formats (e.g. msgpack), JSONCodec is available out of the box.
+type Codec interface {
+	// Flag is the body encoding declared to the worker (payload.Codec* flags)
+	Flag() byte
+	// Marshal encodes the value to the payload body
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes the payload body into the value (pointer)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the JSON Codec
+type JSONCodec struct{}
+
+func (JSONCodec) Flag() byte {
+	return payload.CodecJSON
+}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return j.ConfigCompatibleWithStandardLibrary.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return j.ConfigCompatibleWithStandardLibrary.Unmarshal(data, v)
+}
+
+// ExecTyped encodes the in value with the codec, executes it on the pool and decodes the response body into
+// the out (pointer). The response should be encoded with the same codec (or not declare the codec at all).
+func ExecTyped(p Pool, codec Codec, in interface{}, out interface{}) error {
+	const op = errors.Op("pool_exec_typed")
+	body, err := codec.Marshal(in)
+	if err != nil {
+		return errors.E(op, errors.Encode, err)
+	}
+
+	rqs := &payload.Payload{Body: body}
+	rqs.SetCodec(codec.Flag())
+
+	rsp, err := p.Exec(rqs)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if rsp.Codec != 0 && rsp.Codec != codec.Flag() {
+		return errors.E(op, errors.Decode, errors.Errorf("response codec (%d) doesn't match the codec (%d)", rsp.Codec, codec.Flag()))
+	}
+
+	err = codec.Unmarshal(rsp.Body, out)
+	if err != nil {
+		return errors.E(op, errors.Decode, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package pool_test
+
+import (
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/pool/internal/testpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func Test_ExecTyped(t *testing.T) {
+	p := testpool.New(`{"name":"hello"}`, 1)
+
+	out := &greeting{}
+	require.NoError(t, pool.ExecTyped(p, pool.JSONCodec{}, &greeting{Name: "foo"}, out))
+	assert.Equal(t, "hello", out.Name)
+
+	// not a JSON response
+	p.Name = "hello"
+	err := pool.ExecTyped(p, pool.JSONCodec{}, &greeting{Name: "foo"}, out)
+	assert.True(t, errors.Is(errors.Decode, err))
+
+	// not encodable value
+	err = pool.ExecTyped(p, pool.JSONCodec{}, make(chan int), out)
+	assert.True(t, errors.Is(errors.Encode, err))
+
+	// pool errors are passed as is
+	p.Err = errors.E(errors.Op("test"), errors.NoFreeWorkers)
+	err = pool.ExecTyped(p, pool.JSONCodec{}, &greeting{Name: "foo"}, out)
+	assert.True(t, errors.Is(errors.NoFreeWorkers, err))
+}
+
+func Test_JSONCodec(t *testing.T) {
+	c := pool.JSONCodec{}
+	assert.Equal(t, payload.CodecJSON, c.Flag())
+
+	data, err := c.Marshal(&greeting{Name: "foo"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"foo"}`, string(data))
+
+	out := &greeting{}
+	require.NoError(t, c.Unmarshal(data, out))
+	assert.Equal(t, "foo", out.Name)
+}
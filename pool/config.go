@@ -3,20 +3,105 @@ package pool
 import (
 	"runtime"
 	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/utils"
 )
 
+// MaxJobsUnlimited is the MaxJobs value (default) disabling the recycling by the number of executions,
+// the worker handles as many tasks as it can. Not to be confused with 1 - new process for each task.
+const MaxJobsUnlimited uint64 = 0
+
 // Config .. Pool config Configures the pool behavior.
 type Config struct {
 	// Debug flag creates new fresh worker before every request.
 	Debug bool
 
+	// DebugKeepWorker reuses one persistent worker across the requests in the Debug mode, so the step debugger
+	// stays attached to a stable pid. The worker is recycled on Reset (OnConfigChange) or when it's no longer
+	// ready, requests are executed one at a time. Ignored w/o the Debug.
+	DebugKeepWorker bool `mapstructure:"debug_keep_worker"`
+
 	// NumWorkers defines how many sub-processes can be run at once. This value
 	// might be doubled by Swapper while hot-swap. Defaults to number of CPU cores.
 	NumWorkers uint64 `mapstructure:"num_workers"`
 
+	// MaxWorkers defines the upper limit of workers the pool is allowed to run at once,
+	// used by elastic features (eager allocation). Defaults to NumWorkers.
+	MaxWorkers uint64 `mapstructure:"max_workers"`
+
+	// EagerAllocate allocates a new worker on demand (up to the MaxWorkers) when there are no free workers
+	// in the container, instead of waiting for the busy worker to be released. Whichever arrives first is used.
+	EagerAllocate bool `mapstructure:"eager_allocate"`
+
+	// ContainerCapacity defines the capacity of the ready workers container. Should be greater or equal to the
+	// NumWorkers, and to the MaxWorkers when workers are allocated on demand (EagerAllocate), otherwise extra
+	// workers can't be pushed to the container. Defaults to MaxWorkers.
+	ContainerCapacity uint64 `mapstructure:"container_capacity"`
+
+	// StrictTake defines the behavior for the not ready workers found in the container on Take.
+	// true (default) - kill them, false - push them back and emit EventWorkerInconsistentState (diagnostic mode).
+	StrictTake *bool `mapstructure:"strict_take"`
+
+	// VerifyChecksums enables CRC32 checksums of the frame payload, computed on send and validated on the response.
+	// Mismatch is reported as a network error and the worker is recycled. Workers should support it: a worker which
+	// doesn't send the response checksum fails every request and is recycled each time.
+	VerifyChecksums bool `mapstructure:"verify_checksums"`
+
+	// VerifyCorrelationIDs enables the per-request correlation IDs, sent with the request and validated on the response
+	// to detect the relay desynchronization. Mismatch is reported as a network error and the worker is recycled.
+	// Workers should support it (echo the ID), same as the checksums.
+	VerifyCorrelationIDs bool `mapstructure:"verify_correlation_ids"`
+
+	// PreflightCheck spawns a single worker and round-trips the health check on it (WithHealthCheck) before
+	// allocating the rest of the workers, so the wrong command (binary, path, script) fails the Initialize with one
+	// clear error.
+	PreflightCheck bool `mapstructure:"preflight_check"`
+
+	// FastExec makes the Exec take the lean happy path: the ready worker is executed without the acquisition
+	// bookkeeping (ExecInfo, WaitingCallers), no free worker falls back to the full path. Ignored with the
+	// middleware (WithMiddleware), MaxJobs or Debug.
+	FastExec bool `mapstructure:"fast_exec"`
+
+	// CancelWorkerOnCtxDone aborts the execution when the caller ctx is canceled (e.g. the client disconnected): the
+	// worker is killed (the request can't be interrupted safely) and replaced, ctx.Err() is returned immediately.
+	// false (default) - the cancellation is ignored, the execution is completed and the response is returned, the
+	// ctx deadline (exec TTL) is applied in both cases.
+	CancelWorkerOnCtxDone bool `mapstructure:"cancel_worker_on_ctx_done"`
+
+	// TreatEmptyResponseAsError makes the Exec methods return the errors.SoftJob error for the responses with the
+	// empty body (except the StopRequest). false (default) - empty responses are returned as is.
+	TreatEmptyResponseAsError bool `mapstructure:"treat_empty_response_as_error"`
+
+	// ResponseReadTimeout bounds the gap between the reads of the worker response, independent of the exec TTL, so the
+	// worker stalled in the middle of the response is killed sooner (errors.ExecTTL). Armed on the first response
+	// byte, the long executions are bounded only by the exec TTL. Disabled when 0.
+	ResponseReadTimeout time.Duration `mapstructure:"response_read_timeout"`
+
+	// WorkerJournal defines the number of the last state transitions (from -> to, time and reason) kept per worker,
+	// see worker.State.Journal. The journal is included in the worker JSON. Disabled when 0.
+	WorkerJournal int `mapstructure:"worker_journal"`
+
+	// StderrBuffer defines the number of the last stderr bytes kept per worker (worker.BaseProcess.Stderr), the
+	// oldest output is dropped and the EventWorkerStderrOverflow is pushed once the buffer is full. Capped by the
+	// worker.MaxStderrBuffer. Disabled when 0.
+	StderrBuffer int `mapstructure:"stderr_buffer"`
+
+	// RedactEnv defines additional env keys (case-insensitive substrings) to redact in the worker Env audit,
+	// see worker.DefaultRedactedEnv.
+	RedactEnv []string `mapstructure:"redact_env"`
+
+	// ExecCacheSize defines how many responses can be cached by the ExecCached (LRU). Defaults to 1000.
+	ExecCacheSize uint64 `mapstructure:"exec_cache_size"`
+
+	// GracefulExitCodes defines the non-zero exit codes of the workers exiting by design (e.g. "recycle me"), such
+	// exits are recycled as the clean ones (EventWorkerDestruct) instead of the errors (EventWorkerError), and are not
+	// counted by the quarantine. Empty by default, every non-zero exit is the error.
+	GracefulExitCodes []int `mapstructure:"graceful_exit_codes"`
+
 	// MaxJobs defines how many executions is allowed for the worker until
-	// it's destruction. set 1 to create new process for each new task, 0 to let
-	// worker handle as many tasks as it can.
+	// it's destruction. set 1 to create new process for each new task, MaxJobsUnlimited (0, default)
+	// to let worker handle as many tasks as it can (never recycled by the number of executions).
 	MaxJobs uint64 `mapstructure:"max_jobs"`
 
 	// AllocateTimeout defines for how long pool will be waiting for a worker to
@@ -27,6 +112,51 @@ type Config struct {
 	// properly destroy, if timeout reached worker will be killed. Defaults to 60s.
 	DestroyTimeout time.Duration `mapstructure:"destroy_timeout"`
 
+	// PoolIdleTimeout enables the idle detection: EventPoolIdle is pushed when no worker has been taken during the
+	// timeout (low traffic), e.g. to scale the pool down. Disabled when 0.
+	PoolIdleTimeout time.Duration `mapstructure:"pool_idle_timeout"`
+
+	// ReapTimeout bounds the wait for the killed worker process to be reaped, so the recycle storms don't leave
+	// the zombie processes behind. Kill doesn't wait when 0.
+	ReapTimeout time.Duration `mapstructure:"reap_timeout"`
+
+	// ReadyTimeout bounds the wait for the spawned worker to reach the StateReady (e.g. hanging bootstrap). The worker
+	// which doesn't is killed and counted as the allocation failure (EventWorkerNotReady). Disabled when 0.
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+
+	// SpawnRate limits the workers (re)spawned by the watcher to the number per second, so the mass recycles and
+	// the crash loops don't spike the host load. The spawns over the rate are delayed. Unlimited when 0.
+	SpawnRate float64 `mapstructure:"spawn_rate"`
+	// SpawnBurst is the number of the workers spawned at once within the SpawnRate, 1 if 0.
+	SpawnBurst int `mapstructure:"spawn_burst"`
+	// MaxConcurrentSpawns limits the number of the workers (re)spawned by the watcher at the same time, the mass
+	// exits are replaced in waves. Unlimited when 0.
+	MaxConcurrentSpawns int `mapstructure:"max_concurrent_spawns"`
+
+	// BootRetries defines the number of the Initialize retries of the initial workers allocation (e.g. a transient
+	// resource shortage at the container startup), BootRetryDelay apart. The partially allocated workers are killed
+	// before the retry. Initialize fails on the first error when 0.
+	BootRetries int `mapstructure:"boot_retries"`
+	// BootRetryDelay is the delay between the boot retries.
+	BootRetryDelay time.Duration `mapstructure:"boot_retry_delay"`
+
+	// MaxPoolLifetime defines the uptime after which the whole pool is gracefully destroyed (EventPoolLifetimeReached),
+	// so the host restarts it fresh (e.g. cron-style runners). Unlimited when 0.
+	MaxPoolLifetime time.Duration `mapstructure:"max_pool_lifetime"`
+	// MaxTotalExecs defines the number of the executions across all the workers after which the whole pool is
+	// gracefully destroyed, same as the MaxPoolLifetime. Control payloads are not counted. Unlimited when 0.
+	MaxTotalExecs uint64 `mapstructure:"max_total_execs"`
+
+	// ThroughputWindow defines the window the pool Throughput is averaged over, rounded down to whole seconds
+	// (1s at least). Defaults to 10s.
+	ThroughputWindow time.Duration `mapstructure:"throughput_window"`
+
+	// RetryPolicy defines the retries of the failed idempotent payloads, nil - disabled.
+	RetryPolicy *RetryPolicy `mapstructure:"retry_policy"`
+
+	// Quarantine holds the worker replacements after the consecutive worker failures (crash loop), nil - disabled.
+	Quarantine *QuarantineConfig `mapstructure:"quarantine"`
+
 	// Supervision config to limit worker and pool memory usage.
 	Supervisor *SupervisorConfig `mapstructure:"supervisor"`
 }
@@ -37,6 +167,18 @@ func (cfg *Config) InitDefaults() {
 		cfg.NumWorkers = uint64(runtime.NumCPU())
 	}
 
+	if cfg.MaxWorkers < cfg.NumWorkers {
+		cfg.MaxWorkers = cfg.NumWorkers
+	}
+
+	if cfg.ContainerCapacity == 0 {
+		cfg.ContainerCapacity = cfg.MaxWorkers
+	}
+
+	if cfg.StrictTake == nil {
+		cfg.StrictTake = utils.Bool(true)
+	}
+
 	if cfg.AllocateTimeout == 0 {
 		cfg.AllocateTimeout = time.Minute
 	}
@@ -44,12 +186,178 @@ func (cfg *Config) InitDefaults() {
 	if cfg.DestroyTimeout == 0 {
 		cfg.DestroyTimeout = time.Minute
 	}
+
+	if cfg.ThroughputWindow == 0 {
+		cfg.ThroughputWindow = defaultThroughputWindow
+	}
+
+	if cfg.Quarantine != nil {
+		cfg.Quarantine.InitDefaults()
+	}
+
 	if cfg.Supervisor == nil {
 		return
 	}
 	cfg.Supervisor.InitDefaults()
 }
 
+// clone returns the deep copy of the config, so the pool config can't be modified by the caller
+func (cfg *Config) clone() *Config {
+	cp := *cfg
+	if cfg.StrictTake != nil {
+		strictTake := *cfg.StrictTake
+		cp.StrictTake = &strictTake
+	}
+	if cfg.RedactEnv != nil {
+		cp.RedactEnv = append([]string(nil), cfg.RedactEnv...)
+	}
+	if cfg.GracefulExitCodes != nil {
+		cp.GracefulExitCodes = append([]int(nil), cfg.GracefulExitCodes...)
+	}
+	if cfg.RetryPolicy != nil {
+		retryPolicy := *cfg.RetryPolicy
+		cp.RetryPolicy = &retryPolicy
+	}
+	if cfg.Quarantine != nil {
+		quarantine := *cfg.Quarantine
+		cp.Quarantine = &quarantine
+	}
+	if cfg.Supervisor != nil {
+		supervisor := *cfg.Supervisor
+		cp.Supervisor = &supervisor
+	}
+
+	return &cp
+}
+
+// Validate rejects the impossible config values combinations. Should be called on the user provided config
+// before the InitDefaults, zero values (except the NumWorkers) mean the defaults, e.g. max_jobs 0 is the
+// MaxJobsUnlimited (never recycle), not "recycle every job" (1).
+func (cfg *Config) Validate() error {
+	const op = errors.Op("pool_config_validate")
+	if cfg.NumWorkers == 0 && !cfg.Debug {
+		return errors.E(op, errors.Str("num_workers should be greater than 0 (or debug mode enabled)"))
+	}
+
+	if cfg.MaxWorkers != 0 && cfg.MaxWorkers < cfg.NumWorkers {
+		return errors.E(op, errors.Errorf("max_workers (%d) should be greater or equal to the num_workers (%d)", cfg.MaxWorkers, cfg.NumWorkers))
+	}
+
+	if cfg.ContainerCapacity != 0 && cfg.ContainerCapacity < cfg.NumWorkers {
+		return errors.E(op, errors.Errorf("container_capacity (%d) should be greater or equal to the num_workers (%d)", cfg.ContainerCapacity, cfg.NumWorkers))
+	}
+
+	if cfg.AllocateTimeout < 0 {
+		return errors.E(op, errors.Errorf("allocate_timeout (%s) should not be negative", cfg.AllocateTimeout))
+	}
+
+	if cfg.DestroyTimeout < 0 {
+		return errors.E(op, errors.Errorf("destroy_timeout (%s) should not be negative", cfg.DestroyTimeout))
+	}
+
+	if cfg.ResponseReadTimeout < 0 {
+		return errors.E(op, errors.Errorf("response_read_timeout (%s) should not be negative", cfg.ResponseReadTimeout))
+	}
+
+	if cfg.StderrBuffer < 0 {
+		return errors.E(op, errors.Errorf("stderr_buffer (%d) should not be negative", cfg.StderrBuffer))
+	}
+
+	if cfg.WorkerJournal < 0 {
+		return errors.E(op, errors.Errorf("worker_journal (%d) should not be negative", cfg.WorkerJournal))
+	}
+
+	if cfg.PoolIdleTimeout < 0 {
+		return errors.E(op, errors.Errorf("pool_idle_timeout (%s) should not be negative", cfg.PoolIdleTimeout))
+	}
+
+	if cfg.ReapTimeout < 0 {
+		return errors.E(op, errors.Errorf("reap_timeout (%s) should not be negative", cfg.ReapTimeout))
+	}
+
+	if cfg.ReadyTimeout < 0 {
+		return errors.E(op, errors.Errorf("ready_timeout (%s) should not be negative", cfg.ReadyTimeout))
+	}
+
+	if cfg.SpawnRate < 0 || cfg.SpawnBurst < 0 {
+		return errors.E(op, errors.Errorf("spawn_rate (%v) and spawn_burst (%d) should not be negative", cfg.SpawnRate, cfg.SpawnBurst))
+	}
+
+	if cfg.MaxConcurrentSpawns < 0 {
+		return errors.E(op, errors.Errorf("max_concurrent_spawns (%d) should not be negative", cfg.MaxConcurrentSpawns))
+	}
+
+	if cfg.BootRetries < 0 || cfg.BootRetryDelay < 0 {
+		return errors.E(op, errors.Errorf("boot_retries (%d) and boot_retry_delay (%s) should not be negative", cfg.BootRetries, cfg.BootRetryDelay))
+	}
+
+	if cfg.MaxPoolLifetime < 0 {
+		return errors.E(op, errors.Errorf("max_pool_lifetime (%s) should not be negative", cfg.MaxPoolLifetime))
+	}
+
+	for i := 0; i < len(cfg.GracefulExitCodes); i++ {
+		if cfg.GracefulExitCodes[i] < 1 || cfg.GracefulExitCodes[i] > 255 {
+			return errors.E(op, errors.Errorf("graceful_exit_codes (%d) should be in the 1-255 range", cfg.GracefulExitCodes[i]))
+		}
+	}
+
+	if cfg.ThroughputWindow < 0 {
+		return errors.E(op, errors.Errorf("throughput_window (%s) should not be negative", cfg.ThroughputWindow))
+	}
+
+	if cfg.Quarantine != nil {
+		err := cfg.Quarantine.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Supervisor == nil {
+		return nil
+	}
+
+	return cfg.Supervisor.Validate()
+}
+
+// QuarantineConfig configures the quarantine of the crash-looping workers. After the Failures consecutive
+// worker failures within the Window, exited workers are not reallocated during the Cooldown. Then a single worker
+// is probed before the full capacity is restored.
+type QuarantineConfig struct {
+	// Failures defines the number of consecutive worker failures (unexpected exits) to quarantine the replacements.
+	Failures uint64 `mapstructure:"failures"`
+
+	// Window defines the time frame of the consecutive failures. Defaults to 1 minute.
+	Window time.Duration `mapstructure:"window"`
+
+	// Cooldown defines for how long the replacements are held before the probe. Defaults to 30s.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// InitDefaults enables default config values.
+func (cfg *QuarantineConfig) InitDefaults() {
+	if cfg.Window == 0 {
+		cfg.Window = time.Minute
+	}
+
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = time.Second * 30
+	}
+}
+
+// Validate rejects the quarantine config w/o the failures threshold or with the negative durations.
+func (cfg *QuarantineConfig) Validate() error {
+	const op = errors.Op("quarantine_config_validate")
+	if cfg.Failures == 0 {
+		return errors.E(op, errors.Str("quarantine is enabled, but quarantine.failures is 0"))
+	}
+
+	if cfg.Window < 0 || cfg.Cooldown < 0 {
+		return errors.E(op, errors.Str("quarantine.window and quarantine.cooldown should not be negative"))
+	}
+
+	return nil
+}
+
 type SupervisorConfig struct {
 	// WatchTick defines how often to check the state of worker.
 	WatchTick time.Duration `mapstructure:"watch_tick"`
@@ -65,6 +373,14 @@ type SupervisorConfig struct {
 
 	// MaxWorkerMemory limits memory per worker.
 	MaxWorkerMemory uint64 `mapstructure:"max_worker_memory"`
+
+	// MaxRelaySilence defines maximum duration the working worker can spend w/o the relay activity (no frames
+	// sent or received), the hung worker is killed and replaced. Disabled when 0.
+	MaxRelaySilence time.Duration `mapstructure:"max_relay_silence"`
+
+	// MaxSuspend defines the safety max-duration of the supervisor suspension, after that
+	// the supervision is automatically resumed. Defaults to 10 minutes.
+	MaxSuspend time.Duration `mapstructure:"max_suspend"`
 }
 
 // InitDefaults enables default config values.
@@ -72,4 +388,26 @@ func (cfg *SupervisorConfig) InitDefaults() {
 	if cfg.WatchTick == 0 {
 		cfg.WatchTick = time.Second
 	}
+
+	if cfg.MaxSuspend == 0 {
+		cfg.MaxSuspend = time.Minute * 10
+	}
+}
+
+// Validate rejects the supervisor config with nothing to watch or with the negative values.
+func (cfg *SupervisorConfig) Validate() error {
+	const op = errors.Op("supervisor_config_validate")
+	if cfg.WatchTick < 0 {
+		return errors.E(op, errors.Errorf("supervisor.watch_tick (%s) should not be negative", cfg.WatchTick))
+	}
+
+	if cfg.TTL < 0 || cfg.IdleTTL < 0 || cfg.ExecTTL < 0 || cfg.MaxSuspend < 0 || cfg.MaxRelaySilence < 0 {
+		return errors.E(op, errors.Str("supervisor.ttl, supervisor.idle_ttl, supervisor.exec_ttl, supervisor.max_suspend and supervisor.max_relay_silence should not be negative"))
+	}
+
+	if cfg.TTL == 0 && cfg.IdleTTL == 0 && cfg.ExecTTL == 0 && cfg.MaxWorkerMemory == 0 && cfg.MaxRelaySilence == 0 {
+		return errors.E(op, errors.Str("supervisor is enabled, but all the thresholds (ttl, idle_ttl, exec_ttl, max_worker_memory, max_relay_silence) are 0"))
+	}
+
+	return nil
 }
@@ -0,0 +1,231 @@
+package pool
+
+import (
+	"context"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/spiral/roadrunner/v2/transport/testtransport"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Config_Validate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{NumWorkers: 2}
+	}
+
+	assert.NoError(t, valid().Validate())
+
+	cfg := valid()
+	cfg.NumWorkers = 0
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "num_workers")
+
+	cfg.Debug = true
+	assert.NoError(t, cfg.Validate())
+
+	cfg = valid()
+	cfg.AllocateTimeout = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "allocate_timeout")
+
+	cfg = valid()
+	cfg.MaxWorkers = 1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max_workers")
+
+	cfg = valid()
+	cfg.ContainerCapacity = 1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "container_capacity")
+
+	cfg = valid()
+	cfg.Supervisor = &SupervisorConfig{}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "thresholds")
+
+	cfg.Supervisor.ExecTTL = time.Second
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Supervisor = &SupervisorConfig{MaxRelaySilence: -time.Second}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max_relay_silence")
+
+	// relay silence alone is the threshold
+	cfg.Supervisor.MaxRelaySilence = time.Second
+	assert.NoError(t, cfg.Validate())
+
+	cfg = valid()
+	cfg.PoolIdleTimeout = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "pool_idle_timeout")
+
+	cfg = valid()
+	cfg.ReapTimeout = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "reap_timeout")
+
+	cfg = valid()
+	cfg.ReadyTimeout = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ready_timeout")
+
+	cfg = valid()
+	cfg.SpawnRate = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "spawn_rate")
+
+	cfg = valid()
+	cfg.MaxConcurrentSpawns = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max_concurrent_spawns")
+
+	cfg = valid()
+	cfg.BootRetryDelay = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "boot_retry_delay")
+
+	cfg = valid()
+	cfg.ThroughputWindow = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "throughput_window")
+
+	cfg = valid()
+	cfg.GracefulExitCodes = []int{3, 256}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "graceful_exit_codes (256)")
+
+	cfg = valid()
+	cfg.StderrBuffer = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "stderr_buffer")
+
+	cfg = valid()
+	cfg.WorkerJournal = -1
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "worker_journal")
+
+	cfg = valid()
+	cfg.ResponseReadTimeout = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "response_read_timeout")
+
+	cfg = valid()
+	cfg.MaxPoolLifetime = -time.Second
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max_pool_lifetime")
+
+	cfg = valid()
+	cfg.Quarantine = &QuarantineConfig{Cooldown: time.Second}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "quarantine.failures")
+
+	cfg.Quarantine.Failures = 3
+	assert.NoError(t, cfg.Validate())
+
+	cfg.InitDefaults()
+	assert.Equal(t, time.Minute, cfg.Quarantine.Window)
+	assert.Equal(t, time.Second, cfg.Quarantine.Cooldown)
+}
+
+func Test_Config_ValidateInitialize(t *testing.T) {
+	// rejected before spawning the workers
+	_, err := Initialize(context.Background(), func() *exec.Cmd {
+		return exec.Command("php", "../tests/client.php", "echo", "pipes")
+	}, pipe.NewPipeFactory(), &Config{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "num_workers")
+}
+
+func Test_Config_Preflight(t *testing.T) {
+	var spawns int64
+	_, err := Initialize(context.Background(), func() *exec.Cmd {
+		atomic.AddInt64(&spawns, 1)
+		return exec.Command("/nonexistent/php", "../tests/client.php", "echo", "pipes")
+	}, pipe.NewPipeFactory(), &Config{NumWorkers: 4, PreflightCheck: true})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "preflight check failed")
+	// the rest of the workers are not spawned
+	assert.Equal(t, int64(1), atomic.LoadInt64(&spawns))
+}
+
+func Test_Config_PreflightRoundTrip(t *testing.T) {
+	var spawns int64
+	cmd := func() *exec.Cmd {
+		atomic.AddInt64(&spawns, 1)
+		return exec.Command("php", "worker.php")
+	}
+	cfg := func() *Config {
+		return &Config{NumWorkers: 2, AllocateTimeout: time.Second, DestroyTimeout: time.Second, PreflightCheck: true}
+	}
+
+	// introspected
+	p, err := Initialize(context.Background(), cmd, testtransport.NewFactory(testtransport.Config{}), cfg())
+	assert.NoError(t, err)
+	assert.Len(t, p.Workers(), 2)
+	p.Destroy(context.Background())
+
+	// the ping is not replied in time
+	atomic.StoreInt64(&spawns, 0)
+	_, err = Initialize(context.Background(), cmd, testtransport.NewFactory(testtransport.Config{Latency: time.Second}), cfg(),
+		WithHealthCheck(&payload.Payload{Body: []byte("ping")}, time.Millisecond*100, 0))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "preflight check failed, worker doesn't respond")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&spawns))
+}
+
+func Test_Config_Clone(t *testing.T) {
+	strictTake := true
+	cfg := &Config{
+		NumWorkers:        2,
+		StrictTake:        &strictTake,
+		RedactEnv:         []string{"TOKEN"},
+		GracefulExitCodes: []int{3},
+		RetryPolicy:       &RetryPolicy{MaxRetries: 1},
+		Quarantine:        &QuarantineConfig{Failures: 3},
+		Supervisor:        &SupervisorConfig{ExecTTL: time.Second},
+	}
+
+	sp := &StaticPool{cfg: cfg}
+	cp := sp.Config()
+	assert.Equal(t, cfg, cp)
+
+	// the copy doesn't share the pool config
+	*cp.StrictTake = false
+	cp.RedactEnv[0] = "KEY"
+	cp.GracefulExitCodes[0] = 4
+	cp.RetryPolicy.MaxRetries = 2
+	cp.Quarantine.Failures = 5
+	cp.Supervisor.ExecTTL = time.Minute
+	cp.NumWorkers = 4
+	assert.True(t, *cfg.StrictTake)
+	assert.Equal(t, "TOKEN", cfg.RedactEnv[0])
+	assert.Equal(t, 3, cfg.GracefulExitCodes[0])
+	assert.Equal(t, uint64(1), cfg.RetryPolicy.MaxRetries)
+	assert.Equal(t, uint64(3), cfg.Quarantine.Failures)
+	assert.Equal(t, time.Second, cfg.Supervisor.ExecTTL)
+	assert.Equal(t, uint64(2), cfg.NumWorkers)
+}
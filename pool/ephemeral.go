@@ -0,0 +1,111 @@
+package pool
+
+import (
+	"context"
+	"sync"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// AllocateEphemeral spawns the dedicated worker outside the watcher (e.g. for a one-off expensive admin task), it's
+// not counted in the NumWorkers, doesn't take the requests and is not supervised. The returned release func destroys
+// the worker, it's safe to call it more than once and should be deferred, so the worker is destroyed even if the
+// caller panics. Workers not released are killed on Destroy.
+func (sp *StaticPool) AllocateEphemeral(ctx context.Context) (worker.SyncWorker, func(), error) {
+	const op = errors.Op("static_pool_allocate_ephemeral")
+	if sp.destroyed() {
+		return nil, nil, errors.E(op, errors.WatcherStopped, ErrPoolDraining)
+	}
+
+	type allocated struct {
+		sw  worker.SyncWorker
+		err error
+	}
+
+	// the allocator doesn't accept the ctx, the late worker is destroyed once allocated
+	ch := make(chan allocated, 1)
+	go func() {
+		sw, err := sp.allocator()
+		ch <- allocated{sw, err}
+	}()
+
+	var res allocated
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		go func() {
+			if late := <-ch; late.err == nil {
+				destroyEphemeral(late.sw)
+			}
+		}()
+		return nil, nil, errors.E(op, errors.TimeOut, ctx.Err())
+	}
+
+	if res.err != nil {
+		if errors.Is(errors.WatcherStopped, res.err) {
+			return nil, nil, errors.E(op, errors.WatcherStopped, ErrPoolDraining)
+		}
+		return nil, nil, errors.E(op, errors.WorkerAllocate, res.err)
+	}
+
+	sw := res.sw
+	sp.ephemeralMu.Lock()
+	// destroyed during the allocation
+	if sp.destroyed() {
+		sp.ephemeralMu.Unlock()
+		destroyEphemeral(sw)
+		return nil, nil, errors.E(op, errors.WatcherStopped, ErrPoolDraining)
+	}
+	if sp.ephemeral == nil {
+		sp.ephemeral = make(map[worker.SyncWorker]struct{})
+	}
+	sp.ephemeral[sw] = struct{}{}
+	sp.ephemeralMu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			sp.ephemeralMu.Lock()
+			_, ok := sp.ephemeral[sw]
+			delete(sp.ephemeral, sw)
+			sp.ephemeralMu.Unlock()
+
+			// already killed by the Destroy
+			if ok {
+				destroyEphemeral(sw)
+			}
+		})
+	}
+
+	return sw, release, nil
+}
+
+// destroyEphemerals kills the ephemeral workers not released yet, called on Destroy
+func (sp *StaticPool) destroyEphemerals() {
+	sp.ephemeralMu.Lock()
+	workers := sp.ephemeral
+	sp.ephemeral = nil
+	sp.ephemeralMu.Unlock()
+
+	for sw := range workers {
+		destroyEphemeral(sw)
+	}
+}
+
+// destroyed reports whether the Destroy is called
+func (sp *StaticPool) destroyed() bool {
+	select {
+	case <-sp.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// destroyEphemeral kills the worker (even in the middle of the request) and reaps the process
+func destroyEphemeral(sw worker.SyncWorker) {
+	sw.State().Set(worker.StateDestroyed)
+	_ = sw.Kill()
+	_ = sw.Wait()
+}
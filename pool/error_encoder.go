@@ -0,0 +1,50 @@
+package pool
+
+import (
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// KindEncoder handles the Exec errors of the kind it's registered for (see WithKindEncoder), next is the rest of the
+// chain: the encoders registered later and the default encoder. The encoder owns the worker, it should either call
+// the next (the default encoder releases, recycles or kills the worker) or manage the worker itself.
+type KindEncoder func(err error, w worker.BaseProcess, next ErrorEncoder) (*payload.Payload, error)
+
+type kindEncoder struct {
+	kind    errors.Kind
+	encoder KindEncoder
+}
+
+// WithKindEncoder registers the encoder of the errors of the kind (errors.Is), consulted before the default encoder
+// in the registration order, so the new error kinds can be handled without replacing the whole encoder. Ignored
+// with the WithErrorEncoder.
+func WithKindEncoder(kind errors.Kind, encoder KindEncoder) Options {
+	return func(p *StaticPool) {
+		p.kindEncoders = append(p.kindEncoders, kindEncoder{kind: kind, encoder: encoder})
+	}
+}
+
+// WithErrorEncoder replaces the whole Exec error encoder, both the default one and the WithKindEncoder chain
+func WithErrorEncoder(encoder ErrorEncoder) Options {
+	return func(p *StaticPool) {
+		p.errEncoder = encoder
+	}
+}
+
+// chainEncoders wraps the default encoder with the kind encoders, the first registered is consulted first
+func chainEncoders(encoders []kindEncoder, def ErrorEncoder) ErrorEncoder {
+	next := def
+	for i := len(encoders) - 1; i >= 0; i-- {
+		ke := encoders[i]
+		rest := next
+		next = func(err error, w worker.BaseProcess) (*payload.Payload, error) {
+			if errors.Is(ke.kind, err) {
+				return ke.encoder(err, w, rest)
+			}
+			return rest(err, w)
+		}
+	}
+
+	return next
+}
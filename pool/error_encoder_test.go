@@ -0,0 +1,54 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ChainEncoders(t *testing.T) {
+	var calls []string
+	def := func(err error, _ worker.BaseProcess) (*payload.Payload, error) {
+		calls = append(calls, "default")
+		return nil, err
+	}
+
+	sp := &StaticPool{}
+	WithKindEncoder(errors.SoftJob, func(err error, w worker.BaseProcess, next ErrorEncoder) (*payload.Payload, error) {
+		calls = append(calls, "soft_job")
+		_, err = next(err, w)
+		return &payload.Payload{Body: []byte("details")}, err
+	})(sp)
+	WithKindEncoder(errors.Network, func(err error, _ worker.BaseProcess, _ ErrorEncoder) (*payload.Payload, error) {
+		calls = append(calls, "network")
+		return nil, err
+	})(sp)
+	WithKindEncoder(errors.SoftJob, func(err error, w worker.BaseProcess, next ErrorEncoder) (*payload.Payload, error) {
+		calls = append(calls, "soft_job_2")
+		return next(err, w)
+	})(sp)
+
+	enc := chainEncoders(sp.kindEncoders, def)
+
+	// matching encoders in the registration order, falls through to the default
+	rsp, err := enc(errors.E(errors.Op("test"), errors.SoftJob, errors.Str("failed")), nil)
+	require.Error(t, err)
+	assert.Equal(t, "details", rsp.String())
+	assert.Equal(t, []string{"soft_job", "soft_job_2", "default"}, calls)
+
+	// the encoder might not call the next
+	calls = nil
+	_, err = enc(errors.E(errors.Op("test"), errors.Network, errors.Str("broken pipe")), nil)
+	require.Error(t, err)
+	assert.Equal(t, []string{"network"}, calls)
+
+	// not registered kind
+	calls = nil
+	_, err = enc(errors.E(errors.Op("test"), errors.ExecTTL), nil)
+	require.Error(t, err)
+	assert.Equal(t, []string{"default"}, calls)
+}
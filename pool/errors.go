@@ -0,0 +1,56 @@
+package pool
+
+import (
+	stderr "errors"
+
+	"github.com/spiral/errors"
+	"go.uber.org/multierr"
+)
+
+// maxStopRequests limits the consecutive StopRequest responses for a single request, ErrStopLoop is returned after
+const maxStopRequests = 10
+
+// Sentinel errors of the pool. Errors are wrapped with errors.E (the op traces remain), use IsErr to match them.
+var (
+	// ErrNoFactory - Initialize is called w/o the transport factory
+	ErrNoFactory = errors.Str("no factory initialized")
+	// ErrStopLoop - workers keep responding with the StopRequest, the request is not executed
+	ErrStopLoop = errors.Str("too many consecutive stop requests")
+	// ErrPoolDraining - the pool is destroyed, new requests are rejected (errors.WatcherStopped kind)
+	ErrPoolDraining = errors.Str("pool is draining")
+	// ErrOverloaded - no free workers during the allocate timeout, or immediately for the TryExec (errors.NoFreeWorkers kind)
+	ErrOverloaded = errors.Str("no free workers, pool is overloaded")
+	// ErrPoolInitializing - the request is executed before the Initialize has allocated and watched the workers
+	ErrPoolInitializing = errors.Str("pool initializing")
+	// ErrStandby - the request is executed on the standby pool which is not activated yet
+	ErrStandby = errors.Str("standby")
+	// ErrRequestCanceled - the in-flight request is canceled by the CancelAll
+	ErrRequestCanceled = errors.Str("request canceled")
+)
+
+// IsErr reports whether the err (or any error it wraps via errors.E) is the target sentinel error
+func IsErr(err, target error) bool {
+	for err != nil {
+		if err == target {
+			return true
+		}
+
+		e, ok := err.(*errors.Error)
+		if !ok {
+			break
+		}
+		err = e.Err
+	}
+
+	// combined errors (e.g. DumpAllWorkers)
+	if errs := multierr.Errors(err); len(errs) > 1 {
+		for i := 0; i < len(errs); i++ {
+			if IsErr(errs[i], target) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return stderr.Is(err, target)
+}
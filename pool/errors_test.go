@@ -0,0 +1,31 @@
+package pool
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+)
+
+func Test_IsErr(t *testing.T) {
+	err := errors.E(errors.Op("outer"), errors.E(errors.Op("inner"), errors.NoFreeWorkers, ErrOverloaded))
+	assert.True(t, IsErr(err, ErrOverloaded))
+	// kind is kept
+	assert.True(t, errors.Is(errors.NoFreeWorkers, err))
+	assert.False(t, IsErr(err, ErrPoolDraining))
+
+	assert.True(t, IsErr(multierr.Append(errors.Str("foo"), errors.E(errors.Op("op"), ErrStopLoop)), ErrStopLoop))
+	assert.False(t, IsErr(nil, ErrStopLoop))
+	// same message, not the sentinel
+	assert.False(t, IsErr(errors.Str("request canceled"), ErrRequestCanceled))
+}
+
+func Test_InitializeNoFactory(t *testing.T) {
+	_, err := Initialize(context.Background(), func() *exec.Cmd {
+		return exec.Command("php", "../tests/client.php", "echo", "pipes")
+	}, nil, &Config{NumWorkers: 1})
+	assert.True(t, IsErr(err, ErrNoFactory))
+}
@@ -0,0 +1,200 @@
+package pool
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// defaultExecCacheSize is the default number of cached responses
+const defaultExecCacheSize uint64 = 1000
+
+type cacheEntry struct {
+	key      string
+	rsp      *payload.Payload
+	deadline time.Time
+}
+
+// keyGen is the invalidation generation of the key being executed, refs is the number of the execs in progress
+type keyGen struct {
+	gen  uint64
+	refs int
+}
+
+// execCache is the in-memory LRU cache of the worker responses used by the ExecCached
+type execCache struct {
+	mu    sync.Mutex
+	size  uint64
+	ll    *list.List
+	items map[string]*list.Element
+	// generations of the keys with the execs in progress, bumped by the invalidate, so the response of the exec
+	// started before the invalidation is not cached
+	gens map[string]*keyGen
+
+	hits   uint64
+	misses uint64
+}
+
+func newExecCache(size uint64) *execCache {
+	if size == 0 {
+		size = defaultExecCacheSize
+	}
+
+	return &execCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+		gens:  make(map[string]*keyGen),
+	}
+}
+
+// payloadKey hashes the payload Codec, Body and Context
+func payloadKey(p *payload.Payload) string {
+	h := sha256.New()
+	// the same body in a different encoding is a different request
+	_, _ = h.Write([]byte{p.GetCodec()})
+	_, _ = h.Write(p.Context)
+	// separator, to distinguish {ctx: "ab", body: "c"} from {ctx: "a", body: "bc"}
+	var l [8]byte
+	binary.LittleEndian.PutUint64(l[:], uint64(len(p.Context)))
+	_, _ = h.Write(l[:])
+	_, _ = h.Write(p.Body)
+	// prefixed, so the hashes never collide with the caller keys
+	return "h" + string(h.Sum(nil))
+}
+
+// callerKey is the cache key of the ExecCachedKey, the caller provided key is used as is
+func callerKey(key string) string {
+	return "k" + key
+}
+
+func (c *execCache) get(key string) (*payload.Payload, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.deadline) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	atomic.AddUint64(&c.hits, 1)
+	return copyPayload(entry.rsp), true
+}
+
+// begin registers the exec of the key, returns the generation to pass to the put
+func (c *execCache) begin(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.gens[key]
+	if !ok {
+		g = &keyGen{}
+		c.gens[key] = g
+	}
+	g.refs++
+	return g.gen
+}
+
+// end unregisters the exec of the key, returns false if the key is invalidated since the begin with the gen.
+// Should be called under the lock.
+func (c *execCache) end(key string, gen uint64) bool {
+	g := c.gens[key]
+	g.refs--
+	if g.refs == 0 {
+		delete(c.gens, key)
+	}
+
+	return g.gen == gen
+}
+
+// put caches the response of the exec registered by the begin, the response is dropped if the key is invalidated
+// meanwhile. Nil rsp (failed exec) only unregisters it.
+func (c *execCache) put(key string, gen uint64, rsp *payload.Payload, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.end(key, gen) || rsp == nil {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*cacheEntry)
+		entry.rsp = copyPayload(rsp)
+		entry.deadline = time.Now().Add(ttl)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, rsp: copyPayload(rsp), deadline: time.Now().Add(ttl)})
+
+	// evict the least recently used
+	for uint64(c.ll.Len()) > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops the cached response
+func (c *execCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+
+	// the execs in progress are not cached
+	if g, ok := c.gens[key]; ok {
+		g.gen++
+	}
+}
+
+// execCached returns the cached response or executes the payload and caches a successful response, the response
+// of the failed exec is returned with the error unchanged
+func (c *execCache) execCached(key string, ttl time.Duration, p *payload.Payload, exec func(*payload.Payload) (*payload.Payload, error)) (*payload.Payload, error) {
+	if rsp, ok := c.get(key); ok {
+		return rsp, nil
+	}
+
+	gen := c.begin(key)
+	rsp, err := exec(p)
+	if err != nil {
+		c.put(key, gen, nil, ttl)
+		// not cached, but the error details sent by the worker (errors.SoftJob) are returned as is
+		return rsp, err
+	}
+
+	c.put(key, gen, rsp, ttl)
+	return rsp, nil
+}
+
+// copyPayload is used to not share the cached payload with the callers
+func copyPayload(p *payload.Payload) *payload.Payload {
+	cp := &payload.Payload{Codec: p.Codec}
+	if p.Body != nil {
+		cp.Body = make([]byte, len(p.Body))
+		copy(cp.Body, p.Body)
+	}
+	if p.Context != nil {
+		cp.Context = make([]byte, len(p.Context))
+		copy(cp.Context, p.Context)
+	}
+	return cp
+}
@@ -0,0 +1,184 @@
+package pool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExecCache_HitMiss(t *testing.T) {
+	c := newExecCache(10)
+	calls := 0
+	exec := func(p *payload.Payload) (*payload.Payload, error) {
+		calls++
+		return &payload.Payload{Body: []byte("hello")}, nil
+	}
+
+	p := &payload.Payload{Body: []byte("hello"), Context: []byte("ctx")}
+
+	rsp, err := c.execCached(payloadKey(p), time.Minute, p, exec)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), rsp.Body)
+
+	// mutation of the returned payload should not affect the cache
+	rsp.Body[0] = 'x'
+
+	rsp, err = c.execCached(payloadKey(p), time.Minute, p, exec)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), rsp.Body)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, uint64(1), c.hits)
+	assert.Equal(t, uint64(1), c.misses)
+
+	// same bytes, but different split between the context and the body
+	p2 := &payload.Payload{Body: []byte("xhello"), Context: []byte("ct")}
+	assert.NotEqual(t, payloadKey(p), payloadKey(p2))
+
+	// same bytes, but different codec
+	p3 := &payload.Payload{Body: []byte("hello"), Context: []byte("ctx")}
+	p3.SetCodec(payload.CodecJSON)
+	assert.NotEqual(t, payloadKey(p), payloadKey(p3))
+	// not declared is raw
+	p3.SetCodec(payload.CodecRaw)
+	assert.Equal(t, payloadKey(p), payloadKey(p3))
+}
+
+func Test_ExecCache_TTL(t *testing.T) {
+	c := newExecCache(10)
+	calls := 0
+	exec := func(p *payload.Payload) (*payload.Payload, error) {
+		calls++
+		return &payload.Payload{Body: p.Body}, nil
+	}
+
+	p := &payload.Payload{Body: []byte("hello")}
+	_, err := c.execCached(payloadKey(p), time.Millisecond, p, exec)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 5)
+
+	_, err = c.execCached(payloadKey(p), time.Millisecond, p, exec)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, uint64(2), c.misses)
+}
+
+func Test_ExecCache_Evict(t *testing.T) {
+	c := newExecCache(2)
+	exec := func(p *payload.Payload) (*payload.Payload, error) {
+		return &payload.Payload{Body: p.Body}, nil
+	}
+
+	p1 := &payload.Payload{Body: []byte("1")}
+	p2 := &payload.Payload{Body: []byte("2")}
+	p3 := &payload.Payload{Body: []byte("3")}
+
+	for _, p := range []*payload.Payload{p1, p2, p1, p3} {
+		_, err := c.execCached(payloadKey(p), time.Minute, p, exec)
+		require.NoError(t, err)
+	}
+
+	// p2 is the least recently used
+	_, ok := c.get(payloadKey(p2))
+	assert.False(t, ok)
+	_, ok = c.get(payloadKey(p1))
+	assert.True(t, ok)
+	_, ok = c.get(payloadKey(p3))
+	assert.True(t, ok)
+}
+
+func Test_ExecCache_NoCacheOnError(t *testing.T) {
+	c := newExecCache(2)
+	calls := 0
+	exec := func(p *payload.Payload) (*payload.Payload, error) {
+		calls++
+		// the error details (soft job payload)
+		return &payload.Payload{Body: []byte("details")}, errors.New("failed")
+	}
+
+	p := &payload.Payload{Body: []byte("1")}
+	for i := 0; i < 2; i++ {
+		rsp, err := c.execCached(payloadKey(p), time.Minute, p, exec)
+		assert.Error(t, err)
+		require.NotNil(t, rsp)
+		assert.Equal(t, "details", rsp.String())
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func Test_ExecCache_InvalidateDuringExec(t *testing.T) {
+	c := newExecCache(10)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	exec := func(p *payload.Payload) (*payload.Payload, error) {
+		if string(p.Body) == "stale" {
+			close(started)
+			<-release
+		}
+		return &payload.Payload{Body: p.Body}, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rsp, err := c.execCached(callerKey("1"), time.Minute, &payload.Payload{Body: []byte("stale")}, exec)
+		assert.NoError(t, err)
+		assert.Equal(t, "stale", rsp.String())
+	}()
+
+	<-started
+	// the write happens while the read is in progress
+	c.invalidate(callerKey("1"))
+	close(release)
+	<-done
+
+	// the stale response is not cached
+	rsp, err := c.execCached(callerKey("1"), time.Minute, &payload.Payload{Body: []byte("fresh")}, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", rsp.String())
+	assert.Empty(t, c.gens)
+
+	rsp, err = c.execCached(callerKey("1"), time.Minute, &payload.Payload{Body: []byte("other")}, exec)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", rsp.String())
+}
+
+func Test_ExecCache_CallerKey(t *testing.T) {
+	c := newExecCache(10)
+	calls := 0
+	exec := func(p *payload.Payload) (*payload.Payload, error) {
+		calls++
+		return &payload.Payload{Body: p.Body}, nil
+	}
+
+	// differently serialized, but equivalent payloads
+	p1 := &payload.Payload{Body: []byte(`{"tenant":1,"id":2}`)}
+	p2 := &payload.Payload{Body: []byte(`{"id":2,"tenant":1}`)}
+
+	rsp, err := c.execCached(callerKey("1:2"), time.Minute, p1, exec)
+	require.NoError(t, err)
+	assert.Equal(t, p1.Body, rsp.Body)
+
+	rsp, err = c.execCached(callerKey("1:2"), time.Minute, p2, exec)
+	require.NoError(t, err)
+	assert.Equal(t, p1.Body, rsp.Body)
+	assert.Equal(t, 1, calls)
+
+	// caller keys don't share the entries with the payload hashes
+	_, ok := c.get(payloadKey(p1))
+	assert.False(t, ok)
+
+	c.invalidate(callerKey("1:2"))
+	rsp, err = c.execCached(callerKey("1:2"), time.Minute, p2, exec)
+	require.NoError(t, err)
+	assert.Equal(t, p2.Body, rsp.Body)
+	assert.Equal(t, 2, calls)
+
+	// unknown key
+	c.invalidate(callerKey("3:4"))
+}
@@ -0,0 +1,41 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/utils"
+)
+
+// SupervisorWrapper exports the supervisorWrapper for the external tests
+func SupervisorWrapper(p Pool, cfg *SupervisorConfig) Supervised {
+	return supervisorWrapper(p, events.NewEventsHandler(), cfg, newExecCache(0), nil, utils.SystemClock())
+}
+
+// DeadlinePool records the ctx deadline of the tryExecWithTTL calls, which can't be implemented outside the package
+type DeadlinePool struct {
+	Pool
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func NewDeadlinePool(p Pool) *DeadlinePool {
+	return &DeadlinePool{Pool: p}
+}
+
+// Deadline returns the deadline of the last tryExecWithTTL call
+func (dp *DeadlinePool) Deadline() time.Time {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return dp.deadline
+}
+
+func (dp *DeadlinePool) tryExecWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error) {
+	dp.mu.Lock()
+	dp.deadline, _ = ctx.Deadline()
+	dp.mu.Unlock()
+	return dp.Pool.TryExec(rqs)
+}
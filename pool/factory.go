@@ -0,0 +1,98 @@
+package pool
+
+import (
+	"context"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/transport"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// migratePoll is the interval of the MigrateWorkers checks for the old workers finishing the requests
+const migratePoll = time.Millisecond * 10
+
+// factoryKey is the worker attachment key of the generation of the factory which spawned the worker
+type factoryKey struct{}
+
+// SetFactory replaces the transport factory (e.g. pipes -> sockets), the workers allocated from now on use the new
+// factory, the existing workers keep their transport until they are replaced (see MigrateWorkers). The previous
+// factory is not closed, it still serves the old workers and should be closed by the caller after the migration.
+func (sp *StaticPool) SetFactory(factory transport.Factory) error {
+	const op = errors.Op("static_pool_set_factory")
+	if factory == nil {
+		return errors.E(op, ErrNoFactory)
+	}
+
+	sp.factoryMu.Lock()
+	sp.factory = factory
+	sp.factoryGen++
+	sp.factoryMu.Unlock()
+
+	return nil
+}
+
+// MigrateWorkers replaces (warm replacement, one by one) the workers spawned by the factories other than the current
+// one (see SetFactory), so the pool capacity never dips. Workers in the middle of the request are replaced after the
+// request is completed, MigrateWorkers returns once all the workers use the current factory or the ctx is done. The
+// progress is observable via the workers Transport.
+func (sp *StaticPool) MigrateWorkers(ctx context.Context) error {
+	const op = errors.Op("static_pool_migrate_workers")
+	for {
+		stale := sp.staleWorkers()
+		if len(stale) == 0 {
+			return nil
+		}
+
+		for i := 0; i < len(stale); i++ {
+			if ctx.Err() != nil {
+				return errors.E(op, errors.TimeOut, ctx.Err())
+			}
+
+			// already replaced workers are skipped by the watcher
+			err := sp.ww.Replace(stale[i])
+			if err != nil {
+				return errors.E(op, err)
+			}
+		}
+
+		// replaced working workers are listed until the request is completed
+		select {
+		case <-ctx.Done():
+			return errors.E(op, errors.TimeOut, ctx.Err())
+		case <-time.After(migratePoll):
+		}
+	}
+}
+
+// currentFactory returns the factory used for the new workers and its generation
+func (sp *StaticPool) currentFactory() (transport.Factory, uint64) {
+	sp.factoryMu.RLock()
+	defer sp.factoryMu.RUnlock()
+	return sp.factory, sp.factoryGen
+}
+
+// staleWorkers returns the workers spawned by the factories other than the current one
+func (sp *StaticPool) staleWorkers() []worker.BaseProcess {
+	_, gen := sp.currentFactory()
+	workers := sp.ww.List()
+	stale := make([]worker.BaseProcess, 0, len(workers))
+	for i := 0; i < len(workers); i++ {
+		g, ok := workers[i].Attachment(factoryKey{})
+		if ok && g.(uint64) == gen {
+			continue
+		}
+		stale = append(stale, workers[i])
+	}
+
+	return stale
+}
+
+// transportName returns the name of the factory transport, empty if the factory doesn't report it (transport.Named)
+func transportName(factory transport.Factory) string {
+	if n, ok := factory.(transport.Named); ok {
+		return n.Name()
+	}
+
+	return ""
+}
@@ -0,0 +1,77 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/spiral/roadrunner/v2/transport/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TransportName(t *testing.T) {
+	assert.Equal(t, "pipes", transportName(pipe.NewPipeFactory()))
+	assert.Equal(t, "", transportName(notReadyFactory{}))
+
+	sp := &StaticPool{}
+	err := sp.SetFactory(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrNoFactory.Error())
+}
+
+func Test_StaticPool_MigrateWorkers(t *testing.T) {
+	ls, err := net.Listen("tcp", "127.0.0.1:9011")
+	require.NoError(t, err)
+	defer func() { _ = ls.Close() }()
+
+	// the command is switched along with the factory
+	var sockets uint32
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd {
+			if atomic.LoadUint32(&sockets) == 1 {
+				return exec.Command("php", "../tests/client.php", "echo", "tcp")
+			}
+			return exec.Command("php", "../tests/client.php", "echo", "pipes")
+		},
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second * 5,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	for _, w := range p.Workers() {
+		assert.Equal(t, "pipes", w.Transport())
+	}
+
+	atomic.StoreUint32(&sockets, 1)
+	require.NoError(t, p.SetFactory(socket.NewSocketServer(ls, time.Minute)))
+	// existing workers are not affected
+	for _, w := range p.Workers() {
+		assert.Equal(t, "pipes", w.Transport())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	require.NoError(t, p.MigrateWorkers(ctx))
+
+	workers := p.Workers()
+	require.Len(t, workers, 2)
+	for _, w := range workers {
+		assert.Equal(t, "sockets", w.Transport())
+	}
+
+	rsp, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", rsp.String())
+}
@@ -0,0 +1,174 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// FallbackCondition decides whether the request failed on the primary pool should be routed to the secondary pool
+type FallbackCondition func(err error) bool
+
+// FallbackOnNoFreeWorkers is the default fallback condition, the primary pool is saturated
+func FallbackOnNoFreeWorkers(err error) bool {
+	return errors.Is(errors.NoFreeWorkers, err)
+}
+
+type FallbackOptions func(fp *FallbackPool)
+
+// WithFallbackCondition overrides the default FallbackOnNoFreeWorkers condition
+func WithFallbackCondition(cond FallbackCondition) FallbackOptions {
+	return func(fp *FallbackPool) {
+		fp.condition = cond
+	}
+}
+
+// WithFallbackEvents sets the events handler used to push the EventPoolFallback
+func WithFallbackEvents(eh events.Handler) FallbackOptions {
+	return func(fp *FallbackPool) {
+		fp.events = eh
+	}
+}
+
+// FallbackPool routes the overflow traffic to the secondary pool. Requests are executed on the primary pool only
+// if it has a free worker (TryExec), otherwise (see FallbackCondition) they're executed on the secondary pool.
+// All other methods (workers, counters, etc.) are related to the primary pool, Destroy destroys both pools.
+// ExecWeighted and ExecWithAllocateTimeout are executed on the primary pool only.
+// ExecCached and ExecCachedKey use the own cache (responses of both pools), Invalidate, CacheHits and CacheMisses
+// are related to it. WaitIdle waits for both pools.
+type FallbackPool struct {
+	Pool
+	secondary Pool
+	condition FallbackCondition
+	events    events.Handler
+	cache     *execCache
+}
+
+// NewFallbackPool creates the pool routing the overflow traffic from the primary to the secondary pool
+func NewFallbackPool(primary, secondary Pool, options ...FallbackOptions) *FallbackPool {
+	fp := &FallbackPool{
+		Pool:      primary,
+		secondary: secondary,
+		condition: FallbackOnNoFreeWorkers,
+		events:    events.NewEventsHandler(),
+		cache:     newExecCache(0),
+	}
+
+	for i := 0; i < len(options); i++ {
+		options[i](fp)
+	}
+
+	return fp
+}
+
+func (fp *FallbackPool) Exec(rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("fallback_pool_exec")
+	rsp, err := fp.Pool.TryExec(rqs)
+	if err == nil || !fp.condition(err) {
+		return rsp, err
+	}
+
+	fp.fallback(op, err)
+	return fp.secondary.Exec(rqs)
+}
+
+func (fp *FallbackPool) TryExec(rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("fallback_pool_try_exec")
+	rsp, err := fp.Pool.TryExec(rqs)
+	if err == nil || !fp.condition(err) {
+		return rsp, err
+	}
+
+	fp.fallback(op, err)
+	return fp.secondary.TryExec(rqs)
+}
+
+func (fp *FallbackPool) ExecDeadline(deadline time.Time, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("fallback_pool_exec_deadline")
+	if !time.Now().Before(deadline) {
+		return nil, errors.E(op, errors.ExecTTL)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	rsp, err := fp.Pool.tryExecWithTTL(ctx, rqs)
+	if err == nil || !fp.condition(err) {
+		return rsp, err
+	}
+
+	fp.fallback(op, err)
+	return fp.secondary.ExecDeadline(deadline, rqs)
+}
+
+func (fp *FallbackPool) ExecCached(rqs *payload.Payload, ttl time.Duration) (*payload.Payload, error) {
+	return fp.cache.execCached(payloadKey(rqs), ttl, rqs, fp.Exec)
+}
+
+func (fp *FallbackPool) ExecCachedKey(key string, ttl time.Duration, rqs *payload.Payload) (*payload.Payload, error) {
+	return fp.cache.execCached(callerKey(key), ttl, rqs, fp.Exec)
+}
+
+func (fp *FallbackPool) Invalidate(key string) {
+	fp.cache.invalidate(callerKey(key))
+}
+
+func (fp *FallbackPool) CacheHits() uint64 {
+	return atomic.LoadUint64(&fp.cache.hits)
+}
+
+func (fp *FallbackPool) CacheMisses() uint64 {
+	return atomic.LoadUint64(&fp.cache.misses)
+}
+
+// CancelAll cancels the in-flight requests of both pools
+func (fp *FallbackPool) CancelAll() {
+	fp.Pool.CancelAll()
+	fp.secondary.CancelAll()
+}
+
+// Destroy destroys both pools
+func (fp *FallbackPool) Destroy(ctx context.Context) {
+	fp.Pool.Destroy(ctx)
+	fp.secondary.Destroy(ctx)
+}
+
+// WaitIdle waits for the primary and then for the secondary pool, the ctx bounds the whole wait
+func (fp *FallbackPool) WaitIdle(ctx context.Context) error {
+	err := fp.Pool.WaitIdle(ctx)
+	if err != nil {
+		return err
+	}
+
+	return fp.secondary.WaitIdle(ctx)
+}
+
+func (fp *FallbackPool) execWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("fallback_pool_exec_with_context")
+	rsp, err := fp.Pool.tryExecWithTTL(ctx, rqs)
+	if err == nil || !fp.condition(err) {
+		return rsp, err
+	}
+
+	fp.fallback(op, err)
+	return fp.secondary.execWithTTL(ctx, rqs)
+}
+
+func (fp *FallbackPool) tryExecWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("fallback_pool_try_exec_with_context")
+	rsp, err := fp.Pool.tryExecWithTTL(ctx, rqs)
+	if err == nil || !fp.condition(err) {
+		return rsp, err
+	}
+
+	fp.fallback(op, err)
+	return fp.secondary.tryExecWithTTL(ctx, rqs)
+}
+
+func (fp *FallbackPool) fallback(op errors.Op, err error) {
+	fp.events.Push(events.PoolEvent{Event: events.EventPoolFallback, Payload: fp.secondary, Error: errors.E(op, err)})
+}
@@ -0,0 +1,75 @@
+package pool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/pool/internal/testpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FallbackPool(t *testing.T) {
+	primary := testpool.New("primary", 1)
+	secondary := testpool.New("secondary", 1)
+
+	eh := events.NewEventsHandler()
+	fallbacks := 0
+	eh.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolFallback {
+			fallbacks++
+		}
+	})
+
+	fp := pool.NewFallbackPool(primary, secondary, pool.WithFallbackEvents(eh))
+
+	rsp, err := fp.Exec(&payload.Payload{})
+	require.NoError(t, err)
+	assert.Equal(t, "primary", rsp.String())
+	assert.Equal(t, 0, fallbacks)
+
+	// primary pool is saturated
+	primary.Err = errors.E(errors.Op("test"), errors.NoFreeWorkers)
+	rsp, err = fp.Exec(&payload.Payload{})
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", rsp.String())
+	assert.Equal(t, 1, fallbacks)
+
+	// other errors are not routed to the secondary pool by default
+	primary.Err = errors.E(errors.Op("test"), errors.SoftJob)
+	_, err = fp.Exec(&payload.Payload{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, fallbacks)
+
+	fp = pool.NewFallbackPool(primary, secondary, pool.WithFallbackEvents(eh), pool.WithFallbackCondition(func(err error) bool {
+		return err != nil
+	}))
+	rsp, err = fp.Exec(&payload.Payload{})
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", rsp.String())
+	assert.Equal(t, 2, fallbacks)
+
+	fp.Destroy(context.Background())
+	assert.False(t, primary.DestroyDeadline())
+	assert.False(t, secondary.DestroyDeadline())
+}
+
+func Test_FallbackPool_Supervised(t *testing.T) {
+	primary := testpool.New("primary", 1)
+	secondary := testpool.New("secondary", 1)
+	dp := pool.NewDeadlinePool(primary)
+	sp := pool.SupervisorWrapper(dp, &pool.SupervisorConfig{ExecTTL: time.Second})
+
+	fp := pool.NewFallbackPool(sp, secondary)
+
+	// exec TTL of the supervised primary pool caps the deadline
+	rsp, err := fp.ExecDeadline(time.Now().Add(time.Minute), &payload.Payload{})
+	require.NoError(t, err)
+	assert.Equal(t, "primary", rsp.String())
+	assert.WithinDuration(t, time.Now().Add(time.Second), dp.Deadline(), time.Millisecond*500)
+}
@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/utils"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// takenCtx is the done context of the non-blocking Take, the free worker is preferred over the ctx (see the
+// container Pop), so only the ready worker is taken
+var takenCtx = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// fastExecEnabled reports whether the Exec takes the fast path (FastExec), the configurations which need the full
+// path bookkeeping on every call (middleware, MaxJobs, debug) are not eligible
+func (sp *StaticPool) fastExecEnabled() bool {
+	return sp.cfg.FastExec && !sp.cfg.Debug && len(sp.middleware) == 0 && sp.cfg.MaxJobs == MaxJobsUnlimited
+}
+
+// execFast is the lean happy path of the Exec: the ready worker is taken without waiting, executed and released.
+// No free worker falls back to the full path (wait for the AllocateTimeout, events), the exec errors are encoded
+// as usual.
+func (sp *StaticPool) execFast(p *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("static_pool_exec_fast")
+	if atomic.LoadUint32(&sp.initializing) == 1 {
+		return sp.execChain(context.Background(), p)
+	}
+
+	w, err := sp.ww.Take(takenCtx)
+	if err != nil {
+		return sp.execChain(context.Background(), p)
+	}
+
+	call := sp.inflight.start(w, nil)
+	rsp, err := w.(worker.SyncWorker).Exec(p)
+	sp.countExec(p)
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
+	if err != nil {
+		return sp.errEncoder(err, w)
+	}
+
+	if len(rsp.Body) == 0 {
+		// worker want's to be terminated
+		if utils.AsString(rsp.Context) == StopRequest {
+			payload.PutPayload(rsp)
+			sp.stopWorker(w)
+			return sp.execStops(context.Background(), p, 1, 1)
+		}
+
+		sp.ww.Release(w)
+		return sp.checkEmpty(op, rsp)
+	}
+
+	sp.ww.Release(w)
+	return rsp, nil
+}
@@ -0,0 +1,46 @@
+// Package graceful is a convenience for the standalone binaries built on top of the pool.
+// It is optional and not used by the pool itself.
+package graceful
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/pool"
+)
+
+// RunUntilSignal blocks until one of the signals received or the ctx is canceled. Then the pool is destroyed,
+// workers are allowed to complete their tasks during the grace period, after that they are killed.
+// If no signals provided, SIGINT and SIGTERM are used. Returns ctx error if the ctx was canceled.
+//
+// Usage from the main():
+//
+//	p, err := pool.Initialize(ctx, cmd, factory, cfg)
+//	...
+//	_ = graceful.RunUntilSignal(ctx, p, time.Second*30)
+func RunUntilSignal(ctx context.Context, p pool.Pool, grace time.Duration, signals ...os.Signal) error {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, signals...)
+	defer signal.Stop(sigCh)
+
+	var err error
+	select {
+	case <-sigCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// do not inherit the parent ctx, it might be already canceled
+	ctxD, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	p.Destroy(ctxD)
+	return err
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+// +build !windows
+
+package graceful
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/pool/internal/testpool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunUntilSignal_Ctx(t *testing.T) {
+	tp := testpool.New("", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	err := RunUntilSignal(ctx, tp, time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-tp.Destroyed():
+		// grace period
+		assert.True(t, tp.DestroyDeadline())
+	default:
+		t.Fatal("pool should be destroyed")
+	}
+}
+
+func TestRunUntilSignal_Signal(t *testing.T) {
+	tp := testpool.New("", 1)
+
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		_ = syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	}()
+
+	err := RunUntilSignal(context.Background(), tp, time.Second, syscall.SIGUSR1)
+	assert.NoError(t, err)
+
+	select {
+	case <-tp.Destroyed():
+		// grace period
+		assert.True(t, tp.DestroyDeadline())
+	default:
+		t.Fatal("pool should be destroyed")
+	}
+}
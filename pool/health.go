@@ -0,0 +1,153 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// defaultHealthCheckTimeout is the default timeout of the worker health check round-trip
+const defaultHealthCheckTimeout = time.Second * 5
+
+// WithHealthCheck sets the ping payload executed on the worker by the IsWorkerHealthy and the ForceHealthCheck (not
+// counted toward the MaxJobs), nil ping - the CONTROL introspect command is sent instead. The round-trip is bounded
+// by the timeout (5s if 0), the result is cached for the cacheTTL per worker (0 - not cached).
+func WithHealthCheck(ping *payload.Payload, timeout, cacheTTL time.Duration) Options {
+	return func(sp *StaticPool) {
+		sp.healthPing = controlPayload(ping)
+		if timeout > 0 {
+			sp.healthTimeout = timeout
+		}
+		sp.health.ttl = cacheTTL
+	}
+}
+
+// healthCache keeps the recent health check results, keyed by the worker ID (the pids might be reused)
+type healthCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	results map[string]healthResult
+}
+
+type healthResult struct {
+	err error
+	at  time.Time
+}
+
+// get returns the cached result of the worker checked within the ttl
+func (hc *healthCache) get(id string) (healthResult, bool) {
+	if hc.ttl == 0 {
+		return healthResult{}, false
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	res, ok := hc.results[id]
+	if !ok || time.Since(res.at) > hc.ttl {
+		return healthResult{}, false
+	}
+
+	return res, true
+}
+
+// put caches the result, the expired results (e.g. of the exited workers) are dropped
+func (hc *healthCache) put(id string, err error) {
+	if hc.ttl == 0 {
+		return
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.results == nil {
+		hc.results = make(map[string]healthResult)
+	}
+
+	now := time.Now()
+	for k, res := range hc.results {
+		if now.Sub(res.at) > hc.ttl {
+			delete(hc.results, k)
+		}
+	}
+	hc.results[id] = healthResult{err: err, at: now}
+}
+
+// IsWorkerHealthy reports whether the worker responds to the health check (see WithHealthCheck), the recent result
+// is returned from the cache if enabled. The error is the reason of the failed check, errors.TimeOut - the worker
+// was busy during the AllocateTimeout and wasn't checked.
+func (sp *StaticPool) IsWorkerHealthy(pid int64) (bool, error) {
+	w := sp.findWorker(pid)
+	if w != nil {
+		if res, ok := sp.health.get(w.ID()); ok {
+			return res.err == nil, res.err
+		}
+	}
+
+	return sp.ForceHealthCheck(pid)
+}
+
+// ForceHealthCheck is the IsWorkerHealthy which always round-trips to the worker, the result is cached. The worker
+// is taken from the container for the check, so it doesn't serve the requests meanwhile. Failed worker is replaced
+// the same way as after the failed request.
+func (sp *StaticPool) ForceHealthCheck(pid int64) (bool, error) {
+	const op = errors.Op("static_pool_force_health_check")
+	if sp.destroyed() {
+		return false, errors.E(op, errors.WatcherStopped, ErrPoolDraining)
+	}
+
+	ctxTake, cancel := context.WithTimeout(context.Background(), sp.cfg.AllocateTimeout)
+	defer cancel()
+	w, err := sp.ww.TakeWorker(ctxTake, pid)
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	err = sp.checkHealth(w)
+	sp.health.put(w.ID(), err)
+	if err != nil {
+		return false, errors.E(op, err)
+	}
+
+	return true, nil
+}
+
+// checkHealth runs the health check round-trip on the taken worker and returns it back
+func (sp *StaticPool) checkHealth(w worker.BaseProcess) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sp.healthTimeout)
+	defer cancel()
+
+	sp.inflight.begin()
+	if sp.healthPing != nil {
+		_, err := w.(worker.SyncWorker).ExecWithTTL(ctx, sp.healthPing)
+		// errored (killed on the timeout) workers are replaced on release
+		sp.ww.Release(w)
+		sp.inflight.end()
+		return err
+	}
+
+	_, err := w.(worker.SyncWorker).Introspect(ctx)
+	if w.State().Value() == worker.StateWorking {
+		// the reply is still pending, release the worker after it arrives
+		go sp.releaseAfterReply(w)
+		return err
+	}
+
+	sp.ww.Release(w)
+	sp.inflight.end()
+	return err
+}
+
+// findWorker returns the worker with the pid, nil if there is no such worker
+func (sp *StaticPool) findWorker(pid int64) worker.BaseProcess {
+	workers := sp.ww.List()
+	for i := 0; i < len(workers); i++ {
+		if workers[i].Pid() == pid {
+			return workers[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package pool
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HealthCache(t *testing.T) {
+	hc := &healthCache{}
+	// disabled
+	hc.put("a", nil)
+	_, ok := hc.get("a")
+	assert.False(t, ok)
+
+	hc.ttl = time.Millisecond * 50
+	hc.put("a", nil)
+	hc.put("b", errors.Str("unhealthy"))
+	res, ok := hc.get("b")
+	require.True(t, ok)
+	assert.Error(t, res.err)
+	res, ok = hc.get("a")
+	require.True(t, ok)
+	assert.NoError(t, res.err)
+
+	time.Sleep(time.Millisecond * 60)
+	_, ok = hc.get("a")
+	assert.False(t, ok)
+	// expired results are dropped on put
+	hc.put("c", nil)
+	assert.Len(t, hc.results, 1)
+}
+
+func Test_StaticPool_IsWorkerHealthy(t *testing.T) {
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithHealthCheck(&payload.Payload{Body: []byte("ping")}, time.Second, time.Minute),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	w := p.Workers()[1]
+	healthy, err := p.IsWorkerHealthy(w.Pid())
+	require.NoError(t, err)
+	assert.True(t, healthy)
+	// health check is not counted toward the MaxJobs
+	assert.Equal(t, uint64(0), w.State().NumExecs())
+	assert.Len(t, p.Workers(), 2)
+
+	_, err = p.ForceHealthCheck(-1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package pool
+
+import (
+	"context"
+	"sync"
+
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// inflightCall is the Exec call being processed by the worker
+type inflightCall struct {
+	id     uint64
+	w      worker.BaseProcess
+	cancel context.CancelFunc
+	// canceled by the CancelAll, protected by the inflight mutex
+	canceled bool
+}
+
+// inflight tracks the in-flight Exec calls
+type inflight struct {
+	mu    sync.Mutex
+	seq   uint64
+	calls map[uint64]*inflightCall
+	// number of the pool own round-trips (health checks, dumps, shutdown payloads), they keep the pool busy, but
+	// are neither listed nor canceled
+	internal int
+	// closed once there are no calls, replaced by the first call started
+	idle chan struct{}
+}
+
+func newInflight() *inflight {
+	idle := make(chan struct{})
+	close(idle)
+	return &inflight{
+		calls: make(map[uint64]*inflightCall),
+		idle:  idle,
+	}
+}
+
+// start registers the call executed on the worker, cancel might be nil for the calls without context
+func (i *inflight) start(w worker.BaseProcess, cancel context.CancelFunc) *inflightCall {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.busy()
+	i.seq++
+	c := &inflightCall{id: i.seq, w: w, cancel: cancel}
+	i.calls[c.id] = c
+	return c
+}
+
+// finish removes the call and reports whether it was canceled.
+// Worker of the canceled call is already killed and must not be released or encoded back.
+func (i *inflight) finish(c *inflightCall) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// canceled call is already removed
+	if _, ok := i.calls[c.id]; ok {
+		delete(i.calls, c.id)
+		i.notifyIdle()
+	}
+	return c.canceled
+}
+
+// begin registers the pool own round-trip on the taken worker, must be paired with the end
+func (i *inflight) begin() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.busy()
+	i.internal++
+}
+
+// end removes the pool own round-trip registered by the begin
+func (i *inflight) end() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.internal--
+	i.notifyIdle()
+}
+
+// cancelAll marks all the calls as canceled and removes them
+func (i *inflight) cancelAll() []*inflightCall {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	calls := make([]*inflightCall, 0, len(i.calls))
+	for id, c := range i.calls {
+		c.canceled = true
+		calls = append(calls, c)
+		delete(i.calls, id)
+	}
+	if len(calls) != 0 {
+		i.notifyIdle()
+	}
+
+	return calls
+}
+
+// done returns the channel closed once there are no calls (immediately if there are none at the moment)
+func (i *inflight) done() <-chan struct{} {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.idle
+}
+
+// busy replaces the closed idle channel by the first call or round-trip, should be called under the mutex
+func (i *inflight) busy() {
+	if len(i.calls) == 0 && i.internal == 0 {
+		i.idle = make(chan struct{})
+	}
+}
+
+// notifyIdle wakes up the done waiters if the last call or round-trip is removed, should be called under the mutex
+func (i *inflight) notifyIdle() {
+	if len(i.calls) == 0 && i.internal == 0 {
+		close(i.idle)
+	}
+}
+
+// list returns request ID -> worker pid of the in-flight calls
+func (i *inflight) list() map[uint64]int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	res := make(map[uint64]int64, len(i.calls))
+	for id, c := range i.calls {
+		res[id] = c.w.Pid()
+	}
+
+	return res
+}
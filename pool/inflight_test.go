@@ -0,0 +1,36 @@
+package pool
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Inflight(t *testing.T) {
+	w, err := pipe.NewPipeFactory().SpawnWorkerWithTimeout(context.Background(), exec.Command("php", "../tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+	defer func() {
+		_ = w.Stop()
+	}()
+
+	in := newInflight()
+	c1 := in.start(w, nil)
+	c2 := in.start(w, nil)
+	assert.Len(t, in.list(), 2)
+
+	// finished before the cancellation
+	assert.False(t, in.finish(c1))
+
+	calls := in.cancelAll()
+	require.Len(t, calls, 1)
+	assert.Equal(t, c2.id, calls[0].id)
+	assert.Len(t, in.list(), 0)
+
+	// canceled call must not be released by the caller
+	assert.True(t, in.finish(c2))
+	assert.Len(t, in.cancelAll(), 0)
+}
@@ -2,9 +2,12 @@ package pool
 
 import (
 	"context"
+	"time"
 
 	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/transport"
 	"github.com/spiral/roadrunner/v2/worker"
+	workerWatcher "github.com/spiral/roadrunner/v2/worker_watcher"
 )
 
 // Pool managed set of inner worker processes.
@@ -12,20 +15,143 @@ type Pool interface {
 	// GetConfig returns pool configuration.
 	GetConfig() interface{}
 
-	// Exec executes task with payload
+	// Config returns the copy of the pool configuration.
+	Config() *Config
+
+	// Exec executes task with payload. The response is owned by the caller and might be returned to the payload
+	// pool with the payload.PutPayload once it's no longer used (applies to all the Exec methods).
 	Exec(rqs *payload.Payload) (*payload.Payload, error)
 
+	// ExecWeighted executes task with payload, the weight is counted toward the worker MaxJobs instead of 1
+	// (heavy requests recycle the worker sooner), weight 0 is counted as 1
+	ExecWeighted(weight uint64, rqs *payload.Payload) (*payload.Payload, error)
+
+	// ExecWithAllocateTimeout executes task with payload waiting for the free worker up to the timeout instead of
+	// the AllocateTimeout (0 - AllocateTimeout), the exec TTL is not changed
+	ExecWithAllocateTimeout(timeout time.Duration, rqs *payload.Payload) (*payload.Payload, error)
+
+	// TryExec executes task with payload only if there is a free worker, errors.NoFreeWorkers returned immediately otherwise
+	TryExec(rqs *payload.Payload) (*payload.Payload, error)
+
+	// ExecDeadline executes task with payload within the end-to-end deadline. The remaining budget is split between
+	// the worker acquisition and the execution, errors.ExecTTL returned immediately if the deadline already passed.
+	ExecDeadline(deadline time.Time, rqs *payload.Payload) (*payload.Payload, error)
+
+	// InFlight returns the in-flight requests, request ID -> worker pid
+	InFlight() map[uint64]int64
+
+	// CancelAll cancels all the in-flight requests and kills the workers processing them.
+	// Used together with Destroy to shut down the pool without waiting for the long-running requests.
+	CancelAll()
+
+	// ExecCached executes task with payload, successful response is cached for the ttl keyed on the payload hash.
+	// Should be used explicitly only for the idempotent (deterministic) payloads.
+	ExecCached(rqs *payload.Payload, ttl time.Duration) (*payload.Payload, error)
+
+	// ExecCachedKey executes task with payload, successful response is cached for the ttl keyed on the caller
+	// provided key instead of the payload hash (equivalent payloads share the entry).
+	ExecCachedKey(key string, ttl time.Duration, rqs *payload.Payload) (*payload.Payload, error)
+
+	// Invalidate drops the response cached by the ExecCachedKey with the key
+	Invalidate(key string)
+
+	// CacheHits returns number of the ExecCached and ExecCachedKey cache hits
+	CacheHits() uint64
+
+	// CacheMisses returns number of the ExecCached and ExecCachedKey cache misses
+	CacheMisses() uint64
+
 	// Workers returns worker list associated with the pool.
 	Workers() (workers []worker.BaseProcess)
 
+	// OldestWorker returns the worker with the earliest Created time (e.g. the canary "control" worker), nil if no workers.
+	OldestWorker() worker.BaseProcess
+
+	// NewestWorker returns the most recently created worker, nil if no workers.
+	NewestWorker() worker.BaseProcess
+
+	// SlotWorker returns the worker in the logical slot (0 up to the number of workers), the replacement of the
+	// exited or recycled worker takes its vacated slot. Nil if the slot is vacant.
+	SlotWorker(slot int) worker.BaseProcess
+
+	// DrainWorker marks the worker as draining: it finishes the current request, is no longer used for the new
+	// requests and is recycled (warm replacement) once idle. Targeted recycling w/o the full pool Reset.
+	DrainWorker(pid int64) error
+
+	// IsWorkerHealthy runs the health check round-trip for the worker (or returns the cached recent result).
+	IsWorkerHealthy(pid int64) (bool, error)
+
+	// ForceHealthCheck runs the health check round-trip for the worker, the cached result is not used.
+	ForceHealthCheck(pid int64) (bool, error)
+
+	// AllocateEphemeral spawns the dedicated worker outside the pool accounting (not counted in the NumWorkers, not
+	// used for the requests), the returned func destroys it. Not released workers are killed on Destroy.
+	AllocateEphemeral(ctx context.Context) (worker.SyncWorker, func(), error)
+
 	// RemoveWorker removes worker from the pool.
 	RemoveWorker(worker worker.BaseProcess) error
 
+	// SetNumWorkers scales the number of workers up or down, up to the container capacity.
+	// Scaling down destroys only the free workers, waiting for the busy workers during the AllocateTimeout.
+	SetNumWorkers(num uint64) error
+
+	// SetNumWorkersPolicy is the SetNumWorkers removing the excess workers according to the policy, ScaleDownGraceful
+	// doesn't wait for the busy workers, they're removed after the current request. Reports the number of the
+	// removed and the deferred workers.
+	SetNumWorkersPolicy(num uint64, policy workerWatcher.ScaleDownPolicy) (workerWatcher.ScaleResult, error)
+
+	// Reset replaces all the workers one by one (rolling), without the capacity dip.
+	Reset(ctx context.Context) error
+
+	// RecycleByLabel replaces (warm replacement) the workers with the label key=value one by one, other workers are
+	// not affected. Workers in the middle of the request are killed after the request is completed.
+	RecycleByLabel(key, value string) error
+
+	// SetFactory replaces the transport factory used for the new workers, the existing workers are not affected.
+	SetFactory(factory transport.Factory) error
+
+	// MigrateWorkers replaces (rolling) the workers spawned by the previous factories, see SetFactory.
+	MigrateWorkers(ctx context.Context) error
+
+	// OnExecMilestone sets the callback called (async) each time the worker NumExecs reaches the multiple of every.
+	OnExecMilestone(every uint64, fn func(w worker.BaseProcess, count uint64))
+
+	// OnConfigChange triggers the rolling Reset whenever the channel signals (debounced).
+	OnConfigChange(ch <-chan struct{})
+
 	// Destroy all underlying stack (but let them to complete the task).
 	Destroy(ctx context.Context)
 
+	// DumpAllWorkers asks all free workers to report their internal state via the CONTROL introspect command
+	// (workers should support it), returns per-pid diagnostics. Busy workers are skipped.
+	DumpAllWorkers(ctx context.Context) (map[int64]map[string]interface{}, error)
+
+	// AllocFailures returns the cumulative number of failed worker allocations
+	AllocFailures() uint64
+
+	// SuccessfulAllocs returns the cumulative number of successful worker allocations
+	SuccessfulAllocs() uint64
+
+	// Throughput returns the completed executions per second across the pool over the ThroughputWindow
+	Throughput() float64
+
+	// WaitingCallers returns the number of the callers blocked waiting for the free worker
+	WaitingCallers() int
+
+	// WaitIdle blocks until there are no in-flight requests or the ctx is done
+	WaitIdle(ctx context.Context) error
+
 	// ExecWithContext executes task with context which is used with timeout
 	execWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error)
+
+	// execWeightedWithTTL is the execWithTTL counting the weight toward the MaxJobs
+	execWeightedWithTTL(ctx context.Context, weight uint64, rqs *payload.Payload) (*payload.Payload, error)
+
+	// tryExecWithTTL executes task with context only if there is a free worker
+	tryExecWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error)
+
+	// replaceWorker recycles the worker using the warm replacement
+	replaceWorker(w worker.BaseProcess) error
 }
 
 // Watcher is an interface for the Sync workers lifecycle
@@ -36,18 +162,49 @@ type Watcher interface {
 	// Take takes the first free worker
 	Take(ctx context.Context) (worker.BaseProcess, error)
 
+	// TakeWorker takes the free worker with the pid, the busy worker is waited for during the ctx
+	TakeWorker(ctx context.Context, pid int64) (worker.BaseProcess, error)
+
+	// TakeOrAllocate takes the first free worker, if there are no free workers, it allocates a new one (up to the max workers)
+	// in parallel with waiting for the worker to be released
+	TakeOrAllocate(ctx context.Context) (worker.BaseProcess, error)
+
 	// Release releases the worker putting it back to the queue
 	Release(w worker.BaseProcess)
 
 	// Allocate - allocates new worker and put it into the WorkerWatcher
 	Allocate() error
 
-	// Destroy destroys the underlying container
-	Destroy(ctx context.Context)
+	// SetNumWorkers scales the number of workers up or down
+	SetNumWorkers(ctx context.Context, num uint64) error
+
+	// SetNumWorkersPolicy scales the number of workers up or down, the excess workers are removed according to the policy
+	SetNumWorkersPolicy(ctx context.Context, num uint64, policy workerWatcher.ScaleDownPolicy) (workerWatcher.ScaleResult, error)
+
+	// Destroy destroys the underlying container, returns the combined error of the workers failed to be killed
+	Destroy(ctx context.Context) error
 
 	// List return all container w/o removing it from internal storage
 	List() []worker.BaseProcess
 
+	// Oldest returns the worker with the earliest Created time, nil if no workers
+	Oldest() worker.BaseProcess
+
+	// Newest returns the worker with the latest Created time, nil if no workers
+	Newest() worker.BaseProcess
+
+	// Slot returns the worker in the logical slot, the replacement takes the slot of the exited worker, nil if vacant
+	Slot(slot int) worker.BaseProcess
+
+	// Drain stops taking the worker for the new requests and recycles it once idle
+	Drain(pid int64) error
+
 	// Remove will remove worker from the container
 	Remove(wb worker.BaseProcess)
+
+	// Replace allocates the replacement before stopping the worker (warm replacement for the planned recycles)
+	Replace(wb worker.BaseProcess) error
+
+	// WatchGoroutines returns the number of the running wait goroutines (one per watched worker process), leak diagnostics
+	WatchGoroutines() int
 }
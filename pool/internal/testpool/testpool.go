@@ -0,0 +1,259 @@
+// Package testpool contains the in-memory pool.Pool fake shared by the pool tests.
+package testpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/transport"
+	"github.com/spiral/roadrunner/v2/worker"
+	workerWatcher "github.com/spiral/roadrunner/v2/worker_watcher"
+)
+
+// Pool is the pool.Pool fake. Exec methods respond with the Name as the body (or fail with the Err),
+// methods not used by the tests panic.
+type Pool struct {
+	// unexported pool.Pool methods can't be implemented outside the pool package, nil - calls panic
+	pool.Pool
+
+	// Name is the response body of the Exec methods
+	Name string
+	// Err is returned by the Exec methods
+	Err error
+	// Cfg returned by the GetConfig and the Config, nil - no config
+	Cfg *pool.Config
+	// Limit - SetNumWorkers stops at the limit with an error, 0 - no limit
+	Limit uint64
+	// Rate is returned by the Throughput
+	Rate float64
+
+	mu              sync.Mutex
+	num             uint64
+	destroyed       chan struct{}
+	destroyOnce     sync.Once
+	destroyDeadline bool
+}
+
+// New creates the fake pool with the number of workers
+func New(name string, num uint64) *Pool {
+	return &Pool{
+		Name:      name,
+		num:       num,
+		destroyed: make(chan struct{}),
+	}
+}
+
+func (p *Pool) GetConfig() interface{} {
+	if p.Cfg == nil {
+		return nil
+	}
+	return p.Cfg
+}
+
+func (p *Pool) Config() *pool.Config {
+	return p.Cfg
+}
+
+func (p *Pool) Exec(_ *payload.Payload) (*payload.Payload, error) {
+	return p.respond()
+}
+
+func (p *Pool) ExecWeighted(_ uint64, _ *payload.Payload) (*payload.Payload, error) {
+	return p.respond()
+}
+
+func (p *Pool) ExecWithAllocateTimeout(_ time.Duration, _ *payload.Payload) (*payload.Payload, error) {
+	return p.respond()
+}
+
+func (p *Pool) TryExec(_ *payload.Payload) (*payload.Payload, error) {
+	return p.respond()
+}
+
+func (p *Pool) ExecDeadline(_ time.Time, _ *payload.Payload) (*payload.Payload, error) {
+	return p.respond()
+}
+
+func (p *Pool) Workers() []worker.BaseProcess {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return make([]worker.BaseProcess, p.num)
+}
+
+func (p *Pool) SetNumWorkers(num uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Limit != 0 && num > p.Limit {
+		p.num = p.Limit
+		return errors.Str("allocate error")
+	}
+	p.num = num
+	return nil
+}
+
+func (p *Pool) SetNumWorkersPolicy(_ uint64, _ workerWatcher.ScaleDownPolicy) (workerWatcher.ScaleResult, error) {
+	panic("testpool: unexpected SetNumWorkersPolicy call")
+}
+
+// NumWorkers returns the number of workers set by the SetNumWorkers
+func (p *Pool) NumWorkers() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.num
+}
+
+func (p *Pool) Destroy(ctx context.Context) {
+	p.destroyOnce.Do(func() {
+		_, p.destroyDeadline = ctx.Deadline()
+		close(p.destroyed)
+	})
+}
+
+// Destroyed is closed on the first Destroy call
+func (p *Pool) Destroyed() <-chan struct{} {
+	return p.destroyed
+}
+
+// DestroyDeadline reports whether the Destroy ctx had a deadline
+func (p *Pool) DestroyDeadline() bool {
+	<-p.destroyed
+	return p.destroyDeadline
+}
+
+func (p *Pool) InFlight() map[uint64]int64 {
+	panic("testpool: unexpected InFlight call")
+}
+
+func (p *Pool) CancelAll() {
+	panic("testpool: unexpected CancelAll call")
+}
+
+func (p *Pool) ExecCached(_ *payload.Payload, _ time.Duration) (*payload.Payload, error) {
+	panic("testpool: unexpected ExecCached call")
+}
+
+func (p *Pool) ExecCachedKey(_ string, _ time.Duration, _ *payload.Payload) (*payload.Payload, error) {
+	panic("testpool: unexpected ExecCachedKey call")
+}
+
+func (p *Pool) Invalidate(_ string) {
+	panic("testpool: unexpected Invalidate call")
+}
+
+func (p *Pool) CacheHits() uint64 {
+	panic("testpool: unexpected CacheHits call")
+}
+
+func (p *Pool) CacheMisses() uint64 {
+	panic("testpool: unexpected CacheMisses call")
+}
+
+func (p *Pool) OldestWorker() worker.BaseProcess {
+	panic("testpool: unexpected OldestWorker call")
+}
+
+func (p *Pool) NewestWorker() worker.BaseProcess {
+	panic("testpool: unexpected NewestWorker call")
+}
+
+func (p *Pool) SlotWorker(_ int) worker.BaseProcess {
+	panic("testpool: unexpected SlotWorker call")
+}
+
+func (p *Pool) DrainWorker(_ int64) error {
+	panic("testpool: unexpected DrainWorker call")
+}
+
+func (p *Pool) AllocateEphemeral(_ context.Context) (worker.SyncWorker, func(), error) {
+	panic("testpool: unexpected AllocateEphemeral call")
+}
+
+func (p *Pool) RemoveWorker(_ worker.BaseProcess) error {
+	panic("testpool: unexpected RemoveWorker call")
+}
+
+func (p *Pool) Reset(_ context.Context) error {
+	panic("testpool: unexpected Reset call")
+}
+
+func (p *Pool) RecycleByLabel(_, _ string) error {
+	panic("testpool: unexpected RecycleByLabel call")
+}
+
+func (p *Pool) IsWorkerHealthy(_ int64) (bool, error) {
+	panic("testpool: unexpected IsWorkerHealthy call")
+}
+
+func (p *Pool) ForceHealthCheck(_ int64) (bool, error) {
+	panic("testpool: unexpected ForceHealthCheck call")
+}
+
+func (p *Pool) SetFactory(_ transport.Factory) error {
+	panic("testpool: unexpected SetFactory call")
+}
+
+func (p *Pool) MigrateWorkers(_ context.Context) error {
+	panic("testpool: unexpected MigrateWorkers call")
+}
+
+func (p *Pool) OnExecMilestone(_ uint64, _ func(w worker.BaseProcess, count uint64)) {
+	panic("testpool: unexpected OnExecMilestone call")
+}
+
+func (p *Pool) OnConfigChange(_ <-chan struct{}) {
+	panic("testpool: unexpected OnConfigChange call")
+}
+
+func (p *Pool) DumpAllWorkers(_ context.Context) (map[int64]map[string]interface{}, error) {
+	panic("testpool: unexpected DumpAllWorkers call")
+}
+
+func (p *Pool) AllocFailures() uint64 {
+	panic("testpool: unexpected AllocFailures call")
+}
+
+func (p *Pool) SuccessfulAllocs() uint64 {
+	panic("testpool: unexpected SuccessfulAllocs call")
+}
+
+func (p *Pool) Throughput() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Rate
+}
+
+// SetRate sets the Throughput value, safe for the concurrent use
+func (p *Pool) SetRate(rate float64) {
+	p.mu.Lock()
+	p.Rate = rate
+	p.mu.Unlock()
+}
+
+func (p *Pool) WaitingCallers() int {
+	panic("testpool: unexpected WaitingCallers call")
+}
+
+func (p *Pool) WaitIdle(_ context.Context) error {
+	panic("testpool: unexpected WaitIdle call")
+}
+
+// SetErr sets the Err safely for the concurrent Exec calls
+func (p *Pool) SetErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Err = err
+}
+
+func (p *Pool) respond() (*payload.Payload, error) {
+	p.mu.Lock()
+	err := p.Err
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &payload.Payload{Body: []byte(p.Name)}, nil
+}
@@ -0,0 +1,52 @@
+package pool
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// watchLifetime destroys the pool after the MaxPoolLifetime, exits on Destroy
+func (sp *StaticPool) watchLifetime() {
+	select {
+	case <-sp.clock.After(sp.cfg.MaxPoolLifetime):
+		sp.lifetimeReached()
+	case <-sp.stopCh:
+	}
+}
+
+// countExec counts the execution on the worker toward the MaxTotalExecs and the Throughput, control payloads are
+// not counted
+func (sp *StaticPool) countExec(p *payload.Payload) {
+	if p.Control {
+		return
+	}
+
+	if sp.throughput != nil {
+		sp.throughput.add(sp.clock.Now())
+	}
+
+	n := atomic.AddUint64(&sp.totalExecs, 1)
+	if sp.cfg.MaxTotalExecs != 0 && n == sp.cfg.MaxTotalExecs {
+		// the worker of the current request should be released first
+		go sp.lifetimeReached()
+	}
+}
+
+// lifetimeReached emits the EventPoolLifetimeReached and gracefully destroys the pool, once
+func (sp *StaticPool) lifetimeReached() {
+	if !atomic.CompareAndSwapUint32(&sp.lifetimeEnded, 0, 1) || sp.destroyed() {
+		return
+	}
+
+	sp.events.Push(events.PoolEvent{Event: events.EventPoolLifetimeReached, Payload: events.PoolLifetime{
+		Uptime: sp.clock.Now().Sub(sp.started),
+		Execs:  atomic.LoadUint64(&sp.totalExecs),
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), sp.cfg.DestroyTimeout)
+	defer cancel()
+	sp.Destroy(ctx)
+}
@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"context"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// ExecFunc executes the payload, ctx carries the exec deadline (if any)
+type ExecFunc func(ctx context.Context, p *payload.Payload) (*payload.Payload, error)
+
+// Middleware wraps the Exec call (auth, metrics, tracing), next should be called to execute the payload on the worker
+// and might be skipped to short-circuit the call.
+type Middleware func(next ExecFunc) ExecFunc
+
+// WithMiddleware wraps the Exec calls with the middleware chain, built once on Initialize. The first middleware is
+// the outermost one. Applied to all the Exec methods (Exec, ExecWeighted, ExecWithAllocateTimeout, ExecDeadline,
+// TryExec, ExecCached misses) and to their supervised variants, on each attempt of the RetryPolicy. The ctx carries
+// the deadline of the ExecDeadline and the exec TTL.
+func WithMiddleware(middleware ...Middleware) Options {
+	return func(p *StaticPool) {
+		p.middleware = append(p.middleware, middleware...)
+	}
+}
+
+// chainMiddleware wraps the exec with the middleware, the first one is the outermost
+func chainMiddleware(exec ExecFunc, middleware []Middleware) ExecFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		exec = middleware[i](exec)
+	}
+
+	return exec
+}
+
+// execOptions are the parameters of the Exec method, carried to the end of the middleware chain by the ctx
+type execOptions struct {
+	// counted toward the MaxJobs, 0 is counted as 1
+	weight uint64
+	// allocTimeout is set - wait for the free worker up to the allocTimeout (0 - don't wait) instead of the
+	// AllocateTimeout
+	allocTimeoutSet bool
+	allocTimeout    time.Duration
+	// end-to-end deadline of the ExecDeadline, zero - none
+	deadline time.Time
+}
+
+type execOptionsKey struct{}
+
+// withExecOptions returns the ctx carrying the options to the execTerminal
+func withExecOptions(ctx context.Context, opts execOptions) context.Context {
+	return context.WithValue(ctx, execOptionsKey{}, opts)
+}
+
+// execTerminal is the end of the middleware chain, ctx w/o the deadline and the cancellation (Exec) is executed
+// w/o the TTL
+func (sp *StaticPool) execTerminal(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+	opts, ok := ctx.Value(execOptionsKey{}).(execOptions)
+	if !ok {
+		opts.weight = 1
+	}
+
+	switch {
+	case !opts.deadline.IsZero():
+		return sp.execDeadline(ctx, opts.deadline, p, 0)
+	case opts.allocTimeoutSet:
+		return sp.execWithAllocTimeout(ctx, p, opts.allocTimeout, opts.weight, 0)
+	case ctx.Done() == nil:
+		return sp.execStops(ctx, p, opts.weight, 0)
+	default:
+		return sp.execWithAllocTimeout(ctx, p, sp.cfg.AllocateTimeout, opts.weight, 0)
+	}
+}
+
+// ExecInfo is the worker side of the Exec call, filled by the pool if the call ctx is prepared by the WithExecInfo
+// (e.g. by the tracing or the metrics middleware). Should be read after the next returned.
+type ExecInfo struct {
+	// Pid of the worker executed the payload (the last one after the StopRequest), 0 - no worker acquired
+	Pid int64
+	// Wait is the worker acquisition time
+	Wait time.Duration
+	// Exec is the execution time on the worker
+	Exec time.Duration
+}
+
+type execInfoKey struct{}
+
+// WithExecInfo returns the ctx the pool fills the ExecInfo of the call on, should be passed to the next
+func WithExecInfo(ctx context.Context) (context.Context, *ExecInfo) {
+	info := &ExecInfo{}
+	return context.WithValue(ctx, execInfoKey{}, info), info
+}
+
+// execInfoFrom returns the ExecInfo carried by the ctx, nil if there is none
+func execInfoFrom(ctx context.Context) *ExecInfo {
+	info, _ := ctx.Value(execInfoKey{}).(*ExecInfo)
+	return info
+}
+
+func (ei *ExecInfo) acquired(pid int64, wait time.Duration) {
+	if ei == nil {
+		return
+	}
+
+	ei.Pid = pid
+	ei.Wait += wait
+}
+
+func (ei *ExecInfo) executed(d time.Duration) {
+	if ei == nil {
+		return
+	}
+
+	ei.Exec += d
+}
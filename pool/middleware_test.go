@@ -0,0 +1,196 @@
+package pool
+
+import (
+	"context"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/spiral/roadrunner/v2/transport/testtransport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagMiddleware appends the tag to the request context before and to the response body after the call
+func tagMiddleware(tag string) Middleware {
+	return func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+			p.Context = append(p.Context, tag...)
+			rsp, err := next(ctx, p)
+			if err != nil {
+				return nil, err
+			}
+			rsp.Body = append(rsp.Body, tag...)
+			return rsp, nil
+		}
+	}
+}
+
+func Test_ChainMiddleware(t *testing.T) {
+	exec := chainMiddleware(func(_ context.Context, p *payload.Payload) (*payload.Payload, error) {
+		return &payload.Payload{Body: append([]byte(nil), p.Context...)}, nil
+	}, []Middleware{tagMiddleware("a"), tagMiddleware("b")})
+
+	rsp, err := exec(context.Background(), &payload.Payload{})
+	require.NoError(t, err)
+	// the first middleware is the outermost
+	assert.Equal(t, "abba", rsp.String())
+
+	// short-circuit
+	denied := errors.Str("denied")
+	exec = chainMiddleware(func(_ context.Context, _ *payload.Payload) (*payload.Payload, error) {
+		t.Fatal("next should not be called")
+		return nil, nil
+	}, []Middleware{func(_ ExecFunc) ExecFunc {
+		return func(_ context.Context, _ *payload.Payload) (*payload.Payload, error) {
+			return nil, denied
+		}
+	}})
+
+	_, err = exec(context.Background(), &payload.Payload{})
+	assert.Equal(t, denied, err)
+}
+
+func Test_StaticPool_Middleware(t *testing.T) {
+	var elapsed time.Duration
+	timing := func(next ExecFunc) ExecFunc {
+		return func(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+			start := time.Now()
+			defer func() {
+				elapsed = time.Since(start)
+			}()
+			return next(ctx, p)
+		}
+	}
+
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithMiddleware(timing, tagMiddleware("-mw")),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello-mw", res.String())
+	assert.NotZero(t, elapsed)
+
+	res, err = p.TryExec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello-mw", res.String())
+}
+
+func Test_StaticPool_MiddlewareExecMethods(t *testing.T) {
+	for _, supervised := range []bool{false, true} {
+		var calls int64
+		cfg := &Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		}
+		if supervised {
+			cfg.Supervisor = &SupervisorConfig{ExecTTL: time.Second}
+		}
+
+		p, err := Initialize(
+			context.Background(),
+			func() *exec.Cmd { return exec.Command("php", "worker.php") },
+			testtransport.NewFactory(testtransport.Config{}),
+			cfg,
+			WithMiddleware(func(next ExecFunc) ExecFunc {
+				return func(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+					atomic.AddInt64(&calls, 1)
+					return next(ctx, p)
+				}
+			}, tagMiddleware("-mw")),
+		)
+		require.NoError(t, err)
+
+		methods := map[string]func(p *payload.Payload) (*payload.Payload, error){
+			"Exec": p.Exec,
+			"ExecWeighted": func(pld *payload.Payload) (*payload.Payload, error) {
+				return p.ExecWeighted(2, pld)
+			},
+			"ExecWithAllocateTimeout": func(pld *payload.Payload) (*payload.Payload, error) {
+				return p.ExecWithAllocateTimeout(time.Millisecond*500, pld)
+			},
+			"ExecDeadline": func(pld *payload.Payload) (*payload.Payload, error) {
+				return p.ExecDeadline(time.Now().Add(time.Second), pld)
+			},
+			"TryExec": p.TryExec,
+			"ExecCached": func(pld *payload.Payload) (*payload.Payload, error) {
+				return p.ExecCached(pld, time.Minute)
+			},
+		}
+
+		for name, method := range methods {
+			before := atomic.LoadInt64(&calls)
+			res, err := method(&payload.Payload{Body: []byte(name)})
+			require.NoError(t, err, name)
+			assert.Equal(t, name+"-mw", res.String(), name)
+			assert.Equal(t, before+1, atomic.LoadInt64(&calls), name)
+		}
+
+		p.Destroy(context.Background())
+	}
+}
+
+func Test_ExecInfo(t *testing.T) {
+	// not prepared ctx, recording is no-op
+	execInfoFrom(context.Background()).acquired(1, time.Second)
+	execInfoFrom(context.Background()).executed(time.Second)
+
+	ctx, info := WithExecInfo(context.Background())
+	// derived ctx carries the same info
+	ctxAlloc, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	execInfoFrom(ctxAlloc).acquired(10, time.Millisecond)
+	execInfoFrom(ctx).executed(time.Millisecond * 2)
+	// StopRequest, the request is executed on the next worker
+	execInfoFrom(ctxAlloc).acquired(11, time.Millisecond)
+	execInfoFrom(ctx).executed(time.Millisecond * 2)
+
+	assert.Equal(t, ExecInfo{Pid: 11, Wait: time.Millisecond * 2, Exec: time.Millisecond * 4}, *info)
+}
+
+func Test_StaticPool_ExecInfo(t *testing.T) {
+	var info *ExecInfo
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithMiddleware(func(next ExecFunc) ExecFunc {
+			return func(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+				ctx, info = WithExecInfo(ctx)
+				return next(ctx, p)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	_, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+
+	require.NotNil(t, info)
+	assert.Equal(t, p.Workers()[0].Pid(), info.Pid)
+	assert.Greater(t, info.Exec, time.Duration(0))
+}
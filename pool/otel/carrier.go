@@ -0,0 +1,124 @@
+package otel
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+
+	j "github.com/json-iterator/go"
+	"github.com/spiral/roadrunner/v2/payload"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var json = j.ConfigCompatibleWithStandardLibrary
+
+// JSONCarrier is the default Carrier, the trace context fields (e.g. traceparent) are set as the top-level string
+// fields of the JSON object Context. Empty Context is replaced with the object, other Context formats are not
+// supported (nil carrier, the trace context is not injected). Only the trace fields are touched, the rest of the
+// Context is kept byte for byte (values, order, formatting).
+func JSONCarrier(p *payload.Payload) propagation.TextMapCarrier {
+	fields := make(map[string]stdjson.RawMessage)
+	obj := []byte("{}")
+	if len(p.Context) != 0 {
+		err := json.Unmarshal(p.Context, &fields)
+		if err != nil || fields == nil {
+			return nil
+		}
+		obj = bytes.TrimSpace(p.Context)
+	}
+
+	return &jsonCarrier{
+		p:      p,
+		fields: fields,
+		// w/o the closing brace
+		prefix:  obj[:len(obj)-1],
+		numOrig: len(fields),
+	}
+}
+
+type jsonCarrier struct {
+	p      *payload.Payload
+	fields map[string]stdjson.RawMessage
+
+	// original object w/o the closing brace and the number of its fields, the injected fields are appended
+	prefix  []byte
+	numOrig int
+	// injected fields, in the Set order
+	injected []string
+	// the original Context has the injected field, the object is re-encoded
+	reencode bool
+}
+
+func (jc *jsonCarrier) Get(key string) string {
+	var v string
+	if raw, ok := jc.fields[key]; ok {
+		_ = json.Unmarshal(raw, &v)
+	}
+	return v
+}
+
+// Set sets the field and re-encodes the Context, the previous Context bytes are not modified
+func (jc *jsonCarrier) Set(key, value string) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	if !jc.isInjected(key) {
+		if _, ok := jc.fields[key]; ok {
+			jc.reencode = true
+		}
+		jc.injected = append(jc.injected, key)
+	}
+	jc.fields[key] = raw
+
+	data, err := jc.encode()
+	if err != nil {
+		return
+	}
+	jc.p.Context = data
+}
+
+func (jc *jsonCarrier) Keys() []string {
+	keys := make([]string, 0, len(jc.fields))
+	for k := range jc.fields {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (jc *jsonCarrier) isInjected(key string) bool {
+	for i := 0; i < len(jc.injected); i++ {
+		if jc.injected[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// encode appends the injected fields to the original object, the object with the overwritten field is re-encoded
+// (the raw values are kept)
+func (jc *jsonCarrier) encode() ([]byte, error) {
+	if jc.reencode {
+		return json.Marshal(jc.fields)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(jc.prefix)+64*len(jc.injected)))
+	buf.Write(jc.prefix)
+	n := jc.numOrig
+	for i := 0; i < len(jc.injected); i++ {
+		key, err := json.Marshal(jc.injected[i])
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		n++
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(jc.fields[jc.injected[i]])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,121 @@
+// Package otel provides the pool.Middleware emitting the OpenTelemetry span per Exec. It's the separate module,
+// so the pool doesn't depend on the OpenTelemetry.
+package otel
+
+import (
+	"context"
+
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// instrumentationName is the name of the tracer
+	instrumentationName = "github.com/spiral/roadrunner/v2/pool/otel"
+	// spanName is the name of the Exec span
+	spanName = "pool.exec"
+)
+
+// span attributes
+const (
+	AttrWorkerPid = attribute.Key("rr.worker.pid")
+	// AttrWaitMs is the worker acquisition time in milliseconds
+	AttrWaitMs = attribute.Key("rr.exec.wait_ms")
+	// AttrExecMs is the execution time on the worker in milliseconds
+	AttrExecMs = attribute.Key("rr.exec.exec_ms")
+	// AttrOutcome is "ok" or "error"
+	AttrOutcome = attribute.Key("rr.exec.outcome")
+)
+
+// Carrier returns the carrier the trace context is injected into, called with the copy of the request payload.
+// nil carrier - the trace context is not injected.
+type Carrier func(p *payload.Payload) propagation.TextMapCarrier
+
+type Options func(t *tracing)
+
+// WithTracerProvider sets the tracer provider (the global one by default)
+func WithTracerProvider(tp trace.TracerProvider) Options {
+	return func(t *tracing) {
+		t.provider = tp
+	}
+}
+
+// WithPropagator sets the propagator of the trace context (the global one by default)
+func WithPropagator(p propagation.TextMapPropagator) Options {
+	return func(t *tracing) {
+		t.propagator = p
+	}
+}
+
+// WithCarrier sets the carrier of the trace context (JSONCarrier by default), e.g. for the custom Context format
+func WithCarrier(c Carrier) Options {
+	return func(t *tracing) {
+		t.carrier = c
+	}
+}
+
+type tracing struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	carrier    Carrier
+}
+
+// Middleware starts the span per Exec, injects the trace context into the payload Context (see WithCarrier) and
+// records the worker pid, the acquisition and the execution time and the outcome. The span is created even if the
+// worker is not acquired (e.g. no free workers). The caller payload is not modified, the worker gets the copy.
+func Middleware(options ...Options) pool.Middleware {
+	t := &tracing{carrier: JSONCarrier}
+	for i := 0; i < len(options); i++ {
+		options[i](t)
+	}
+
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+	if t.propagator == nil {
+		t.propagator = otel.GetTextMapPropagator()
+	}
+
+	tracer := t.provider.Tracer(instrumentationName)
+
+	return func(next pool.ExecFunc) pool.ExecFunc {
+		return func(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+			ctx, span := tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient))
+			defer span.End()
+
+			if t.carrier != nil {
+				cp := *p
+				if c := t.carrier(&cp); c != nil {
+					t.propagator.Inject(ctx, c)
+					p = &cp
+				}
+			}
+
+			ctx, info := pool.WithExecInfo(ctx)
+			rsp, err := next(ctx, p)
+
+			if info.Pid != 0 {
+				span.SetAttributes(AttrWorkerPid.Int64(info.Pid))
+			}
+			span.SetAttributes(
+				AttrWaitMs.Int64(info.Wait.Milliseconds()),
+				AttrExecMs.Int64(info.Exec.Milliseconds()),
+			)
+
+			if err != nil {
+				span.SetAttributes(AttrOutcome.String("error"))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return rsp, err
+			}
+
+			span.SetAttributes(AttrOutcome.String("ok"))
+			return rsp, nil
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package otel
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func initMiddleware(next pool.ExecFunc) (pool.ExecFunc, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+	mw := Middleware(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))),
+		WithPropagator(propagation.TraceContext{}),
+	)
+
+	return mw(next), sr
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for i := 0; i < len(attrs); i++ {
+		if attrs[i].Key == key {
+			return attrs[i].Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestMiddleware_Span(t *testing.T) {
+	var received []byte
+	exec, sr := initMiddleware(func(_ context.Context, p *payload.Payload) (*payload.Payload, error) {
+		received = p.Context
+		return &payload.Payload{Body: []byte("hello")}, nil
+	})
+
+	rqs := &payload.Payload{Context: []byte(`{"tenant":"a"}`), Body: []byte("hello")}
+	rsp, err := exec(context.Background(), rqs)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", rsp.String())
+
+	// caller payload is not modified
+	assert.Equal(t, `{"tenant":"a"}`, string(rqs.Context))
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, spanName, spans[0].Name())
+
+	v, ok := attr(spans[0].Attributes(), AttrOutcome)
+	require.True(t, ok)
+	assert.Equal(t, "ok", v.AsString())
+	// no worker pid reported by the next
+	_, ok = attr(spans[0].Attributes(), AttrWorkerPid)
+	assert.False(t, ok)
+
+	// trace context is injected, the context fields are kept
+	fields := map[string]string{}
+	require.NoError(t, json.Unmarshal(received, &fields))
+	assert.Equal(t, "a", fields["tenant"])
+	assert.Contains(t, fields["traceparent"], spans[0].SpanContext().TraceID().String())
+}
+
+func TestMiddleware_AcquisitionError(t *testing.T) {
+	exec, sr := initMiddleware(func(_ context.Context, _ *payload.Payload) (*payload.Payload, error) {
+		return nil, errors.E(errors.Op("test_exec"), errors.NoFreeWorkers, pool.ErrOverloaded)
+	})
+
+	_, err := exec(context.Background(), &payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+
+	v, ok := attr(spans[0].Attributes(), AttrOutcome)
+	require.True(t, ok)
+	assert.Equal(t, "error", v.AsString())
+	require.Len(t, spans[0].Events(), 1)
+}
+
+func TestJSONCarrier(t *testing.T) {
+	// empty context is replaced with the object
+	p := &payload.Payload{}
+	c := JSONCarrier(p)
+	require.NotNil(t, c)
+	c.Set("traceparent", "tp")
+	assert.Equal(t, `{"traceparent":"tp"}`, string(p.Context))
+	assert.Equal(t, "tp", c.Get("traceparent"))
+	assert.Equal(t, []string{"traceparent"}, c.Keys())
+
+	// not a JSON object
+	assert.Nil(t, JSONCarrier(&payload.Payload{Context: []byte("raw")}))
+	assert.Nil(t, JSONCarrier(&payload.Payload{Context: []byte("[1]")}))
+}
+
+func TestJSONCarrier_RoundTrip(t *testing.T) {
+	ctx := `{"id": 9007199254740993, "b":[1, 2], "a":{"x":1.50}}`
+	p := &payload.Payload{Context: []byte(ctx)}
+	c := JSONCarrier(p)
+	require.NotNil(t, c)
+	c.Set("traceparent", "tp")
+	c.Set("tracestate", "ts")
+	c.Set("traceparent", "tp2")
+
+	// the original fields are kept as is, the trace fields are appended
+	assert.Equal(t, `{"id": 9007199254740993, "b":[1, 2], "a":{"x":1.50},"traceparent":"tp2","tracestate":"ts"}`, string(p.Context))
+	fields := map[string]stdjson.RawMessage{}
+	require.NoError(t, json.Unmarshal(p.Context, &fields))
+	assert.Equal(t, "9007199254740993", string(fields["id"]))
+	assert.Equal(t, "tp2", c.Get("traceparent"))
+
+	// the overwritten field re-encodes the object, the raw values are kept
+	p = &payload.Payload{Context: []byte(`{"traceparent":"old","id":9007199254740993}`)}
+	c = JSONCarrier(p)
+	require.NotNil(t, c)
+	c.Set("traceparent", "new")
+	fields = map[string]stdjson.RawMessage{}
+	require.NoError(t, json.Unmarshal(p.Context, &fields))
+	assert.Equal(t, "9007199254740993", string(fields["id"]))
+	assert.Equal(t, `"new"`, string(fields["traceparent"]))
+
+	// empty object
+	p = &payload.Payload{Context: []byte(" {} ")}
+	JSONCarrier(p).Set("traceparent", "tp")
+	assert.Equal(t, `{"traceparent":"tp"}`, string(p.Context))
+}
@@ -0,0 +1,66 @@
+package pool
+
+import (
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// RetryPolicy defines how the failed Exec calls are retried. Only payloads marked as payload.Idempotent
+// are retried, non-idempotent payloads are executed once regardless of the policy (see RetryUnsent).
+type RetryPolicy struct {
+	// MaxRetries defines how many times the failed request is retried, 0 - disabled.
+	MaxRetries uint64 `mapstructure:"max_retries"`
+
+	// Backoff defines the pause between the retries.
+	Backoff time.Duration `mapstructure:"backoff"`
+
+	// RetryUnsent retries the non-idempotent payloads too, if the request didn't reach the worker at all (the worker
+	// is not allocated or the relay send failed before any bytes are written, see worker.SendError). Requests
+	// partially sent to the worker are never retried.
+	RetryUnsent bool `mapstructure:"retry_unsent"`
+}
+
+// retryable reports whether the request failed because of the worker (crash, broken relay, allocation),
+// not because of the request itself
+func retryable(err error) bool {
+	return errors.Is(errors.Network, err) || errors.Is(errors.WorkerAllocate, err)
+}
+
+// unsent reports whether the request failed before it reached the worker, so it's safe to retry the
+// non-idempotent payload
+func unsent(err error) bool {
+	if errors.Is(errors.WorkerAllocate, err) {
+		return true
+	}
+
+	se, ok := worker.AsSendError(err)
+	return ok && se.Unsent
+}
+
+// exec executes the payload, retries failed idempotent payloads (and the unsent ones with the RetryUnsent)
+// according to the policy
+func (rp *RetryPolicy) exec(p *payload.Payload, exec func(*payload.Payload) (*payload.Payload, error)) (*payload.Payload, error) {
+	if rp == nil || rp.MaxRetries == 0 || (!p.Idempotent && !rp.RetryUnsent) {
+		return exec(p)
+	}
+
+	var attempt uint64
+	for {
+		rsp, err := exec(p)
+		if err == nil || !retryable(err) || attempt == rp.MaxRetries {
+			return rsp, err
+		}
+
+		if !p.Idempotent && !unsent(err) {
+			return rsp, err
+		}
+
+		attempt++
+		if rp.Backoff > 0 {
+			time.Sleep(rp.Backoff)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RetryPolicy(t *testing.T) {
+	rp := &RetryPolicy{MaxRetries: 2}
+	calls := 0
+	failing := func(err error) func(*payload.Payload) (*payload.Payload, error) {
+		calls = 0
+		return func(_ *payload.Payload) (*payload.Payload, error) {
+			calls++
+			return nil, err
+		}
+	}
+
+	networkErr := errors.E(errors.Op("test"), errors.Network)
+
+	// not idempotent payloads are never retried
+	_, err := rp.exec(&payload.Payload{}, failing(networkErr))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = rp.exec(&payload.Payload{Idempotent: true}, failing(networkErr))
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	// request errors are not retried
+	_, err = rp.exec(&payload.Payload{Idempotent: true}, failing(errors.E(errors.Op("test"), errors.SoftJob)))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// disabled policy
+	var nilPolicy *RetryPolicy
+	_, err = nilPolicy.exec(&payload.Payload{Idempotent: true}, failing(networkErr))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// succeeded after the retry
+	calls = 0
+	rsp, err := rp.exec(&payload.Payload{Idempotent: true}, func(_ *payload.Payload) (*payload.Payload, error) {
+		calls++
+		if calls == 1 {
+			return nil, networkErr
+		}
+		return &payload.Payload{Body: []byte("ok")}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", rsp.String())
+	assert.Equal(t, 2, calls)
+}
+
+func Test_RetryPolicy_Unsent(t *testing.T) {
+	rp := &RetryPolicy{MaxRetries: 2, RetryUnsent: true}
+	calls := 0
+	failing := func(err error) func(*payload.Payload) (*payload.Payload, error) {
+		calls = 0
+		return func(_ *payload.Payload) (*payload.Payload, error) {
+			calls++
+			return nil, err
+		}
+	}
+
+	op := errors.Op("test")
+	// not idempotent payload, not sent at all
+	_, err := rp.exec(&payload.Payload{}, failing(errors.E(op, errors.Network, &worker.SendError{Unsent: true, Err: errors.Str("broken pipe")})))
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	_, err = rp.exec(&payload.Payload{}, failing(errors.E(op, errors.WorkerAllocate)))
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	// partially sent, or the progress is unknown
+	_, err = rp.exec(&payload.Payload{}, failing(errors.E(op, errors.Network, &worker.SendError{Sent: 10, Err: errors.Str("broken pipe")})))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	_, err = rp.exec(&payload.Payload{}, failing(errors.E(op, errors.Network)))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	// idempotent payloads are retried regardless
+	_, err = rp.exec(&payload.Payload{Idempotent: true}, failing(errors.E(op, errors.Network, &worker.SendError{Sent: 10, Err: errors.Str("broken pipe")})))
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+}
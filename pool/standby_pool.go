@@ -0,0 +1,243 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// defaultStandbyInterval is the default interval of the standby pool health checks
+const defaultStandbyInterval = time.Second * 10
+
+type StandbyOptions func(sp *StandbyPool)
+
+// WithStandbyHealthCheck sets the interval of the health checks (10s by default) and the ping payload executed
+// on a free worker on each check (not counted toward the MaxJobs). nil ping - only the workers states are checked.
+func WithStandbyHealthCheck(interval time.Duration, ping *payload.Payload) StandbyOptions {
+	return func(sp *StandbyPool) {
+		if interval > 0 {
+			sp.interval = interval
+		}
+		sp.ping = controlPayload(ping)
+	}
+}
+
+// WithStandbyEvents sets the events handler used to push the EventStandbyUnhealthy and EventStandbyActivated
+func WithStandbyEvents(eh events.Handler) StandbyOptions {
+	return func(sp *StandbyPool) {
+		sp.events = eh
+	}
+}
+
+// StandbyPool keeps the booted pool out of the rotation (failover): requests are rejected with the ErrStandby
+// until the pool is activated, the workers are kept ready and health-checked periodically. Activate promotes
+// the pool instantly, all other methods are related to the underlying pool.
+type StandbyPool struct {
+	Pool
+	interval time.Duration
+	ping     *payload.Payload
+	events   events.Handler
+
+	// 1 - activated, serves the requests (atomic)
+	active uint32
+	// 1 - the last health check succeeded (atomic)
+	healthy uint32
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewStandbyPool wraps the pool into the standby, health checks are started immediately
+func NewStandbyPool(p Pool, options ...StandbyOptions) *StandbyPool {
+	sp := &StandbyPool{
+		Pool:     p,
+		interval: defaultStandbyInterval,
+		events:   events.NewEventsHandler(),
+		healthy:  1,
+		stopCh:   make(chan struct{}),
+	}
+
+	for i := 0; i < len(options); i++ {
+		options[i](sp)
+	}
+
+	go sp.watch()
+
+	return sp
+}
+
+// Activate promotes the standby pool to serve the requests, health checks are stopped
+func (sp *StandbyPool) Activate() {
+	if !atomic.CompareAndSwapUint32(&sp.active, 0, 1) {
+		return
+	}
+
+	sp.stop()
+	sp.events.Push(events.PoolEvent{Event: events.EventStandbyActivated, Payload: sp.Pool})
+}
+
+// Active reports whether the pool is activated
+func (sp *StandbyPool) Active() bool {
+	return atomic.LoadUint32(&sp.active) == 1
+}
+
+// Healthy reports whether the last health check succeeded
+func (sp *StandbyPool) Healthy() bool {
+	return atomic.LoadUint32(&sp.healthy) == 1
+}
+
+func (sp *StandbyPool) Exec(rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.Exec(rqs)
+}
+
+func (sp *StandbyPool) ExecWeighted(weight uint64, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_weighted")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.ExecWeighted(weight, rqs)
+}
+
+func (sp *StandbyPool) ExecWithAllocateTimeout(timeout time.Duration, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_with_allocate_timeout")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.ExecWithAllocateTimeout(timeout, rqs)
+}
+
+func (sp *StandbyPool) TryExec(rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_try_exec")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.TryExec(rqs)
+}
+
+func (sp *StandbyPool) ExecDeadline(deadline time.Time, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_deadline")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.ExecDeadline(deadline, rqs)
+}
+
+func (sp *StandbyPool) ExecCached(rqs *payload.Payload, ttl time.Duration) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_cached")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.ExecCached(rqs, ttl)
+}
+
+func (sp *StandbyPool) ExecCachedKey(key string, ttl time.Duration, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_cached_key")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.ExecCachedKey(key, ttl, rqs)
+}
+
+// Destroy stops the health checks and destroys the underlying pool
+func (sp *StandbyPool) Destroy(ctx context.Context) {
+	sp.stop()
+	sp.Pool.Destroy(ctx)
+}
+
+func (sp *StandbyPool) execWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_with_context")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.execWithTTL(ctx, rqs)
+}
+
+func (sp *StandbyPool) execWeightedWithTTL(ctx context.Context, weight uint64, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_exec_weighted_with_context")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.execWeightedWithTTL(ctx, weight, rqs)
+}
+
+func (sp *StandbyPool) tryExecWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("standby_pool_try_exec_with_context")
+	if !sp.Active() {
+		return nil, errors.E(op, ErrStandby)
+	}
+
+	return sp.Pool.tryExecWithTTL(ctx, rqs)
+}
+
+// watch runs the health checks until the pool is activated or destroyed
+func (sp *StandbyPool) watch() {
+	tt := time.NewTicker(sp.interval)
+	defer tt.Stop()
+
+	for {
+		select {
+		case <-sp.stopCh:
+			return
+		case <-tt.C:
+			sp.check()
+		}
+	}
+}
+
+// check runs the health check, failures are pushed as the EventStandbyUnhealthy
+func (sp *StandbyPool) check() {
+	const op = errors.Op("standby_pool_health_check")
+	err := sp.health()
+	if err == nil {
+		atomic.StoreUint32(&sp.healthy, 1)
+		return
+	}
+
+	atomic.StoreUint32(&sp.healthy, 0)
+	sp.events.Push(events.PoolEvent{Event: events.EventStandbyUnhealthy, Payload: sp.Pool, Error: errors.E(op, err)})
+}
+
+// health checks that the workers are ready, and that the ping payload is executed (if set)
+func (sp *StandbyPool) health() error {
+	workers := sp.Pool.Workers()
+	if len(workers) == 0 {
+		return errors.Str("no workers")
+	}
+
+	if sp.ping == nil {
+		for i := 0; i < len(workers); i++ {
+			if !workers[i].State().IsActive() {
+				return errors.Errorf("worker %d is not active (%s)", workers[i].Pid(), workers[i].State().String())
+			}
+		}
+		return nil
+	}
+
+	// bypasses the standby check, the pool doesn't serve the requests, a free worker should be available
+	_, err := sp.Pool.TryExec(sp.ping)
+	return err
+}
+
+func (sp *StandbyPool) stop() {
+	sp.stopOnce.Do(func() {
+		close(sp.stopCh)
+	})
+}
@@ -0,0 +1,66 @@
+package pool_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/pool/internal/testpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StandbyPool(t *testing.T) {
+	p := testpool.New("standby", 1)
+
+	eh := events.NewEventsHandler()
+	unhealthy := int64(0)
+	activated := int64(0)
+	eh.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok {
+			switch ev.Event {
+			case events.EventStandbyUnhealthy:
+				atomic.AddInt64(&unhealthy, 1)
+			case events.EventStandbyActivated:
+				atomic.AddInt64(&activated, 1)
+			}
+		}
+	})
+
+	sp := pool.NewStandbyPool(p, pool.WithStandbyEvents(eh), pool.WithStandbyHealthCheck(time.Millisecond*20, &payload.Payload{Body: []byte("ping")}))
+	assert.False(t, sp.Active())
+	assert.True(t, sp.Healthy())
+
+	_, err := sp.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.True(t, pool.IsErr(err, pool.ErrStandby))
+	_, err = sp.TryExec(&payload.Payload{Body: []byte("hello")})
+	assert.True(t, pool.IsErr(err, pool.ErrStandby))
+	_, err = sp.ExecDeadline(time.Now().Add(time.Second), &payload.Payload{Body: []byte("hello")})
+	assert.True(t, pool.IsErr(err, pool.ErrStandby))
+
+	// failed ping
+	p.SetErr(errors.Str("ping failed"))
+	assert.Eventually(t, func() bool {
+		return !sp.Healthy() && atomic.LoadInt64(&unhealthy) > 0
+	}, time.Second, time.Millisecond*10)
+
+	p.SetErr(nil)
+	assert.Eventually(t, sp.Healthy, time.Second, time.Millisecond*10)
+
+	sp.Activate()
+	sp.Activate()
+	assert.True(t, sp.Active())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&activated))
+
+	rsp, err := sp.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "standby", rsp.String())
+
+	sp.Destroy(context.Background())
+	<-p.Destroyed()
+}
@@ -2,7 +2,11 @@ package pool
 
 import (
 	"context"
+	"fmt"
 	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spiral/errors"
@@ -12,11 +16,22 @@ import (
 	"github.com/spiral/roadrunner/v2/utils"
 	"github.com/spiral/roadrunner/v2/worker"
 	workerWatcher "github.com/spiral/roadrunner/v2/worker_watcher"
+	"go.uber.org/multierr"
 )
 
 // StopRequest can be sent by worker to indicate that restart is required.
 const StopRequest = "{\"stop\":true}"
 
+// defaultResetDebounce is the default debounce window of the OnConfigChange signals
+const defaultResetDebounce = time.Second
+
+// dumpTakeTimeout is the time DumpAllWorkers (and the shutdown payload) waits for a free worker before skipping
+// the rest as busy
+const dumpTakeTimeout = time.Millisecond * 100
+
+// defaultShutdownTimeout is the default timeout of the shutdown payload execution (see WithShutdownPayload)
+const defaultShutdownTimeout = time.Second * 5
+
 // ErrorEncoder encode error or make a decision based on the error type
 type ErrorEncoder func(err error, w worker.BaseProcess) (*payload.Payload, error)
 
@@ -31,8 +46,11 @@ type StaticPool struct {
 	// worker command creator
 	cmd Command
 
-	// creates and connects to stack
-	factory transport.Factory
+	// creates and connects to stack, replaced by the SetFactory
+	factoryMu sync.RWMutex
+	factory   transport.Factory
+	// incremented by the SetFactory
+	factoryGen uint64
 
 	// distributes the events
 	events events.Handler
@@ -46,28 +64,125 @@ type StaticPool struct {
 	// allocate new worker
 	allocator worker.Allocator
 
-	// errEncoder is the default Exec error encoder
+	// errEncoder is the Exec error encoder, the default one wrapped with the kindEncoders unless WithErrorEncoder
 	errEncoder ErrorEncoder
+	// encoders of the specific error kinds (WithKindEncoder)
+	kindEncoders []kindEncoder
+
+	// labels attached to every allocated worker
+	labels map[string]string
+
+	// responses cache used by the ExecCached
+	cache *execCache
+
+	// in-flight requests, used by the CancelAll
+	inflight *inflight
+
+	// ready workers container factory, nil - default
+	container workerWatcher.ContainerFactory
+
+	// wait for all the workers to be ready on Initialize, 0 - don't wait
+	waitReady time.Duration
+	// executed on the first worker before the rest are allocated, nil - no leader
+	leader *payload.Payload
+	// executed on each idle worker on Destroy, nil - no shutdown payload
+	shutdown        *payload.Payload
+	shutdownTimeout time.Duration
+	// executed by the worker health check, nil - the introspect command (see WithHealthCheck)
+	healthPing    *payload.Payload
+	healthTimeout time.Duration
+	health        healthCache
+
+	// debounce window of the OnConfigChange signals
+	resetDebounce time.Duration
+	// exec count milestone callback shared by the workers, see OnExecMilestone
+	milestones *worker.ExecMilestones
+	// closed on Destroy, stops the OnConfigChange goroutines
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// cancels the allocator context on Destroy
+	allocCancel context.CancelFunc
+
+	// custom supervisor constructor (WithSupervisor), nil - the SupervisorConfig one
+	newSupervisor func(p Pool) Supervisor
+	// started custom supervisor, stopped on Destroy
+	supervisor Supervisor
+
+	// persistent debug worker (DebugKeepWorker), nil - not allocated yet, guarded by the debugMu
+	debugMu     sync.Mutex
+	debugWorker worker.SyncWorker
+
+	// Exec middleware (WithMiddleware) and the chain built on Initialize
+	middleware []Middleware
+	execChain  ExecFunc
+	// Exec takes the fast path (see fastExecEnabled)
+	fastExec bool
+
+	// workers allocated by the AllocateEphemeral and not released yet, guarded by the ephemeralMu
+	ephemeralMu sync.Mutex
+	ephemeral   map[worker.SyncWorker]struct{}
+
+	// classifier of the watcher spawn failures (WithSpawnClassifier), nil - all the failures are retried
+	spawnClassifier workerWatcher.SpawnClassifier
+
+	// time source of the supervisor and the watcher (WithClock)
+	clock utils.Clock
+	// Initialize time, the MaxPoolLifetime start
+	started time.Time
+
+	// executions across all the workers, see MaxTotalExecs (atomic)
+	totalExecs uint64
+	// rolling window of the executions, see Throughput
+	throughput *throughput
+	// 1 - the pool lifetime is reached, the pool is being destroyed (atomic)
+	lifetimeEnded uint32
+
+	// 1 - Initialize has not completed the allocation and the watch yet, Exec is rejected (atomic)
+	initializing uint32
+
+	// allocation counters
+	allocFailures    uint64
+	successfulAllocs uint64
+
+	// callers blocked waiting for the free worker (atomic), see WaitingCallers
+	waiting int64
 }
 
 // Initialize creates new worker pool and task multiplexer. StaticPool will initiate with one worker.
 func Initialize(ctx context.Context, cmd Command, factory transport.Factory, cfg *Config, options ...Options) (Pool, error) {
 	const op = errors.Op("static_pool_initialize")
 	if factory == nil {
-		return nil, errors.E(op, errors.Str("no factory initialized"))
+		return nil, errors.E(op, ErrNoFactory)
 	}
+	// validate the user provided values, before the defaults
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
 	cfg.InitDefaults()
 
 	if cfg.Debug {
 		cfg.NumWorkers = 0
+		cfg.MaxWorkers = 0
 		cfg.MaxJobs = 1
 	}
 
 	p := &StaticPool{
-		cfg:     cfg,
-		cmd:     cmd,
-		factory: factory,
-		events:  events.NewEventsHandler(),
+		cfg:      cfg,
+		cmd:      cmd,
+		factory:  factory,
+		events:   events.NewEventsHandler(),
+		cache:    newExecCache(cfg.ExecCacheSize),
+		inflight: newInflight(),
+		stopCh:   make(chan struct{}),
+		clock:    utils.SystemClock(),
+
+		initializing:  1,
+		resetDebounce: defaultResetDebounce,
+		milestones:    &worker.ExecMilestones{},
+		healthTimeout: defaultHealthCheckTimeout,
+		throughput:    newThroughput(cfg.ThroughputWindow),
 	}
 
 	// add pool options
@@ -75,28 +190,72 @@ func Initialize(ctx context.Context, cmd Command, factory transport.Factory, cfg
 		options[i](p)
 	}
 
+	// listeners are wired before any worker is allocated
+	for i := 0; i < len(p.listeners); i++ {
+		p.addListener(p.listeners[i])
+	}
+
+	p.execChain = chainMiddleware(p.execTerminal, p.middleware)
+	p.fastExec = p.fastExecEnabled()
+
 	// set up workers allocator
-	p.allocator = p.newPoolAllocator(ctx, p.cfg.AllocateTimeout, factory, cmd)
+	// allocator context is canceled on Destroy, so the spawn retries stop during the shutdown
+	allocCtx, allocCancel := context.WithCancel(ctx)
+	p.allocCancel = allocCancel
+	p.allocator = p.newPoolAllocator(allocCtx, p.cfg.AllocateTimeout, cmd)
 	// set up workers watcher
-	p.ww = workerWatcher.NewSyncWorkerWatcher(p.allocator, p.cfg.NumWorkers, p.events, p.cfg.AllocateTimeout)
+	wwOptions := []workerWatcher.Options{
+		workerWatcher.WithMaxWorkers(p.cfg.MaxWorkers),
+		workerWatcher.WithStrictTake(*p.cfg.StrictTake),
+		workerWatcher.WithContainerCapacity(p.cfg.ContainerCapacity),
+		workerWatcher.WithContainer(p.container),
+		workerWatcher.WithIdleTimeout(p.cfg.PoolIdleTimeout),
+		workerWatcher.WithReapTimeout(p.cfg.ReapTimeout),
+		workerWatcher.WithSpawnRate(p.cfg.SpawnRate, p.cfg.SpawnBurst),
+		workerWatcher.WithMaxConcurrentSpawns(p.cfg.MaxConcurrentSpawns),
+		workerWatcher.WithClock(p.clock),
+		workerWatcher.WithSpawnClassifier(p.spawnClassifier),
+		workerWatcher.WithGracefulExitCodes(p.cfg.GracefulExitCodes),
+	}
+	if p.cfg.Quarantine != nil {
+		wwOptions = append(wwOptions, workerWatcher.WithQuarantine(p.cfg.Quarantine.Failures, p.cfg.Quarantine.Window, p.cfg.Quarantine.Cooldown))
+	}
+	p.ww = workerWatcher.NewSyncWorkerWatcher(p.allocator, p.cfg.NumWorkers, p.events, p.cfg.AllocateTimeout, wwOptions...)
 
-	// allocate requested number of workers
-	workers, err := p.allocateWorkers(p.cfg.NumWorkers)
+	// allocate requested number of workers and add them to the watcher
+	err = p.boot(ctx)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
-	// add workers to the watcher
-	err = p.ww.Watch(workers)
-	if err != nil {
-		return nil, errors.E(op, err)
+	if p.errEncoder == nil {
+		p.errEncoder = chainEncoders(p.kindEncoders, defaultErrEncoder(p))
+	}
+	// the workers are watched, ready to execute
+	p.started = p.clock.Now()
+	atomic.StoreUint32(&p.initializing, 0)
+
+	if p.waitReady > 0 {
+		err = p.waitWorkersReady(p.waitReady)
+		if err != nil {
+			p.Destroy(ctx)
+			return nil, errors.E(op, err)
+		}
 	}
 
-	p.errEncoder = defaultErrEncoder(p)
+	if p.cfg.MaxPoolLifetime > 0 {
+		go p.watchLifetime()
+	}
+
+	if p.newSupervisor != nil {
+		p.supervisor = p.newSupervisor(p)
+		p.supervisor.Start()
+		return p, nil
+	}
 
 	// if supervised config not nil, guess, that pool wanted to be supervised
 	if cfg.Supervisor != nil {
-		sp := supervisorWrapper(p, p.events, p.cfg.Supervisor)
+		sp := supervisorWrapper(p, p.events, p.cfg.Supervisor, p.cache, p.cfg.RetryPolicy, p.clock)
 		// start watcher timer
 		sp.Start()
 		return sp, nil
@@ -105,15 +264,110 @@ func Initialize(ctx context.Context, cmd Command, factory transport.Factory, cfg
 	return p, nil
 }
 
+// AddListeners registers the pool and the workers events listeners. Listeners are wired by the Initialize after
+// all the options are applied (regardless of the options order) and before any worker is allocated, so the
+// EventWorkerConstruct events of the initial workers are delivered.
 func AddListeners(listeners ...events.Listener) Options {
 	return func(p *StaticPool) {
-		p.listeners = listeners
-		for i := 0; i < len(listeners); i++ {
-			p.addListener(listeners[i])
+		p.listeners = append(p.listeners, listeners...)
+	}
+}
+
+// WithEventsCoalescing collapses identical pool events within the window into a single events.CoalescedEvent.
+// Opt-in, useful for the crash-looping workers flooding the listeners with the same events.
+func WithEventsCoalescing(window time.Duration) Options {
+	return func(p *StaticPool) {
+		p.events = events.NewCoalescingHandler(p.events, window)
+	}
+}
+
+// WithRecentEvents records the pool events in the ring buffer, so the crash handler or the debug endpoint
+// could dump the recent pool activity (re.Snapshot()). Wired as the AddListeners listener.
+func WithRecentEvents(re *events.RecentEvents) Options {
+	return func(p *StaticPool) {
+		p.listeners = append(p.listeners, re.Listen)
+	}
+}
+
+// WithLabels attaches the labels to every allocated worker, labels are included into the worker events and states.
+// Useful to attribute events and stats per tenant when one pool serves multiple tenants.
+func WithLabels(labels map[string]string) Options {
+	return func(p *StaticPool) {
+		p.labels = labels
+	}
+}
+
+// WithLeaderPayload executes the payload on the first allocated worker (e.g. DB migrations) before the rest of
+// the workers are allocated. Initialize fails with the payload error. The leader worker stays in the pool.
+// The execution is not counted toward the MaxJobs.
+func WithLeaderPayload(p *payload.Payload) Options {
+	return func(sp *StaticPool) {
+		sp.leader = controlPayload(p)
+	}
+}
+
+// WithShutdownPayload executes the payload once on each idle worker on Destroy (cleanup hook: flush buffers, close
+// connections), before the workers are stopped. Each execution is bounded by the timeout (5s if 0), failures are
+// pushed as the EventWorkerError events and don't block the Destroy. Executions are not counted toward the MaxJobs.
+func WithShutdownPayload(p *payload.Payload, timeout time.Duration) Options {
+	return func(sp *StaticPool) {
+		sp.shutdown = controlPayload(p)
+		sp.shutdownTimeout = timeout
+		if sp.shutdownTimeout == 0 {
+			sp.shutdownTimeout = defaultShutdownTimeout
 		}
 	}
 }
 
+// WaitReady blocks Initialize until all the workers report StateReady or the timeout elapses
+func WaitReady(timeout time.Duration) Options {
+	return func(p *StaticPool) {
+		p.waitReady = timeout
+	}
+}
+
+// WithContainer sets the ready workers container, e.g. the lock-free ring for the very high request rates:
+// pool.WithContainer(func(capacity uint64) workerWatcher.Vector { return ring.NewRing(capacity) })
+func WithContainer(factory workerWatcher.ContainerFactory) Options {
+	return func(p *StaticPool) {
+		p.container = factory
+	}
+}
+
+// WithResetDebounce sets the debounce window of the OnConfigChange signals, rapid signals within the window trigger a single Reset
+func WithResetDebounce(window time.Duration) Options {
+	return func(p *StaticPool) {
+		p.resetDebounce = window
+	}
+}
+
+// WithSupervisor replaces the default supervisor with the custom one (e.g. the time-of-day or the external signal
+// driven recycling). The constructor receives the pool once the workers are allocated, the supervisor is started
+// by the Initialize and stopped on Destroy. The SupervisorConfig rules are not evaluated.
+func WithSupervisor(newSupervisor func(p Pool) Supervisor) Options {
+	return func(p *StaticPool) {
+		p.newSupervisor = newSupervisor
+	}
+}
+
+// WithClock sets the time source of the supervisor ticks and rules, the watcher allocation retries and the destroy
+// poll (system clock by default). The tests advance the fake clock to trigger the TTL, idle and memory rules
+// deterministically.
+func WithClock(clock utils.Clock) Options {
+	return func(p *StaticPool) {
+		p.clock = clock
+	}
+}
+
+// WithSpawnClassifier sets the classifier of the worker spawn failures: the transient ones are retried during the
+// AllocateTimeout, the permanent ones (e.g. binary not found) are returned immediately. All the failures are retried
+// by default. Initialize allocation is not retried regardless.
+func WithSpawnClassifier(classifier workerWatcher.SpawnClassifier) Options {
+	return func(p *StaticPool) {
+		p.spawnClassifier = classifier
+	}
+}
+
 // AddListener connects event listener to the pool.
 func (sp *StaticPool) addListener(listener events.Listener) {
 	sp.events.AddListener(listener)
@@ -124,82 +378,546 @@ func (sp *StaticPool) GetConfig() interface{} {
 	return sp.cfg
 }
 
+// Config returns the copy of the pool configuration (with the defaults applied), changes are not applied to the pool
+func (sp *StaticPool) Config() *Config {
+	return sp.cfg.clone()
+}
+
 // Workers returns worker list associated with the pool.
 func (sp *StaticPool) Workers() (workers []worker.BaseProcess) {
 	return sp.ww.List()
 }
 
+func (sp *StaticPool) OldestWorker() worker.BaseProcess {
+	return sp.ww.Oldest()
+}
+
+func (sp *StaticPool) NewestWorker() worker.BaseProcess {
+	return sp.ww.Newest()
+}
+
+func (sp *StaticPool) SlotWorker(slot int) worker.BaseProcess {
+	return sp.ww.Slot(slot)
+}
+
+func (sp *StaticPool) DrainWorker(pid int64) error {
+	const op = errors.Op("static_pool_drain_worker")
+	err := sp.ww.Drain(pid)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
 func (sp *StaticPool) RemoveWorker(wb worker.BaseProcess) error {
 	sp.ww.Remove(wb)
 	return nil
 }
 
-// Exec executes provided payload on the worker
+// DumpAllWorkers takes all the free workers from the container (Exec calls are waiting meanwhile), asks them
+// to report their internal state in parallel and aggregates per-pid diagnostics. Busy workers (which can't be
+// taken during the dumpTakeTimeout) are skipped. The workers should support the CONTROL introspect command,
+// a worker which doesn't reply until the ctx is done is returned to the container after the late reply.
+func (sp *StaticPool) DumpAllWorkers(ctx context.Context) (map[int64]map[string]interface{}, error) {
+	const op = errors.Op("static_pool_dump_all_workers")
+	num := len(sp.ww.List())
+	taken := make([]worker.BaseProcess, 0, num)
+	for i := 0; i < num; i++ {
+		// the take deadline is separate, so the busy workers don't eat the introspect deadline
+		takeCtx, cancel := context.WithTimeout(context.Background(), dumpTakeTimeout)
+		w, err := sp.ww.Take(takeCtx)
+		cancel()
+		if err != nil {
+			break
+		}
+		sp.inflight.begin()
+		taken = append(taken, w)
+	}
+
+	var mu sync.Mutex
+	var errs error
+	res := make(map[int64]map[string]interface{}, len(taken))
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(taken))
+	for i := 0; i < len(taken); i++ {
+		go func(w worker.BaseProcess) {
+			defer wg.Done()
+			diag, err := w.(worker.SyncWorker).Introspect(ctx)
+
+			mu.Lock()
+			if err != nil {
+				errs = multierr.Append(errs, errors.E(op, errors.Errorf("pid: %d, error: %v", w.Pid(), err)))
+			} else {
+				if diag == nil {
+					diag = make(map[string]interface{}, 2)
+				}
+				// launch command line and environment for the audit
+				diag["cmdline"] = w.CmdLine()
+				diag["env"] = w.Env()
+				res[w.Pid()] = diag
+			}
+			mu.Unlock()
+
+			if w.State().Value() == worker.StateWorking {
+				// the reply is still pending, release the worker after it arrives
+				go sp.releaseAfterReply(w)
+				return
+			}
+
+			// errored workers are killed on release
+			sp.ww.Release(w)
+			sp.inflight.end()
+		}(taken[i])
+	}
+
+	wg.Wait()
+
+	if len(taken) < num {
+		errs = multierr.Append(errs, errors.E(op, errors.Errorf("%d workers were busy", num-len(taken))))
+	}
+
+	return res, errs
+}
+
+// shutdownWorkers executes the shutdown payload once on each idle worker, busy workers are skipped
+func (sp *StaticPool) shutdownWorkers() {
+	const op = errors.Op("static_pool_shutdown_workers")
+	num := len(sp.ww.List())
+	taken := make([]worker.BaseProcess, 0, num)
+	for i := 0; i < num; i++ {
+		takeCtx, cancel := context.WithTimeout(context.Background(), dumpTakeTimeout)
+		w, err := sp.ww.Take(takeCtx)
+		cancel()
+		if err != nil {
+			break
+		}
+		sp.inflight.begin()
+		taken = append(taken, w)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(taken))
+	for i := 0; i < len(taken); i++ {
+		go func(w worker.BaseProcess) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), sp.shutdownTimeout)
+			defer cancel()
+
+			rsp, err := w.(worker.SyncWorker).ExecWithTTL(ctx, sp.shutdown)
+			if err != nil {
+				sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err), Labels: w.Labels()})
+			}
+			payload.PutPayload(rsp)
+
+			// errored (timed out) workers are killed on release
+			sp.ww.Release(w)
+			sp.inflight.end()
+		}(taken[i])
+	}
+
+	wg.Wait()
+}
+
+// releaseAfterReply releases the worker with the pending introspect reply when it's no longer working, the round-trip
+// registered by the inflight begin ends with it
+func (sp *StaticPool) releaseAfterReply(w worker.BaseProcess) {
+	tt := time.NewTicker(time.Millisecond * 10)
+	defer tt.Stop()
+	defer sp.inflight.end()
+
+	for {
+		select {
+		case <-sp.stopCh:
+			return
+		case <-tt.C:
+			if w.State().Value() != worker.StateWorking {
+				sp.ww.Release(w)
+				return
+			}
+		}
+	}
+}
+
+// waitWorkersReady waits for all the workers to be in the StateReady, returns an error with the not ready workers after the timeout
+func (sp *StaticPool) waitWorkersReady(timeout time.Duration) error {
+	const op = errors.Op("static_pool_wait_ready")
+	tt := time.NewTicker(time.Millisecond * 10)
+	defer tt.Stop()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		notReady := make([]string, 0, 1)
+		workers := sp.ww.List()
+		for i := 0; i < len(workers); i++ {
+			if workers[i].State().Value() != worker.StateReady {
+				notReady = append(notReady, fmt.Sprintf("pid: %d, state: %s", workers[i].Pid(), workers[i].State().String()))
+			}
+		}
+
+		if len(notReady) == 0 {
+			return nil
+		}
+
+		select {
+		case <-tt.C:
+		case <-deadline.C:
+			return errors.E(op, errors.Errorf("workers failed to become ready in %s: %s", timeout, strings.Join(notReady, "; ")))
+		}
+	}
+}
+
+// AllocFailures returns the cumulative number of failed worker allocations
+func (sp *StaticPool) AllocFailures() uint64 {
+	return atomic.LoadUint64(&sp.allocFailures)
+}
+
+// SuccessfulAllocs returns the cumulative number of successful worker allocations
+func (sp *StaticPool) SuccessfulAllocs() uint64 {
+	return atomic.LoadUint64(&sp.successfulAllocs)
+}
+
+// WaitingCallers returns the number of the callers blocked waiting for the free worker (saturation gauge),
+// sustained high value means the pool is undersized. The value at the allocate timeout is reported with the
+// EventNoFreeWorkers (events.NoFreeWorkers payload)
+func (sp *StaticPool) WaitingCallers() int {
+	return int(atomic.LoadInt64(&sp.waiting))
+}
+
+// Exec executes provided payload on the worker, failed idempotent payloads are retried according to the RetryPolicy
 func (sp *StaticPool) Exec(p *payload.Payload) (*payload.Payload, error) {
+	return sp.cfg.RetryPolicy.exec(p, sp.exec)
+}
+
+func (sp *StaticPool) exec(p *payload.Payload) (*payload.Payload, error) {
+	if sp.fastExec {
+		return sp.execFast(p)
+	}
+
+	return sp.execChain(context.Background(), p)
+}
+
+// ExecWeighted executes the payload counting the weight toward the worker MaxJobs instead of 1 (heavy requests
+// recycle the worker sooner), weight 0 is counted as 1
+func (sp *StaticPool) ExecWeighted(weight uint64, p *payload.Payload) (*payload.Payload, error) {
+	ctx := withExecOptions(context.Background(), execOptions{weight: weight})
+	return sp.cfg.RetryPolicy.exec(p, func(p *payload.Payload) (*payload.Payload, error) {
+		return sp.execChain(ctx, p)
+	})
+}
+
+// ExecWithAllocateTimeout executes the payload waiting for the free worker up to the timeout instead of the
+// AllocateTimeout (0 - AllocateTimeout), e.g. per-endpoint capacity wait before shedding. Exec TTL is not changed.
+func (sp *StaticPool) ExecWithAllocateTimeout(timeout time.Duration, p *payload.Payload) (*payload.Payload, error) {
+	if timeout == 0 {
+		timeout = sp.cfg.AllocateTimeout
+	}
+
+	ctx := withExecOptions(context.Background(), execOptions{weight: 1, allocTimeoutSet: true, allocTimeout: timeout})
+	return sp.cfg.RetryPolicy.exec(p, func(p *payload.Payload) (*payload.Payload, error) {
+		return sp.execChain(ctx, p)
+	})
+}
+
+// execStops executes the payload w/o the TTL, stops is the number of the consecutive StopRequest responses.
+// ctx carries only the values (ExecInfo), the deadline and the cancellation are not applied.
+func (sp *StaticPool) execStops(ctx context.Context, p *payload.Payload, weight uint64, stops int) (*payload.Payload, error) {
 	const op = errors.Op("static_pool_exec")
 	if sp.cfg.Debug {
 		return sp.execDebug(p)
 	}
-	ctxGetFree, cancel := context.WithTimeout(context.Background(), sp.cfg.AllocateTimeout)
+	ctxGetFree, cancel := context.WithTimeout(ctx, sp.cfg.AllocateTimeout)
 	defer cancel()
-	w, err := sp.takeWorker(ctxGetFree, op)
+	w, err := sp.takeWorker(ctx, ctxGetFree, op, true)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
+	call := sp.inflight.start(w, nil)
+	start := time.Now()
 	rsp, err := w.(worker.SyncWorker).Exec(p)
+	execInfoFrom(ctx).executed(time.Since(start))
+	sp.countExec(p)
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
 	if err != nil {
 		return sp.errEncoder(err, w)
 	}
 
 	// worker want's to be terminated
 	if len(rsp.Body) == 0 && utils.AsString(rsp.Context) == StopRequest {
+		payload.PutPayload(rsp)
 		sp.stopWorker(w)
-		return sp.Exec(p)
+		if stops+1 >= maxStopRequests {
+			return nil, errors.E(op, ErrStopLoop)
+		}
+		return sp.execStops(ctx, p, weight, stops+1)
 	}
 
-	if sp.cfg.MaxJobs != 0 {
+	if sp.countsMaxJobs(p) {
+		registerWeight(w, weight)
 		sp.checkMaxJobs(w)
-		return rsp, nil
+		return sp.checkEmpty(op, rsp)
 	}
 	// return worker back
 	sp.ww.Release(w)
-	return rsp, nil
+	return sp.checkEmpty(op, rsp)
+}
+
+// ExecDeadline executes provided payload on the worker within the deadline.
+// Acquisition uses the AllocateTimeout capped by the deadline, execution gets the rest of the budget.
+// Be careful, sync with pool.execWithTTL method
+func (sp *StaticPool) ExecDeadline(deadline time.Time, p *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("static_pool_exec_deadline")
+	if !time.Now().Before(deadline) {
+		return nil, errors.E(op, errors.ExecTTL)
+	}
+
+	ctx, cancel := context.WithDeadline(withExecOptions(context.Background(), execOptions{weight: 1, deadline: deadline}), deadline)
+	defer cancel()
+	return sp.execChain(ctx, p)
+}
+
+// execDeadline executes the payload within the deadline, ctx carries only the values (ExecInfo)
+func (sp *StaticPool) execDeadline(parent context.Context, deadline time.Time, p *payload.Payload, stops int) (*payload.Payload, error) {
+	const op = errors.Op("static_pool_exec_deadline")
+	if !time.Now().Before(deadline) {
+		return nil, errors.E(op, errors.ExecTTL)
+	}
+
+	ctx, cancel := context.WithDeadline(detachedContext{parent}, deadline)
+	defer cancel()
+
+	if sp.cfg.Debug {
+		return sp.execDebugWithTTL(ctx, p)
+	}
+
+	// acquisition can't take more than the whole budget (the deadline caps the alloc timeout)
+	ctxAlloc, cancelAlloc := context.WithTimeout(ctx, sp.cfg.AllocateTimeout)
+	defer cancelAlloc()
+	w, err := sp.takeWorker(ctx, ctxAlloc, op, true)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// worker acquired, but there is no time left for the execution
+	if !time.Now().Before(deadline) {
+		sp.ww.Release(w)
+		return nil, errors.E(op, errors.ExecTTL)
+	}
+
+	ctx, cancelExec := context.WithCancel(ctx)
+	defer cancelExec()
+
+	call := sp.inflight.start(w, cancelExec)
+	rsp, err := w.(worker.SyncWorker).ExecWithTTL(ctx, p)
+	sp.countExec(p)
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
+	if err != nil {
+		return sp.errEncoder(err, w)
+	}
+
+	// worker want's to be terminated
+	if len(rsp.Body) == 0 && utils.AsString(rsp.Context) == StopRequest {
+		payload.PutPayload(rsp)
+		sp.stopWorker(w)
+		if stops+1 >= maxStopRequests {
+			return nil, errors.E(op, ErrStopLoop)
+		}
+		return sp.execDeadline(parent, deadline, p, stops+1)
+	}
+
+	if sp.countsMaxJobs(p) {
+		sp.checkMaxJobs(w)
+		return sp.checkEmpty(op, rsp)
+	}
+
+	// return worker back
+	sp.ww.Release(w)
+	return sp.checkEmpty(op, rsp)
+}
+
+// InFlight returns the in-flight requests, request ID -> worker pid
+func (sp *StaticPool) InFlight() map[uint64]int64 {
+	return sp.inflight.list()
+}
+
+// WaitIdle blocks until there are no in-flight requests (all the workers are idle) or the ctx is done. Unlike the
+// DrainWorker or the Destroy it doesn't affect the workers or reject the requests, it only observes the completion
+// of the current work, so it returns once the pool is idle even if new requests arrive later. The pool own
+// round-trips (health checks, DumpAllWorkers, the shutdown payloads) and the debug mode execs are waited for too.
+func (sp *StaticPool) WaitIdle(ctx context.Context) error {
+	const op = errors.Op("static_pool_wait_idle")
+	select {
+	case <-sp.inflight.done():
+		return nil
+	case <-ctx.Done():
+		return errors.E(op, errors.TimeOut, ctx.Err())
+	}
+}
+
+// CancelAll cancels all the in-flight requests and kills the workers processing them
+func (sp *StaticPool) CancelAll() {
+	const op = errors.Op("static_pool_cancel_all")
+	calls := sp.inflight.cancelAll()
+	for i := 0; i < len(calls); i++ {
+		c := calls[i]
+		c.w.State().Set(worker.StateInvalid)
+		if c.cancel != nil {
+			c.cancel()
+		}
+		// the watcher will allocate a replacement after the process exit
+		_ = c.w.Kill()
+		sp.events.Push(events.PoolEvent{Event: events.EventExecCanceled, Payload: c.w, Error: errors.E(op, errors.Errorf("request %d canceled", c.id))})
+	}
+}
+
+// ExecCached executes provided payload on the worker, successful response is cached for the ttl keyed on the payload hash
+func (sp *StaticPool) ExecCached(p *payload.Payload, ttl time.Duration) (*payload.Payload, error) {
+	return sp.cache.execCached(payloadKey(p), ttl, p, sp.Exec)
+}
+
+// ExecCachedKey executes provided payload on the worker, successful response is cached for the ttl keyed on the
+// caller provided key (e.g. tenant+resource), so the equivalent payloads serialized differently share the response
+func (sp *StaticPool) ExecCachedKey(key string, ttl time.Duration, p *payload.Payload) (*payload.Payload, error) {
+	return sp.cache.execCached(callerKey(key), ttl, p, sp.Exec)
+}
+
+// Invalidate drops the response cached by the ExecCachedKey with the key (e.g. on writes)
+func (sp *StaticPool) Invalidate(key string) {
+	sp.cache.invalidate(callerKey(key))
+}
+
+// CacheHits returns number of the ExecCached and ExecCachedKey cache hits
+func (sp *StaticPool) CacheHits() uint64 {
+	return atomic.LoadUint64(&sp.cache.hits)
+}
+
+// CacheMisses returns number of the ExecCached and ExecCachedKey cache misses
+func (sp *StaticPool) CacheMisses() uint64 {
+	return atomic.LoadUint64(&sp.cache.misses)
+}
+
+// TryExec executes provided payload on the worker only if there is a free worker, otherwise
+// errors.NoFreeWorkers returned immediately without waiting for the AllocateTimeout (and w/o the EventNoFreeWorkers)
+func (sp *StaticPool) TryExec(p *payload.Payload) (*payload.Payload, error) {
+	return sp.tryExecWithTTL(context.Background(), p)
 }
 
-// Be careful, sync with pool.Exec method
 func (sp *StaticPool) execWithTTL(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+	return sp.execChain(ctx, p)
+}
+
+func (sp *StaticPool) execWeightedWithTTL(ctx context.Context, weight uint64, p *payload.Payload) (*payload.Payload, error) {
+	return sp.execChain(withExecOptions(ctx, execOptions{weight: weight}), p)
+}
+
+func (sp *StaticPool) tryExecWithTTL(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+	return sp.execChain(withExecOptions(ctx, execOptions{weight: 1, allocTimeoutSet: true}), p)
+}
+
+// execContext derives the worker execution ctx, the worker TTL is taken from the ctx deadline when present,
+// from the configured exec TTL otherwise. The ctx cancellation is not propagated unless the CancelWorkerOnCtxDone.
+func (sp *StaticPool) execContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !sp.cfg.CancelWorkerOnCtxDone {
+		ctx = detachedContext{ctx}
+		if ok {
+			return context.WithDeadline(ctx, deadline)
+		}
+	}
+
+	if !ok && sp.cfg.Supervisor != nil && sp.cfg.Supervisor.ExecTTL != 0 {
+		return context.WithTimeout(ctx, sp.cfg.Supervisor.ExecTTL)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// detachedContext keeps the values of the parent ctx (e.g. the ExecInfo), but not its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (dc detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (dc detachedContext) Done() <-chan struct{}             { return nil }
+func (dc detachedContext) Err() error                        { return nil }
+func (dc detachedContext) Value(key interface{}) interface{} { return dc.parent.Value(key) }
+
+// canceledExec handles the execution aborted by the caller ctx cancellation (CancelWorkerOnCtxDone), the worker is
+// already killed by the ExecWithTTL and replaced by the watcher. It returns false if the ctx is not canceled (e.g. the
+// deadline is exceeded, which is the exec TTL).
+func (sp *StaticPool) canceledExec(ctx context.Context, w worker.BaseProcess) bool {
+	if !sp.cfg.CancelWorkerOnCtxDone || ctx.Err() != context.Canceled {
+		return false
+	}
+
+	w.State().SetReason(worker.StateInvalid, "ctx canceled")
+	_ = w.Kill()
+	return true
+}
+
+// execWithAllocTimeout waits for the free worker up to the allocTimeout, 0 - don't wait. weight is counted toward
+// the MaxJobs, stops is the number of the consecutive StopRequest responses
+// Be careful, sync with pool.Exec method
+func (sp *StaticPool) execWithAllocTimeout(ctx context.Context, p *payload.Payload, allocTimeout time.Duration, weight uint64, stops int) (*payload.Payload, error) {
 	const op = errors.Op("static_pool_exec_with_context")
 	if sp.cfg.Debug {
 		return sp.execDebugWithTTL(ctx, p)
 	}
 
-	ctxAlloc, cancel := context.WithTimeout(context.Background(), sp.cfg.AllocateTimeout)
+	// single ctx governs both the acquisition and the execution, the ctx deadline caps the alloc timeout
+	ctxAlloc, cancel := context.WithTimeout(ctx, allocTimeout)
 	defer cancel()
-	w, err := sp.takeWorker(ctxAlloc, op)
+	// the try path (0 alloc timeout) doesn't push the EventNoFreeWorkers, the caller handles the error (e.g. fallback)
+	w, err := sp.takeWorker(ctx, ctxAlloc, op, allocTimeout != 0)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
+	callerCtx := ctx
+	ctx, cancelExec := sp.execContext(ctx)
+	defer cancelExec()
+
+	call := sp.inflight.start(w, cancelExec)
+	start := time.Now()
 	rsp, err := w.(worker.SyncWorker).ExecWithTTL(ctx, p)
+	execInfoFrom(ctx).executed(time.Since(start))
+	sp.countExec(p)
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
 	if err != nil {
+		if sp.canceledExec(callerCtx, w) {
+			return nil, errors.E(op, callerCtx.Err())
+		}
 		return sp.errEncoder(err, w)
 	}
 
 	// worker want's to be terminated
 	if len(rsp.Body) == 0 && utils.AsString(rsp.Context) == StopRequest {
+		payload.PutPayload(rsp)
 		sp.stopWorker(w)
-		return sp.execWithTTL(ctx, p)
+		if stops+1 >= maxStopRequests {
+			return nil, errors.E(op, ErrStopLoop)
+		}
+		return sp.execWithAllocTimeout(callerCtx, p, allocTimeout, weight, stops+1)
 	}
 
-	if sp.cfg.MaxJobs != 0 {
+	if sp.countsMaxJobs(p) {
+		registerWeight(w, weight)
 		sp.checkMaxJobs(w)
-		return rsp, nil
+		return sp.checkEmpty(op, rsp)
 	}
 
 	// return worker back
 	sp.ww.Release(w)
-	return rsp, nil
+	return sp.checkEmpty(op, rsp)
 }
 
 func (sp *StaticPool) stopWorker(w worker.BaseProcess) {
@@ -207,40 +925,279 @@ func (sp *StaticPool) stopWorker(w worker.BaseProcess) {
 	w.State().Set(worker.StateInvalid)
 	err := w.Stop()
 	if err != nil {
-		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err)})
+		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err), Labels: w.Labels()})
+	}
+}
+
+// softJobPayload returns the error payload sent by the worker with the errors.SoftJob error (worker.SoftJobError),
+// nil if there is none
+func softJobPayload(err error) *payload.Payload {
+	if sj, ok := worker.AsSoftJobError(err); ok {
+		return sj.Payload
 	}
+
+	return nil
+}
+
+// checkEmpty returns the errors.SoftJob error for the response with the empty body if the TreatEmptyResponseAsError
+// is set, the response is returned as is otherwise (default)
+func (sp *StaticPool) checkEmpty(op errors.Op, rsp *payload.Payload) (*payload.Payload, error) {
+	if sp.cfg.TreatEmptyResponseAsError && len(rsp.Body) == 0 {
+		return nil, errors.E(op, errors.SoftJob, errors.Str("worker returned an empty response body"))
+	}
+
+	return rsp, nil
+}
+
+// controlPayload returns the copy of the payload marked as the control one (not counted toward the MaxJobs),
+// the caller's payload is not modified
+func controlPayload(p *payload.Payload) *payload.Payload {
+	if p == nil {
+		return nil
+	}
+
+	cp := *p
+	cp.Control = true
+	return &cp
+}
+
+// registerWeight counts the extra weight of the execution, the execution itself is counted by the worker
+func registerWeight(w worker.BaseProcess, weight uint64) {
+	if weight > 1 {
+		w.State().AddWeight(weight - 1)
+	}
+}
+
+// countsMaxJobs reports whether the execution of the payload is counted toward the MaxJobs, the control payloads
+// (payload.Payload.Control) are not counted and are not checked
+func (sp *StaticPool) countsMaxJobs(p *payload.Payload) bool {
+	return sp.cfg.MaxJobs != MaxJobsUnlimited && !p.Control
+}
+
+// maxJobsReached reports whether the worker should be recycled by the number of executions, never for the
+// MaxJobsUnlimited. Heavy executions are counted with their weight.
+func (sp *StaticPool) maxJobsReached(w worker.BaseProcess) bool {
+	return sp.cfg.MaxJobs != MaxJobsUnlimited && w.State().WeightedExecs() >= sp.cfg.MaxJobs
 }
 
-// checkMaxJobs check for worker number of executions and kill workers if that number more than sp.cfg.MaxJobs
+// checkMaxJobs check for worker number of executions and replaces the worker in the background (warm replacement)
+// if that number more than sp.cfg.MaxJobs, so no caller pays the kill-and-retry cost. The worker is released otherwise.
 //go:inline
 func (sp *StaticPool) checkMaxJobs(w worker.BaseProcess) {
-	if w.State().NumExecs() >= sp.cfg.MaxJobs {
+	if sp.maxJobsReached(w) {
 		w.State().Set(worker.StateMaxJobsReached)
-		sp.ww.Release(w)
+		// planned recycle, the replacement is allocated before stopping the worker
+		go func() {
+			_ = sp.replaceWorker(w)
+		}()
 		return
 	}
 
 	sp.ww.Release(w)
 }
 
-func (sp *StaticPool) takeWorker(ctxGetFree context.Context, op errors.Op) (worker.BaseProcess, error) {
+// replaceWorker recycles the worker using the warm replacement, if the replacement can't be allocated,
+// the worker is stopped and the watcher allocates a new one after the process exit
+func (sp *StaticPool) replaceWorker(w worker.BaseProcess) error {
+	const op = errors.Op("static_pool_replace_worker")
+	err := sp.ww.Replace(w)
+	if err != nil {
+		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err), Labels: w.Labels()})
+		w.State().Set(worker.StateInvalid)
+		errS := w.Stop()
+		if errS != nil {
+			_ = w.Kill()
+		}
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// takeWorker takes the free worker, if there are no free workers during the ctxGetFree (derived from the caller ctx),
+// errors.NoFreeWorkers (ErrOverloaded) is returned and the EventNoFreeWorkers is pushed (if notify). The caller ctx
+// done before the ctxGetFree is reported as errors.TimeOut, the destroyed pool as errors.WatcherStopped
+// (ErrPoolDraining). The watcher error is kept in all the cases.
+func (sp *StaticPool) takeWorker(ctx, ctxGetFree context.Context, op errors.Op, notify bool) (worker.BaseProcess, error) {
+	if atomic.LoadUint32(&sp.initializing) == 1 {
+		return nil, errors.E(op, ErrPoolInitializing)
+	}
+
+	start := time.Now()
+	var w worker.BaseProcess
+	var err error
+	atomic.AddInt64(&sp.waiting, 1)
 	// Get function consumes context with timeout
-	w, err := sp.ww.Take(ctxGetFree)
+	if sp.cfg.EagerAllocate {
+		w, err = sp.ww.TakeOrAllocate(ctxGetFree)
+	} else {
+		w, err = sp.ww.Take(ctxGetFree)
+	}
+	// number of the waiting callers including this one
+	waiting := atomic.AddInt64(&sp.waiting, -1) + 1
 	if err != nil {
-		// if the error is of kind NoFreeWorkers, it means, that we can't get worker from the stack during the allocate timeout
-		if errors.Is(errors.NoFreeWorkers, err) {
-			sp.events.Push(events.PoolEvent{Event: events.EventNoFreeWorkers, Error: errors.E(op, err)})
+		switch {
+		// the pool is destroyed
+		case errors.Is(errors.WatcherStopped, err):
+			return nil, errors.E(op, errors.WatcherStopped, multierr.Combine(ErrPoolDraining, err))
+		// the caller gave up (canceled or its deadline is before the allocate timeout), not the capacity exhaustion
+		case ctx.Err() != nil:
+			return nil, errors.E(op, errors.TimeOut, multierr.Combine(ctx.Err(), err))
+		// we can't get worker from the stack during the allocate timeout
+		case errors.Is(errors.NoFreeWorkers, err):
+			if notify {
+				sp.events.Push(events.PoolEvent{
+					Event:   events.EventNoFreeWorkers,
+					Payload: events.NoFreeWorkers{WaitingCallers: int(waiting)},
+					Error:   errors.E(op, err),
+				})
+			}
+			return nil, errors.E(op, errors.NoFreeWorkers, multierr.Combine(ErrOverloaded, err))
+		default:
 			return nil, errors.E(op, err)
 		}
-		// else if err not nil - return error
-		return nil, errors.E(op, err)
 	}
+
+	execInfoFrom(ctxGetFree).acquired(w.Pid(), time.Since(start))
 	return w, nil
 }
 
+// SetNumWorkers scales the number of workers up or down, up to the container capacity
+func (sp *StaticPool) SetNumWorkers(num uint64) error {
+	_, err := sp.SetNumWorkersPolicy(num, workerWatcher.ScaleDownImmediate)
+	return err
+}
+
+// SetNumWorkersPolicy scales the number of workers up or down, up to the container capacity. ScaleDownImmediate waits
+// for the busy workers during the AllocateTimeout, ScaleDownGraceful returns immediately, the busy excess workers are
+// removed once they finish the current request (the Deferred number).
+func (sp *StaticPool) SetNumWorkersPolicy(num uint64, policy workerWatcher.ScaleDownPolicy) (workerWatcher.ScaleResult, error) {
+	const op = errors.Op("static_pool_set_num_workers")
+	if sp.cfg.Debug {
+		return workerWatcher.ScaleResult{}, errors.E(op, errors.Str("can't scale the pool in the debug mode"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sp.cfg.AllocateTimeout)
+	defer cancel()
+
+	res, err := sp.ww.SetNumWorkersPolicy(ctx, num, policy)
+	if err != nil {
+		return res, errors.E(op, err)
+	}
+
+	return res, nil
+}
+
+// Reset replaces all the workers one by one using the warm replacement, so the pool capacity never dips.
+// Workers in the middle of the request are killed after the request is completed.
+func (sp *StaticPool) Reset(ctx context.Context) error {
+	const op = errors.Op("static_pool_reset")
+	if sp.cfg.Debug && sp.cfg.DebugKeepWorker {
+		sp.resetDebugWorker()
+	}
+
+	workers := sp.ww.List()
+	for i := 0; i < len(workers); i++ {
+		if ctx.Err() != nil {
+			return errors.E(op, errors.TimeOut, ctx.Err())
+		}
+
+		err := sp.ww.Replace(workers[i])
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+// RecycleByLabel replaces the workers with the label key=value one by one using the warm replacement.
+// Free workers are marked as StateMaxJobsReached, so they are not taken while being replaced.
+func (sp *StaticPool) RecycleByLabel(key, value string) error {
+	const op = errors.Op("static_pool_recycle_by_label")
+	var errs error
+	workers := sp.ww.List()
+	for i := 0; i < len(workers); i++ {
+		if v, ok := workers[i].Labels()[key]; !ok || v != value {
+			continue
+		}
+
+		// working workers are detected by the replacement to be killed after the request
+		if workers[i].State().Value() != worker.StateWorking {
+			workers[i].State().Set(worker.StateMaxJobsReached)
+		}
+
+		err := sp.replaceWorker(workers[i])
+		if err != nil {
+			errs = multierr.Append(errs, errors.E(op, err))
+		}
+	}
+
+	return errs
+}
+
+// OnExecMilestone sets the callback called each time the worker NumExecs reaches the multiple of every (e.g. to adjust
+// the aging workers), it replaces the previous one, every 0 or nil fn removes it. The callback is dispatched in its own
+// goroutine and doesn't block the Exec, it should be safe for the concurrent use.
+func (sp *StaticPool) OnExecMilestone(every uint64, fn func(w worker.BaseProcess, count uint64)) {
+	sp.milestones.Set(every, fn)
+}
+
+// OnConfigChange triggers the rolling Reset whenever the channel signals, rapid signals are coalesced within the
+// debounce window (see WithResetDebounce). Stops when the channel is closed or the pool is destroyed.
+func (sp *StaticPool) OnConfigChange(ch <-chan struct{}) {
+	go func() {
+		const op = errors.Op("static_pool_on_config_change")
+		var debounce <-chan time.Time
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					return
+				}
+				// restart the debounce window
+				debounce = time.After(sp.resetDebounce)
+			case <-debounce:
+				debounce = nil
+				sp.events.Push(events.PoolEvent{Event: events.EventPoolRestart, Payload: sp})
+				ctx, cancel := context.WithTimeout(context.Background(), sp.cfg.AllocateTimeout)
+				err := sp.Reset(ctx)
+				cancel()
+				if err != nil {
+					sp.events.Push(events.PoolEvent{Event: events.EventSupervisorError, Error: errors.E(op, err)})
+				}
+			case <-sp.stopCh:
+				return
+			}
+		}
+	}()
+}
+
 // Destroy all underlying stack (but let them complete the task).
 func (sp *StaticPool) Destroy(ctx context.Context) {
-	sp.ww.Destroy(ctx)
+	const op = errors.Op("static_pool_destroy")
+	sp.stopOnce.Do(func() {
+		close(sp.stopCh)
+		if sp.supervisor != nil {
+			sp.supervisor.Stop()
+		}
+		if sp.cfg.Debug && sp.cfg.DebugKeepWorker {
+			sp.resetDebugWorker()
+		}
+		sp.destroyEphemerals()
+		if sp.shutdown != nil {
+			sp.shutdownWorkers()
+		}
+		sp.allocCancel()
+	})
+	err := sp.ww.Destroy(ctx)
+	if err != nil {
+		sp.events.Push(events.PoolEvent{Event: events.EventPoolError, Error: errors.E(op, err)})
+	}
+
+	if ch, ok := sp.events.(*events.CoalescingHandler); ok {
+		ch.Stop()
+	}
 }
 
 func defaultErrEncoder(sp *StaticPool) ErrorEncoder {
@@ -250,39 +1207,33 @@ func defaultErrEncoder(sp *StaticPool) ErrorEncoder {
 		switch {
 		case errors.Is(errors.ExecTTL, err):
 			sp.events.Push(events.PoolEvent{Event: events.EventExecTTL, Error: errors.E(op, err)})
-			w.State().Set(worker.StateInvalid)
+			w.State().SetReason(worker.StateInvalid, "exec ttl")
+			// worker might be still stuck executing the request (it ignores the deadline), kill it
+			// the watcher will allocate a replacement after the process exit
+			_ = w.Kill()
 			return nil, err
 
 		case errors.Is(errors.SoftJob, err):
-			sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err)})
-
-			// if max jobs exceed
-			if sp.cfg.MaxJobs != 0 && w.State().NumExecs() >= sp.cfg.MaxJobs {
-				// mark old as invalid and stop
-				w.State().Set(worker.StateInvalid)
-				errS := w.Stop()
-				if errS != nil {
-					return nil, errors.E(op, errors.SoftJob, errors.Errorf("err: %v\nerrStop: %v", err, errS))
-				}
-
-				return nil, err
-			}
+			sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err), Labels: w.Labels()})
+			// the worker error details (if any) are returned alongside the error
+			rsp := softJobPayload(err)
 
-			// soft jobs errors are allowed, just put the worker back
-			sp.ww.Release(w)
+			// soft jobs errors are allowed, the worker is put back, or replaced in the background if max jobs exceed,
+			// so the caller doesn't wait for the worker to stop
+			sp.checkMaxJobs(w)
 
-			return nil, err
+			return rsp, err
 		case errors.Is(errors.Network, err):
 			// in case of network error, we can't stop the worker, we should kill it
-			w.State().Set(worker.StateInvalid)
-			sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err)})
+			w.State().SetReason(worker.StateInvalid, "network error")
+			sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: w, Payload: errors.E(op, err), Labels: w.Labels()})
 
 			// kill the worker instead of sending net packet to it
 			_ = w.Kill()
 
 			return nil, err
 		default:
-			w.State().Set(worker.StateInvalid)
+			w.State().SetReason(worker.StateInvalid, "exec error")
 			sp.events.Push(events.PoolEvent{Event: events.EventWorkerDestruct, Payload: w})
 			// stop the worker, worker here might be in the broken state (network)
 			errS := w.Stop()
@@ -295,17 +1246,40 @@ func defaultErrEncoder(sp *StaticPool) ErrorEncoder {
 	}
 }
 
-func (sp *StaticPool) newPoolAllocator(ctx context.Context, timeout time.Duration, factory transport.Factory, cmd func() *exec.Cmd) worker.Allocator {
+func (sp *StaticPool) newPoolAllocator(ctx context.Context, timeout time.Duration, cmd func() *exec.Cmd) worker.Allocator {
 	return func() (worker.SyncWorker, error) {
+		const op = errors.Op("static_pool_allocator")
 		ctxT, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
+		// the factory might be replaced during the spawn, the worker is attributed to the one which spawned it
+		factory, gen := sp.currentFactory()
 		w, err := factory.SpawnWorkerWithTimeout(ctxT, cmd(), sp.listeners...)
 		if err != nil {
+			// pool is shutting down, not a spawn failure
+			if ctx.Err() != nil {
+				return nil, errors.E(op, errors.WatcherStopped, ctx.Err())
+			}
+			atomic.AddUint64(&sp.allocFailures, 1)
+			return nil, err
+		}
+
+		if sp.cfg.ReadyTimeout > 0 && !awaitReady(ctx, w, sp.cfg.ReadyTimeout) {
+			// spawned, but never ready, should not get into the container
+			_ = w.Kill()
+			_ = w.Wait()
+			if ctx.Err() != nil {
+				return nil, errors.E(op, errors.WatcherStopped, ctx.Err())
+			}
+			atomic.AddUint64(&sp.allocFailures, 1)
+			err = errors.E(op, errors.WorkerAllocate, errors.Errorf("worker %d is spawned, but not ready within %s", w.Pid(), sp.cfg.ReadyTimeout))
+			sp.events.Push(events.PoolEvent{Event: events.EventWorkerNotReady, Payload: w, Error: err})
 			return nil, err
 		}
+		atomic.AddUint64(&sp.successfulAllocs, 1)
 
 		// wrap sync worker
-		sw := worker.From(w)
+		sw := worker.From(w, worker.WithChecksums(sp.cfg.VerifyChecksums), worker.WithCorrelationIDs(sp.cfg.VerifyCorrelationIDs), worker.WithResponseReadTimeout(sp.cfg.ResponseReadTimeout), worker.WithSyncJournal(sp.cfg.WorkerJournal), worker.WithSyncStderrBuffer(sp.cfg.StderrBuffer), worker.WithSyncLabels(sp.labels), worker.WithSyncRedactedEnv(sp.cfg.RedactEnv...), worker.WithExecMilestones(sp.milestones), worker.WithSyncTransport(transportName(factory)))
+		sw.SetAttachment(factoryKey{}, gen)
 
 		sp.events.Push(events.PoolEvent{
 			Event:   events.EventWorkerConstruct,
@@ -315,48 +1289,225 @@ func (sp *StaticPool) newPoolAllocator(ctx context.Context, timeout time.Duratio
 	}
 }
 
+// workerReadyPoll is the interval of the spawned worker state checks
+const workerReadyPoll = time.Millisecond * 10
+
+// awaitReady waits for the spawned worker to reach the StateReady up to the timeout
+func awaitReady(ctx context.Context, w worker.BaseProcess, timeout time.Duration) bool {
+	if w.State().Value() == worker.StateReady {
+		return true
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tt := time.NewTicker(workerReadyPoll)
+	defer tt.Stop()
+
+	for {
+		select {
+		case <-tt.C:
+			if w.State().Value() == worker.StateReady {
+				return true
+			}
+		case <-deadline.C:
+			return w.State().Value() == worker.StateReady
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
 // execDebug used when debug mode was not set and exec_ttl is 0
 func (sp *StaticPool) execDebug(p *payload.Payload) (*payload.Payload, error) {
 	const op = errors.Op("static_pool_exec_debug")
+	if atomic.LoadUint32(&sp.initializing) == 1 {
+		return nil, errors.E(op, ErrPoolInitializing)
+	}
+
+	if sp.cfg.DebugKeepWorker {
+		return sp.execDebugKept(context.Background(), p)
+	}
+
 	sw, err := sp.allocator()
 	if err != nil {
 		return nil, err
 	}
 
 	// redirect call to the workers' exec method (without ttl)
+	call := sp.inflight.start(sw, nil)
 	r, err := sw.Exec(p)
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
 	if err != nil {
-		return nil, errors.E(op, err)
+		return softJobPayload(err), errors.E(op, err)
 	}
 
 	// destroy the worker
 	sw.State().Set(worker.StateDestroyed)
 	err = sw.Kill()
 	if err != nil {
-		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: sw, Payload: err})
+		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: sw, Payload: err, Labels: sw.Labels()})
 		return nil, errors.E(op, err)
 	}
 
-	return r, nil
+	return sp.checkEmpty(op, r)
 }
 
 // execDebugWithTTL used when user set debug mode and exec_ttl
 func (sp *StaticPool) execDebugWithTTL(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("static_pool_exec_debug_with_ttl")
+	if sp.cfg.DebugKeepWorker {
+		return sp.execDebugKept(ctx, p)
+	}
+
 	sw, err := sp.allocator()
 	if err != nil {
 		return nil, err
 	}
 
 	// redirect call to the worker with TTL
+	call := sp.inflight.start(sw, nil)
 	r, err := sw.ExecWithTTL(ctx, p)
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
 	if stopErr := sw.Stop(); stopErr != nil {
-		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: sw, Payload: err})
+		sp.events.Push(events.WorkerEvent{Event: events.EventWorkerError, Worker: sw, Payload: err, Labels: sw.Labels()})
+	}
+	if err != nil {
+		return softJobPayload(err), err
 	}
 
-	return r, err
+	return sp.checkEmpty(op, r)
+}
+
+// execDebugKept executes the payload on the persistent debug worker (DebugKeepWorker), ctx w/o the deadline and
+// the cancellation is executed w/o the TTL
+func (sp *StaticPool) execDebugKept(ctx context.Context, p *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("static_pool_exec_debug_kept")
+	sp.debugMu.Lock()
+	defer sp.debugMu.Unlock()
+
+	// allocated on the first call, or after the previous one is gone (exec TTL, crash)
+	if sp.debugWorker == nil || sp.debugWorker.State().Value() != worker.StateReady {
+		sp.stopDebugWorker()
+		sw, err := sp.allocator()
+		if err != nil {
+			return nil, err
+		}
+		sp.debugWorker = sw
+	}
+
+	var r *payload.Payload
+	var err error
+	call := sp.inflight.start(sp.debugWorker, nil)
+	if ctx.Done() == nil {
+		r, err = sp.debugWorker.Exec(p)
+	} else {
+		r, err = sp.debugWorker.ExecWithTTL(ctx, p)
+	}
+	if sp.inflight.finish(call) {
+		return nil, errors.E(op, ErrRequestCanceled)
+	}
+	if err != nil {
+		return softJobPayload(err), errors.E(op, err)
+	}
+
+	return sp.checkEmpty(op, r)
+}
+
+// resetDebugWorker stops the persistent debug worker, the next request allocates the fresh one.
+// Waits for the request in progress.
+func (sp *StaticPool) resetDebugWorker() {
+	sp.debugMu.Lock()
+	sp.stopDebugWorker()
+	sp.debugMu.Unlock()
+}
+
+// stopDebugWorker stops the persistent debug worker, should be called under the debugMu
+func (sp *StaticPool) stopDebugWorker() {
+	if sp.debugWorker == nil {
+		return
+	}
+
+	err := sp.debugWorker.Stop()
+	if err != nil {
+		_ = sp.debugWorker.Kill()
+	}
+	sp.debugWorker = nil
+}
+
+// allocatePool allocates the required number of workers. With the PreflightCheck or the WithLeaderPayload the first
+// worker is allocated alone: it's health checked (preflight) and/or executes the leader payload, the rest of the
+// workers are allocated after it succeeds. On failure the allocated workers are killed.
+func (sp *StaticPool) allocatePool(numWorkers uint64) ([]worker.BaseProcess, error) {
+	const op = errors.Op("static_pool_allocate_pool")
+	if numWorkers == 0 || (!sp.cfg.PreflightCheck && sp.leader == nil) {
+		return sp.allocateWorkers(numWorkers)
+	}
+
+	var w worker.SyncWorker
+	var err error
+	if sp.cfg.PreflightCheck {
+		w, err = sp.preflight()
+	} else {
+		w, err = sp.allocator()
+	}
+	if err != nil {
+		return nil, errors.E(op, errors.WorkerAllocate, err)
+	}
+
+	if sp.leader != nil {
+		var rsp *payload.Payload
+		rsp, err = w.Exec(sp.leader)
+		if err != nil {
+			killWorkers([]worker.BaseProcess{w})
+			return nil, errors.E(op, errors.Errorf("leader payload failed: %v", err))
+		}
+		payload.PutPayload(rsp)
+	}
+
+	workers, err := sp.allocateWorkers(numWorkers - 1)
+	if err != nil {
+		killWorkers([]worker.BaseProcess{w})
+		return nil, err
+	}
+
+	return append([]worker.BaseProcess{w}, workers...), nil
+}
+
+// preflight spawns a single worker and round-trips the health check on it (the WithHealthCheck ping or the CONTROL
+// introspect), so the wrong command fails with one clear error instead of the NumWorkers allocation errors
+func (sp *StaticPool) preflight() (worker.SyncWorker, error) {
+	const op = errors.Op("static_pool_preflight")
+	w, err := sp.allocator()
+	if err != nil {
+		return nil, errors.E(op, errors.WorkerAllocate, errors.Errorf("preflight check failed, worker can't be spawned: %v", err))
+	}
+
+	if w.State().Value() != worker.StateReady {
+		killWorkers([]worker.BaseProcess{w})
+		return nil, errors.E(op, errors.WorkerAllocate, errors.Errorf("preflight check failed, worker is not ready: %s", w.State().String()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sp.healthTimeout)
+	defer cancel()
+
+	if sp.healthPing != nil {
+		_, err = w.ExecWithTTL(ctx, sp.healthPing)
+	} else {
+		_, err = w.Introspect(ctx)
+	}
+	if err != nil {
+		// the reply might be still pending, the worker is not reused
+		killWorkers([]worker.BaseProcess{w})
+		return nil, errors.E(op, errors.WorkerAllocate, errors.Errorf("preflight check failed, worker doesn't respond: %v", err))
+	}
+
+	return w, nil
 }
 
-// allocate required number of stack
 func (sp *StaticPool) allocateWorkers(numWorkers uint64) ([]worker.BaseProcess, error) {
 	const op = errors.Op("static_pool_allocate_workers")
 	workers := make([]worker.BaseProcess, 0, numWorkers)
@@ -365,6 +1516,8 @@ func (sp *StaticPool) allocateWorkers(numWorkers uint64) ([]worker.BaseProcess,
 	for i := uint64(0); i < numWorkers; i++ {
 		w, err := sp.allocator()
 		if err != nil {
+			// partially allocated workers are not watched
+			killWorkers(workers)
 			return nil, errors.E(op, errors.WorkerAllocate, err)
 		}
 
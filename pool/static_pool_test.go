@@ -8,16 +8,21 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/spiral/errors"
 	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/internal/testclock"
 	"github.com/spiral/roadrunner/v2/payload"
 	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/spiral/roadrunner/v2/transport/testtransport"
 	"github.com/spiral/roadrunner/v2/utils"
 	"github.com/spiral/roadrunner/v2/worker"
+	workerWatcher "github.com/spiral/roadrunner/v2/worker_watcher"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var cfg = &Config{
@@ -59,6 +64,7 @@ func Test_ConfigNoErrorInitDefaults(t *testing.T) {
 		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
 		pipe.NewPipeFactory(),
 		&Config{
+			NumWorkers:      1,
 			AllocateTimeout: time.Second,
 			DestroyTimeout:  time.Second,
 		},
@@ -155,12 +161,17 @@ func Test_StaticPool_JobError(t *testing.T) {
 
 	res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
 	assert.Error(t, err)
-	assert.Nil(t, res)
+	// the worker error payload is returned alongside the error
+	require.NotNil(t, res)
+	assert.Contains(t, res.String(), "hello")
 
 	if errors.Is(errors.SoftJob, err) == false {
 		t.Fatal("error should be of type errors.Exec")
 	}
 
+	sj, ok := worker.AsSoftJobError(err)
+	require.True(t, ok)
+	assert.Equal(t, res, sj.Payload)
 	assert.Contains(t, err.Error(), "hello")
 	p.Destroy(ctx)
 }
@@ -571,151 +582,1324 @@ func Test_Static_Pool_WrongCommand2(t *testing.T) {
 	assert.Nil(t, p)
 }
 
-/* PTR:
-Benchmark_Pool_Echo-32    	   49076	     29926 ns/op	    8016 B/op	      20 allocs/op
-Benchmark_Pool_Echo-32    	   47257	     30779 ns/op	    8047 B/op	      20 allocs/op
-Benchmark_Pool_Echo-32    	   46737	     29440 ns/op	    8065 B/op	      20 allocs/op
-Benchmark_Pool_Echo-32    	   51177	     29074 ns/op	    7981 B/op	      20 allocs/op
-Benchmark_Pool_Echo-32    	   51764	     28319 ns/op	    8012 B/op	      20 allocs/op
-Benchmark_Pool_Echo-32    	   54054	     30714 ns/op	    7987 B/op	      20 allocs/op
-Benchmark_Pool_Echo-32    	   54391	     30689 ns/op	    8055 B/op	      20 allocs/op
+func Test_StaticPool_EagerAllocate(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "delay", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			MaxWorkers:      3,
+			EagerAllocate:   true,
+			AllocateTimeout: time.Second * 5,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
 
-VAL:
-Benchmark_Pool_Echo-32    	   47936	     28679 ns/op	    7942 B/op	      19 allocs/op
-Benchmark_Pool_Echo-32    	   49010	     29830 ns/op	    7970 B/op	      19 allocs/op
-Benchmark_Pool_Echo-32    	   46771	     29031 ns/op	    8014 B/op	      19 allocs/op
-Benchmark_Pool_Echo-32    	   47760	     30517 ns/op	    7955 B/op	      19 allocs/op
-Benchmark_Pool_Echo-32    	   48148	     29816 ns/op	    7950 B/op	      19 allocs/op
-Benchmark_Pool_Echo-32    	   52705	     29809 ns/op	    7979 B/op	      19 allocs/op
-Benchmark_Pool_Echo-32    	   54374	     27776 ns/op	    7947 B/op	      19 allocs/op
-*/
-func Benchmark_Pool_Echo(b *testing.B) {
+	wg := &sync.WaitGroup{}
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer wg.Done()
+			// 500ms delay
+			_, errE := p.Exec(&payload.Payload{Body: []byte("500")})
+			assert.NoError(t, errE)
+			// should never overshoot the max workers
+			assert.LessOrEqual(t, len(p.Workers()), 3)
+		}()
+	}
+
+	wg.Wait()
+	assert.Len(t, p.Workers(), 3)
+
+	p.Destroy(ctx)
+}
+
+func Test_StaticPool_AllocCounters(t *testing.T) {
 	ctx := context.Background()
 	p, err := Initialize(
 		ctx,
 		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
 		pipe.NewPipeFactory(),
-		cfg,
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
 	)
-	if err != nil {
-		b.Fatal(err)
-	}
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
 
-	bd := make([]byte, 1024)
-	c := make([]byte, 1024)
+	assert.Equal(t, uint64(2), p.SuccessfulAllocs())
+	assert.Equal(t, uint64(0), p.AllocFailures())
 
-	pld := &payload.Payload{
-		Context: c,
-		Body:    bd,
+	// kill the worker, watcher should allocate a new one
+	_ = p.Workers()[0].Kill()
+	assert.Eventually(t, func() bool {
+		return p.SuccessfulAllocs() == 3
+	}, time.Second*5, time.Millisecond*50)
+
+	p.Destroy(ctx)
+}
+
+func Test_StaticPool_ContainerCapacity(t *testing.T) {
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:        4,
+			ContainerCapacity: 2,
+			AllocateTimeout:   time.Second,
+			DestroyTimeout:    time.Second,
+		},
+	)
+
+	assert.Error(t, err)
+	assert.Nil(t, p)
+	assert.Contains(t, err.Error(), "container_capacity")
+}
+
+func Test_StaticPool_ExecDeadline(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/sleep.php", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	assert.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	// deadline already passed
+	_, err = p.ExecDeadline(time.Now().Add(-time.Second), &payload.Payload{Body: []byte("foo")})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(errors.ExecTTL, err))
+
+	// sleep.php sleeps longer than the budget
+	_, err = p.ExecDeadline(time.Now().Add(time.Millisecond*500), &payload.Payload{Body: []byte("foo")})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(errors.ExecTTL, err))
+}
+
+func Test_StaticPool_CancelAll(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/sleep.php", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	assert.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, errE := p.Exec(&payload.Payload{Body: []byte("foo")})
+		errCh <- errE
+	}()
+
+	assert.Eventually(t, func() bool {
+		return len(p.InFlight()) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	p.CancelAll()
+
+	select {
+	case errE := <-errCh:
+		assert.Error(t, errE)
+	case <-time.After(time.Second * 5):
+		t.Fatal("in-flight request was not canceled")
 	}
+	assert.Len(t, p.InFlight(), 0)
+}
 
-	b.ResetTimer()
-	b.ReportAllocs()
-	for n := 0; n < b.N; n++ {
-		if _, err := p.Exec(pld); err != nil {
-			b.Fail()
-		}
+func Test_StaticPool_WaitReady(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WaitReady(time.Second*5),
+	)
+	assert.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	for _, w := range p.Workers() {
+		assert.Equal(t, worker.StateReady, w.State().Value())
 	}
 }
 
-// Benchmark_Pool_Echo_Batched-32          366996          2873 ns/op        1233 B/op          24 allocs/op
-// PTR -> Benchmark_Pool_Echo_Batched-32    	  406839	      2900 ns/op	    1059 B/op	      23 allocs/op
-// PTR -> Benchmark_Pool_Echo_Batched-32    	  413312	      2904 ns/op	    1067 B/op	      23 allocs/op
-func Benchmark_Pool_Echo_Batched(b *testing.B) {
+func Test_StaticPool_LeaderPayload(t *testing.T) {
 	ctx := context.Background()
 	p, err := Initialize(
 		ctx,
 		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
 		pipe.NewPipeFactory(),
 		&Config{
-			NumWorkers:      uint64(runtime.NumCPU()),
-			AllocateTimeout: time.Second * 100,
+			NumWorkers:      3,
+			AllocateTimeout: time.Second,
 			DestroyTimeout:  time.Second,
 		},
+		WithLeaderPayload(&payload.Payload{Body: []byte("migrate")}),
 	)
-	assert.NoError(b, err)
+	assert.NoError(t, err)
 	defer p.Destroy(ctx)
 
-	bd := make([]byte, 1024)
-	c := make([]byte, 1024)
+	workers := p.Workers()
+	assert.Len(t, workers, 3)
+	// leader stays in the pool
+	assert.Equal(t, uint64(1), workers[0].State().NumExecs())
 
-	pld := &payload.Payload{
-		Context: c,
-		Body:    bd,
+	// leader payload error aborts the Initialize, the rest of the workers are not spawned
+	spawns := 0
+	_, err = Initialize(
+		ctx,
+		func() *exec.Cmd {
+			spawns++
+			return exec.Command("php", "../tests/client.php", "error", "pipes")
+		},
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      3,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithLeaderPayload(&payload.Payload{Body: []byte("migrate")}),
+	)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "leader payload failed")
+	assert.Equal(t, 1, spawns)
+}
+
+func Test_StaticPool_EmptyResponse(t *testing.T) {
+	ctx := context.Background()
+	for _, asError := range []bool{false, true} {
+		p, err := Initialize(
+			ctx,
+			func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+			pipe.NewPipeFactory(),
+			&Config{
+				NumWorkers:                1,
+				AllocateTimeout:           time.Second,
+				DestroyTimeout:            time.Second,
+				TreatEmptyResponseAsError: asError,
+			},
+		)
+		require.NoError(t, err)
+
+		// echo responds with the empty body
+		res, err := p.Exec(&payload.Payload{Context: []byte("ctx")})
+		if asError {
+			assert.Error(t, err)
+			assert.True(t, errors.Is(errors.SoftJob, err))
+			assert.Nil(t, res)
+		} else {
+			assert.NoError(t, err)
+			assert.Empty(t, res.Body)
+		}
+
+		// non-empty responses are not affected
+		res, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", res.String())
+
+		p.Destroy(ctx)
 	}
+}
 
-	b.ResetTimer()
-	b.ReportAllocs()
+func Test_StaticPool_Initializing(t *testing.T) {
+	sp := &StaticPool{cfg: &Config{AllocateTimeout: time.Second}, initializing: 1}
+	sp.execChain = sp.execTerminal
 
-	var wg sync.WaitGroup
-	for i := 0; i < b.N; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if _, err := p.Exec(pld); err != nil {
-				b.Fail()
-				log.Println(err)
-			}
-		}()
+	_, err := sp.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.Error(t, err)
+	assert.True(t, IsErr(err, ErrPoolInitializing))
+	assert.Contains(t, err.Error(), "pool initializing")
+
+	sp.cfg.Debug = true
+	_, err = sp.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.True(t, IsErr(err, ErrPoolInitializing))
+}
+
+func Test_StaticPool_MaxJobsReached(t *testing.T) {
+	w, err := worker.InitBaseWorker(exec.Command("php", "../tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		w.State().RegisterExec()
 	}
 
-	wg.Wait()
+	// never recycled
+	sp := &StaticPool{cfg: &Config{MaxJobs: MaxJobsUnlimited}}
+	assert.False(t, sp.maxJobsReached(w))
+
+	sp.cfg.MaxJobs = 1000
+	assert.True(t, sp.maxJobsReached(w))
+	sp.cfg.MaxJobs = 1001
+	assert.False(t, sp.maxJobsReached(w))
 }
 
-// Benchmark_Pool_Echo_Replaced-32    	     104/100	  10900218 ns/op	   52365 B/op	     125 allocs/op
-func Benchmark_Pool_Echo_Replaced(b *testing.B) {
+func Test_StaticPool_MaxJobsUnlimited(t *testing.T) {
 	ctx := context.Background()
 	p, err := Initialize(
 		ctx,
-		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "pid", "pipes") },
 		pipe.NewPipeFactory(),
 		&Config{
 			NumWorkers:      1,
-			MaxJobs:         1,
+			MaxJobs:         MaxJobsUnlimited,
 			AllocateTimeout: time.Second,
 			DestroyTimeout:  time.Second,
 		},
 	)
-	assert.NoError(b, err)
+	require.NoError(t, err)
 	defer p.Destroy(ctx)
-	b.ResetTimer()
-	b.ReportAllocs()
 
-	for n := 0; n < b.N; n++ {
-		if _, err := p.Exec(&payload.Payload{Body: []byte("hello")}); err != nil {
-			b.Fail()
-			log.Println(err)
-		}
+	pid := strconv.Itoa(int(p.Workers()[0].Pid()))
+	for i := 0; i < 10; i++ {
+		res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+		require.NoError(t, err)
+		assert.Equal(t, pid, string(res.Body))
 	}
 }
 
-// BenchmarkToStringUnsafe-12    	566317729	         1.91 ns/op	       0 B/op	       0 allocs/op
-// BenchmarkToStringUnsafe-32    	1000000000	         0.4434 ns/op	       0 B/op	       0 allocs/op
-func BenchmarkToStringUnsafe(b *testing.B) {
-	testPayload := []byte("falsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtoj")
-	b.ResetTimer()
-	b.ReportAllocs()
+func Test_StaticPool_ControlPayload(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "pid", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			MaxJobs:         2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
 
-	for i := 0; i < b.N; i++ {
-		res := utils.AsString(testPayload)
-		_ = res
+	pid := strconv.Itoa(int(p.Workers()[0].Pid()))
+	// synthetic traffic doesn't recycle the worker
+	for i := 0; i < 10; i++ {
+		res, err := p.Exec(&payload.Payload{Body: []byte("ping"), Control: true})
+		require.NoError(t, err)
+		assert.Equal(t, pid, string(res.Body))
 	}
+	assert.Equal(t, uint64(0), p.Workers()[0].State().NumExecs())
+
+	res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, pid, string(res.Body))
 }
 
-// BenchmarkToStringSafe-32    	 8017846	       182.5 ns/op	     896 B/op	       1 allocs/op
-// inline BenchmarkToStringSafe-12    	28926276	        46.6 ns/op	     128 B/op	       1 allocs/op
-func BenchmarkToStringSafe(b *testing.B) {
-	testPayload := []byte("falsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtoj")
+func Test_ControlPayload(t *testing.T) {
+	assert.Nil(t, controlPayload(nil))
 
-	b.ResetTimer()
-	b.ReportAllocs()
+	p := &payload.Payload{Body: []byte("ping")}
+	cp := controlPayload(p)
+	assert.True(t, cp.Control)
+	assert.Equal(t, p.Body, cp.Body)
+	// the caller's payload is not modified
+	assert.False(t, p.Control)
+}
 
-	for i := 0; i < b.N; i++ {
-		res := toStringNotFun(testPayload)
-		_ = res
+// notReadyFactory starts the process, but never reports it ready (hanging bootstrap)
+type notReadyFactory struct{}
+
+func (notReadyFactory) SpawnWorkerWithTimeout(_ context.Context, cmd *exec.Cmd, _ ...events.Listener) (*worker.Process, error) {
+	return notReadyFactory{}.SpawnWorker(cmd)
+}
+
+func (notReadyFactory) SpawnWorker(cmd *exec.Cmd, _ ...events.Listener) (*worker.Process, error) {
+	w, err := worker.InitBaseWorker(cmd)
+	if err != nil {
+		return nil, err
 	}
+	w.AttachRelay(nopRelay{})
+	return w, w.Start()
 }
 
-func toStringNotFun(data []byte) string {
-	return string(data)
+func (notReadyFactory) Close() error {
+	return nil
+}
+
+func Test_StaticPool_ReadyTimeout(t *testing.T) {
+	notReady := make(chan events.PoolEvent, 1)
+	sp := &StaticPool{cfg: &Config{ReadyTimeout: time.Millisecond * 100}, events: events.NewEventsHandler(), factory: notReadyFactory{}}
+	sp.addListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventWorkerNotReady {
+			notReady <- ev
+		}
+	})
+
+	allocator := sp.newPoolAllocator(context.Background(), time.Second, func() *exec.Cmd {
+		return exec.Command("sleep", "10")
+	})
+
+	w, err := allocator()
+	assert.Nil(t, w)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.WorkerAllocate, err))
+	assert.Contains(t, err.Error(), "not ready within 100ms")
+	assert.Equal(t, uint64(1), sp.AllocFailures())
+	assert.Equal(t, uint64(0), sp.SuccessfulAllocs())
+
+	select {
+	case ev := <-notReady:
+		assert.Error(t, ev.Error)
+	case <-time.After(time.Second):
+		t.Fatal("no EventWorkerNotReady")
+	}
+}
+
+func Test_StaticPool_DebugKeepWorker(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "pid", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			Debug:           true,
+			DebugKeepWorker: true,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	pid := res.String()
+
+	// stable pid for the debugger
+	for i := 0; i < 5; i++ {
+		res, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+		require.NoError(t, err)
+		assert.Equal(t, pid, res.String())
+	}
+
+	// recycled on the code change
+	require.NoError(t, p.Reset(ctx))
+	res, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.NotEqual(t, pid, res.String())
+}
+
+func Test_StaticPool_ConstructEvents(t *testing.T) {
+	var constructed int32
+	listener := func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventWorkerConstruct {
+			atomic.AddInt32(&constructed, 1)
+		}
+	}
+
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      3,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		AddListeners(listener),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	// delivered for all the initial workers
+	assert.Equal(t, int32(3), atomic.LoadInt32(&constructed))
+}
+
+func Test_AddListeners(t *testing.T) {
+	p := &StaticPool{events: events.NewEventsHandler()}
+	AddListeners(func(event interface{}) {})(p)
+	AddListeners(func(event interface{}) {}, func(event interface{}) {})(p)
+
+	// collected, wired by the Initialize
+	assert.Len(t, p.listeners, 3)
+	assert.Equal(t, 0, p.events.NumListeners())
+}
+
+func Test_StaticPool_CheckEmpty(t *testing.T) {
+	const op = errors.Op("test")
+	sp := &StaticPool{cfg: &Config{}}
+	rsp, err := sp.checkEmpty(op, &payload.Payload{})
+	assert.NoError(t, err)
+	assert.NotNil(t, rsp)
+
+	sp.cfg.TreatEmptyResponseAsError = true
+	_, err = sp.checkEmpty(op, &payload.Payload{Context: []byte("ctx")})
+	assert.True(t, errors.Is(errors.SoftJob, err))
+
+	rsp, err = sp.checkEmpty(op, &payload.Payload{Body: []byte("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", rsp.String())
+}
+
+func Test_StaticPool_ExecContext(t *testing.T) {
+	sp := &StaticPool{cfg: &Config{Supervisor: &SupervisorConfig{ExecTTL: time.Minute}}}
+
+	// worker TTL is derived from the ctx deadline
+	deadline := time.Now().Add(time.Second)
+	parent, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	ctx, cancelExec := sp.execContext(parent)
+	got, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, deadline, got)
+	cancelExec()
+
+	// no deadline - configured exec TTL
+	ctx, cancelExec = sp.execContext(context.Background())
+	got, ok = ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), got, time.Second)
+	cancelExec()
+
+	// no exec TTL either
+	sp.cfg.Supervisor = nil
+	ctx, cancelExec = sp.execContext(context.Background())
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+	cancelExec()
+	assert.Error(t, ctx.Err())
+}
+
+func Test_StaticPool_ShutdownPayload(t *testing.T) {
+	ctx := context.Background()
+	var failures int64
+	p, err := Initialize(
+		ctx,
+		// error worker fails the shutdown payload
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "error", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithShutdownPayload(&payload.Payload{Body: []byte("shutdown")}, time.Second),
+		AddListeners(func(event interface{}) {
+			if ev, ok := event.(events.WorkerEvent); ok && ev.Event == events.EventWorkerError {
+				if strings.Contains(ev.Payload.(error).Error(), "static_pool_shutdown_workers") {
+					atomic.AddInt64(&failures, 1)
+				}
+			}
+		}),
+	)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		p.Destroy(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 10):
+		t.Fatal("failed shutdown payload should not block the Destroy")
+	}
+	assert.Equal(t, int64(2), atomic.LoadInt64(&failures))
+}
+
+func Test_StaticPool_RecycleByLabel(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithLabels(map[string]string{"tenant": "a"}),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	pids := func() map[int64]struct{} {
+		res := make(map[int64]struct{})
+		for _, w := range p.Workers() {
+			res[w.Pid()] = struct{}{}
+		}
+		return res
+	}
+	before := pids()
+
+	// other tenant
+	require.NoError(t, p.RecycleByLabel("tenant", "b"))
+	assert.Equal(t, before, pids())
+
+	require.NoError(t, p.RecycleByLabel("tenant", "a"))
+	assert.Eventually(t, func() bool {
+		after := pids()
+		if len(after) != 2 {
+			return false
+		}
+		for pid := range after {
+			if _, ok := before[pid]; ok {
+				return false
+			}
+		}
+		return true
+	}, time.Second*5, time.Millisecond*10)
+
+	res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", res.String())
+}
+
+func Test_StaticPool_OnConfigChange(t *testing.T) {
+	ctx := context.Background()
+	restarts := uint64(0)
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second * 5,
+			DestroyTimeout:  time.Second,
+		},
+		WithResetDebounce(time.Millisecond*200),
+		AddListeners(func(event interface{}) {
+			if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolRestart {
+				atomic.AddUint64(&restarts, 1)
+			}
+		}),
+	)
+	assert.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	pids := make(map[int64]struct{})
+	for _, w := range p.Workers() {
+		pids[w.Pid()] = struct{}{}
+	}
+
+	ch := make(chan struct{})
+	p.OnConfigChange(ch)
+
+	// rapid changes are coalesced into a single reset
+	for i := 0; i < 3; i++ {
+		ch <- struct{}{}
+	}
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint64(&restarts) == 1
+	}, time.Second*10, time.Millisecond*10)
+
+	assert.Eventually(t, func() bool {
+		ready := 0
+		for _, w := range p.Workers() {
+			if _, ok := pids[w.Pid()]; ok {
+				return false
+			}
+			if w.State().Value() == worker.StateReady {
+				ready++
+			}
+		}
+		return ready == 2
+	}, time.Second*10, time.Millisecond*10)
+
+	time.Sleep(time.Millisecond * 500)
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&restarts))
+
+	res, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", res.String())
+}
+
+/* PTR:
+Benchmark_Pool_Echo-32    	   49076	     29926 ns/op	    8016 B/op	      20 allocs/op
+Benchmark_Pool_Echo-32    	   47257	     30779 ns/op	    8047 B/op	      20 allocs/op
+Benchmark_Pool_Echo-32    	   46737	     29440 ns/op	    8065 B/op	      20 allocs/op
+Benchmark_Pool_Echo-32    	   51177	     29074 ns/op	    7981 B/op	      20 allocs/op
+Benchmark_Pool_Echo-32    	   51764	     28319 ns/op	    8012 B/op	      20 allocs/op
+Benchmark_Pool_Echo-32    	   54054	     30714 ns/op	    7987 B/op	      20 allocs/op
+Benchmark_Pool_Echo-32    	   54391	     30689 ns/op	    8055 B/op	      20 allocs/op
+
+VAL:
+Benchmark_Pool_Echo-32    	   47936	     28679 ns/op	    7942 B/op	      19 allocs/op
+Benchmark_Pool_Echo-32    	   49010	     29830 ns/op	    7970 B/op	      19 allocs/op
+Benchmark_Pool_Echo-32    	   46771	     29031 ns/op	    8014 B/op	      19 allocs/op
+Benchmark_Pool_Echo-32    	   47760	     30517 ns/op	    7955 B/op	      19 allocs/op
+Benchmark_Pool_Echo-32    	   48148	     29816 ns/op	    7950 B/op	      19 allocs/op
+Benchmark_Pool_Echo-32    	   52705	     29809 ns/op	    7979 B/op	      19 allocs/op
+Benchmark_Pool_Echo-32    	   54374	     27776 ns/op	    7947 B/op	      19 allocs/op
+*/
+func Benchmark_Pool_Echo(b *testing.B) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		cfg,
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	bd := make([]byte, 1024)
+	c := make([]byte, 1024)
+
+	pld := &payload.Payload{
+		Context: c,
+		Body:    bd,
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, err := p.Exec(pld); err != nil {
+			b.Fail()
+		}
+	}
+}
+
+// Benchmark_Pool_Echo_FastExec is the Benchmark_Pool_Echo with the FastExec, compare the ns/op and allocs/op
+func Benchmark_Pool_Echo_FastExec(b *testing.B) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      uint64(runtime.NumCPU()),
+			AllocateTimeout: time.Second * 5,
+			DestroyTimeout:  time.Second * 5,
+			FastExec:        true,
+		},
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Destroy(ctx)
+
+	pld := &payload.Payload{
+		Context: make([]byte, 1024),
+		Body:    make([]byte, 1024),
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if _, err := p.Exec(pld); err != nil {
+			b.Fail()
+		}
+	}
+}
+
+// Benchmark_Pool_Echo_Batched-32          366996          2873 ns/op        1233 B/op          24 allocs/op
+// PTR -> Benchmark_Pool_Echo_Batched-32    	  406839	      2900 ns/op	    1059 B/op	      23 allocs/op
+// PTR -> Benchmark_Pool_Echo_Batched-32    	  413312	      2904 ns/op	    1067 B/op	      23 allocs/op
+func Benchmark_Pool_Echo_Batched(b *testing.B) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      uint64(runtime.NumCPU()),
+			AllocateTimeout: time.Second * 100,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	assert.NoError(b, err)
+	defer p.Destroy(ctx)
+
+	bd := make([]byte, 1024)
+	c := make([]byte, 1024)
+
+	pld := &payload.Payload{
+		Context: c,
+		Body:    bd,
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Exec(pld); err != nil {
+				b.Fail()
+				log.Println(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// Benchmark_Pool_Echo_Replaced-32    	     104/100	  10900218 ns/op	   52365 B/op	     125 allocs/op
+func Benchmark_Pool_Echo_Replaced(b *testing.B) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			MaxJobs:         1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	assert.NoError(b, err)
+	defer p.Destroy(ctx)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		if _, err := p.Exec(&payload.Payload{Body: []byte("hello")}); err != nil {
+			b.Fail()
+			log.Println(err)
+		}
+	}
+}
+
+// BenchmarkToStringUnsafe-12    	566317729	         1.91 ns/op	       0 B/op	       0 allocs/op
+// BenchmarkToStringUnsafe-32    	1000000000	         0.4434 ns/op	       0 B/op	       0 allocs/op
+func BenchmarkToStringUnsafe(b *testing.B) {
+	testPayload := []byte("falsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtoj")
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		res := utils.AsString(testPayload)
+		_ = res
+	}
+}
+
+// BenchmarkToStringSafe-32    	 8017846	       182.5 ns/op	     896 B/op	       1 allocs/op
+// inline BenchmarkToStringSafe-12    	28926276	        46.6 ns/op	     128 B/op	       1 allocs/op
+func BenchmarkToStringSafe(b *testing.B) {
+	testPayload := []byte("falsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtojfalsflasjlifjwpoihejfoiwejow{}{}{}{}jelfjasjfhwaopiehjtopwhtgohrgouahsgkljasdlfjasl;fjals;jdflkndgouwhetopwqhjtoj")
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		res := toStringNotFun(testPayload)
+		_ = res
+	}
+}
+
+func toStringNotFun(data []byte) string {
+	return string(data)
+}
+
+func Test_StaticPool_ExecWeighted(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			MaxJobs:         4,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	pid := p.Workers()[0].Pid()
+
+	// light request counts 1
+	_, err = p.ExecWeighted(1, &payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, pid, p.Workers()[0].Pid())
+	assert.Equal(t, uint64(1), p.Workers()[0].State().WeightedExecs())
+
+	// heavy request reaches the MaxJobs
+	_, err = p.ExecWeighted(3, &payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		workers := p.Workers()
+		return len(workers) == 1 && workers[0].Pid() != pid
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func Test_StaticPool_ExecWithAllocateTimeout(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		// sleep for the 3 seconds
+		func() *exec.Cmd { return exec.Command("php", "../tests/sleep.php", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second * 10,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(ctx)
+
+	go func() {
+		_, _ = p.Exec(&payload.Payload{Body: []byte("hello")})
+	}()
+	time.Sleep(time.Millisecond * 500)
+
+	// sheds after the per-call timeout instead of the 10s AllocateTimeout
+	start := time.Now()
+	_, err = p.ExecWithAllocateTimeout(time.Millisecond*200, &payload.Payload{Body: []byte("hello")})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(errors.NoFreeWorkers, err))
+	assert.Less(t, time.Since(start), time.Second*2)
+}
+
+func Test_StaticPool_AllocateEphemeral(t *testing.T) {
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+
+	sw, release, err := p.AllocateEphemeral(ctx)
+	require.NoError(t, err)
+
+	// not in the pool accounting
+	assert.Len(t, p.Workers(), 1)
+	assert.NotEqual(t, p.Workers()[0].Pid(), sw.Pid())
+
+	res, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", res.String())
+
+	release()
+	assert.Equal(t, worker.StateDestroyed, sw.State().Value())
+	// released once
+	release()
+
+	// released by the Destroy
+	sw, _, err = p.AllocateEphemeral(ctx)
+	require.NoError(t, err)
+	p.Destroy(ctx)
+	assert.Equal(t, worker.StateDestroyed, sw.State().Value())
+
+	_, _, err = p.AllocateEphemeral(ctx)
+	assert.True(t, IsErr(err, ErrPoolDraining))
+}
+
+func Test_StaticPool_MaxTotalExecs(t *testing.T) {
+	reached := make(chan events.PoolLifetime, 1)
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+			MaxTotalExecs:   3,
+		},
+		AddListeners(func(event interface{}) {
+			if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolLifetimeReached {
+				reached <- ev.Payload.(events.PoolLifetime)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	for i := 0; i < 3; i++ {
+		_, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+		require.NoError(t, err)
+	}
+
+	select {
+	case pl := <-reached:
+		assert.Equal(t, uint64(3), pl.Execs)
+	case <-time.After(time.Second * 5):
+		t.Fatal("pool lifetime should be reached")
+	}
+
+	// destroyed
+	require.Eventually(t, func() bool {
+		_, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+		return IsErr(err, ErrPoolDraining)
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func Test_StaticPool_MaxPoolLifetime(t *testing.T) {
+	clock := testclock.New(time.Now())
+	reached := make(chan events.PoolLifetime, 1)
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Millisecond * 100,
+			MaxPoolLifetime: time.Hour,
+		},
+		WithClock(clock),
+		AddListeners(func(event interface{}) {
+			if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolLifetimeReached {
+				reached <- ev.Payload.(events.PoolLifetime)
+			}
+		}),
+	)
+	require.NoError(t, err)
+
+	// lifetime timer is started
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond*10)
+	clock.Advance(time.Hour)
+
+	select {
+	case pl := <-reached:
+		assert.Equal(t, time.Hour, pl.Uptime)
+	case <-time.After(time.Second * 5):
+		t.Fatal("pool lifetime should be reached")
+	}
+
+	require.Eventually(t, func() bool {
+		_, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+		return IsErr(err, ErrPoolDraining)
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func Test_StaticPool_ResponseReadTimeout(t *testing.T) {
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "delay", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:          1,
+			AllocateTimeout:     time.Second,
+			DestroyTimeout:      time.Second,
+			ResponseReadTimeout: time.Millisecond * 200,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	_, err = p.Exec(&payload.Payload{Body: []byte("10")})
+	require.NoError(t, err)
+
+	pid := p.Workers()[0].Pid()
+	// long computation without the response bytes is not a stall
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	_, err = p.execWithTTL(ctx, &payload.Payload{Body: []byte("1000")})
+	require.NoError(t, err)
+
+	workers := p.Workers()
+	require.Len(t, workers, 1)
+	assert.Equal(t, pid, workers[0].Pid())
+}
+
+func Test_StaticPool_FastExec(t *testing.T) {
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      1,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+			FastExec:        true,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+	sp := p.(*StaticPool)
+	require.True(t, sp.fastExec)
+
+	rsp, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", rsp.String())
+
+	// the only worker is busy, the full path waits for it
+	w, err := sp.ww.Take(context.Background())
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		sp.ww.Release(w)
+	}()
+	rsp, err = p.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", rsp.String())
+
+	// not eligible configurations take the full path
+	sp.cfg.MaxJobs = 10
+	assert.False(t, sp.fastExecEnabled())
+}
+
+func Test_StaticPool_WaitingCallers(t *testing.T) {
+	// no workers, the callers wait for the allocate timeout
+	sp := &StaticPool{
+		cfg: &Config{AllocateTimeout: time.Second},
+		ww:  workerWatcher.NewSyncWorkerWatcher(nil, 0, events.NewEventsHandler(), time.Second),
+	}
+	sp.execChain = sp.execTerminal
+	sp.events = events.NewEventsHandler()
+
+	// the saturation is reported with the EventNoFreeWorkers
+	waiting := make(chan int, 3)
+	sp.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventNoFreeWorkers {
+			waiting <- ev.Payload.(events.NoFreeWorkers).WaitingCallers
+		}
+	})
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := sp.Exec(&payload.Payload{Body: []byte("hello")})
+			assert.True(t, IsErr(err, ErrOverloaded))
+		}()
+	}
+
+	require.Eventually(t, func() bool { return sp.WaitingCallers() == 3 }, time.Second, time.Millisecond*10)
+	wg.Wait()
+	assert.Equal(t, 0, sp.WaitingCallers())
+
+	require.Len(t, waiting, 3)
+	for i := 0; i < 3; i++ {
+		n := <-waiting
+		assert.True(t, n >= 1 && n <= 3, n)
+	}
+}
+
+func Test_StaticPool_TakeWorkerErrors(t *testing.T) {
+	// no workers, the callers wait for the allocate timeout
+	sp := &StaticPool{
+		cfg:    &Config{AllocateTimeout: time.Millisecond * 50},
+		ww:     workerWatcher.NewSyncWorkerWatcher(nil, 0, events.NewEventsHandler(), time.Second),
+		events: events.NewEventsHandler(),
+	}
+	sp.execChain = sp.execTerminal
+
+	// capacity exhaustion, the watcher error is kept
+	_, err := sp.Exec(&payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.NoFreeWorkers, err))
+	assert.True(t, IsErr(err, ErrOverloaded))
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+
+	// the caller ctx is done before the allocate timeout
+	sp.cfg.AllocateTimeout = time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = sp.execWithTTL(ctx, &payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+	assert.False(t, errors.Is(errors.NoFreeWorkers, err))
+	assert.False(t, IsErr(err, ErrOverloaded))
+
+	// destroyed
+	require.NoError(t, sp.ww.Destroy(context.Background()))
+	_, err = sp.Exec(&payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.WatcherStopped, err))
+	assert.True(t, IsErr(err, ErrPoolDraining))
+}
+
+func Test_StaticPool_WaitIdle(t *testing.T) {
+	sp := &StaticPool{inflight: newInflight()}
+
+	// no in-flight requests
+	require.NoError(t, sp.WaitIdle(context.Background()))
+
+	c1 := sp.inflight.start(nil, nil)
+	c2 := sp.inflight.start(nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	assert.True(t, errors.Is(errors.TimeOut, sp.WaitIdle(ctx)))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sp.WaitIdle(context.Background())
+	}()
+
+	sp.inflight.finish(c1)
+	select {
+	case <-done:
+		t.Fatal("pool is not idle yet")
+	case <-time.After(time.Millisecond * 10):
+	}
+
+	sp.inflight.finish(c2)
+	require.NoError(t, <-done)
+
+	// canceled calls are removed at once, the late finish doesn't notify twice
+	c3 := sp.inflight.start(nil, nil)
+	sp.inflight.cancelAll()
+	require.NoError(t, sp.WaitIdle(context.Background()))
+	assert.True(t, sp.inflight.finish(c3))
+
+	// the pool own round-trips (health checks, dumps) keep the pool busy, but are neither listed nor canceled
+	sp.inflight.begin()
+	assert.Empty(t, sp.InFlight())
+	assert.Empty(t, sp.inflight.cancelAll())
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel2()
+	assert.True(t, errors.Is(errors.TimeOut, sp.WaitIdle(ctx2)))
+
+	c4 := sp.inflight.start(nil, nil)
+	sp.inflight.end()
+	select {
+	case <-sp.inflight.done():
+		t.Fatal("pool is not idle yet")
+	default:
+	}
+	sp.inflight.finish(c4)
+	require.NoError(t, sp.WaitIdle(context.Background()))
+}
+
+func Test_StaticPool_DetachedExecContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "v"), time.Minute)
+	deadline, _ := parent.Deadline()
+
+	// the cancellation is not propagated by default, the deadline and the values are kept
+	sp := &StaticPool{cfg: &Config{}}
+	ctx, cancelExec := sp.execContext(parent)
+	defer cancelExec()
+	cancel()
+	assert.NoError(t, ctx.Err())
+	dl, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, deadline, dl)
+	assert.Equal(t, "v", ctx.Value(key{}))
+
+	parent, cancel = context.WithCancel(context.Background())
+	sp = &StaticPool{cfg: &Config{CancelWorkerOnCtxDone: true}}
+	ctx, cancelExec = sp.execContext(parent)
+	defer cancelExec()
+	cancel()
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func Test_StaticPool_CancelWorkerOnCtxDone(t *testing.T) {
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "delay", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:            1,
+			AllocateTimeout:       time.Second,
+			DestroyTimeout:        time.Second,
+			CancelWorkerOnCtxDone: true,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	pid := p.Workers()[0].Pid()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*100, cancel)
+
+	start := time.Now()
+	_, err = p.execWithTTL(ctx, &payload.Payload{Body: []byte("2000")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.False(t, errors.Is(errors.ExecTTL, err))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// aborted worker is replaced
+	require.Eventually(t, func() bool {
+		workers := p.Workers()
+		return len(workers) == 1 && workers[0].Pid() != pid
+	}, time.Second*5, time.Millisecond*50)
+}
+
+func Test_StaticPool_RecentEvents(t *testing.T) {
+	re := events.NewRecentEvents(16)
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "worker.php") },
+		testtransport.NewFactory(testtransport.Config{}),
+		&Config{
+			NumWorkers:      2,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+		WithRecentEvents(re),
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	// wired with the listeners, before the initial workers are allocated
+	constructs := 0
+	snapshot := re.Snapshot()
+	for i := 0; i < len(snapshot); i++ {
+		if ev, ok := snapshot[i].(events.PoolEvent); ok && ev.Event == events.EventWorkerConstruct {
+			constructs++
+		}
+	}
+	assert.Equal(t, 2, constructs)
+}
+
+func Test_StaticPool_SlotWorker(t *testing.T) {
+	p, err := Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "worker.php") },
+		testtransport.NewFactory(testtransport.Config{}),
+		&Config{
+			NumWorkers:      3,
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+		},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	workers := p.Workers()
+	require.Len(t, workers, 3)
+	for i := 0; i < len(workers); i++ {
+		require.NotNil(t, p.SlotWorker(i))
+		assert.Equal(t, workers[i].Pid(), p.SlotWorker(i).Pid())
+	}
+	assert.Nil(t, p.SlotWorker(3))
+	assert.Nil(t, p.SlotWorker(-1))
+
+	// the replacement takes the slot of the exited worker
+	pid := workers[1].Pid()
+	require.NoError(t, workers[1].Kill())
+	require.Eventually(t, func() bool {
+		w := p.SlotWorker(1)
+		return w != nil && w.Pid() != pid && w.State().Value() == worker.StateReady
+	}, time.Second*5, time.Millisecond*20)
+	assert.Equal(t, workers[0].Pid(), p.SlotWorker(0).Pid())
+	assert.Equal(t, workers[2].Pid(), p.SlotWorker(2).Pid())
 }
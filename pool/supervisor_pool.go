@@ -3,13 +3,17 @@ package pool
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spiral/errors"
 	"github.com/spiral/roadrunner/v2/events"
 	"github.com/spiral/roadrunner/v2/payload"
 	"github.com/spiral/roadrunner/v2/state/process"
+	"github.com/spiral/roadrunner/v2/transport"
+	"github.com/spiral/roadrunner/v2/utils"
 	"github.com/spiral/roadrunner/v2/worker"
+	workerWatcher "github.com/spiral/roadrunner/v2/worker_watcher"
 )
 
 const MB = 1024 * 1024
@@ -17,10 +21,25 @@ const MB = 1024 * 1024
 // NSEC_IN_SEC nanoseconds in second
 const NSEC_IN_SEC int64 = 1000000000 //nolint:stylecheck
 
-type Supervised interface {
-	Pool
+// Supervisor watches the pool workers and recycles them according to its rules. The default implementation is
+// configured by the SupervisorConfig, the custom one is set with the WithSupervisor option.
+type Supervisor interface {
 	// Start used to start watching process for all pool workers
 	Start()
+	// Stop stops watching the pool workers
+	Stop()
+	// Control evaluates the rules against the pool workers once, called by the watcher on every tick
+	Control()
+}
+
+type Supervised interface {
+	Pool
+	Supervisor
+	// Suspend pauses all recycling checks (memory, TTL, idle), workers keep running.
+	// Supervision is automatically resumed after the SupervisorConfig.MaxSuspend.
+	Suspend()
+	// Resume resumes the suspended supervision
+	Resume()
 }
 
 type supervised struct {
@@ -29,15 +48,26 @@ type supervised struct {
 	pool   Pool
 	stopCh chan struct{}
 	mu     *sync.RWMutex
+	// unix nano timestamp until the supervision is suspended, 0 - not suspended
+	suspendedUntil int64
+	// responses cache shared with the underlying pool, misses are executed with the exec TTL
+	cache *execCache
+	// retries of the failed idempotent payloads
+	retry *RetryPolicy
+	// time source of the ticks and the rules
+	clock utils.Clock
 }
 
-func supervisorWrapper(pool Pool, events events.Handler, cfg *SupervisorConfig) Supervised {
+func supervisorWrapper(pool Pool, events events.Handler, cfg *SupervisorConfig, cache *execCache, retry *RetryPolicy, clock utils.Clock) Supervised {
 	sp := &supervised{
 		cfg:    cfg,
 		events: events,
 		pool:   pool,
 		mu:     &sync.RWMutex{},
 		stopCh: make(chan struct{}),
+		cache:  cache,
+		retry:  retry,
+		clock:  clock,
 	}
 
 	return sp
@@ -48,64 +78,307 @@ func (sp *supervised) execWithTTL(_ context.Context, _ *payload.Payload) (*paylo
 }
 
 func (sp *supervised) Exec(rqs *payload.Payload) (*payload.Payload, error) {
-	const op = errors.Op("supervised_exec_with_context")
 	if sp.cfg.ExecTTL == 0 {
+		// retries are applied by the pool
 		return sp.pool.Exec(rqs)
 	}
 
+	return sp.retry.exec(rqs, sp.exec)
+}
+
+func (sp *supervised) ExecWeighted(weight uint64, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("supervised_exec_weighted")
+	if sp.cfg.ExecTTL == 0 {
+		return sp.pool.ExecWeighted(weight, rqs)
+	}
+
+	return sp.retry.exec(rqs, func(rqs *payload.Payload) (*payload.Payload, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), sp.cfg.ExecTTL)
+		defer cancel()
+
+		res, err := sp.pool.execWeightedWithTTL(ctx, weight, rqs)
+		if err != nil {
+			return res, errors.E(op, err)
+		}
+
+		return res, nil
+	})
+}
+
+// ExecWithAllocateTimeout is executed by the pool, the exec TTL is applied by the pool as well
+func (sp *supervised) ExecWithAllocateTimeout(timeout time.Duration, rqs *payload.Payload) (*payload.Payload, error) {
+	return sp.pool.ExecWithAllocateTimeout(timeout, rqs)
+}
+
+func (sp *supervised) execWeightedWithTTL(_ context.Context, _ uint64, _ *payload.Payload) (*payload.Payload, error) {
+	panic("used to satisfy pool interface")
+}
+
+func (sp *supervised) exec(rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("supervised_exec_with_context")
+
 	ctx, cancel := context.WithTimeout(context.Background(), sp.cfg.ExecTTL)
 	defer cancel()
 
 	res, err := sp.pool.execWithTTL(ctx, rqs)
 	if err != nil {
-		return nil, errors.E(op, err)
+		return res, errors.E(op, err)
 	}
 
 	return res, nil
 }
 
+func (sp *supervised) TryExec(rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("supervised_try_exec")
+	if sp.cfg.ExecTTL == 0 {
+		return sp.pool.TryExec(rqs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sp.cfg.ExecTTL)
+	defer cancel()
+
+	res, err := sp.pool.tryExecWithTTL(ctx, rqs)
+	if err != nil {
+		return res, errors.E(op, err)
+	}
+
+	return res, nil
+}
+
+// tryExecWithTTL is used by the wrappers (e.g. FallbackPool), exec TTL caps the ctx deadline
+func (sp *supervised) tryExecWithTTL(ctx context.Context, rqs *payload.Payload) (*payload.Payload, error) {
+	if sp.cfg.ExecTTL == 0 {
+		return sp.pool.tryExecWithTTL(ctx, rqs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sp.cfg.ExecTTL)
+	defer cancel()
+
+	return sp.pool.tryExecWithTTL(ctx, rqs)
+}
+
+func (sp *supervised) ExecDeadline(deadline time.Time, rqs *payload.Payload) (*payload.Payload, error) {
+	const op = errors.Op("supervised_exec_deadline")
+	// exec TTL caps the deadline
+	if sp.cfg.ExecTTL != 0 {
+		ttlDeadline := time.Now().Add(sp.cfg.ExecTTL)
+		if ttlDeadline.Before(deadline) {
+			deadline = ttlDeadline
+		}
+	}
+
+	res, err := sp.pool.ExecDeadline(deadline, rqs)
+	if err != nil {
+		return res, errors.E(op, err)
+	}
+
+	return res, nil
+}
+
+func (sp *supervised) InFlight() map[uint64]int64 {
+	return sp.pool.InFlight()
+}
+
+func (sp *supervised) CancelAll() {
+	sp.pool.CancelAll()
+}
+
+func (sp *supervised) replaceWorker(w worker.BaseProcess) error {
+	return sp.pool.replaceWorker(w)
+}
+
+func (sp *supervised) ExecCached(rqs *payload.Payload, ttl time.Duration) (*payload.Payload, error) {
+	return sp.cache.execCached(payloadKey(rqs), ttl, rqs, sp.Exec)
+}
+
+func (sp *supervised) ExecCachedKey(key string, ttl time.Duration, rqs *payload.Payload) (*payload.Payload, error) {
+	return sp.cache.execCached(callerKey(key), ttl, rqs, sp.Exec)
+}
+
+func (sp *supervised) Invalidate(key string) {
+	sp.cache.invalidate(callerKey(key))
+}
+
+func (sp *supervised) CacheHits() uint64 {
+	return atomic.LoadUint64(&sp.cache.hits)
+}
+
+func (sp *supervised) CacheMisses() uint64 {
+	return atomic.LoadUint64(&sp.cache.misses)
+}
+
 func (sp *supervised) GetConfig() interface{} {
 	return sp.pool.GetConfig()
 }
 
+func (sp *supervised) Config() *Config {
+	return sp.pool.Config()
+}
+
 func (sp *supervised) Workers() (workers []worker.BaseProcess) {
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
 	return sp.pool.Workers()
 }
 
+func (sp *supervised) AllocateEphemeral(ctx context.Context) (worker.SyncWorker, func(), error) {
+	return sp.pool.AllocateEphemeral(ctx)
+}
+
 func (sp *supervised) RemoveWorker(worker worker.BaseProcess) error {
 	return sp.pool.RemoveWorker(worker)
 }
 
+func (sp *supervised) SetNumWorkers(num uint64) error {
+	return sp.pool.SetNumWorkers(num)
+}
+
+func (sp *supervised) SetNumWorkersPolicy(num uint64, policy workerWatcher.ScaleDownPolicy) (workerWatcher.ScaleResult, error) {
+	return sp.pool.SetNumWorkersPolicy(num, policy)
+}
+
+func (sp *supervised) Reset(ctx context.Context) error {
+	return sp.pool.Reset(ctx)
+}
+
+func (sp *supervised) IsWorkerHealthy(pid int64) (bool, error) {
+	return sp.pool.IsWorkerHealthy(pid)
+}
+
+func (sp *supervised) ForceHealthCheck(pid int64) (bool, error) {
+	return sp.pool.ForceHealthCheck(pid)
+}
+
+func (sp *supervised) SetFactory(factory transport.Factory) error {
+	return sp.pool.SetFactory(factory)
+}
+
+func (sp *supervised) MigrateWorkers(ctx context.Context) error {
+	return sp.pool.MigrateWorkers(ctx)
+}
+
+func (sp *supervised) OnExecMilestone(every uint64, fn func(w worker.BaseProcess, count uint64)) {
+	sp.pool.OnExecMilestone(every, fn)
+}
+
+func (sp *supervised) OnConfigChange(ch <-chan struct{}) {
+	sp.pool.OnConfigChange(ch)
+}
+
 func (sp *supervised) Destroy(ctx context.Context) {
 	sp.pool.Destroy(ctx)
 }
 
+func (sp *supervised) DumpAllWorkers(ctx context.Context) (map[int64]map[string]interface{}, error) {
+	return sp.pool.DumpAllWorkers(ctx)
+}
+
+func (sp *supervised) OldestWorker() worker.BaseProcess {
+	return sp.pool.OldestWorker()
+}
+
+func (sp *supervised) NewestWorker() worker.BaseProcess {
+	return sp.pool.NewestWorker()
+}
+
+func (sp *supervised) SlotWorker(slot int) worker.BaseProcess {
+	return sp.pool.SlotWorker(slot)
+}
+
+func (sp *supervised) DrainWorker(pid int64) error {
+	return sp.pool.DrainWorker(pid)
+}
+
+func (sp *supervised) RecycleByLabel(key, value string) error {
+	return sp.pool.RecycleByLabel(key, value)
+}
+
+func (sp *supervised) AllocFailures() uint64 {
+	return sp.pool.AllocFailures()
+}
+
+func (sp *supervised) SuccessfulAllocs() uint64 {
+	return sp.pool.SuccessfulAllocs()
+}
+
+func (sp *supervised) Throughput() float64 {
+	return sp.pool.Throughput()
+}
+
+func (sp *supervised) WaitingCallers() int {
+	return sp.pool.WaitingCallers()
+}
+
+func (sp *supervised) WaitIdle(ctx context.Context) error {
+	return sp.pool.WaitIdle(ctx)
+}
+
 func (sp *supervised) Start() {
 	go func() {
-		watchTout := time.NewTicker(sp.cfg.WatchTick)
+		watchTout := sp.clock.NewTicker(sp.cfg.WatchTick)
 		for {
 			select {
 			case <-sp.stopCh:
 				watchTout.Stop()
 				return
 			// stop here
-			case <-watchTout.C:
-				sp.mu.Lock()
-				sp.control()
-				sp.mu.Unlock()
+			case <-watchTout.C():
+				sp.Control()
 			}
 		}
 	}()
 }
 
+func (sp *supervised) Control() {
+	sp.mu.Lock()
+	sp.control()
+	sp.mu.Unlock()
+}
+
 func (sp *supervised) Stop() {
 	sp.stopCh <- struct{}{}
 }
 
+func (sp *supervised) Suspend() {
+	atomic.StoreInt64(&sp.suspendedUntil, sp.clock.Now().Add(sp.cfg.MaxSuspend).UnixNano())
+}
+
+func (sp *supervised) Resume() {
+	atomic.StoreInt64(&sp.suspendedUntil, 0)
+}
+
+// suspended reports whether the supervision is suspended, auto-resumes after the safety max-duration
+func (sp *supervised) suspended(now time.Time) bool {
+	until := atomic.LoadInt64(&sp.suspendedUntil)
+	if until == 0 {
+		return false
+	}
+
+	if now.UnixNano() >= until {
+		// safety max-duration reached, do not allow to disable supervision forever
+		atomic.CompareAndSwapInt64(&sp.suspendedUntil, until, 0)
+		return false
+	}
+
+	return true
+}
+
+// relaySilent reports whether the working worker has been silent (no frames sent or received) for the MaxRelaySilence
+func (sp *supervised) relaySilent(w worker.BaseProcess, now time.Time) bool {
+	if sp.cfg.MaxRelaySilence == 0 || w.State().Value() != worker.StateWorking {
+		return false
+	}
+
+	la := w.LastRelayActivity()
+	return !la.IsZero() && now.Sub(la) >= sp.cfg.MaxRelaySilence
+}
+
 func (sp *supervised) control() { //nolint:gocognit
-	now := time.Now()
+	now := sp.clock.Now()
+
+	if sp.suspended(now) {
+		return
+	}
 
 	// MIGHT BE OUTDATED
 	// It's a copy of the Workers pointers
@@ -126,6 +399,15 @@ func (sp *supervised) control() { //nolint:gocognit
 			continue
 		}
 
+		// hung worker, doesn't need the process state
+		if sp.relaySilent(workers[i], now) {
+			// can't be stopped via the relay, the watcher allocates the replacement after the exit
+			workers[i].State().SetReason(worker.StateInvalid, "relay silence")
+			_ = workers[i].Kill()
+			sp.events.Push(events.PoolEvent{Event: events.EventRelaySilence, Payload: workers[i]})
+			continue
+		}
+
 		s, err := process.WorkerProcessState(workers[i])
 		if err != nil {
 			// worker not longer valid for supervision
@@ -143,11 +425,15 @@ func (sp *supervised) control() { //nolint:gocognit
 			*/
 
 			if workers[i].State().Value() != worker.StateWorking {
-				workers[i].State().Set(worker.StateInvalid)
-				_ = workers[i].Stop()
+				// planned recycle, worker keeps serving until the replacement is allocated
+				go func(w worker.BaseProcess) {
+					_ = sp.pool.replaceWorker(w)
+				}(workers[i])
+				sp.events.Push(events.PoolEvent{Event: events.EventTTL, Payload: workers[i]})
+				continue
 			}
 			// just to double check
-			workers[i].State().Set(worker.StateInvalid)
+			workers[i].State().SetReason(worker.StateInvalid, "ttl")
 			sp.events.Push(events.PoolEvent{Event: events.EventTTL, Payload: workers[i]})
 			continue
 		}
@@ -163,11 +449,11 @@ func (sp *supervised) control() { //nolint:gocognit
 			*/
 
 			if workers[i].State().Value() != worker.StateWorking {
-				workers[i].State().Set(worker.StateInvalid)
+				workers[i].State().SetReason(worker.StateInvalid, "max memory")
 				_ = workers[i].Stop()
 			}
 			// just to double check
-			workers[i].State().Set(worker.StateInvalid)
+			workers[i].State().SetReason(worker.StateInvalid, "max memory")
 			sp.events.Push(events.PoolEvent{Event: events.EventMaxMemory, Payload: workers[i]})
 			continue
 		}
@@ -218,11 +504,11 @@ func (sp *supervised) control() { //nolint:gocognit
 				*/
 
 				if workers[i].State().Value() != worker.StateWorking {
-					workers[i].State().Set(worker.StateInvalid)
+					workers[i].State().SetReason(worker.StateInvalid, "idle ttl")
 					_ = workers[i].Stop()
 				}
 				// just to double-check
-				workers[i].State().Set(worker.StateInvalid)
+				workers[i].State().SetReason(worker.StateInvalid, "idle ttl")
 				sp.events.Push(events.PoolEvent{Event: events.EventIdleTTL, Payload: workers[i]})
 			}
 		}
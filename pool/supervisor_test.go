@@ -4,12 +4,18 @@ import (
 	"context"
 	"os"
 	"os/exec"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/shirou/gopsutil/process"
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/frame"
 	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/internal/testclock"
 	"github.com/spiral/roadrunner/v2/payload"
 	"github.com/spiral/roadrunner/v2/transport/pipe"
+	"github.com/spiral/roadrunner/v2/utils"
 	"github.com/spiral/roadrunner/v2/worker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -128,6 +134,53 @@ func TestSupervisedPool_ExecTTL_TimedOut(t *testing.T) {
 	assert.NotEqual(t, pid, p.Workers()[0].Pid())
 }
 
+func TestSupervisedPool_ExecTTL_WorkerKilled(t *testing.T) {
+	var cfgExecTTL = &Config{
+		NumWorkers:      uint64(1),
+		AllocateTimeout: time.Second,
+		DestroyTimeout:  time.Second,
+		Supervisor: &SupervisorConfig{
+			WatchTick: 1 * time.Second,
+			ExecTTL:   1 * time.Second,
+		},
+	}
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		// worker ignores the deadline and sleeps for the 300 seconds
+		func() *exec.Cmd { return exec.Command("php", "../tests/sleep.php", "pipes") },
+		pipe.NewPipeFactory(),
+		cfgExecTTL,
+	)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	defer p.Destroy(context.Background())
+
+	pid := p.Workers()[0].Pid()
+
+	resp, err := p.Exec(&payload.Payload{
+		Context: []byte(""),
+		Body:    []byte("foo"),
+	})
+
+	assert.Error(t, err)
+	assert.True(t, errors.Is(errors.ExecTTL, err))
+	assert.Empty(t, resp)
+
+	// the wedged process should be killed within a bounded time
+	require.Eventually(t, func() bool {
+		exists, errP := process.PidExists(int32(pid))
+		return errP == nil && !exists
+	}, time.Second*2, time.Millisecond*50)
+
+	// and replaced by the watcher
+	require.Eventually(t, func() bool {
+		workers := p.Workers()
+		return len(workers) == 1 && workers[0].Pid() != pid && workers[0].State().Value() == worker.StateReady
+	}, time.Second*5, time.Millisecond*50)
+}
+
 func TestSupervisedPool_ExecTTL_WorkerRestarted(t *testing.T) {
 	var cfgExecTTL = &Config{
 		NumWorkers: uint64(1),
@@ -411,3 +464,183 @@ func TestSupervisedPool_AllocateFailedOK(t *testing.T) {
 		}
 	}()
 }
+
+func TestSupervisedPool_Suspend(t *testing.T) {
+	sp := &supervised{cfg: &SupervisorConfig{MaxSuspend: time.Second}, clock: utils.SystemClock()}
+	now := time.Now()
+	assert.False(t, sp.suspended(now))
+
+	sp.Suspend()
+	assert.True(t, sp.suspended(time.Now()))
+
+	sp.Resume()
+	assert.False(t, sp.suspended(time.Now()))
+
+	// auto-resume after the max suspend duration
+	sp.Suspend()
+	assert.False(t, sp.suspended(time.Now().Add(time.Second*2)))
+	assert.False(t, sp.suspended(time.Now()))
+}
+
+type nopRelay struct{}
+
+func (nopRelay) Send(_ *frame.Frame) error    { return nil }
+func (nopRelay) Receive(_ *frame.Frame) error { return nil }
+func (nopRelay) Close() error                 { return nil }
+
+func TestSupervisedPool_RelaySilent(t *testing.T) {
+	w, err := worker.InitBaseWorker(exec.Command("php", "../tests/sleep.php", "pipes"))
+	require.NoError(t, err)
+	w.AttachRelay(nopRelay{})
+	w.State().Set(worker.StateWorking)
+
+	sp := &supervised{cfg: &SupervisorConfig{MaxRelaySilence: time.Second}}
+	// no relay activity yet
+	assert.False(t, sp.relaySilent(w, time.Now().Add(time.Hour)))
+
+	require.NoError(t, w.Relay().Send(frame.NewFrame()))
+	assert.False(t, sp.relaySilent(w, time.Now()))
+	assert.True(t, sp.relaySilent(w, time.Now().Add(time.Second*2)))
+
+	// idle worker is not hung
+	w.State().Set(worker.StateReady)
+	assert.False(t, sp.relaySilent(w, time.Now().Add(time.Second*2)))
+
+	sp.cfg.MaxRelaySilence = 0
+	w.State().Set(worker.StateWorking)
+	assert.False(t, sp.relaySilent(w, time.Now().Add(time.Second*2)))
+}
+
+func TestSupervisedPool_MaxRelaySilence(t *testing.T) {
+	var cfgSilence = &Config{
+		NumWorkers:      uint64(1),
+		AllocateTimeout: time.Second,
+		DestroyTimeout:  time.Second,
+		Supervisor: &SupervisorConfig{
+			WatchTick:       100 * time.Millisecond,
+			MaxRelaySilence: 1 * time.Second,
+		},
+	}
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		// worker sleeps for the 300 seconds w/o sending anything
+		func() *exec.Cmd { return exec.Command("php", "../tests/sleep.php", "pipes") },
+		pipe.NewPipeFactory(),
+		cfgSilence,
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	pid := p.Workers()[0].Pid()
+
+	_, err = p.Exec(&payload.Payload{Body: []byte("foo")})
+	assert.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		workers := p.Workers()
+		return len(workers) == 1 && workers[0].Pid() != pid && workers[0].State().Value() == worker.StateReady
+	}, time.Second*5, time.Millisecond*50)
+}
+
+// countingSupervisor records the Supervisor calls
+type countingSupervisor struct {
+	pool    Pool
+	started int32
+	stopped int32
+}
+
+func (cs *countingSupervisor) Start() {
+	atomic.AddInt32(&cs.started, 1)
+}
+
+func (cs *countingSupervisor) Stop() {
+	atomic.AddInt32(&cs.stopped, 1)
+}
+
+func (cs *countingSupervisor) Control() {
+	// recycle all the workers
+	workers := cs.pool.Workers()
+	for i := 0; i < len(workers); i++ {
+		_ = cs.pool.replaceWorker(workers[i])
+	}
+}
+
+func TestSupervisedPool_CustomSupervisor(t *testing.T) {
+	cs := &countingSupervisor{}
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		&Config{
+			NumWorkers:      uint64(1),
+			AllocateTimeout: time.Second,
+			DestroyTimeout:  time.Second,
+			// replaced by the custom one
+			Supervisor: &SupervisorConfig{WatchTick: time.Second, TTL: time.Second},
+		},
+		WithSupervisor(func(p Pool) Supervisor {
+			cs.pool = p
+			return cs
+		}),
+	)
+	require.NoError(t, err)
+
+	_, ok := p.(Supervised)
+	assert.False(t, ok)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cs.started))
+
+	pid := p.Workers()[0].Pid()
+	cs.Control()
+	assert.NotEqual(t, pid, p.Workers()[0].Pid())
+
+	p.Destroy(ctx)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cs.stopped))
+}
+
+func TestSupervisedPool_Clock(t *testing.T) {
+	var cfgClock = &Config{
+		NumWorkers:      uint64(1),
+		AllocateTimeout: time.Second,
+		DestroyTimeout:  time.Second,
+		Supervisor: &SupervisorConfig{
+			WatchTick: time.Second,
+			TTL:       time.Minute,
+		},
+	}
+
+	clock := testclock.New(time.Now())
+	ctx := context.Background()
+	p, err := Initialize(
+		ctx,
+		func() *exec.Cmd { return exec.Command("php", "../tests/client.php", "echo", "pipes") },
+		pipe.NewPipeFactory(),
+		cfgClock,
+		WithClock(clock),
+	)
+	require.NoError(t, err)
+	defer func() {
+		// the destroy poll runs on the fake clock, the workers are killed after the timeout
+		ctxD, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+		p.Destroy(ctxD)
+	}()
+
+	pid := p.Workers()[0].Pid()
+
+	// supervisor ticker is started
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond*10)
+
+	// tick before the TTL, the worker is kept
+	clock.Advance(time.Second)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, pid, p.Workers()[0].Pid())
+
+	// TTL reached on the next tick
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		workers := p.Workers()
+		return len(workers) == 1 && workers[0].Pid() != pid && workers[0].State().Value() == worker.StateReady
+	}, time.Second*5, time.Millisecond*50)
+}
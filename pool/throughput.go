@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultThroughputWindow is the Throughput window if not configured
+const defaultThroughputWindow = time.Second * 10
+
+// throughput is the rolling window counter of the executions with the per-second buckets. The bucket is the unix
+// second (high 32 bits) and the count within it (low 32 bits), so the record is a single atomic add except for the
+// first record of the second, which swaps the stale bucket (CAS).
+type throughput struct {
+	// number of the complete seconds the rate is computed over
+	window  uint32
+	buckets []uint64
+}
+
+// newThroughput returns the counter over the window (rounded down to seconds, 1s at least)
+func newThroughput(window time.Duration) *throughput {
+	n := uint32(window / time.Second)
+	if n == 0 {
+		n = 1
+	}
+
+	// +1 - the current (incomplete) second
+	return &throughput{window: n, buckets: make([]uint64, n+1)}
+}
+
+// add records the execution completed at the now
+func (t *throughput) add(now time.Time) {
+	sec := uint32(now.Unix())
+	b := &t.buckets[sec%uint32(len(t.buckets))]
+	for {
+		v := atomic.LoadUint64(b)
+		if uint32(v>>32) == sec {
+			atomic.AddUint64(b, 1)
+			return
+		}
+
+		// the bucket of the previous cycle is reset
+		if atomic.CompareAndSwapUint64(b, v, uint64(sec)<<32|1) {
+			return
+		}
+	}
+}
+
+// rate returns the executions per second over the window complete seconds before the now
+func (t *throughput) rate(now time.Time) float64 {
+	sec := uint32(now.Unix())
+	var total uint64
+	for i := 0; i < len(t.buckets); i++ {
+		v := atomic.LoadUint64(&t.buckets[i])
+		if age := sec - uint32(v>>32); age >= 1 && age <= t.window {
+			total += v & 0xffffffff
+		}
+	}
+
+	return float64(total) / float64(t.window)
+}
+
+// Throughput returns the completed executions per second across the pool, averaged over the ThroughputWindow
+// (complete seconds, the current one is not counted). Control payloads are not counted. The rate is underestimated
+// during the first window after the Initialize.
+func (sp *StaticPool) Throughput() float64 {
+	if sp.throughput == nil {
+		return 0
+	}
+
+	return sp.throughput.rate(sp.clock.Now())
+}
@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/internal/testclock"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Throughput(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tp := newThroughput(time.Second * 4)
+	assert.Equal(t, float64(0), tp.rate(now))
+
+	// 4 execs per second for the 4 seconds
+	for s := 0; s < 4; s++ {
+		for i := 0; i < 4; i++ {
+			tp.add(now.Add(time.Duration(s) * time.Second))
+		}
+	}
+	// the current second is not counted
+	assert.Equal(t, float64(3), tp.rate(now.Add(time.Second*3)))
+	assert.Equal(t, float64(4), tp.rate(now.Add(time.Second*4)))
+
+	// old seconds leave the window
+	assert.Equal(t, float64(2), tp.rate(now.Add(time.Second*6)))
+	assert.Equal(t, float64(0), tp.rate(now.Add(time.Second*8)))
+
+	// stale bucket is reset by the next cycle
+	tp.add(now.Add(time.Second * 10))
+	assert.Equal(t, 0.25, tp.rate(now.Add(time.Second*11)))
+
+	// window is rounded down to seconds, 1s at least
+	tp = newThroughput(time.Millisecond * 100)
+	tp.add(now)
+	tp.add(now)
+	assert.Equal(t, float64(2), tp.rate(now.Add(time.Second)))
+}
+
+func Test_Throughput_Concurrent(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tp := newThroughput(time.Second)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				tp.add(now)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(10000), tp.rate(now.Add(time.Second)))
+}
+
+func Test_StaticPool_Throughput(t *testing.T) {
+	clock := testclock.New(time.Unix(1000, 0))
+	sp := &StaticPool{cfg: &Config{}, clock: clock}
+	// not initialized
+	assert.Equal(t, float64(0), sp.Throughput())
+
+	sp.throughput = newThroughput(time.Second * 2)
+	sp.countExec(&payload.Payload{})
+	sp.countExec(&payload.Payload{})
+	// control payloads are not counted
+	sp.countExec(&payload.Payload{Control: true})
+
+	clock.Advance(time.Second)
+	assert.Equal(t, float64(1), sp.Throughput())
+}
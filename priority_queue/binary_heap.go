@@ -5,12 +5,19 @@ binary heap (min-heap) algorithm used as a core for the priority queue
 package priorityqueue
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/spiral/errors"
 )
 
 type BinHeap struct {
 	items []Item
+	// insertion time (unix nano) of the items, same order as the items
+	inserted []int64
 	// find a way to use pointer to the raw data
 	len    uint64
 	maxLen uint64
@@ -19,7 +26,8 @@ type BinHeap struct {
 
 func NewBinHeap(maxLen uint64) *BinHeap {
 	return &BinHeap{
-		items:  make([]Item, 0, 1000),
+		items:    make([]Item, 0, 1000),
+		inserted: make([]int64, 0, 1000),
 		len:    0,
 		maxLen: maxLen,
 		cond:   sync.Cond{L: &sync.Mutex{}},
@@ -45,6 +53,7 @@ func (bh *BinHeap) fixUp() {
 
 func (bh *BinHeap) swap(i, j uint64) {
 	(bh.items)[i], (bh.items)[j] = (bh.items)[j], (bh.items)[i]
+	bh.inserted[i], bh.inserted[j] = bh.inserted[j], bh.inserted[i]
 }
 
 func (bh *BinHeap) fixDown(curr, end int) {
@@ -90,7 +99,7 @@ func (bh *BinHeap) Insert(item Item) {
 		bh.cond.L.Lock()
 	}
 
-	bh.items = append(bh.items, item)
+	bh.push(item)
 
 	// add len to the slice
 	atomic.AddUint64(&bh.len, 1)
@@ -111,15 +120,95 @@ func (bh *BinHeap) ExtractMin() Item {
 		bh.cond.Wait()
 	}
 
+	item := bh.extract()
+	bh.cond.L.Unlock()
+	return item
+}
+
+// ExtractMinWait blocks until the item is available or the context is canceled
+func (bh *BinHeap) ExtractMinWait(ctx context.Context) (Item, error) {
+	const op = errors.Op("binheap_extract_min_wait")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// lock is needed to not broadcast between the ctx check and the Wait in the loop below
+			bh.cond.L.Lock()
+			bh.cond.Broadcast()
+			bh.cond.L.Unlock()
+		case <-stopCh:
+		}
+	}()
+
+	bh.cond.L.Lock()
+
+	// if len == 0, wait for the signal or the context cancellation
+	for bh.Len() == 0 {
+		if ctx.Err() != nil {
+			bh.cond.L.Unlock()
+			return nil, errors.E(op, errors.TimeOut, ctx.Err())
+		}
+		bh.cond.Wait()
+	}
+
+	item := bh.extract()
+	bh.cond.L.Unlock()
+	return item, nil
+}
+
+// extract removes the min item, should be called under the lock
+func (bh *BinHeap) extract() Item {
 	bh.swap(0, bh.len-1)
 
 	item := (bh.items)[int(bh.len)-1]
 	bh.items = (bh).items[0 : int(bh.len)-1]
+	bh.inserted = bh.inserted[0 : int(bh.len)-1]
 	bh.fixDown(0, int(bh.len-2))
 
 	// reduce len
 	atomic.AddUint64(&bh.len, ^uint64(0))
 
-	bh.cond.L.Unlock()
 	return item
 }
+
+// push appends the item to the end of the heap, fixUp should be called after, should be called under the lock
+func (bh *BinHeap) push(item Item) {
+	bh.items = append(bh.items, item)
+	bh.inserted = append(bh.inserted, time.Now().UnixNano())
+}
+
+// ItemInfo is the metadata of the queued item, see Items
+type ItemInfo struct {
+	ID       string
+	Priority int64
+	// Age is the time since the item was inserted (loaded, for the items restored with the LoadFrom)
+	Age time.Duration
+}
+
+// Items returns the metadata of the queued items w/o removing them (e.g. for monitoring), the highest priority
+// (lowest value) first, the older items first within the same priority. Bodies are not copied. It's the
+// point-in-time snapshot taken under the lock, it might be stale immediately after the return.
+func (bh *BinHeap) Items() []ItemInfo {
+	bh.cond.L.Lock()
+	now := time.Now().UnixNano()
+	items := make([]ItemInfo, 0, len(bh.items))
+	for i := 0; i < len(bh.items); i++ {
+		items = append(items, ItemInfo{
+			ID:       bh.items[i].ID(),
+			Priority: bh.items[i].Priority(),
+			Age:      time.Duration(now - bh.inserted[i]),
+		})
+	}
+	bh.cond.L.Unlock()
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Priority != items[j].Priority {
+			return items[i].Priority < items[j].Priority
+		}
+		return items[i].Age > items[j].Age
+	})
+
+	return items
+}
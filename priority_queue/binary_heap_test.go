@@ -1,8 +1,10 @@
 package priorityqueue
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -87,6 +89,63 @@ func TestBinHeap_MaxLen(t *testing.T) {
 	time.Sleep(time.Second)
 }
 
+func TestBinHeap_ExtractMinWait(t *testing.T) {
+	bh := NewBinHeap(100)
+
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		bh.Insert(Test(42))
+	}()
+
+	item, err := bh.ExtractMinWait(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, Test(42), item)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	item, err = bh.ExtractMinWait(ctx)
+	require.Error(t, err)
+	require.Nil(t, item)
+}
+
+func TestBinHeap_ExtractMinWaitConcurrent(t *testing.T) {
+	bh := NewBinHeap(10000)
+	const items = 1000
+	const consumers = 10
+
+	ctx, cancel := context.WithCancel(context.Background())
+	got := uint64(0)
+	wg := sync.WaitGroup{}
+	wg.Add(consumers)
+
+	for i := 0; i < consumers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				_, err := bh.ExtractMinWait(ctx)
+				if err != nil {
+					return
+				}
+				atomic.AddUint64(&got, 1)
+			}
+		}()
+	}
+
+	for i := 0; i < items; i++ {
+		bh.Insert(Test(rand.Int())) //nolint:gosec
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadUint64(&got) == items
+	}, time.Second*5, time.Millisecond*10)
+
+	// all consumers should exit on the cancellation
+	cancel()
+	wg.Wait()
+	require.Equal(t, uint64(0), bh.Len())
+}
+
 func TestNewPriorityQueue(t *testing.T) {
 	insertsPerSec := uint64(0)
 	getPerSec := uint64(0)
@@ -152,3 +211,27 @@ func TestNewPriorityQueue(t *testing.T) {
 	stopCh <- struct{}{}
 	stopCh <- struct{}{}
 }
+
+func TestBinHeap_Items(t *testing.T) {
+	bh := NewBinHeap(10)
+	bh.Insert(snapItem{Test: 2, id: "b1"})
+	time.Sleep(time.Millisecond)
+	bh.Insert(snapItem{Test: 1, id: "a"})
+	bh.Insert(snapItem{Test: 2, id: "b2"})
+	bh.Insert(snapItem{Test: 3, id: "c"})
+
+	items := bh.Items()
+	require.Len(t, items, 4)
+	ids := make([]string, 0, len(items))
+	for i := 0; i < len(items); i++ {
+		ids = append(ids, items[i].ID)
+	}
+	require.Equal(t, []string{"a", "b1", "b2", "c"}, ids)
+	require.Equal(t, int64(2), items[1].Priority)
+	require.True(t, items[1].Age > items[2].Age)
+
+	// items are not consumed
+	require.Equal(t, uint64(4), bh.Len())
+	require.Equal(t, "a", bh.ExtractMin().ID())
+	require.Len(t, bh.Items(), 3)
+}
@@ -1,8 +1,14 @@
 package priorityqueue
 
+import (
+	"context"
+)
+
 type Queue interface {
 	Insert(item Item)
 	ExtractMin() Item
+	// ExtractMinWait blocks until the item is available or the context is canceled
+	ExtractMinWait(ctx context.Context) (Item, error)
 	Len() uint64
 }
 
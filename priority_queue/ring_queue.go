@@ -0,0 +1,137 @@
+package priorityqueue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spiral/errors"
+)
+
+// ErrQueueFull - the item is inserted into the full RingQueue with the TryInsert
+var ErrQueueFull = errors.Str("queue is full")
+
+// RingQueue is the bounded FIFO Queue backed by the ring buffer: the Priority is ignored, items are extracted in
+// the insertion order. Insert and ExtractMin are O(1). Insert never blocks: the item inserted into the full queue is
+// dropped (Nack-ed and counted, see Dropped), TryInsert returns the ErrQueueFull instead.
+type RingQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+
+	items []Item
+	head  uint64
+	// number of the queued items (atomic, written under the mu)
+	len uint64
+	// number of the items dropped by the Insert into the full queue (atomic)
+	dropped uint64
+}
+
+// NewRingQueue creates the RingQueue with the fixed capacity (1 if 0)
+func NewRingQueue(capacity uint64) *RingQueue {
+	if capacity == 0 {
+		capacity = 1
+	}
+
+	rq := &RingQueue{
+		items: make([]Item, capacity),
+	}
+	rq.notEmpty = sync.NewCond(&rq.mu)
+	return rq
+}
+
+func (rq *RingQueue) Len() uint64 {
+	return atomic.LoadUint64(&rq.len)
+}
+
+// Cap returns the capacity of the queue
+func (rq *RingQueue) Cap() uint64 {
+	return uint64(len(rq.items))
+}
+
+// Dropped returns the number of the items dropped by the Insert into the full queue
+func (rq *RingQueue) Dropped() uint64 {
+	return atomic.LoadUint64(&rq.dropped)
+}
+
+// Insert inserts the item at the tail. If the queue is full, the item is dropped: it is Nack-ed (so the backend may
+// redeliver it) and counted in the Dropped. Use the TryInsert to get the ErrQueueFull instead.
+func (rq *RingQueue) Insert(item Item) {
+	if rq.TryInsert(item) != nil {
+		atomic.AddUint64(&rq.dropped, 1)
+		_ = item.Nack()
+	}
+}
+
+// TryInsert inserts the item at the tail, ErrQueueFull is returned if the queue is full
+func (rq *RingQueue) TryInsert(item Item) error {
+	const op = errors.Op("ring_queue_try_insert")
+	rq.mu.Lock()
+	if rq.len == uint64(len(rq.items)) {
+		rq.mu.Unlock()
+		return errors.E(op, ErrQueueFull)
+	}
+
+	rq.push(item)
+	rq.mu.Unlock()
+	rq.notEmpty.Signal()
+	return nil
+}
+
+// ExtractMin extracts the oldest item, blocks while the queue is empty
+func (rq *RingQueue) ExtractMin() Item {
+	rq.mu.Lock()
+	for rq.len == 0 {
+		rq.notEmpty.Wait()
+	}
+
+	item := rq.pop()
+	rq.mu.Unlock()
+	return item
+}
+
+// ExtractMinWait blocks until the item is available or the context is canceled
+func (rq *RingQueue) ExtractMinWait(ctx context.Context) (Item, error) {
+	const op = errors.Op("ring_queue_extract_min_wait")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// lock is needed to not broadcast between the ctx check and the Wait in the loop below
+			rq.mu.Lock()
+			rq.notEmpty.Broadcast()
+			rq.mu.Unlock()
+		case <-stopCh:
+		}
+	}()
+
+	rq.mu.Lock()
+	for rq.len == 0 {
+		if ctx.Err() != nil {
+			rq.mu.Unlock()
+			return nil, errors.E(op, errors.TimeOut, ctx.Err())
+		}
+		rq.notEmpty.Wait()
+	}
+
+	item := rq.pop()
+	rq.mu.Unlock()
+	return item, nil
+}
+
+// push adds the item at the tail, should be called under the lock with the free space
+func (rq *RingQueue) push(item Item) {
+	rq.items[(rq.head+rq.len)%uint64(len(rq.items))] = item
+	atomic.AddUint64(&rq.len, 1)
+}
+
+// pop removes the item at the head, should be called under the lock on the non-empty queue
+func (rq *RingQueue) pop() Item {
+	item := rq.items[rq.head]
+	// do not hold the extracted item
+	rq.items[rq.head] = nil
+	rq.head = (rq.head + 1) % uint64(len(rq.items))
+	atomic.AddUint64(&rq.len, ^uint64(0))
+	return item
+}
@@ -0,0 +1,102 @@
+package priorityqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingQueue_FIFO(t *testing.T) {
+	var q Queue = NewRingQueue(3)
+
+	// priority is ignored
+	q.Insert(Test(3))
+	q.Insert(Test(1))
+	q.Insert(Test(2))
+	require.Equal(t, uint64(3), q.Len())
+
+	assert.Equal(t, Test(3), q.ExtractMin())
+	// wraps around
+	q.Insert(Test(0))
+	assert.Equal(t, Test(1), q.ExtractMin())
+	assert.Equal(t, Test(2), q.ExtractMin())
+	assert.Equal(t, Test(0), q.ExtractMin())
+	assert.Equal(t, uint64(0), q.Len())
+}
+
+func TestRingQueue_TryInsert(t *testing.T) {
+	rq := NewRingQueue(2)
+	assert.Equal(t, uint64(2), rq.Cap())
+	require.NoError(t, rq.TryInsert(Test(1)))
+	require.NoError(t, rq.TryInsert(Test(2)))
+
+	err := rq.TryInsert(Test(3))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "queue is full")
+	assert.Equal(t, uint64(2), rq.Len())
+
+	assert.Equal(t, Test(1), rq.ExtractMin())
+	require.NoError(t, rq.TryInsert(Test(3)))
+}
+
+// nackItem counts the Nack calls
+type nackItem struct {
+	Test
+	nacks *int
+}
+
+func (n nackItem) Nack() error {
+	*n.nacks++
+	return nil
+}
+
+func TestRingQueue_InsertDropsWhileFull(t *testing.T) {
+	rq := NewRingQueue(1)
+	nacks := 0
+	rq.Insert(nackItem{Test: 1, nacks: &nacks})
+
+	done := make(chan struct{})
+	go func() {
+		rq.Insert(nackItem{Test: 2, nacks: &nacks})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("insert should not block while the queue is full")
+	}
+
+	assert.Equal(t, 1, nacks)
+	assert.Equal(t, uint64(1), rq.Dropped())
+	assert.Equal(t, uint64(1), rq.Len())
+	assert.Equal(t, Test(1), rq.ExtractMin().(nackItem).Test)
+
+	rq.Insert(Test(3))
+	assert.Equal(t, uint64(1), rq.Dropped())
+	assert.Equal(t, Test(3), rq.ExtractMin())
+}
+
+func TestRingQueue_ExtractMinWait(t *testing.T) {
+	rq := NewRingQueue(10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	item, err := rq.ExtractMinWait(ctx)
+	assert.Nil(t, item)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		rq.Insert(Test(5))
+	}()
+
+	item, err = rq.ExtractMinWait(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Test(5), item)
+}
@@ -0,0 +1,135 @@
+package priorityqueue
+
+import (
+	"io"
+	"sort"
+	"sync/atomic"
+
+	j "github.com/json-iterator/go"
+	"github.com/spiral/errors"
+)
+
+var json = j.ConfigCompatibleWithStandardLibrary
+
+// snapshotItem is the serialized Item
+type snapshotItem struct {
+	ID       string `json:"id"`
+	Priority int64  `json:"priority"`
+	Body     []byte `json:"body"`
+	Context  []byte `json:"context"`
+}
+
+// RestoredItem is the Item loaded from the snapshot (see LoadFrom). Ack, Nack and Requeue callbacks can't be
+// serialized, they should be re-bound (AckFn, NackFn, RequeueFn) after the item is extracted, unbound calls return
+// an error.
+type RestoredItem struct {
+	id       string
+	priority int64
+	body     []byte
+	context  []byte
+
+	AckFn     func() error
+	NackFn    func() error
+	RequeueFn func(headers map[string][]string, delay int64) error
+}
+
+func (ri *RestoredItem) ID() string {
+	return ri.id
+}
+
+func (ri *RestoredItem) Priority() int64 {
+	return ri.priority
+}
+
+func (ri *RestoredItem) Body() []byte {
+	return ri.body
+}
+
+func (ri *RestoredItem) Context() ([]byte, error) {
+	return ri.context, nil
+}
+
+func (ri *RestoredItem) Ack() error {
+	if ri.AckFn == nil {
+		return errors.E(errors.Op("restored_item_ack"), errors.Errorf("item %s is restored from the snapshot, Ack is not bound", ri.id))
+	}
+	return ri.AckFn()
+}
+
+func (ri *RestoredItem) Nack() error {
+	if ri.NackFn == nil {
+		return errors.E(errors.Op("restored_item_nack"), errors.Errorf("item %s is restored from the snapshot, Nack is not bound", ri.id))
+	}
+	return ri.NackFn()
+}
+
+func (ri *RestoredItem) Requeue(headers map[string][]string, delay int64) error {
+	if ri.RequeueFn == nil {
+		return errors.E(errors.Op("restored_item_requeue"), errors.Errorf("item %s is restored from the snapshot, Requeue is not bound", ri.id))
+	}
+	return ri.RequeueFn(headers, delay)
+}
+
+// SaveTo writes the snapshot of the queued items (ID, priority, body and context) to the writer, the highest
+// priority (lowest value) first. Items are not removed from the queue. Ack, Nack and Requeue callbacks are not
+// serialized, see RestoredItem.
+func (bh *BinHeap) SaveTo(w io.Writer) error {
+	const op = errors.Op("binheap_save_to")
+	bh.cond.L.Lock()
+	items := make([]snapshotItem, 0, len(bh.items))
+	for i := 0; i < len(bh.items); i++ {
+		ctx, err := bh.items[i].Context()
+		if err != nil {
+			bh.cond.L.Unlock()
+			return errors.E(op, errors.Errorf("item %s context: %v", bh.items[i].ID(), err))
+		}
+
+		items = append(items, snapshotItem{
+			ID:       bh.items[i].ID(),
+			Priority: bh.items[i].Priority(),
+			Body:     bh.items[i].Body(),
+			Context:  ctx,
+		})
+	}
+	bh.cond.L.Unlock()
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Priority < items[j].Priority
+	})
+
+	err := json.NewEncoder(w).Encode(items)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// LoadFrom reads the snapshot written by the SaveTo and inserts the items as the RestoredItem (callbacks should be
+// re-bound). Items are added to the already queued ones, the max length is not applied.
+func (bh *BinHeap) LoadFrom(r io.Reader) error {
+	const op = errors.Op("binheap_load_from")
+	var items []snapshotItem
+	err := json.NewDecoder(r).Decode(&items)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	bh.cond.L.Lock()
+	for i := 0; i < len(items); i++ {
+		bh.push(&RestoredItem{
+			id:       items[i].ID,
+			priority: items[i].Priority,
+			body:     items[i].Body,
+			context:  items[i].Context,
+		})
+		atomic.AddUint64(&bh.len, 1)
+		bh.fixUp()
+	}
+	bh.cond.L.Unlock()
+
+	// wake up the waiting consumers
+	bh.cond.Broadcast()
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package priorityqueue
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapItem struct {
+	Test
+	id   string
+	body string
+}
+
+func (si snapItem) ID() string               { return si.id }
+func (si snapItem) Body() []byte             { return []byte(si.body) }
+func (si snapItem) Context() ([]byte, error) { return []byte(`{"id":"` + si.id + `"}`), nil }
+
+func TestBinHeap_Snapshot(t *testing.T) {
+	bh := NewBinHeap(10)
+	bh.Insert(snapItem{Test: 3, id: "c", body: "third"})
+	bh.Insert(snapItem{Test: 1, id: "a", body: "first"})
+	bh.Insert(snapItem{Test: 2, id: "b", body: "second"})
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, bh.SaveTo(buf))
+	// snapshot doesn't remove the items
+	assert.Equal(t, uint64(3), bh.Len())
+
+	restored := NewBinHeap(10)
+	require.NoError(t, restored.LoadFrom(buf))
+	require.Equal(t, uint64(3), restored.Len())
+
+	for _, id := range []string{"a", "b", "c"} {
+		item := restored.ExtractMin()
+		assert.Equal(t, id, item.ID())
+		assert.Equal(t, bh.ExtractMin().Body(), item.Body())
+		ctx, err := item.Context()
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"`+id+`"}`, string(ctx))
+
+		// callbacks are not serialized
+		assert.Error(t, item.Ack())
+		acked := false
+		item.(*RestoredItem).AckFn = func() error { acked = true; return nil }
+		assert.NoError(t, item.Ack())
+		assert.True(t, acked)
+	}
+
+	assert.Error(t, restored.LoadFrom(bytes.NewBufferString("not a snapshot")))
+}
@@ -24,11 +24,26 @@ type State struct {
 	// Values might vary for different operating systems and based on RSS.
 	MemoryUsage uint64 `json:"memoryUsage"`
 
+	// BytesSent is the number of bytes sent to the worker via the relay.
+	BytesSent uint64 `json:"bytesSent"`
+
+	// BytesReceived is the number of bytes received from the worker via the relay.
+	BytesReceived uint64 `json:"bytesReceived"`
+
 	// CPU_Percent returns how many percent of the CPU time this process uses
 	CPUPercent float64
 
 	// Command used in the service plugin and shows a command for the particular service
 	Command string
+
+	// Labels attached to the worker
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// InFlight is the number of the requests the worker is executing
+	InFlight int `json:"inFlight"`
+
+	// LastResponseAt is unix nano timestamp of the last successful response, 0 - never responded.
+	LastResponseAt int64 `json:"lastResponseAt"`
 }
 
 // WorkerProcessState creates new worker state definition.
@@ -52,9 +67,26 @@ func WorkerProcessState(w worker.BaseProcess) (*State, error) {
 		NumJobs:     w.State().NumExecs(),
 		Created:     w.Created().UnixNano(),
 		MemoryUsage: i.RSS,
+		Labels:      w.Labels(),
+
+		BytesSent:     w.BytesSent(),
+		BytesReceived: w.BytesReceived(),
+		InFlight:      w.InFlight(),
+
+		LastResponseAt: lastResponseAt(w),
 	}, nil
 }
 
+// lastResponseAt returns the unix nano time of the last worker response, 0 if the worker never responded
+func lastResponseAt(w worker.BaseProcess) int64 {
+	t := w.State().LastResponseAt()
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
 func GeneralProcessState(pid int, command string) (State, error) {
 	const op = errors.Op("process_state")
 	p, _ := process.NewProcess(int32(pid))
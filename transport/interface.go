@@ -19,3 +19,9 @@ type Factory interface {
 	// Close the factory and underlying connections.
 	Close() error
 }
+
+// Named is implemented by the factories reporting the transport name (e.g. "pipes", "sockets"), the name is
+// available as the worker Transport, so the transport migration (pool SetFactory) is observable.
+type Named interface {
+	Name() string
+}
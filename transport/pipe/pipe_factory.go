@@ -22,6 +22,11 @@ func NewPipeFactory() *Factory {
 	return &Factory{}
 }
 
+// Name returns the transport name, transport.Named interface
+func (f *Factory) Name() string {
+	return "pipes"
+}
+
 type sr struct {
 	w   *worker.Process
 	err error
@@ -72,9 +77,12 @@ func (f *Factory) SpawnWorkerWithTimeout(ctx context.Context, cmd *exec.Cmd, lis
 			}
 		}
 
-		// Init new PIPE relay
-		relay := pipe.NewPipeRelay(in, out)
-		w.AttachRelay(relay)
+		// Init new PIPE relay, the writes are counted to tell the partially sent requests, the reads - to tell the
+		// stalled responses
+		wc := &worker.WriteCounter{}
+		rc := &worker.ReadCounter{}
+		relay := pipe.NewPipeRelay(rc.ReadCloser(in), wc.WriteCloser(out))
+		w.AttachRelay(worker.WithReadCounter(worker.WithWriteCounter(relay, wc), rc))
 
 		// Start the worker
 		err = w.Start()
@@ -166,9 +174,12 @@ func (f *Factory) SpawnWorker(cmd *exec.Cmd, listeners ...events.Listener) (*wor
 		return nil, errors.E(op, err)
 	}
 
-	// Init new PIPE relay
-	relay := pipe.NewPipeRelay(in, out)
-	w.AttachRelay(relay)
+	// Init new PIPE relay, the writes are counted to tell the partially sent requests, the reads - to tell the
+	// stalled responses
+	wc := &worker.WriteCounter{}
+	rc := &worker.ReadCounter{}
+	relay := pipe.NewPipeRelay(rc.ReadCloser(in), wc.WriteCloser(out))
+	w.AttachRelay(worker.WithReadCounter(worker.WithWriteCounter(relay, wc), rc))
 
 	// Start the worker
 	err = w.Start()
@@ -55,6 +55,11 @@ func NewSocketServer(ls net.Listener, tout time.Duration) *Factory {
 	return f
 }
 
+// Name returns the transport name, transport.Named interface
+func (f *Factory) Name() string {
+	return "sockets"
+}
+
 // blocking operation, returns an error
 func (f *Factory) listen() error {
 	errGr := &errgroup.Group{}
@@ -65,7 +70,10 @@ func (f *Factory) listen() error {
 				return err
 			}
 
-			rl := socket.NewSocketRelay(conn)
+			// the writes are counted to tell the partially sent requests, the reads - to tell the stalled responses
+			wc := &worker.WriteCounter{}
+			rc := &worker.ReadCounter{}
+			rl := worker.WithReadCounter(worker.WithWriteCounter(socket.NewSocketRelay(rc.ReadWriteCloser(wc.ReadWriteCloser(conn))), wc), rc)
 			pid, err := internal.FetchPID(rl)
 			if err != nil {
 				return err
@@ -209,7 +217,7 @@ func (f *Factory) Close() error {
 }
 
 // waits for Process to connect over socket and returns associated relay of timeout
-func (f *Factory) findRelayWithContext(ctx context.Context, w worker.BaseProcess) (*socket.Relay, error) {
+func (f *Factory) findRelayWithContext(ctx context.Context, w worker.BaseProcess) (relay.Relay, error) {
 	ticker := time.NewTicker(time.Millisecond * 10)
 	for {
 		select {
@@ -226,12 +234,12 @@ func (f *Factory) findRelayWithContext(ctx context.Context, w worker.BaseProcess
 			if !ok {
 				continue
 			}
-			return tmp.(*socket.Relay), nil
+			return tmp.(relay.Relay), nil
 		}
 	}
 }
 
-func (f *Factory) findRelay(w worker.BaseProcess) (*socket.Relay, error) {
+func (f *Factory) findRelay(w worker.BaseProcess) (relay.Relay, error) {
 	const op = errors.Op("factory_find_relay")
 	// poll every 1ms for the relay
 	pollDone := time.NewTimer(f.tout)
@@ -244,7 +252,7 @@ func (f *Factory) findRelay(w worker.BaseProcess) (*socket.Relay, error) {
 			if !ok {
 				continue
 			}
-			return tmp.(*socket.Relay), nil
+			return tmp.(relay.Relay), nil
 		}
 	}
 }
@@ -0,0 +1,149 @@
+// Package testtransport provides the transport.Factory of the synthetic workers for the capacity and the load
+// testing of the pool (Take/Release, supervisor, autoscaler) w/o the PHP. The worker echoes the request after the
+// configured latency and fails the configured fraction of the requests.
+package testtransport
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/pipe"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/internal"
+	"github.com/spiral/roadrunner/v2/worker"
+	"go.uber.org/multierr"
+)
+
+// Config .. synthetic workers config
+type Config struct {
+	// Latency defines the execution time of the request.
+	Latency time.Duration
+	// Jitter defines the random addition to the Latency, [0, Jitter).
+	Jitter time.Duration
+	// FailRate defines the fraction of the requests failed with the errors.SoftJob error, 0 - never, 1 - always.
+	FailRate float64
+	// SpawnLatency defines the time the worker takes to become ready.
+	SpawnLatency time.Duration
+	// Placeholder defines the command of the placeholder process backing the worker (pid, Wait, Kill, memory), it
+	// should run until the stdin is closed. Defaults to "cat". The pool command is ignored, except the env and the dir.
+	Placeholder []string
+}
+
+// Factory spawns the synthetic workers, the relay is an in-memory pipe served by the goroutine per worker.
+type Factory struct {
+	cfg Config
+}
+
+// NewFactory returns the synthetic workers factory
+func NewFactory(cfg Config) *Factory {
+	if len(cfg.Placeholder) == 0 {
+		cfg.Placeholder = []string{"cat"}
+	}
+
+	return &Factory{cfg: cfg}
+}
+
+// Name returns the transport name, transport.Named interface
+func (f *Factory) Name() string {
+	return "synthetic"
+}
+
+// SpawnWorkerWithTimeout creates the synthetic worker, the ctx bounds the SpawnLatency.
+func (f *Factory) SpawnWorkerWithTimeout(ctx context.Context, cmd *exec.Cmd, listeners ...events.Listener) (*worker.Process, error) {
+	const op = errors.Op("synthetic_factory_spawn_worker_with_timeout")
+	if f.cfg.SpawnLatency > 0 {
+		timer := time.NewTimer(f.cfg.SpawnLatency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.E(op, errors.TimeOut, ctx.Err())
+		}
+	}
+
+	w, err := f.spawn(cmd, listeners)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return w, nil
+}
+
+// SpawnWorker creates the synthetic worker.
+func (f *Factory) SpawnWorker(cmd *exec.Cmd, listeners ...events.Listener) (*worker.Process, error) {
+	const op = errors.Op("synthetic_factory_spawn_worker")
+	if f.cfg.SpawnLatency > 0 {
+		time.Sleep(f.cfg.SpawnLatency)
+	}
+
+	w, err := f.spawn(cmd, listeners)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return w, nil
+}
+
+// Close does nothing, the workers are stopped by the pool.
+func (f *Factory) Close() error {
+	return nil
+}
+
+func (f *Factory) spawn(cmd *exec.Cmd, listeners []events.Listener) (*worker.Process, error) {
+	placeholder := exec.Command(f.cfg.Placeholder[0], f.cfg.Placeholder[1:]...) //nolint:gosec
+	placeholder.Env = cmd.Env
+	placeholder.Dir = cmd.Dir
+
+	w, err := worker.InitBaseWorker(placeholder, worker.AddListeners(listeners...))
+	if err != nil {
+		return nil, err
+	}
+
+	// the placeholder exits once the stdin is closed (stop command)
+	stdin, err := placeholder.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	reqR, reqW := io.Pipe()
+	rspR, rspW := io.Pipe()
+	rc := &worker.ReadCounter{}
+	w.AttachRelay(worker.WithReadCounter(&relay{Relay: pipe.NewPipeRelay(rc.ReadCloser(rspR), reqW), closers: []io.Closer{rspR, reqW}}, rc))
+
+	err = w.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	sw := newSyntheticWorker(f.cfg, placeholder.Process.Pid, pipe.NewPipeRelay(reqR, rspW), stdin)
+	go sw.serve()
+
+	pid, err := internal.FetchPID(w.Relay())
+	if pid != w.Pid() {
+		return nil, multierr.Combine(
+			errors.Errorf("pid mismatches, get: %d, want: %d", pid, w.Pid()),
+			err,
+			w.Kill(),
+			w.Wait(),
+		)
+	}
+
+	// everything ok, set ready state
+	w.State().Set(worker.StateReady)
+	return w, nil
+}
+
+// relay is the pool side of the in-memory pipe, closed once the worker process is waited
+type relay struct {
+	*pipe.Relay
+	closers []io.Closer
+}
+
+func (rl *relay) Close() error {
+	var err error
+	for i := 0; i < len(rl.closers); i++ {
+		err = multierr.Append(err, rl.closers[i].Close())
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package testtransport
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/pool"
+	"github.com/spiral/roadrunner/v2/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SyntheticWorker(t *testing.T) {
+	w, err := NewFactory(Config{Latency: time.Millisecond * 10}).SpawnWorkerWithTimeout(context.Background(), exec.Command("php", "worker.php"))
+	require.NoError(t, err)
+	assert.Equal(t, worker.StateReady, w.State().Value())
+	assert.NotEqual(t, int64(0), w.Pid())
+
+	sw := worker.From(w)
+	start := time.Now()
+	rsp, err := sw.Exec(&payload.Payload{Context: []byte("ctx"), Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*10)
+	assert.Equal(t, "ctx", string(rsp.Context))
+	assert.Equal(t, "hello", rsp.String())
+
+	diag, err := sw.Introspect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, true, diag["synthetic"])
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, w.Wait())
+		assert.Equal(t, worker.StateStopped, w.State().Value())
+	}()
+	require.NoError(t, w.Stop())
+	wg.Wait()
+}
+
+func Test_SyntheticWorker_Failures(t *testing.T) {
+	w, err := NewFactory(Config{FailRate: 1}).SpawnWorker(exec.Command("php", "worker.php"))
+	require.NoError(t, err)
+
+	sw := worker.From(w)
+	_, err = sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.SoftJob, err))
+	assert.Contains(t, err.Error(), failure)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Error(t, w.Wait())
+	}()
+	require.NoError(t, w.Kill())
+	wg.Wait()
+}
+
+func Test_SyntheticWorker_SpawnTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	_, err := NewFactory(Config{SpawnLatency: time.Second}).SpawnWorkerWithTimeout(ctx, exec.Command("php", "worker.php"))
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+}
+
+func Test_SyntheticPool(t *testing.T) {
+	p, err := pool.Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "worker.php") },
+		NewFactory(Config{Latency: time.Millisecond, Jitter: time.Millisecond, FailRate: 0.2}),
+		&pool.Config{NumWorkers: 4, AllocateTimeout: time.Second * 5, DestroyTimeout: time.Second * 5},
+	)
+	require.NoError(t, err)
+	defer p.Destroy(context.Background())
+
+	var mu sync.Mutex
+	var ok, failed int
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				rsp, err := p.Exec(&payload.Payload{Body: []byte("hello")})
+				mu.Lock()
+				if err != nil {
+					assert.True(t, errors.Is(errors.SoftJob, err))
+					failed++
+				} else {
+					assert.Equal(t, "hello", rsp.String())
+					ok++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 400, ok+failed)
+	assert.Greater(t, ok, 0)
+	assert.Greater(t, failed, 0)
+	// workers released after the failed requests are replaced in the background
+	require.Eventually(t, func() bool {
+		return len(p.Workers()) == 4
+	}, time.Second*5, time.Millisecond*10)
+	for _, w := range p.Workers() {
+		assert.Equal(t, "synthetic", w.Transport())
+	}
+}
+
+// Benchmark_SyntheticPool measures the pool routing (Take/Release) under the contention, w/o the PHP
+func Benchmark_SyntheticPool(b *testing.B) {
+	p, err := pool.Initialize(
+		context.Background(),
+		func() *exec.Cmd { return exec.Command("php", "worker.php") },
+		NewFactory(Config{}),
+		&pool.Config{NumWorkers: 4, AllocateTimeout: time.Second * 5, DestroyTimeout: time.Second * 5},
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Destroy(context.Background())
+
+	pld := &payload.Payload{Body: []byte("hello")}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := p.Exec(pld); err != nil {
+				b.Fail()
+			}
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package testtransport
+
+import (
+	"io"
+	"math/rand"
+	"time"
+
+	j "github.com/json-iterator/go"
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/goridge/v3/pkg/pipe"
+)
+
+var json = j.ConfigCompatibleWithStandardLibrary
+
+// failure is the error payload of the failed requests
+const failure = "synthetic failure"
+
+// control is any of the CONTROL commands (pid, stop, introspect)
+type control struct {
+	Pid        int  `json:"pid,omitempty"`
+	Stop       bool `json:"stop,omitempty"`
+	Introspect bool `json:"introspect,omitempty"`
+}
+
+// syntheticWorker is the worker side of the relay, served by a single goroutine (requests are sequential)
+type syntheticWorker struct {
+	cfg   Config
+	pid   int
+	rl    *pipe.Relay
+	stdin io.Closer
+	rnd   *rand.Rand
+	execs uint64
+}
+
+func newSyntheticWorker(cfg Config, pid int, rl *pipe.Relay, stdin io.Closer) *syntheticWorker {
+	return &syntheticWorker{
+		cfg:   cfg,
+		pid:   pid,
+		rl:    rl,
+		stdin: stdin,
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano() + int64(pid))), //nolint:gosec
+	}
+}
+
+// serve handles the frames until the stop command or until the relay is closed (the worker is killed)
+func (sw *syntheticWorker) serve() {
+	// the placeholder process exits with the worker
+	defer func() {
+		_ = sw.stdin.Close()
+	}()
+
+	for {
+		fr := frame.NewFrame()
+		err := sw.rl.Receive(fr)
+		if err != nil {
+			return
+		}
+
+		if fr.ReadFlags()&frame.CONTROL != 0 {
+			if !sw.control(fr) {
+				return
+			}
+			continue
+		}
+
+		err = sw.rl.Send(sw.exec(fr))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// control responds to the CONTROL command, false - the worker is stopped
+func (sw *syntheticWorker) control(fr *frame.Frame) bool {
+	cmd := &control{}
+	err := json.Unmarshal(fr.Payload(), cmd)
+	if err != nil {
+		return false
+	}
+
+	var rsp interface{}
+	switch {
+	case cmd.Stop:
+		return false
+	case cmd.Pid != 0:
+		rsp = control{Pid: sw.pid}
+	case cmd.Introspect:
+		rsp = map[string]interface{}{"synthetic": true, "execs": sw.execs}
+	default:
+		return false
+	}
+
+	data, err := json.Marshal(rsp)
+	if err != nil {
+		return false
+	}
+
+	out := frame.NewFrame()
+	out.WriteVersion(out.Header(), frame.VERSION_1)
+	out.WriteFlags(out.Header(), frame.CONTROL)
+	out.WritePayloadLen(out.Header(), uint32(len(data)))
+	out.WritePayload(data)
+	out.WriteCRC(out.Header())
+
+	return sw.rl.Send(out) == nil
+}
+
+// exec echoes the request (context, body, codec, checksum and correlation id) after the latency, or fails it
+func (sw *syntheticWorker) exec(fr *frame.Frame) *frame.Frame {
+	sw.execs++
+	latency := sw.cfg.Latency
+	if sw.cfg.Jitter > 0 {
+		latency += time.Duration(sw.rnd.Int63n(int64(sw.cfg.Jitter)))
+	}
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	out := frame.NewFrame()
+	out.WriteVersion(out.Header(), frame.VERSION_1)
+
+	if sw.cfg.FailRate > 0 && sw.rnd.Float64() < sw.cfg.FailRate {
+		out.WriteFlags(out.Header(), frame.ERROR)
+		out.WritePayloadLen(out.Header(), uint32(len(failure)))
+		out.WritePayload([]byte(failure))
+		out.WriteCRC(out.Header())
+		return out
+	}
+
+	if flags := fr.ReadFlags(); flags != 0 {
+		out.WriteFlags(out.Header(), flags)
+	}
+	if options := fr.ReadOptions(fr.Header()); len(options) != 0 {
+		out.WriteOptions(out.HeaderPtr(), options...)
+	}
+	out.WritePayloadLen(out.Header(), uint32(len(fr.Payload())))
+	out.WritePayload(fr.Payload())
+	out.WriteCRC(out.Header())
+	return out
+}
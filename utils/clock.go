@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"time"
+)
+
+// Clock is the time source of the pool timers (supervisor ticks, allocation retries, destroy poll). The system clock
+// is used by default, the fake one lets the tests advance the time instead of sleeping.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel
+	After(d time.Duration) <-chan time.Time
+	// NewTicker returns the ticker sending the current time every period
+	NewTicker(d time.Duration) Ticker
+	// NewTimer returns the timer sending the current time once after the duration
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls the f in its own goroutine after the duration, the returned timer has no channel
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker is the ticker created by the Clock
+type Ticker interface {
+	// C returns the channel the ticks are delivered on
+	C() <-chan time.Time
+	// Stop turns off the ticker, no more ticks are sent
+	Stop()
+}
+
+// Timer is the one-shot timer created by the Clock
+type Timer interface {
+	// C returns the channel the time is delivered on, nil for the AfterFunc timer
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, returns false if the timer has already fired or been stopped
+	Stop() bool
+	// Reset changes the timer to fire after the duration, returns true if the timer had been active
+	Reset(d time.Duration) bool
+}
+
+// SystemClock returns the Clock backed by the time package
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	t := time.NewTimer(d)
+	return systemTimer{t: t, c: t.C}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{t: time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+	c <-chan time.Time
+}
+
+func (st systemTimer) C() <-chan time.Time {
+	return st.c
+}
+
+func (st systemTimer) Stop() bool {
+	return st.t.Stop()
+}
+
+func (st systemTimer) Reset(d time.Duration) bool {
+	return st.t.Reset(d)
+}
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (st systemTicker) C() <-chan time.Time {
+	return st.t.C
+}
+
+func (st systemTicker) Stop() {
+	st.t.Stop()
+}
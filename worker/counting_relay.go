@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/goridge/v3/pkg/relay"
+)
+
+// countingRelay counts the bytes (frame header and payload) sent to and received from the worker,
+// and records the time (unix nano) of the last frame sent or received
+type countingRelay struct {
+	relay.Relay
+	sent         *uint64
+	received     *uint64
+	lastActivity *int64
+	// transport writes counter (see WithWriteCounter), nil - the send progress is unknown
+	writes *WriteCounter
+	// transport reads counter (see WithReadCounter), nil - the response progress is unknown
+	reads *ReadCounter
+}
+
+// Send returns the SendError on failure
+func (cr *countingRelay) Send(fr *frame.Frame) error {
+	var before uint64
+	if cr.writes != nil {
+		before = cr.writes.Written()
+	}
+
+	err := cr.Relay.Send(fr)
+	if err != nil {
+		if cr.writes == nil {
+			return &SendError{Err: err}
+		}
+
+		sent := cr.writes.Written() - before
+		return &SendError{Sent: sent, Unsent: sent == 0, Err: err}
+	}
+
+	atomic.AddUint64(cr.sent, uint64(len(fr.Header())+len(fr.Payload())))
+	atomic.StoreInt64(cr.lastActivity, time.Now().UnixNano())
+	return nil
+}
+
+func (cr *countingRelay) Receive(fr *frame.Frame) error {
+	err := cr.Relay.Receive(fr)
+	if err != nil {
+		return err
+	}
+
+	atomic.AddUint64(cr.received, uint64(len(fr.Header())+len(fr.Payload())))
+	atomic.StoreInt64(cr.lastActivity, time.Now().UnixNano())
+	return nil
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loopRelay struct {
+	last *frame.Frame
+}
+
+func (lr *loopRelay) Send(fr *frame.Frame) error {
+	lr.last = fr
+	return nil
+}
+
+func (lr *loopRelay) Receive(fr *frame.Frame) error {
+	fr.WritePayload(lr.last.Payload())
+	return nil
+}
+
+func (lr *loopRelay) Close() error { return nil }
+
+func Test_CountingRelay(t *testing.T) {
+	w := &Process{}
+	assert.True(t, w.LastRelayActivity().IsZero())
+	w.AttachRelay(&loopRelay{})
+
+	fr := frame.NewFrame()
+	fr.WritePayload([]byte("hello"))
+	require.NoError(t, w.Relay().Send(fr))
+	assert.Equal(t, uint64(len(fr.Header())+5), w.BytesSent())
+	assert.Equal(t, uint64(0), w.BytesReceived())
+
+	sent := w.LastRelayActivity()
+	assert.False(t, sent.IsZero())
+
+	frR := frame.NewFrame()
+	require.NoError(t, w.Relay().Receive(frR))
+	assert.Equal(t, uint64(len(frR.Header())+5), w.BytesReceived())
+	assert.False(t, w.LastRelayActivity().Before(sent))
+}
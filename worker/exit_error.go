@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/spiral/errors"
+	"go.uber.org/multierr"
+)
+
+// ExitReason is the reason of the fatal worker exit, see ExitError
+type ExitReason uint8
+
+const (
+	// ExitCrashed - the worker is terminated by the SIGSEGV or the SIGBUS (e.g. the extension segfault)
+	ExitCrashed ExitReason = iota + 1
+	// ExitOOMKilled - the worker is killed by the SIGKILL which is not sent by the pool, most likely the OOM killer
+	ExitOOMKilled
+	// ExitAborted - the worker is terminated by the SIGABRT
+	ExitAborted
+)
+
+func (r ExitReason) String() string {
+	switch r {
+	case ExitCrashed:
+		return "worker crashed"
+	case ExitOOMKilled:
+		return "OOM killed"
+	case ExitAborted:
+		return "worker aborted"
+	default:
+		return "unknown"
+	}
+}
+
+// shell exit codes of the processes terminated by the signals (128 + signal), when the worker is started via the shell
+const (
+	exitCodeAbort = 128 + 6
+	exitCodeKill  = 128 + 9
+	exitCodeSegv  = 128 + 11
+)
+
+// ExitError is the Wait error of the worker terminated by the fatal signal (or the shell exit code of it), so the
+// crashes and the OOM kills can be told apart from the regular exits (EventWorkerProcessExit).
+type ExitError struct {
+	Reason ExitReason
+	// Signal is the terminating signal, 0 if the reason is taken from the exit code
+	Signal syscall.Signal
+	// Code is the exit code, -1 if the worker is terminated by the signal
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != 0 {
+		return fmt.Sprintf("%s (signal: %s): %v", e.Reason, e.Signal, e.Err)
+	}
+
+	return fmt.Sprintf("%s (exit code: %d): %v", e.Reason, e.Code, e.Err)
+}
+
+// AsExitError returns the ExitError of the Wait error (wrapped via errors.E or combined), false if there is none
+func AsExitError(err error) (*ExitError, bool) {
+	errs := multierr.Errors(err)
+	for i := 0; i < len(errs); i++ {
+		err = errs[i]
+		for err != nil {
+			if ee, ok := err.(*ExitError); ok {
+				return ee, true
+			}
+
+			e, ok := err.(*errors.Error)
+			if !ok {
+				break
+			}
+			err = e.Err
+		}
+	}
+
+	return nil, false
+}
+
+// ExitCode returns the exit code of the Wait error (wrapped via errors.E or combined), false if the process is not
+// exited by itself with the code (e.g. terminated by the signal)
+func ExitCode(err error) (int, bool) {
+	errs := multierr.Errors(err)
+	for i := 0; i < len(errs); i++ {
+		err = errs[i]
+		for err != nil {
+			switch e := err.(type) {
+			case *exec.ExitError:
+				code := e.ExitCode()
+				return code, code >= 0
+			case *ExitError:
+				err = e.Err
+			case *errors.Error:
+				err = e.Err
+			default:
+				err = nil
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// exitError classifies the exit of the waited process, nil if the exit is not fatal. SIGKILL is classified only if
+// the process is not killed by the pool (Kill, Stop).
+func exitError(ps *os.ProcessState, err error, killed bool) *ExitError {
+	if ps == nil || err == nil {
+		return nil
+	}
+
+	ws, ok := ps.Sys().(syscall.WaitStatus)
+	if !ok {
+		return nil
+	}
+
+	code := ws.ExitStatus()
+	var sig syscall.Signal
+	if ws.Signaled() {
+		sig = ws.Signal()
+		code = -1
+	}
+
+	var reason ExitReason
+	switch {
+	case sig == syscall.SIGSEGV || sig == syscall.SIGBUS || code == exitCodeSegv:
+		reason = ExitCrashed
+	case sig == syscall.SIGABRT || code == exitCodeAbort:
+		reason = ExitAborted
+	case (sig == syscall.SIGKILL || code == exitCodeKill) && !killed:
+		reason = ExitOOMKilled
+	default:
+		return nil
+	}
+
+	return &ExitError{Reason: reason, Signal: sig, Code: code, Err: err}
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+)
+
+func exitOf(t *testing.T, script string, killed bool) *ExitError {
+	cmd := exec.Command("sh", "-c", script)
+	err := cmd.Run()
+	require.Error(t, err)
+	return exitError(cmd.ProcessState, err, killed)
+}
+
+func Test_ExitError(t *testing.T) {
+	ee := exitOf(t, "kill -SEGV $$", false)
+	require.NotNil(t, ee)
+	assert.Equal(t, ExitCrashed, ee.Reason)
+	assert.Equal(t, syscall.SIGSEGV, ee.Signal)
+	assert.Contains(t, ee.Error(), "worker crashed")
+
+	ee = exitOf(t, "kill -ABRT $$", false)
+	require.NotNil(t, ee)
+	assert.Equal(t, ExitAborted, ee.Reason)
+
+	ee = exitOf(t, "kill -KILL $$", false)
+	require.NotNil(t, ee)
+	assert.Equal(t, ExitOOMKilled, ee.Reason)
+	assert.Contains(t, ee.Error(), "OOM killed")
+
+	// the shell exit code of the killed child
+	ee = exitOf(t, "exit 137", false)
+	require.NotNil(t, ee)
+	assert.Equal(t, ExitOOMKilled, ee.Reason)
+	assert.Equal(t, 137, ee.Code)
+
+	// killed by the pool
+	assert.Nil(t, exitOf(t, "kill -KILL $$", true))
+	// regular exits
+	assert.Nil(t, exitOf(t, "exit 1", false))
+	assert.Nil(t, exitOf(t, "kill -TERM $$", false))
+}
+
+func Test_AsExitError(t *testing.T) {
+	ee := &ExitError{Reason: ExitCrashed, Signal: syscall.SIGSEGV, Code: -1, Err: errors.Str("signal: segmentation fault")}
+	err := multierr.Combine(ee, errors.E(errors.Op("worker_process_wait"), ee))
+
+	got, ok := AsExitError(err)
+	require.True(t, ok)
+	assert.Equal(t, ee, got)
+
+	got, ok = AsExitError(errors.E(errors.Op("test"), ee))
+	require.True(t, ok)
+	assert.Equal(t, ee, got)
+
+	_, ok = AsExitError(errors.Str("exit status 1"))
+	assert.False(t, ok)
+	_, ok = AsExitError(nil)
+	assert.False(t, ok)
+}
+
+func Test_ExitCode(t *testing.T) {
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	require.Error(t, err)
+
+	code, ok := ExitCode(multierr.Combine(err, errors.E(errors.Op("worker_process_wait"), err)))
+	require.True(t, ok)
+	assert.Equal(t, 3, code)
+
+	// classified exit
+	ee := exitOf(t, "exit 137", false)
+	require.NotNil(t, ee)
+	code, ok = ExitCode(errors.E(errors.Op("worker_process_wait"), ee))
+	require.True(t, ok)
+	assert.Equal(t, 137, code)
+
+	// terminated by the signal
+	err = exec.Command("sh", "-c", "kill -TERM $$").Run()
+	require.Error(t, err)
+	_, ok = ExitCode(err)
+	assert.False(t, ok)
+
+	_, ok = ExitCode(errors.Str("exit status 3"))
+	assert.False(t, ok)
+	_, ok = ExitCode(nil)
+	assert.False(t, ok)
+}
@@ -16,16 +16,29 @@ type State interface {
 	Value() int64
 	// Set sets the StateImpl
 	Set(value int64)
+	// SetReason sets the StateImpl, the reason is recorded in the journal (if enabled, see WithSyncJournal)
+	SetReason(value int64, reason string)
+	// Journal returns the last state transitions (oldest first), nil if the journal is disabled
+	Journal() []Transition
 	// NumExecs shows how many times WorkerProcess was invoked
 	NumExecs() uint64
 	// IsActive returns true if WorkerProcess not Inactive or Stopped
 	IsActive() bool
 	// RegisterExec using to registering php executions
 	RegisterExec()
+	// AddWeight adds the extra weight of the heavy execution to the weighted executions counter
+	AddWeight(weight uint64)
+	// WeightedExecs returns the weighted executions counter (each execution weights 1 plus the added weight)
+	WeightedExecs() uint64
 	// SetLastUsed sets worker last used time
 	SetLastUsed(lu uint64)
 	// LastUsed return worker last used time
 	LastUsed() uint64
+	// SetLastResponseAt sets the time of the last successful response
+	SetLastResponseAt(t time.Time)
+	// LastResponseAt returns the time of the last successful response (zero - never), unlike the LastUsed (set on
+	// the acquisition) it's updated when the Exec returns successfully
+	LastResponseAt() time.Time
 }
 
 type BaseProcess interface {
@@ -34,6 +47,10 @@ type BaseProcess interface {
 	// Pid returns worker pid.
 	Pid() int64
 
+	// ID returns the stable internal worker identity (UUID) assigned on creation, unlike the pid it's never reused
+	// by the OS, so it's used for the bookkeeping. Pid is for the display.
+	ID() string
+
 	// Created returns time worker was created at.
 	Created() time.Time
 
@@ -62,6 +79,54 @@ type BaseProcess interface {
 
 	// AttachRelay used to attach goridge relay to the worker process
 	AttachRelay(rl relay.Relay)
+
+	// BytesSent returns the number of bytes (frames) sent to the worker via the relay
+	BytesSent() uint64
+
+	// BytesReceived returns the number of bytes (frames) received from the worker via the relay
+	BytesReceived() uint64
+
+	// LastRelayActivity returns the time of the last frame sent to or received from the worker, zero if none
+	LastRelayActivity() time.Time
+
+	// InFlight returns the number of the requests the worker is currently executing (0 or 1 in the exclusive mode)
+	InFlight() int
+
+	// SetLocal sets the worker-local value, locals survive Exec calls and are sent
+	// to the worker in the payload context. Locals belong to the process, so the worker allocated
+	// on the recycle starts without them.
+	SetLocal(key, value string)
+
+	// GetLocal returns the worker-local value associated with the key
+	GetLocal(key string) (string, bool)
+
+	// Locals returns a copy of all worker-local values
+	Locals() map[string]string
+
+	// ClearLocals removes all worker-local values
+	ClearLocals()
+
+	// SetAttachment associates the opaque plugin value (statement cache, connection) with the worker. Attachments
+	// belong to the process and are cleared on its exit, so the worker allocated on the recycle starts without them.
+	SetAttachment(key, value interface{})
+
+	// Attachment returns the value associated with the key
+	Attachment(key interface{}) (interface{}, bool)
+
+	// Labels returns the labels attached to the worker at allocation
+	Labels() map[string]string
+
+	// CmdLine returns the command line the worker was launched with
+	CmdLine() []string
+
+	// Transport returns the name of the transport the worker is connected with (e.g. "pipes"), empty if unknown
+	Transport() string
+
+	// Env returns the environment the worker was launched with (sensitive values are redacted)
+	Env() []string
+
+	// Stderr returns the buffered tail of the stderr output, nil if the buffer is disabled (see WithStderrBuffer)
+	Stderr() []byte
 }
 
 type SyncWorker interface {
@@ -71,4 +136,6 @@ type SyncWorker interface {
 	Exec(rqs *payload.Payload) (*payload.Payload, error)
 	// ExecWithTTL used to handle Exec with TTL
 	ExecWithTTL(ctx context.Context, p *payload.Payload) (*payload.Payload, error)
+	// Introspect asks the worker to report its internal state via the control frame
+	Introspect(ctx context.Context) (map[string]interface{}, error)
 }
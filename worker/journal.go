@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transition is the worker state change recorded in the journal (see WithSyncJournal)
+type Transition struct {
+	From int64
+	To   int64
+	At   time.Time
+	// explicit reason (see State.SetReason) or the function which changed the state
+	Reason string
+}
+
+// transitionJSON is the JSON representation of the Transition
+type transitionJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// unix nano
+	At     int64  `json:"at"`
+	Reason string `json:"reason"`
+}
+
+// MarshalJSON returns the transition as JSON (state names, unix nano time and the reason). json.Marshaler interface
+func (t Transition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(transitionJSON{
+		From:   stateString(t.From),
+		To:     stateString(t.To),
+		At:     t.At.UnixNano(),
+		Reason: t.Reason,
+	})
+}
+
+// journal keeps the last transitions of the worker state (ring buffer)
+type journal struct {
+	mu   sync.Mutex
+	buf  []Transition
+	next int
+	full bool
+}
+
+func newJournal(size int) *journal {
+	return &journal{buf: make([]Transition, size)}
+}
+
+func (j *journal) record(from, to int64, reason string) {
+	t := Transition{From: from, To: to, At: time.Now(), Reason: reason}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.buf[j.next] = t
+	j.next++
+	if j.next == len(j.buf) {
+		j.next = 0
+		j.full = true
+	}
+}
+
+// list returns the transitions from the oldest to the latest
+func (j *journal) list() []Transition {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.full {
+		return append([]Transition(nil), j.buf[:j.next]...)
+	}
+
+	res := make([]Transition, 0, len(j.buf))
+	res = append(res, j.buf[j.next:]...)
+	return append(res, j.buf[:j.next]...)
+}
+
+// callerReason returns the name of the function which called the State.Set (e.g. pool.(*StaticPool).stopWorker)
+func callerReason(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	name := fn.Name()
+	// strip the package path
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
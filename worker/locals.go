@@ -0,0 +1,44 @@
+package worker
+
+import (
+	j "github.com/json-iterator/go"
+)
+
+var json = j.ConfigCompatibleWithStandardLibrary
+
+// LocalsKey is the payload context key under which worker-local values are passed to the worker
+const LocalsKey string = "worker_locals"
+
+// contextWithLocals merges worker-local values into the JSON payload context.
+// Empty context becomes a JSON object with the single LocalsKey, non-JSON-object context is sent as is.
+func contextWithLocals(ctx []byte, locals map[string]string) []byte {
+	if len(locals) == 0 {
+		return ctx
+	}
+
+	obj := make(map[string]j.RawMessage, 1)
+	if len(ctx) > 0 {
+		err := json.Unmarshal(ctx, &obj)
+		if err != nil {
+			// context is not a JSON object, we can't inject the locals
+			return ctx
+		}
+		// JSON null
+		if obj == nil {
+			obj = make(map[string]j.RawMessage, 1)
+		}
+	}
+
+	data, err := json.Marshal(locals)
+	if err != nil {
+		return ctx
+	}
+	obj[LocalsKey] = data
+
+	res, err := json.Marshal(obj)
+	if err != nil {
+		return ctx
+	}
+
+	return res
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Locals(t *testing.T) {
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+
+	assert.Nil(t, w.Locals())
+
+	w.SetLocal("config_version", "42")
+	v, ok := w.GetLocal("config_version")
+	assert.True(t, ok)
+	assert.Equal(t, "42", v)
+
+	w.ClearLocals()
+	_, ok = w.GetLocal("config_version")
+	assert.False(t, ok)
+}
+
+func Test_ContextWithLocals(t *testing.T) {
+	locals := map[string]string{"v": "1"}
+
+	assert.Equal(t, []byte("raw"), contextWithLocals([]byte("raw"), locals))
+	assert.Equal(t, []byte(`{"foo":"bar"}`), contextWithLocals([]byte(`{"foo":"bar"}`), nil))
+	assert.JSONEq(t, `{"worker_locals":{"v":"1"}}`, string(contextWithLocals(nil, locals)))
+	assert.JSONEq(t, `{"foo":"bar","worker_locals":{"v":"1"}}`, string(contextWithLocals([]byte(`{"foo":"bar"}`), locals)))
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"sync/atomic"
+)
+
+// ExecMilestoneFunc is called when the worker NumExecs reaches the multiple of the milestone interval
+type ExecMilestoneFunc func(w BaseProcess, count uint64)
+
+// ExecMilestones is the exec count milestone callback shared by the workers (see WithExecMilestones), it might be
+// set or replaced at any time, the workers pick it up on the next execution.
+type ExecMilestones struct {
+	// *execMilestone, nil - disabled
+	v atomic.Value
+}
+
+type execMilestone struct {
+	every uint64
+	fn    ExecMilestoneFunc
+}
+
+// Set sets the callback called every `every` executions of the worker, every 0 or nil fn disables the callback
+func (em *ExecMilestones) Set(every uint64, fn ExecMilestoneFunc) {
+	if every == 0 || fn == nil {
+		em.v.Store((*execMilestone)(nil))
+		return
+	}
+
+	em.v.Store(&execMilestone{every: every, fn: fn})
+}
+
+// reached dispatches the callback if the count is the milestone, the callback runs in its own goroutine, so the
+// exec caller is not blocked
+func (em *ExecMilestones) reached(w BaseProcess, count uint64) {
+	m, _ := em.v.Load().(*execMilestone)
+	if m == nil || count%m.every != 0 {
+		return
+	}
+
+	go m.fn(w, count)
+}
+
+// WithExecMilestones attaches the shared milestone callback to the worker
+func WithExecMilestones(em *ExecMilestones) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.milestones = em
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExecMilestones(t *testing.T) {
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+
+	em := &ExecMilestones{}
+	sw := From(w, WithExecMilestones(em))
+
+	// no callback
+	sw.registerExec(&payload.Payload{})
+
+	var mu sync.Mutex
+	var counts []uint64
+	em.Set(3, func(bp BaseProcess, count uint64) {
+		assert.Equal(t, sw, bp)
+		mu.Lock()
+		counts = append(counts, count)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 9; i++ {
+		sw.registerExec(&payload.Payload{})
+		// control payloads are not counted
+		sw.registerExec(&payload.Payload{Control: true})
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(counts) == 3
+	}, time.Second, time.Millisecond*10)
+	mu.Lock()
+	assert.ElementsMatch(t, []uint64{3, 6, 9}, counts)
+	mu.Unlock()
+
+	// removed
+	em.Set(0, nil)
+	for i := 0; i < 3; i++ {
+		sw.registerExec(&payload.Payload{})
+	}
+	time.Sleep(time.Millisecond * 50)
+	mu.Lock()
+	assert.Len(t, counts, 3)
+	mu.Unlock()
+}
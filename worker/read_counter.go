@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/spiral/goridge/v3/pkg/relay"
+)
+
+// ReadCounter counts the bytes read from the worker transport (the pipe stdout, the socket connection) to track
+// the response progress, see WithReadCounter
+type ReadCounter struct {
+	read uint64
+}
+
+// Read returns the number of the bytes read
+func (rc *ReadCounter) Read() uint64 {
+	return atomic.LoadUint64(&rc.read)
+}
+
+// ReadCloser wraps the reader, read bytes are counted (including the partial reads)
+func (rc *ReadCounter) ReadCloser(r io.ReadCloser) io.ReadCloser {
+	return &readCountedReadCloser{ReadCloser: r, rc: rc}
+}
+
+// ReadWriteCloser wraps the connection, read bytes are counted (including the partial reads)
+func (rc *ReadCounter) ReadWriteCloser(rwc io.ReadWriteCloser) io.ReadWriteCloser {
+	return &readCountedReadWriteCloser{ReadWriteCloser: rwc, rc: rc}
+}
+
+// WithReadCounter marks the relay as reading from the transport counted by the rc, the worker the relay is attached
+// to (AttachRelay) detects the stalled responses (WithResponseReadTimeout) by the read progress
+func WithReadCounter(rl relay.Relay, rc *ReadCounter) relay.Relay {
+	return &readCountedRelay{Relay: rl, rc: rc}
+}
+
+type readCountedRelay struct {
+	relay.Relay
+	rc *ReadCounter
+}
+
+type readCountedReadCloser struct {
+	io.ReadCloser
+	rc *ReadCounter
+}
+
+func (cr *readCountedReadCloser) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	atomic.AddUint64(&cr.rc.read, uint64(n))
+	return n, err
+}
+
+type readCountedReadWriteCloser struct {
+	io.ReadWriteCloser
+	rc *ReadCounter
+}
+
+func (cr *readCountedReadWriteCloser) Read(p []byte) (int, error) {
+	n, err := cr.ReadWriteCloser.Read(p)
+	atomic.AddUint64(&cr.rc.read, uint64(n))
+	return n, err
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/relay"
+)
+
+// SendError is the relay send failure (e.g. the broken pipe, the worker died), wrapped by the errors.Network error
+// of the Exec. Unsent - no request bytes reached the worker transport, the request is safe to retry. Otherwise
+// the request might be partially received (and acted on) by the worker, Sent is the number of the bytes written.
+// The progress is known only for the relays counted with the WithWriteCounter, Unsent is false for the others.
+type SendError struct {
+	Sent   uint64
+	Unsent bool
+	Err    error
+}
+
+func (e *SendError) Error() string {
+	if e.Unsent {
+		return "request is not sent: " + e.Err.Error()
+	}
+
+	if e.Sent == 0 {
+		// progress is unknown
+		return "request send failed: " + e.Err.Error()
+	}
+
+	return fmt.Sprintf("request is partially sent (%d bytes): %v", e.Sent, e.Err)
+}
+
+// AsSendError returns the SendError wrapped by the err (via errors.E), false if there is none
+func AsSendError(err error) (*SendError, bool) {
+	for err != nil {
+		if se, ok := err.(*SendError); ok {
+			return se, true
+		}
+
+		e, ok := err.(*errors.Error)
+		if !ok {
+			return nil, false
+		}
+		err = e.Err
+	}
+
+	return nil, false
+}
+
+// WriteCounter counts the bytes written to the worker transport (the pipe stdin, the socket connection) to track
+// the relay send progress, see WithWriteCounter
+type WriteCounter struct {
+	written uint64
+}
+
+// Written returns the number of the bytes written
+func (wc *WriteCounter) Written() uint64 {
+	return atomic.LoadUint64(&wc.written)
+}
+
+// WriteCloser wraps the writer, written bytes are counted (including the partial writes)
+func (wc *WriteCounter) WriteCloser(w io.WriteCloser) io.WriteCloser {
+	return &countedWriteCloser{WriteCloser: w, wc: wc}
+}
+
+// ReadWriteCloser wraps the connection, written bytes are counted (including the partial writes)
+func (wc *WriteCounter) ReadWriteCloser(rwc io.ReadWriteCloser) io.ReadWriteCloser {
+	return &countedReadWriteCloser{ReadWriteCloser: rwc, wc: wc}
+}
+
+// WithWriteCounter marks the relay as writing to the transport counted by the wc, the worker the relay is attached
+// to (AttachRelay) reports the send progress in the SendError
+func WithWriteCounter(rl relay.Relay, wc *WriteCounter) relay.Relay {
+	return &countedRelay{Relay: rl, wc: wc}
+}
+
+type countedRelay struct {
+	relay.Relay
+	wc *WriteCounter
+}
+
+type countedWriteCloser struct {
+	io.WriteCloser
+	wc *WriteCounter
+}
+
+func (cw *countedWriteCloser) Write(p []byte) (int, error) {
+	n, err := cw.WriteCloser.Write(p)
+	atomic.AddUint64(&cw.wc.written, uint64(n))
+	return n, err
+}
+
+type countedReadWriteCloser struct {
+	io.ReadWriteCloser
+	wc *WriteCounter
+}
+
+func (cw *countedReadWriteCloser) Write(p []byte) (int, error) {
+	n, err := cw.ReadWriteCloser.Write(p)
+	atomic.AddUint64(&cw.wc.written, uint64(n))
+	return n, err
+}
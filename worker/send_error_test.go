@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenPipe accepts up to the limit bytes, then fails
+type brokenPipe struct {
+	limit int
+}
+
+func (bp *brokenPipe) Write(p []byte) (int, error) {
+	if len(p) <= bp.limit {
+		bp.limit -= len(p)
+		return len(p), nil
+	}
+
+	n := bp.limit
+	bp.limit = 0
+	return n, errors.Str("broken pipe")
+}
+
+func (bp *brokenPipe) Close() error { return nil }
+
+func sendFrame(t *testing.T, limit int, counted bool) error {
+	wc := &WriteCounter{}
+	var rl = pipe.NewPipeRelay(io.NopCloser(nil), wc.WriteCloser(&brokenPipe{limit: limit}))
+
+	w := &Process{}
+	if counted {
+		w.AttachRelay(WithWriteCounter(rl, wc))
+	} else {
+		w.AttachRelay(rl)
+	}
+
+	fr := frame.NewFrame()
+	fr.WritePayload([]byte("hello"))
+	err := w.Relay().Send(fr)
+	require.Error(t, err)
+	return errors.E(errors.Op("test_exec"), errors.Network, err)
+}
+
+func Test_SendError(t *testing.T) {
+	// failed before any bytes are written
+	se, ok := AsSendError(sendFrame(t, 0, true))
+	require.True(t, ok)
+	assert.True(t, se.Unsent)
+	assert.Equal(t, uint64(0), se.Sent)
+	assert.Contains(t, se.Error(), "not sent")
+
+	// partial write
+	se, ok = AsSendError(sendFrame(t, 3, true))
+	require.True(t, ok)
+	assert.False(t, se.Unsent)
+	assert.Equal(t, uint64(3), se.Sent)
+	assert.Contains(t, se.Error(), "partially sent (3 bytes)")
+
+	// progress is unknown w/o the counter
+	se, ok = AsSendError(sendFrame(t, 0, false))
+	require.True(t, ok)
+	assert.False(t, se.Unsent)
+
+	_, ok = AsSendError(errors.E(errors.Op("test_exec"), errors.Network))
+	assert.False(t, ok)
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/roadrunner/v2/payload"
+)
+
+// SoftJobError is the application error of the request sent by the worker (errors.SoftJob kind). Payload carries
+// the error details (e.g. the exception with the stack trace) to render the error page, nil when the worker sent none.
+type SoftJobError struct {
+	Payload *payload.Payload
+}
+
+func (e *SoftJobError) Error() string {
+	if e.Payload == nil {
+		return ""
+	}
+
+	return string(e.Payload.Body)
+}
+
+// AsSoftJobError returns the SoftJobError wrapped by the err (via errors.E), false if there is none
+func AsSoftJobError(err error) (*SoftJobError, bool) {
+	for err != nil {
+		if sj, ok := err.(*SoftJobError); ok {
+			return sj, true
+		}
+
+		e, ok := err.(*errors.Error)
+		if !ok {
+			return nil, false
+		}
+		err = e.Err
+	}
+
+	return nil, false
+}
+
+// softJobError copies the error payload of the frame, the context is split by the offset option (if present)
+func softJobError(fr *frame.Frame) *SoftJobError {
+	data := fr.Payload()
+	if len(data) == 0 {
+		return &SoftJobError{}
+	}
+
+	offset := uint32(0)
+	if options := fr.ReadOptions(fr.Header()); len(options) > optContextOffset && options[optContextOffset] <= uint32(len(data)) {
+		offset = options[optContextOffset]
+	}
+
+	pld := &payload.Payload{
+		Body: make([]byte, len(data[offset:])),
+	}
+	copy(pld.Body, data[offset:])
+	if offset > 0 {
+		pld.Context = make([]byte, offset)
+		copy(pld.Context, data[:offset])
+	}
+
+	return &SoftJobError{Payload: pld}
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorFrame responds with the error frame, options are written if offset >= 0
+func errorFrame(data string, offset int) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.VERSION_1)
+	fr.WriteFlags(fr.Header(), frame.ERROR)
+	if offset >= 0 {
+		fr.WriteOptions(fr.HeaderPtr(), uint32(offset))
+	}
+	fr.WritePayloadLen(fr.Header(), uint32(len(data)))
+	fr.WritePayload([]byte(data))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func Test_SoftJobError(t *testing.T) {
+	responses := []*frame.Frame{
+		errorFrame("exception: stack trace", -1),
+		errorFrame("{\"code\":500}exception", len("{\"code\":500}")),
+		errorFrame("", -1),
+	}
+	sw := relayWorker(t, func(_ *frame.Frame) *frame.Frame {
+		rsp := responses[0]
+		responses = responses[1:]
+		return rsp
+	})
+
+	res, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.Nil(t, res)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.SoftJob, err))
+	assert.Contains(t, err.Error(), "exception: stack trace")
+	sj, ok := AsSoftJobError(err)
+	require.True(t, ok)
+	assert.Equal(t, "exception: stack trace", sj.Payload.String())
+	assert.Nil(t, sj.Payload.Context)
+
+	// the context is split by the offset
+	sw.State().Set(StateReady)
+	_, err = sw.Exec(&payload.Payload{Body: []byte("hello")})
+	sj, ok = AsSoftJobError(err)
+	require.True(t, ok)
+	assert.Equal(t, "exception", sj.Payload.String())
+	assert.Equal(t, []byte("{\"code\":500}"), sj.Payload.Context)
+
+	// no error payload
+	sw.State().Set(StateReady)
+	_, err = sw.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.True(t, errors.Is(errors.SoftJob, err))
+	sj, ok = AsSoftJobError(err)
+	require.True(t, ok)
+	assert.Nil(t, sj.Payload)
+
+	_, ok = AsSoftJobError(errors.E(errors.Op("test"), errors.Str("not a soft job")))
+	assert.False(t, ok)
+	_, ok = AsSoftJobError(nil)
+	assert.False(t, ok)
+}
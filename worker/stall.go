@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// minStallPoll is the lower bound of the response progress poll interval
+const minStallPoll = time.Millisecond
+
+// stallWatch kills the worker once the started response makes no progress within the response read timeout
+type stallWatch struct {
+	done chan struct{}
+	// closed once the watch goroutine exits, so the worker is not killed after the stop
+	exited  chan struct{}
+	stalled uint32
+}
+
+// watchStall starts watching the response reads, reads is the counter value before the request is sent
+func (tw *SyncWorkerImpl) watchStall(rc *ReadCounter, reads uint64) *stallWatch {
+	sw := &stallWatch{done: make(chan struct{}), exited: make(chan struct{})}
+	timeout := tw.responseReadTimeout
+	poll := timeout / 4
+	if poll < minStallPoll {
+		poll = minStallPoll
+	}
+
+	go func() {
+		defer close(sw.exited)
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+
+		// zero until the first response byte
+		var progressed time.Time
+		for {
+			select {
+			case <-sw.done:
+				return
+			case now := <-ticker.C:
+				if n := rc.Read(); n != reads {
+					reads = n
+					progressed = now
+					continue
+				}
+
+				if !progressed.IsZero() && now.Sub(progressed) >= timeout {
+					atomic.StoreUint32(&sw.stalled, 1)
+					// the only way to unblock the Receive is to kill the worker
+					_ = tw.process.Kill()
+					return
+				}
+			}
+		}
+	}()
+
+	return sw
+}
+
+// stop stops the watch, returns true if the worker is killed as stalled
+func (sw *stallWatch) stop() bool {
+	close(sw.done)
+	<-sw.exited
+	return atomic.LoadUint32(&sw.stalled) == 1
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"io"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/goridge/v3/pkg/pipe"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closingRelay closes the in-memory pipes with the process (Wait), like the real pipes
+type closingRelay struct {
+	*pipe.Relay
+	closers []io.Closer
+}
+
+func (cr *closingRelay) Close() error {
+	for i := 0; i < len(cr.closers); i++ {
+		_ = cr.closers[i].Close()
+	}
+	return nil
+}
+
+// stallWorker returns the worker backed by the placeholder process, the respond writes the response of the request
+// to the raw worker -> host pipe
+func stallWorker(t *testing.T, respond func(req *frame.Frame, out io.Writer), options ...SyncWorkerOptions) *SyncWorkerImpl {
+	w, err := InitBaseWorker(exec.Command("sleep", "10"))
+	require.NoError(t, err)
+
+	hostR, workerW := io.Pipe()
+	workerR, hostW := io.Pipe()
+	rc := &ReadCounter{}
+	w.AttachRelay(WithReadCounter(&closingRelay{Relay: pipe.NewPipeRelay(rc.ReadCloser(hostR), hostW), closers: []io.Closer{hostR, hostW}}, rc))
+	require.NoError(t, w.Start())
+	w.State().Set(StateReady)
+
+	go func() {
+		_ = w.Wait()
+	}()
+
+	go func() {
+		rl := pipe.NewPipeRelay(workerR, workerW)
+		for {
+			fr := frame.NewFrame()
+			if errR := rl.Receive(fr); errR != nil {
+				return
+			}
+			respond(fr, workerW)
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = w.Kill()
+	})
+
+	return From(w, options...)
+}
+
+func Test_ResponseReadTimeout_LongExec(t *testing.T) {
+	sw := stallWorker(t, func(req *frame.Frame, out io.Writer) {
+		// long computation, the response is sent at once
+		time.Sleep(time.Millisecond * 300)
+		_, _ = out.Write(echoFrame(req, false).Bytes())
+	}, WithResponseReadTimeout(time.Millisecond*100))
+
+	rsp, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", rsp.String())
+}
+
+func Test_ResponseReadTimeout_Stalled(t *testing.T) {
+	sw := stallWorker(t, func(req *frame.Frame, out io.Writer) {
+		// the response stalls after the header
+		data := echoFrame(req, false).Bytes()
+		_, _ = out.Write(data[:len(data)-2])
+	}, WithResponseReadTimeout(time.Millisecond*100))
+
+	start := time.Now()
+	_, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.ExecTTL, err))
+	assert.Contains(t, err.Error(), "response stalled")
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"sync/atomic"
+	"time"
 )
 
 // SYNC WITH worker_watcher.GET
@@ -40,8 +41,14 @@ const (
 type StateImpl struct {
 	value    int64
 	numExecs uint64
+	// numExecs plus the extra weight of the heavy executions
+	weightedExecs uint64
 	// to be lightweight, use UnixNano
 	lastUsed uint64
+	// unix nano of the last successful response, 0 - never responded
+	lastResponse int64
+	// last transitions of the state, nil - disabled (see enableJournal)
+	journal *journal
 }
 
 // NewWorkerState initializes a state for the sync.Worker
@@ -51,7 +58,12 @@ func NewWorkerState(value int64) *StateImpl {
 
 // String returns current StateImpl as string.
 func (s *StateImpl) String() string {
-	switch s.Value() {
+	return stateString(s.Value())
+}
+
+// stateString returns the state value name
+func stateString(value int64) string {
+	switch value {
 	case StateInactive:
 		return "inactive"
 	case StateReady:
@@ -75,6 +87,27 @@ func (s *StateImpl) String() string {
 	return "undefined"
 }
 
+// stateJSON is the JSON representation of the StateImpl
+type stateJSON struct {
+	Status   string `json:"status"`
+	NumExecs uint64 `json:"numExecs"`
+	// unix nano, 0 - never used
+	LastUsed uint64 `json:"lastUsed"`
+	// unix nano, 0 - never responded
+	LastResponseAt int64 `json:"lastResponseAt"`
+}
+
+// MarshalJSON returns the state as JSON (status name, number of execs, last used and last response unix nano).
+// json.Marshaler interface
+func (s *StateImpl) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stateJSON{
+		Status:         s.String(),
+		NumExecs:       s.NumExecs(),
+		LastUsed:       s.LastUsed(),
+		LastResponseAt: atomic.LoadInt64(&s.lastResponse),
+	})
+}
+
 // NumExecs returns number of registered WorkerProcess execs.
 func (s *StateImpl) NumExecs() uint64 {
 	return atomic.LoadUint64(&s.numExecs)
@@ -93,12 +126,59 @@ func (s *StateImpl) IsActive() bool {
 
 // Set change StateImpl value (status)
 func (s *StateImpl) Set(value int64) {
-	atomic.StoreInt64(&s.value, value)
+	s.set(value, "")
+}
+
+// SetReason changes the StateImpl value, the reason is recorded in the journal (if enabled)
+func (s *StateImpl) SetReason(value int64, reason string) {
+	s.set(value, reason)
+}
+
+func (s *StateImpl) set(value int64, reason string) {
+	prev := atomic.SwapInt64(&s.value, value)
+	if s.journal == nil {
+		return
+	}
+
+	if reason == "" {
+		// the caller of the Set or SetReason
+		reason = callerReason(3)
+	}
+	s.journal.record(prev, value, reason)
+}
+
+// Journal returns the last state transitions from the oldest to the latest, nil if the journal is disabled
+func (s *StateImpl) Journal() []Transition {
+	if s.journal == nil {
+		return nil
+	}
+
+	return s.journal.list()
+}
+
+// enableJournal keeps the last size transitions, should be called before the worker is shared
+func (s *StateImpl) enableJournal(size int) {
+	if size <= 0 {
+		return
+	}
+
+	s.journal = newJournal(size)
 }
 
 // RegisterExec register new execution atomically
 func (s *StateImpl) RegisterExec() {
 	atomic.AddUint64(&s.numExecs, 1)
+	atomic.AddUint64(&s.weightedExecs, 1)
+}
+
+// AddWeight adds the extra weight of the execution atomically, the execution itself is counted by the RegisterExec
+func (s *StateImpl) AddWeight(weight uint64) {
+	atomic.AddUint64(&s.weightedExecs, weight)
+}
+
+// WeightedExecs returns the number of executions including the extra weights
+func (s *StateImpl) WeightedExecs() uint64 {
+	return atomic.LoadUint64(&s.weightedExecs)
 }
 
 // SetLastUsed Update last used time
@@ -109,3 +189,18 @@ func (s *StateImpl) SetLastUsed(lu uint64) {
 func (s *StateImpl) LastUsed() uint64 {
 	return atomic.LoadUint64(&s.lastUsed)
 }
+
+// SetLastResponseAt updates the time of the last successful response
+func (s *StateImpl) SetLastResponseAt(t time.Time) {
+	atomic.StoreInt64(&s.lastResponse, t.UnixNano())
+}
+
+// LastResponseAt returns the time of the last successful response, zero time if the worker never responded
+func (s *StateImpl) LastResponseAt() time.Time {
+	lr := atomic.LoadInt64(&s.lastResponse)
+	if lr == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, lr)
+}
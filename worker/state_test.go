@@ -1,9 +1,12 @@
 package worker
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_NewState(t *testing.T) {
@@ -25,3 +28,61 @@ func Test_IsActive(t *testing.T) {
 	assert.False(t, NewWorkerState(StateStopped).IsActive())
 	assert.False(t, NewWorkerState(StateErrored).IsActive())
 }
+
+func Test_StateMarshalJSON(t *testing.T) {
+	st := NewWorkerState(StateReady)
+	st.RegisterExec()
+	st.SetLastUsed(42)
+
+	data, err := json.Marshal(st)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":"ready","numExecs":1,"lastUsed":42,"lastResponseAt":0}`, string(data))
+
+	st.SetLastResponseAt(time.Unix(0, 43))
+	data, err = json.Marshal(st)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":"ready","numExecs":1,"lastUsed":42,"lastResponseAt":43}`, string(data))
+}
+
+func Test_StateWeightedExecs(t *testing.T) {
+	st := NewWorkerState(StateReady)
+	st.RegisterExec()
+	assert.Equal(t, uint64(1), st.WeightedExecs())
+
+	st.RegisterExec()
+	st.AddWeight(4)
+	assert.Equal(t, uint64(2), st.NumExecs())
+	assert.Equal(t, uint64(6), st.WeightedExecs())
+}
+
+func Test_StateJournal(t *testing.T) {
+	st := NewWorkerState(StateReady)
+	// disabled by default
+	st.Set(StateWorking)
+	assert.Nil(t, st.Journal())
+
+	st.enableJournal(3)
+	st.Set(StateReady)
+	st.SetReason(StateInvalid, "exec ttl")
+
+	j := st.Journal()
+	require.Len(t, j, 2)
+	assert.Equal(t, StateWorking, j[0].From)
+	assert.Equal(t, StateReady, j[0].To)
+	// the caller of the Set
+	assert.Equal(t, "worker.Test_StateJournal", j[0].Reason)
+	assert.Equal(t, "exec ttl", j[1].Reason)
+	assert.False(t, j[1].At.Before(j[0].At))
+
+	// bounded, the oldest transitions are dropped
+	st.Set(StateStopping)
+	st.Set(StateStopped)
+	j = st.Journal()
+	require.Len(t, j, 3)
+	assert.Equal(t, StateInvalid, j[0].To)
+	assert.Equal(t, StateStopped, j[2].To)
+
+	data, err := json.Marshal(j[2])
+	require.NoError(t, err)
+	assert.JSONEq(t, fmt.Sprintf(`{"from":"stopping","to":"stopped","at":%d,"reason":"worker.Test_StateJournal"}`, j[2].At.UnixNano()), string(data))
+}
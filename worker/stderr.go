@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+)
+
+// MaxStderrBuffer is the hard cap of the stderr buffer size (see WithStderrBuffer), bigger sizes are capped
+const MaxStderrBuffer = 1024 * 1024
+
+// WithStderrBuffer keeps the last size bytes of the worker stderr output (see Stderr), the oldest output is dropped
+// once the buffer is full and the EventWorkerStderrOverflow is pushed (once per worker). The buffer is allocated once,
+// the size is capped by the MaxStderrBuffer. Disabled when 0.
+func WithStderrBuffer(size int) Options {
+	return func(p *Process) {
+		p.setStderrBuffer(size)
+	}
+}
+
+func (w *Process) setStderrBuffer(size int) {
+	if size <= 0 {
+		return
+	}
+
+	if size > MaxStderrBuffer {
+		size = MaxStderrBuffer
+	}
+
+	w.stderrMu.Lock()
+	w.stderr = newStderrBuffer(size)
+	w.stderrMu.Unlock()
+}
+
+// Stderr returns the buffered tail of the stderr output, nil if the buffer is disabled
+func (w *Process) Stderr() []byte {
+	w.stderrMu.Lock()
+	defer w.stderrMu.Unlock()
+
+	if w.stderr == nil {
+		return nil
+	}
+
+	return w.stderr.bytes()
+}
+
+// bufferStderr writes the stderr output to the buffer (if enabled), pushes the EventWorkerStderrOverflow on the
+// first dropped output
+func (w *Process) bufferStderr(p []byte) {
+	const op = errors.Op("process_stderr")
+	w.stderrMu.Lock()
+	if w.stderr == nil {
+		w.stderrMu.Unlock()
+		return
+	}
+
+	overflow := w.stderr.write(p) && !w.stderrOverflow
+	if overflow {
+		w.stderrOverflow = true
+	}
+	size := len(w.stderr.buf)
+	w.stderrMu.Unlock()
+
+	if overflow {
+		w.events.Push(events.WorkerEvent{
+			Event:   events.EventWorkerStderrOverflow,
+			Worker:  w,
+			Payload: errors.E(op, errors.Errorf("stderr output exceeds the %d bytes buffer, the oldest output is dropped", size)),
+			Labels:  w.labels,
+		})
+	}
+}
+
+// stderrBuffer is the fixed size ring of the last stderr bytes
+type stderrBuffer struct {
+	buf []byte
+	// start of the oldest byte and the number of the buffered bytes
+	start int
+	len   int
+}
+
+func newStderrBuffer(size int) *stderrBuffer {
+	return &stderrBuffer{buf: make([]byte, size)}
+}
+
+// write appends the output dropping the oldest bytes, reports whether any bytes are dropped
+func (sb *stderrBuffer) write(p []byte) bool {
+	size := len(sb.buf)
+	dropped := sb.len+len(p) > size
+
+	// only the tail fits
+	if len(p) >= size {
+		copy(sb.buf, p[len(p)-size:])
+		sb.start = 0
+		sb.len = size
+		return dropped
+	}
+
+	end := (sb.start + sb.len) % size
+	n := copy(sb.buf[end:], p)
+	copy(sb.buf, p[n:])
+
+	sb.len += len(p)
+	if sb.len > size {
+		// the oldest bytes are overwritten
+		sb.start = (sb.start + sb.len - size) % size
+		sb.len = size
+	}
+
+	return dropped
+}
+
+// bytes returns the copy of the buffered output, the oldest first
+func (sb *stderrBuffer) bytes() []byte {
+	res := make([]byte, 0, sb.len)
+	end := sb.start + sb.len
+	if end <= len(sb.buf) {
+		return append(res, sb.buf[sb.start:end]...)
+	}
+
+	res = append(res, sb.buf[sb.start:]...)
+	return append(res, sb.buf[:end-len(sb.buf)]...)
+}
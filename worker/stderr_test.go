@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StderrBuffer(t *testing.T) {
+	sb := newStderrBuffer(8)
+	assert.False(t, sb.write([]byte("abc")))
+	assert.False(t, sb.write([]byte("defgh")))
+	assert.Equal(t, "abcdefgh", string(sb.bytes()))
+
+	// drop-oldest, wraps around
+	assert.True(t, sb.write([]byte("ijk")))
+	assert.Equal(t, "defghijk", string(sb.bytes()))
+
+	// only the tail of the big write fits
+	assert.True(t, sb.write([]byte("0123456789")))
+	assert.Equal(t, "23456789", string(sb.bytes()))
+	assert.Len(t, sb.buf, 8)
+}
+
+func Test_StderrOverflow(t *testing.T) {
+	overflows := 0
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"), WithStderrBuffer(4), AddListeners(func(event interface{}) {
+		if ev, ok := event.(events.WorkerEvent); ok && ev.Event == events.EventWorkerStderrOverflow {
+			overflows++
+		}
+	}))
+	require.NoError(t, err)
+
+	_, _ = w.Write([]byte("boot"))
+	assert.Equal(t, 0, overflows)
+	_, _ = w.Write([]byte("warning"))
+	_, _ = w.Write([]byte("warning"))
+	assert.Equal(t, "ning", string(w.Stderr()))
+	// pushed once per worker
+	assert.Equal(t, 1, overflows)
+
+	// disabled by default, the size is capped
+	w2, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+	assert.Nil(t, w2.Stderr())
+	w2.setStderrBuffer(MaxStderrBuffer * 2)
+	assert.Len(t, w2.stderr.buf, MaxStderrBuffer)
+}
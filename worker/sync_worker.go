@@ -3,28 +3,120 @@ package worker
 import (
 	"bytes"
 	"context"
+	"hash/crc32"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spiral/errors"
 	"github.com/spiral/goridge/v3/pkg/frame"
 	"github.com/spiral/goridge/v3/pkg/relay"
+	"github.com/spiral/roadrunner/v2/internal"
 	"github.com/spiral/roadrunner/v2/payload"
 	"go.uber.org/multierr"
 )
 
-// Allocator is responsible for worker allocation in the pool
+// Allocator is responsible for worker allocation in the pool. Returns the errors.WatcherStopped error when the
+// allocation is canceled by the pool shutdown (not a spawn failure, should not be retried or reported).
 type Allocator func() (SyncWorker, error)
 
+// frame options positions
+const (
+	// context offset (body starts after the context)
+	optContextOffset int = iota
+	// CRC32 (IEEE) checksum of the frame payload
+	optChecksum
+	// request correlation ID, echoed by the worker
+	optCorrelationID
+)
+
+// SyncWorkerOptions is the SyncWorker options
+type SyncWorkerOptions func(sw *SyncWorkerImpl)
+
+// WithChecksums enables the frame payload checksums (CRC32) computed on send and validated on the response
+// to detect relay corruption. Worker should send the checksum of the response payload in the second frame option,
+// there is no negotiation: a worker which doesn't echo the checksum fails every request (network error) and is
+// killed after each one.
+func WithChecksums(enable bool) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.verifyChecksums = enable
+	}
+}
+
+// WithCorrelationIDs enables the per-request correlation IDs sent in the third frame option and verified on the
+// response to detect the relay desynchronization (response of the previous request read as the current one).
+// Worker should echo the ID in the third response option (the checksum option is 0 if the checksums are disabled),
+// there is no negotiation: a worker which doesn't echo the ID fails every request (network error).
+func WithCorrelationIDs(enable bool) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.correlate = enable
+	}
+}
+
+// WithResponseReadTimeout bounds the gap between the reads of the response, so the worker stalled in the middle of
+// the response is killed faster than the whole exec TTL (errors.ExecTTL). The timeout is armed on the first response
+// byte, the execution itself (before the response) is bounded only by the exec TTL. The relays w/o the read
+// progress (WithReadCounter) bound the whole response wait instead. Disabled when 0.
+func WithResponseReadTimeout(timeout time.Duration) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.responseReadTimeout = timeout
+	}
+}
+
+// WithSyncJournal keeps the last size transitions of the worker state (see State.Journal), e.g. to debug the flaky
+// worker. The transitions before the SyncWorker creation are not recorded. Disabled when 0.
+func WithSyncJournal(size int) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.process.state.enableJournal(size)
+	}
+}
+
+// WithSyncStderrBuffer enables the stderr buffer of the underlying worker process, see WithStderrBuffer
+func WithSyncStderrBuffer(size int) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.process.setStderrBuffer(size)
+	}
+}
+
+// WithSyncLabels attaches the labels to the underlying worker process, see WithLabels
+func WithSyncLabels(labels map[string]string) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.process.setLabels(labels)
+	}
+}
+
+// WithSyncTransport sets the transport name of the underlying worker process, see transport.Named
+func WithSyncTransport(name string) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.process.transport = name
+	}
+}
+
+// WithSyncRedactedEnv adds the env keys to redact in the Env of the underlying worker process, see WithRedactedEnv
+func WithSyncRedactedEnv(keys ...string) SyncWorkerOptions {
+	return func(sw *SyncWorkerImpl) {
+		sw.process.addRedactedEnv(keys)
+	}
+}
+
 type SyncWorkerImpl struct {
 	process *Process
 	fPool   sync.Pool
 	bPool   sync.Pool
+	// send and validate payload checksums
+	verifyChecksums bool
+	// send and validate request correlation IDs, seq is the last sent ID (atomic)
+	correlate bool
+	seq       uint32
+	// bounds the response read after the send, 0 - disabled
+	responseReadTimeout time.Duration
+	// exec count milestone callback, nil - disabled
+	milestones *ExecMilestones
 }
 
 // From creates SyncWorker from BaseProcess
-func From(process *Process) *SyncWorkerImpl {
-	return &SyncWorkerImpl{
+func From(process *Process, options ...SyncWorkerOptions) *SyncWorkerImpl {
+	sw := &SyncWorkerImpl{
 		process: process,
 		fPool: sync.Pool{New: func() interface{} {
 			return frame.NewFrame()
@@ -33,6 +125,12 @@ func From(process *Process) *SyncWorkerImpl {
 			return new(bytes.Buffer)
 		}},
 	}
+
+	for i := 0; i < len(options); i++ {
+		options[i](sw)
+	}
+
+	return sw
 }
 
 // Exec payload without TTL timeout.
@@ -50,29 +148,45 @@ func (tw *SyncWorkerImpl) Exec(p *payload.Payload) (*payload.Payload, error) {
 	tw.process.State().SetLastUsed(uint64(time.Now().UnixNano()))
 	tw.process.State().Set(StateWorking)
 
+	tw.process.addInFlight(1)
 	rsp, err := tw.execPayload(p)
+	tw.process.addInFlight(-1)
 	if err != nil {
 		// just to be more verbose
 		if !errors.Is(errors.SoftJob, err) {
 			tw.process.State().Set(StateErrored)
-			tw.process.State().RegisterExec()
+			tw.registerExec(p)
 		}
 		return nil, errors.E(op, err)
 	}
 
+	tw.process.State().SetLastResponseAt(time.Now())
+
 	// supervisor may set state of the worker during the work
 	// in this case we should not re-write the worker state
 	if tw.process.State().Value() != StateWorking {
-		tw.process.State().RegisterExec()
+		tw.registerExec(p)
 		return rsp, nil
 	}
 
 	tw.process.State().Set(StateReady)
-	tw.process.State().RegisterExec()
+	tw.registerExec(p)
 
 	return rsp, nil
 }
 
+// registerExec counts the execution, the control payloads are not counted (see payload.Payload.Control)
+func (tw *SyncWorkerImpl) registerExec(p *payload.Payload) {
+	if p.Control {
+		return
+	}
+
+	tw.process.State().RegisterExec()
+	if tw.milestones != nil {
+		tw.milestones.reached(tw, tw.process.State().NumExecs())
+	}
+}
+
 type wexec struct {
 	payload *payload.Payload
 	err     error
@@ -102,12 +216,15 @@ func (tw *SyncWorkerImpl) ExecWithTTL(ctx context.Context, p *payload.Payload) (
 		tw.process.State().SetLastUsed(uint64(time.Now().UnixNano()))
 		tw.process.State().Set(StateWorking)
 
+		// counted until the relay returns, even after the exec TTL
+		tw.process.addInFlight(1)
 		rsp, err := tw.execPayload(p)
+		tw.process.addInFlight(-1)
 		if err != nil {
 			// just to be more verbose
 			if errors.Is(errors.SoftJob, err) == false { //nolint:gosimple
 				tw.process.State().Set(StateErrored)
-				tw.process.State().RegisterExec()
+				tw.registerExec(p)
 			}
 			c <- wexec{
 				err: errors.E(op, err),
@@ -115,8 +232,10 @@ func (tw *SyncWorkerImpl) ExecWithTTL(ctx context.Context, p *payload.Payload) (
 			return
 		}
 
+		tw.process.State().SetLastResponseAt(time.Now())
+
 		if tw.process.State().Value() != StateWorking {
-			tw.process.State().RegisterExec()
+			tw.registerExec(p)
 			c <- wexec{
 				payload: rsp,
 				err:     nil,
@@ -125,7 +244,7 @@ func (tw *SyncWorkerImpl) ExecWithTTL(ctx context.Context, p *payload.Payload) (
 		}
 
 		tw.process.State().Set(StateReady)
-		tw.process.State().RegisterExec()
+		tw.registerExec(p)
 
 		c <- wexec{
 			payload: rsp,
@@ -151,6 +270,47 @@ func (tw *SyncWorkerImpl) ExecWithTTL(ctx context.Context, p *payload.Payload) (
 	}
 }
 
+type wintrospect struct {
+	diag map[string]interface{}
+	err  error
+}
+
+// Introspect sends the reserved control frame to the worker and decodes its diagnostic reply,
+// worker should be in the ready state, no normal request is executed. The worker should support the CONTROL
+// introspect command. If the ctx is done before the reply, the worker is not killed: it stays in the working
+// state (the relay is still in use) until the late reply arrives and then returns to the ready state.
+func (tw *SyncWorkerImpl) Introspect(ctx context.Context) (map[string]interface{}, error) {
+	const op = errors.Op("sync_worker_introspect")
+	if tw.process.State().Value() != StateReady {
+		return nil, errors.E(op, errors.Errorf("Process is not ready (%s)", tw.process.State().String()))
+	}
+
+	tw.process.State().Set(StateWorking)
+	c := make(chan wintrospect, 1)
+
+	go func() {
+		diag, err := internal.FetchIntrospection(tw.process.Relay())
+		if err != nil {
+			tw.process.State().Set(StateErrored)
+		} else if tw.process.State().Value() == StateWorking {
+			tw.process.State().Set(StateReady)
+		}
+		c <- wintrospect{diag: diag, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// the reply is still pending, the worker's state is updated when it arrives
+		return nil, errors.E(op, errors.ExecTTL, ctx.Err())
+	case res := <-c:
+		if res.err != nil {
+			return nil, errors.E(op, res.err)
+		}
+
+		return res.diag, nil
+	}
+}
+
 func (tw *SyncWorkerImpl) execPayload(p *payload.Payload) (*payload.Payload, error) {
 	const op = errors.Op("sync_worker_exec_payload")
 
@@ -160,15 +320,36 @@ func (tw *SyncWorkerImpl) execPayload(p *payload.Payload) (*payload.Payload, err
 
 	// can be 0 here
 	fr.WriteVersion(fr.Header(), frame.VERSION_1)
+	// declared body encoding travels with the frame flags
+	if p.Codec != 0 {
+		fr.WriteFlags(fr.Header(), p.Codec)
+	}
 
 	// obtain a buffer
 	buf := tw.get()
 
-	buf.Write(p.Context)
+	// inject worker-local values into the context (if any)
+	pldCtx := contextWithLocals(p.Context, tw.process.Locals())
+
+	buf.Write(pldCtx)
 	buf.Write(p.Body)
 
 	// Context offset
-	fr.WriteOptions(fr.HeaderPtr(), uint32(len(p.Context)))
+	var id uint32
+	switch {
+	case tw.correlate:
+		id = atomic.AddUint32(&tw.seq, 1)
+		// options are positional, checksum is 0 if disabled
+		var sum uint32
+		if tw.verifyChecksums {
+			sum = crc32.ChecksumIEEE(buf.Bytes())
+		}
+		fr.WriteOptions(fr.HeaderPtr(), uint32(len(pldCtx)), sum, id)
+	case tw.verifyChecksums:
+		fr.WriteOptions(fr.HeaderPtr(), uint32(len(pldCtx)), crc32.ChecksumIEEE(buf.Bytes()))
+	default:
+		fr.WriteOptions(fr.HeaderPtr(), uint32(len(pldCtx)))
+	}
 	fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
 	fr.WritePayload(buf.Bytes())
 
@@ -177,6 +358,13 @@ func (tw *SyncWorkerImpl) execPayload(p *payload.Payload) (*payload.Payload, err
 	// return buffer
 	tw.put(buf)
 
+	// the response might start before the Send returns
+	rc := tw.process.readCounter()
+	var readsBefore uint64
+	if rc != nil {
+		readsBefore = rc.Read()
+	}
+
 	err := tw.Relay().Send(fr)
 	if err != nil {
 		return nil, errors.E(op, errors.Network, err)
@@ -185,7 +373,27 @@ func (tw *SyncWorkerImpl) execPayload(p *payload.Payload) (*payload.Payload, err
 	frameR := tw.getFrame()
 	defer tw.putFrame(frameR)
 
+	var readTimer *time.Timer
+	var stall *stallWatch
+	switch {
+	case tw.responseReadTimeout <= 0:
+	case rc != nil:
+		stall = tw.watchStall(rc, readsBefore)
+	default:
+		// the only way to unblock the Receive is to kill the worker
+		readTimer = time.AfterFunc(tw.responseReadTimeout, func() {
+			_ = tw.process.Kill()
+		})
+	}
+
 	err = tw.process.Relay().Receive(frameR)
+	// timer fired, the worker is killed even if the response is received in the meantime
+	if readTimer != nil && !readTimer.Stop() {
+		return nil, errors.E(op, errors.ExecTTL, errors.Errorf("response is not received within the response read timeout (%s)", tw.responseReadTimeout))
+	}
+	if stall != nil && stall.stop() {
+		return nil, errors.E(op, errors.ExecTTL, errors.Errorf("response stalled, no data received within the response read timeout (%s)", tw.responseReadTimeout))
+	}
 	if err != nil {
 		return nil, errors.E(op, errors.Network, err)
 	}
@@ -200,24 +408,46 @@ func (tw *SyncWorkerImpl) execPayload(p *payload.Payload) (*payload.Payload, err
 	flags := frameR.ReadFlags()
 
 	if flags&frame.ERROR != byte(0) {
-		return nil, errors.E(op, errors.SoftJob, errors.Str(string(frameR.Payload())))
+		return nil, errors.E(op, errors.SoftJob, softJobError(frameR))
 	}
 
 	options := frameR.ReadOptions(frameR.Header())
-	if len(options) != 1 {
-		return nil, errors.E(op, errors.Decode, errors.Str("options length should be equal 1 (body offset)"))
+	if len(options) == 0 {
+		return nil, errors.E(op, errors.Decode, errors.Str("options should contain the body offset (and the checksum, if enabled)"))
 	}
 
-	pld := &payload.Payload{
-		Body:    make([]byte, len(frameR.Payload()[options[0]:])),
-		Context: make([]byte, len(frameR.Payload()[:options[0]])),
+	if tw.verifyChecksums {
+		if len(options) <= optChecksum {
+			return nil, errors.E(op, errors.Network, errors.Str("payload checksum is missing"))
+		}
+
+		if crc32.ChecksumIEEE(frameR.Payload()) != options[optChecksum] {
+			return nil, errors.E(op, errors.Network, errors.Str("payload checksum mismatch"))
+		}
+	}
+
+	if tw.correlate {
+		if len(options) <= optCorrelationID {
+			return nil, errors.E(op, errors.Network, errors.Str("correlation id is missing"))
+		}
+
+		if options[optCorrelationID] != id {
+			return nil, errors.E(op, errors.Network, errors.Errorf("relay desynchronized, correlation id mismatch (sent %d, received %d)", id, options[optCorrelationID]))
+		}
 	}
 
+	// the response is owned by the caller, it might be returned with the payload.PutPayload
+	pld := payload.GetPayload()
+	pld.Body = payload.Resize(pld.Body, len(frameR.Payload()[options[optContextOffset]:]))
+	pld.Context = payload.Resize(pld.Context, len(frameR.Payload()[:options[optContextOffset]]))
+	pld.Codec = payload.CodecFromFlags(flags)
+
 	// by copying we free frame's payload slice
 	// we do not hold the pointer from the smaller slice to the initial (which should be in the sync.Pool)
 	// https://blog.golang.org/slices-intro#TOC_6.
-	copy(pld.Body, frameR.Payload()[options[0]:])
-	copy(pld.Context, frameR.Payload()[:options[0]])
+	copy(pld.Body, frameR.Payload()[options[optContextOffset]:])
+	copy(pld.Context, frameR.Payload()[:options[optContextOffset]])
+	pld.Context, pld.Trailers = trailersFromContext(pld.Context)
 
 	return pld, nil
 }
@@ -226,10 +456,18 @@ func (tw *SyncWorkerImpl) String() string {
 	return tw.process.String()
 }
 
+func (tw *SyncWorkerImpl) MarshalJSON() ([]byte, error) {
+	return tw.process.MarshalJSON()
+}
+
 func (tw *SyncWorkerImpl) Pid() int64 {
 	return tw.process.Pid()
 }
 
+func (tw *SyncWorkerImpl) ID() string {
+	return tw.process.ID()
+}
+
 func (tw *SyncWorkerImpl) Created() time.Time {
 	return tw.process.Created()
 }
@@ -262,6 +500,66 @@ func (tw *SyncWorkerImpl) AttachRelay(rl relay.Relay) {
 	tw.process.AttachRelay(rl)
 }
 
+func (tw *SyncWorkerImpl) SetAttachment(key, value interface{}) {
+	tw.process.SetAttachment(key, value)
+}
+
+func (tw *SyncWorkerImpl) Attachment(key interface{}) (interface{}, bool) {
+	return tw.process.Attachment(key)
+}
+
+func (tw *SyncWorkerImpl) BytesSent() uint64 {
+	return tw.process.BytesSent()
+}
+
+func (tw *SyncWorkerImpl) BytesReceived() uint64 {
+	return tw.process.BytesReceived()
+}
+
+func (tw *SyncWorkerImpl) LastRelayActivity() time.Time {
+	return tw.process.LastRelayActivity()
+}
+
+func (tw *SyncWorkerImpl) InFlight() int {
+	return tw.process.InFlight()
+}
+
+func (tw *SyncWorkerImpl) SetLocal(key, value string) {
+	tw.process.SetLocal(key, value)
+}
+
+func (tw *SyncWorkerImpl) GetLocal(key string) (string, bool) {
+	return tw.process.GetLocal(key)
+}
+
+func (tw *SyncWorkerImpl) Locals() map[string]string {
+	return tw.process.Locals()
+}
+
+func (tw *SyncWorkerImpl) ClearLocals() {
+	tw.process.ClearLocals()
+}
+
+func (tw *SyncWorkerImpl) Labels() map[string]string {
+	return tw.process.Labels()
+}
+
+func (tw *SyncWorkerImpl) CmdLine() []string {
+	return tw.process.CmdLine()
+}
+
+func (tw *SyncWorkerImpl) Transport() string {
+	return tw.process.Transport()
+}
+
+func (tw *SyncWorkerImpl) Stderr() []byte {
+	return tw.process.Stderr()
+}
+
+func (tw *SyncWorkerImpl) Env() []string {
+	return tw.process.Env()
+}
+
 // Private
 
 func (tw *SyncWorkerImpl) get() *bytes.Buffer {
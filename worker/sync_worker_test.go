@@ -1,11 +1,20 @@
 package worker
 
 import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"io"
 	"os/exec"
 	"testing"
+	"time"
 
+	"github.com/spiral/errors"
+	"github.com/spiral/goridge/v3/pkg/frame"
+	"github.com/spiral/goridge/v3/pkg/pipe"
 	"github.com/spiral/roadrunner/v2/payload"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_NotStarted_String(t *testing.T) {
@@ -31,3 +40,271 @@ func Test_NotStarted_Exec(t *testing.T) {
 
 	assert.Contains(t, err.Error(), "Process is not ready (inactive)")
 }
+
+// relayWorker creates the ready SyncWorker connected to the in-memory relay, handler plays the worker side
+func relayWorker(t testing.TB, handler func(req *frame.Frame) *frame.Frame, options ...SyncWorkerOptions) *SyncWorkerImpl {
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+
+	// host -> worker
+	hostR, workerW := io.Pipe()
+	// worker -> host
+	workerR, hostW := io.Pipe()
+
+	w.AttachRelay(pipe.NewPipeRelay(hostR, hostW))
+	w.State().Set(StateReady)
+
+	go func() {
+		rl := pipe.NewPipeRelay(workerR, workerW)
+		for {
+			fr := frame.NewFrame()
+			if errR := rl.Receive(fr); errR != nil {
+				return
+			}
+
+			if errS := rl.Send(handler(fr)); errS != nil {
+				return
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = hostR.Close()
+		_ = workerR.Close()
+	})
+
+	return From(w, options...)
+}
+
+// echoFrame responds with the request payload, flip corrupts the payload after the checksum calculation
+func echoFrame(req *frame.Frame, flip bool) *frame.Frame {
+	opts := req.ReadOptions(req.Header())
+	pld := make([]byte, len(req.Payload()))
+	copy(pld, req.Payload())
+
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.VERSION_1)
+	fr.WriteOptions(fr.HeaderPtr(), opts[0], crc32.ChecksumIEEE(pld))
+	if flip {
+		pld[len(pld)-1] ^= 0xFF
+	}
+	fr.WritePayloadLen(fr.Header(), uint32(len(pld)))
+	fr.WritePayload(pld)
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func Test_Checksums(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, false)
+	}, WithChecksums(true))
+
+	res, err := sw.Exec(&payload.Payload{Body: []byte("hello"), Context: []byte("ctx")})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", res.String())
+	assert.Equal(t, []byte("ctx"), res.Context)
+}
+
+func Test_Checksums_Corrupted(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, true)
+	}, WithChecksums(true))
+
+	res, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	assert.Nil(t, res)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.Network, err))
+	assert.Contains(t, err.Error(), "payload checksum mismatch")
+}
+
+func Test_LastResponseAt(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, bytes.Contains(req.Payload(), []byte("corrupted")))
+	}, WithChecksums(true))
+	assert.True(t, sw.State().LastResponseAt().IsZero())
+
+	before := time.Now()
+	_, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	last := sw.State().LastResponseAt()
+	assert.False(t, last.Before(before))
+
+	// failed exec doesn't update it
+	sw.State().Set(StateReady)
+	_, err = sw.Exec(&payload.Payload{Body: []byte("corrupted")})
+	require.Error(t, err)
+	assert.Equal(t, last, sw.State().LastResponseAt())
+}
+
+// correlatedFrame responds with the request payload echoing the request correlation ID
+func correlatedFrame(req *frame.Frame) *frame.Frame {
+	opts := req.ReadOptions(req.Header())
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.VERSION_1)
+	fr.WriteOptions(fr.HeaderPtr(), opts[optContextOffset], opts[optChecksum], opts[optCorrelationID])
+	fr.WritePayloadLen(fr.Header(), uint32(len(req.Payload())))
+	fr.WritePayload(req.Payload())
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func Test_CorrelationIDs(t *testing.T) {
+	var stale *frame.Frame
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		rsp := correlatedFrame(req)
+		if bytes.Contains(req.Payload(), []byte("first")) {
+			stale = rsp
+			return rsp
+		}
+		// misordered frame, the response of the previous request
+		return stale
+	}, WithCorrelationIDs(true))
+
+	res, err := sw.Exec(&payload.Payload{Body: []byte("first")})
+	require.NoError(t, err)
+	assert.Equal(t, "first", res.String())
+
+	res, err = sw.Exec(&payload.Payload{Body: []byte("second")})
+	assert.Nil(t, res)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.Network, err))
+	assert.Contains(t, err.Error(), "correlation id mismatch (sent 2, received 1)")
+}
+
+func Test_CorrelationIDs_Missing(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, false)
+	}, WithCorrelationIDs(true), WithChecksums(true))
+
+	_, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.Network, err))
+	assert.Contains(t, err.Error(), "correlation id is missing")
+}
+
+func Test_Introspect(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		fr := frame.NewFrame()
+		fr.WriteVersion(fr.Header(), frame.VERSION_1)
+		fr.WriteFlags(fr.Header(), frame.CONTROL)
+		if !bytes.Contains(req.Payload(), []byte(`"introspect":true`)) {
+			return fr
+		}
+		data := []byte(`{"memory":1024,"extensions":["json"]}`)
+		fr.WritePayloadLen(fr.Header(), uint32(len(data)))
+		fr.WritePayload(data)
+		fr.WriteCRC(fr.Header())
+		return fr
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	diag, err := sw.Introspect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1024), diag["memory"])
+	assert.Equal(t, []interface{}{"json"}, diag["extensions"])
+	assert.Equal(t, StateReady, sw.State().Value())
+}
+
+func Test_IntrospectTimeout(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		// late reply
+		time.Sleep(time.Millisecond * 200)
+		fr := frame.NewFrame()
+		fr.WriteVersion(fr.Header(), frame.VERSION_1)
+		fr.WriteFlags(fr.Header(), frame.CONTROL)
+		data := []byte(`{"memory":1024}`)
+		fr.WritePayloadLen(fr.Header(), uint32(len(data)))
+		fr.WritePayload(data)
+		fr.WriteCRC(fr.Header())
+		return fr
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	_, err := sw.Introspect(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.ExecTTL, err))
+
+	// not killed, back to the ready state after the late reply
+	assert.Equal(t, StateWorking, sw.State().Value())
+	assert.Eventually(t, func() bool {
+		return sw.State().Value() == StateReady
+	}, time.Second, time.Millisecond*10)
+}
+
+func Test_ControlExecs(t *testing.T) {
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, false)
+	})
+
+	_, err := sw.Exec(&payload.Payload{Body: []byte("ping"), Control: true})
+	require.NoError(t, err)
+	_, err = sw.ExecWithTTL(context.Background(), &payload.Payload{Body: []byte("ping"), Control: true})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), sw.State().NumExecs())
+	// released correctly
+	assert.Equal(t, StateReady, sw.State().Value())
+
+	_, err = sw.Exec(&payload.Payload{Body: []byte("hello")})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), sw.State().NumExecs())
+}
+
+func Test_InFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sw := relayWorker(t, func(req *frame.Frame) *frame.Frame {
+		started <- struct{}{}
+		<-release
+		return echoFrame(req, false)
+	})
+	assert.Equal(t, 0, sw.InFlight())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := sw.Exec(&payload.Payload{Body: []byte("hello")})
+		assert.NoError(t, err)
+	}()
+
+	<-started
+	assert.Equal(t, 1, sw.InFlight())
+	close(release)
+	<-done
+	assert.Equal(t, 0, sw.InFlight())
+}
+
+// BenchmarkExec and BenchmarkExec_PutPayload compare the allocs/op w/o and with the response recycling
+func BenchmarkExec(b *testing.B) {
+	sw := relayWorker(b, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, false)
+	})
+	rqs := &payload.Payload{Body: bytes.Repeat([]byte("a"), 4096), Context: []byte("ctx")}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := sw.Exec(rqs)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExec_PutPayload(b *testing.B) {
+	sw := relayWorker(b, func(req *frame.Frame) *frame.Frame {
+		return echoFrame(req, false)
+	})
+	rqs := &payload.Payload{Body: bytes.Repeat([]byte("a"), 4096), Context: []byte("ctx")}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rsp, err := sw.Exec(rqs)
+		if err != nil {
+			b.Fatal(err)
+		}
+		payload.PutPayload(rsp)
+	}
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"bytes"
+
+	j "github.com/json-iterator/go"
+)
+
+// TrailersKey is the response payload context key under which the worker sends the trailers (out-of-band metadata,
+// e.g. timing, cache status) as a JSON object of strings. The key is removed from the context, values are available
+// via the payload.Payload Trailers. Responses w/o the key are not changed.
+const TrailersKey string = "worker_trailers"
+
+var trailersKey = []byte(`"` + TrailersKey + `"`)
+
+// trailersFromContext extracts the trailers from the JSON response context, returns the context w/o the TrailersKey.
+// Non-JSON-object context or context w/o the trailers is returned as is.
+func trailersFromContext(ctx []byte) ([]byte, map[string]string) {
+	// fast path, most of the responses have no trailers
+	if !bytes.Contains(ctx, trailersKey) {
+		return ctx, nil
+	}
+
+	obj := make(map[string]j.RawMessage)
+	err := json.Unmarshal(ctx, &obj)
+	if err != nil {
+		return ctx, nil
+	}
+
+	raw, ok := obj[TrailersKey]
+	if !ok {
+		// key is the part of a value
+		return ctx, nil
+	}
+
+	var trailers map[string]string
+	err = json.Unmarshal(raw, &trailers)
+	if err != nil {
+		return ctx, nil
+	}
+	delete(obj, TrailersKey)
+
+	if len(obj) == 0 {
+		return nil, trailers
+	}
+
+	res, err := json.Marshal(obj)
+	if err != nil {
+		return ctx, nil
+	}
+
+	return res, trailers
+}
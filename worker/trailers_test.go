@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TrailersFromContext(t *testing.T) {
+	ctx, trailers := trailersFromContext([]byte(`{"status":200}`))
+	assert.Equal(t, []byte(`{"status":200}`), ctx)
+	assert.Nil(t, trailers)
+
+	ctx, trailers = trailersFromContext([]byte("raw worker_trailers"))
+	assert.Equal(t, []byte("raw worker_trailers"), ctx)
+	assert.Nil(t, trailers)
+
+	// key is the part of a value
+	ctx, trailers = trailersFromContext([]byte(`{"foo":"\"worker_trailers\""}`))
+	assert.Equal(t, []byte(`{"foo":"\"worker_trailers\""}`), ctx)
+	assert.Nil(t, trailers)
+
+	ctx, trailers = trailersFromContext([]byte(`{"status":200,"worker_trailers":{"cache":"hit"}}`))
+	assert.JSONEq(t, `{"status":200}`, string(ctx))
+	assert.Equal(t, map[string]string{"cache": "hit"}, trailers)
+
+	ctx, trailers = trailersFromContext([]byte(`{"worker_trailers":{"timing":"12ms"}}`))
+	assert.Nil(t, ctx)
+	assert.Equal(t, map[string]string{"timing": "12ms"}, trailers)
+}
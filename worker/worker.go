@@ -6,8 +6,11 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/spiral/errors"
 	"github.com/spiral/goridge/v3/pkg/relay"
 	"github.com/spiral/roadrunner/v2/events"
@@ -19,6 +22,12 @@ type Options func(p *Process)
 
 // Process - supervised process with api over goridge.Relay.
 type Process struct {
+	// id is the stable identity of the Process (UUID), pid might be reused by the OS
+	id string
+
+	// 1 - the kill signal is sent by the Kill or the Stop (atomic), see ExitError
+	killed uint32
+
 	// created indicates at what time Process has been created.
 	created time.Time
 
@@ -42,6 +51,36 @@ type Process struct {
 
 	// communication bus with underlying process.
 	relay relay.Relay
+	// bytes sent to and received from the process via the relay (atomic)
+	bytesSent     uint64
+	bytesReceived uint64
+	// last frame sent or received (unix nano, atomic), 0 - no relay activity yet
+	lastRelayActivity int64
+	// requests in progress (atomic), 0 or 1 in the exclusive mode
+	inFlight int64
+
+	// host-managed worker-local values, reset on recycle
+	localsMu sync.RWMutex
+	locals   map[string]string
+
+	// plugin-managed opaque values, cleared on the process exit
+	attachments sync.Map
+
+	// labels set at allocation, immutable during the worker lifetime
+	labels map[string]string
+	// transport name set at allocation (see transport.Named), empty - unknown
+	transport string
+
+	// command line and environment captured at spawn (audit)
+	cmdLine []string
+	env     []string
+	// env keys (case-insensitive substrings) to redact in the Env
+	redactEnv []string
+
+	// last stderr output (WithStderrBuffer), nil - disabled, and the EventWorkerStderrOverflow pushed flag
+	stderrMu       sync.Mutex
+	stderr         *stderrBuffer
+	stderrOverflow bool
 }
 
 // InitBaseWorker creates new Process over given exec.cmd.
@@ -50,10 +89,19 @@ func InitBaseWorker(cmd *exec.Cmd, options ...Options) (*Process, error) {
 		return nil, fmt.Errorf("can't attach to running process")
 	}
 	w := &Process{
-		created: time.Now(),
-		events:  events.NewEventsHandler(),
-		cmd:     cmd,
-		state:   NewWorkerState(StateInactive),
+		id:        uuid.NewString(),
+		created:   time.Now(),
+		events:    events.NewEventsHandler(),
+		cmd:       cmd,
+		state:     NewWorkerState(StateInactive),
+		cmdLine:   append([]string(nil), cmd.Args...),
+		env:       append([]string(nil), cmd.Env...),
+		redactEnv: DefaultRedactedEnv,
+	}
+
+	// nil env means that the process inherits the environment of the current process
+	if cmd.Env == nil {
+		w.env = os.Environ()
 	}
 
 	// set self as stderr implementation (Writer interface)
@@ -75,11 +123,78 @@ func AddListeners(listeners ...events.Listener) Options {
 	}
 }
 
+// WithLabels attaches the labels (tenant, group, etc) to the worker
+func WithLabels(labels map[string]string) Options {
+	return func(p *Process) {
+		p.setLabels(labels)
+	}
+}
+
+func (w *Process) setLabels(labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	w.labels = make(map[string]string, len(labels))
+	for k, v := range labels {
+		w.labels[k] = v
+	}
+}
+
+// Labels returns the worker labels, returned map must not be modified
+func (w *Process) Labels() map[string]string {
+	return w.labels
+}
+
+// WithRedactedEnv adds the env keys (case-insensitive substrings) to redact in the Env
+func WithRedactedEnv(keys ...string) Options {
+	return func(p *Process) {
+		p.addRedactedEnv(keys)
+	}
+}
+
+func (w *Process) addRedactedEnv(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	redact := make([]string, 0, len(w.redactEnv)+len(keys))
+	redact = append(redact, w.redactEnv...)
+	for i := 0; i < len(keys); i++ {
+		redact = append(redact, strings.ToUpper(keys[i]))
+	}
+	w.redactEnv = redact
+}
+
+// Transport returns the name of the transport the worker is connected with, empty if unknown
+func (w *Process) Transport() string {
+	return w.transport
+}
+
+// CmdLine returns the command line the worker was launched with
+func (w *Process) CmdLine() []string {
+	return append([]string(nil), w.cmdLine...)
+}
+
+// Env returns the environment the worker was launched with, values of the sensitive keys are redacted
+func (w *Process) Env() []string {
+	env := make([]string, 0, len(w.env))
+	for i := 0; i < len(w.env); i++ {
+		env = append(env, redactEnv(w.env[i], w.redactEnv))
+	}
+	return env
+}
+
 // Pid returns worker pid.
 func (w *Process) Pid() int64 {
 	return int64(w.pid)
 }
 
+// ID returns the stable identity of the process (UUID)
+func (w *Process) ID() string {
+	return w.id
+}
+
 // Created returns time worker was created at.
 func (w *Process) Created() time.Time {
 	return w.created
@@ -96,9 +211,63 @@ func (w *Process) State() State {
 	return w.state
 }
 
-// AttachRelay attaches relay to the worker
+// AttachRelay attaches relay to the worker, the relay wrapped by the WithWriteCounter reports the send progress,
+// the one wrapped by the WithReadCounter reports the response progress
 func (w *Process) AttachRelay(rl relay.Relay) {
-	w.relay = rl
+	cr := &countingRelay{sent: &w.bytesSent, received: &w.bytesReceived, lastActivity: &w.lastRelayActivity}
+unwrap:
+	for {
+		switch c := rl.(type) {
+		case *countedRelay:
+			cr.writes = c.wc
+			rl = c.Relay
+		case *readCountedRelay:
+			cr.reads = c.rc
+			rl = c.Relay
+		default:
+			break unwrap
+		}
+	}
+	cr.Relay = rl
+	w.relay = cr
+}
+
+// readCounter returns the transport reads counter of the relay, nil if the relay is not counted (WithReadCounter)
+func (w *Process) readCounter() *ReadCounter {
+	if cr, ok := w.relay.(*countingRelay); ok {
+		return cr.reads
+	}
+	return nil
+}
+
+// LastRelayActivity returns the time of the last frame sent to or received from the worker, zero if none
+func (w *Process) LastRelayActivity() time.Time {
+	la := atomic.LoadInt64(&w.lastRelayActivity)
+	if la == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, la)
+}
+
+// InFlight returns the number of the requests the worker is currently executing
+func (w *Process) InFlight() int {
+	return int(atomic.LoadInt64(&w.inFlight))
+}
+
+// addInFlight counts the request in progress, delta is 1 on start and -1 on finish
+func (w *Process) addInFlight(delta int64) {
+	atomic.AddInt64(&w.inFlight, delta)
+}
+
+// BytesSent returns the number of bytes sent to the worker via the relay
+func (w *Process) BytesSent() uint64 {
+	return atomic.LoadUint64(&w.bytesSent)
+}
+
+// BytesReceived returns the number of bytes received from the worker via the relay
+func (w *Process) BytesReceived() uint64 {
+	return atomic.LoadUint64(&w.bytesReceived)
 }
 
 // Relay returns relay attached to the worker
@@ -106,6 +275,66 @@ func (w *Process) Relay() relay.Relay {
 	return w.relay
 }
 
+// SetLocal sets the worker-local value
+func (w *Process) SetLocal(key, value string) {
+	w.localsMu.Lock()
+	defer w.localsMu.Unlock()
+	if w.locals == nil {
+		w.locals = make(map[string]string, 1)
+	}
+	w.locals[key] = value
+}
+
+// GetLocal returns the worker-local value associated with the key
+func (w *Process) GetLocal(key string) (string, bool) {
+	w.localsMu.RLock()
+	defer w.localsMu.RUnlock()
+	v, ok := w.locals[key]
+	return v, ok
+}
+
+// Locals returns a copy of all worker-local values
+func (w *Process) Locals() map[string]string {
+	w.localsMu.RLock()
+	defer w.localsMu.RUnlock()
+	if len(w.locals) == 0 {
+		return nil
+	}
+
+	cp := make(map[string]string, len(w.locals))
+	for k, v := range w.locals {
+		cp[k] = v
+	}
+	return cp
+}
+
+// ClearLocals removes all worker-local values
+func (w *Process) ClearLocals() {
+	w.localsMu.Lock()
+	w.locals = nil
+	w.localsMu.Unlock()
+}
+
+// SetAttachment associates the opaque value with the worker, the key should be of the plugin own type
+// (like the context.WithValue keys) to avoid collisions between plugins
+func (w *Process) SetAttachment(key, value interface{}) {
+	w.attachments.Store(key, value)
+}
+
+// Attachment returns the value associated with the key by the SetAttachment
+func (w *Process) Attachment(key interface{}) (interface{}, bool) {
+	return w.attachments.Load(key)
+}
+
+// clearAttachments drops the attachments of the exited process, so the values (e.g. connections) are not leaked
+// by the holders of the worker reference
+func (w *Process) clearAttachments() {
+	w.attachments.Range(func(key, _ interface{}) bool {
+		w.attachments.Delete(key)
+		return true
+	})
+}
+
 // String returns Process description. fmt.Stringer interface
 func (w *Process) String() string {
 	st := w.state.String()
@@ -122,6 +351,37 @@ func (w *Process) String() string {
 	)
 }
 
+// processJSON is the JSON representation of the Process
+type processJSON struct {
+	Pid       int64  `json:"pid"`
+	Status    string `json:"status"`
+	NumExecs  uint64 `json:"numExecs"`
+	Transport string `json:"transport,omitempty"`
+	// unix nano timestamps, last used and last response 0 - never used
+	Created        int64  `json:"created"`
+	LastUsed       uint64 `json:"lastUsed"`
+	LastResponseAt int64  `json:"lastResponseAt"`
+	// last state transitions, if the journal is enabled
+	Journal []Transition `json:"journal,omitempty"`
+	// nanoseconds since the creation
+	Uptime time.Duration `json:"uptime"`
+}
+
+// MarshalJSON returns the Process description as JSON for the tooling. json.Marshaler interface
+func (w *Process) MarshalJSON() ([]byte, error) {
+	return json.Marshal(processJSON{
+		Pid:            w.Pid(),
+		Status:         w.state.String(),
+		NumExecs:       w.state.NumExecs(),
+		Transport:      w.transport,
+		Created:        w.created.UnixNano(),
+		LastUsed:       w.state.LastUsed(),
+		LastResponseAt: atomic.LoadInt64(&w.state.lastResponse),
+		Journal:        w.state.Journal(),
+		Uptime:         time.Since(w.created),
+	})
+}
+
 func (w *Process) Start() error {
 	err := w.cmd.Start()
 	if err != nil {
@@ -139,6 +399,8 @@ func (w *Process) Wait() error {
 	const op = errors.Op("process_wait")
 	var err error
 	err = w.cmd.Wait()
+	// process is gone, the replacement starts w/o the attachments
+	w.clearAttachments()
 
 	// If worker was destroyed, just exit
 	if w.State().Value() == StateDestroyed {
@@ -148,6 +410,10 @@ func (w *Process) Wait() error {
 	// If state is different, and err is not nil, append it to the errors
 	if err != nil {
 		w.State().Set(StateErrored)
+		// fatal signals are reported as the ExitError
+		if ee := exitError(w.cmd.ProcessState, err, atomic.LoadUint32(&w.killed) == 1); ee != nil {
+			err = ee
+		}
 		err = multierr.Combine(err, errors.E(op, err))
 	}
 
@@ -186,6 +452,7 @@ func (w *Process) Stop() error {
 	err := internal.SendControl(w.relay, &internal.StopCommand{Stop: true})
 	if err != nil {
 		w.state.Set(StateKilling)
+		atomic.StoreUint32(&w.killed, 1)
 		_ = w.cmd.Process.Signal(os.Kill)
 		return errors.E(op, errors.Network, err)
 	}
@@ -196,6 +463,7 @@ func (w *Process) Stop() error {
 // Kill kills underlying process, make sure to call Wait() func to gather
 // error log from the stderr. Does not wait for process completion!
 func (w *Process) Kill() error {
+	atomic.StoreUint32(&w.killed, 1)
 	if w.State().Value() == StateDestroyed {
 		err := w.cmd.Process.Signal(os.Kill)
 		if err != nil {
@@ -215,6 +483,26 @@ func (w *Process) Kill() error {
 
 // Worker stderr
 func (w *Process) Write(p []byte) (n int, err error) {
-	w.events.Push(events.WorkerEvent{Event: events.EventWorkerStderr, Worker: w, Payload: p})
+	w.bufferStderr(p)
+	w.events.Push(events.WorkerEvent{Event: events.EventWorkerStderr, Worker: w, Payload: p, Labels: w.labels})
 	return len(p), nil
 }
+
+// DefaultRedactedEnv is the list of the env keys substrings, values of the matching keys are redacted in the Process.Env
+var DefaultRedactedEnv = []string{"PASSWORD", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
+
+func redactEnv(kv string, keys []string) string {
+	i := strings.IndexByte(kv, '=')
+	if i < 0 {
+		return kv
+	}
+
+	name := strings.ToUpper(kv[:i])
+	for j := 0; j < len(keys); j++ {
+		if strings.Contains(name, keys[j]) {
+			return kv[:i+1] + "[REDACTED]"
+		}
+	}
+
+	return kv
+}
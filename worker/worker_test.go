@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_OnStarted(t *testing.T) {
@@ -17,3 +18,90 @@ func Test_OnStarted(t *testing.T) {
 
 	assert.Equal(t, "can't attach to running process", err.Error())
 }
+
+func Test_Labels(t *testing.T) {
+	labels := map[string]string{"tenant": "foo"}
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"), WithLabels(labels))
+	require.NoError(t, err)
+
+	// labels are copied at allocation
+	labels["tenant"] = "bar"
+	assert.Equal(t, map[string]string{"tenant": "foo"}, w.Labels())
+
+	sw := From(w)
+	assert.Equal(t, "foo", sw.Labels()["tenant"])
+
+	w2, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+	assert.Nil(t, From(w2).Labels())
+	assert.Equal(t, "baz", From(w2, WithSyncLabels(map[string]string{"tenant": "baz"})).Labels()["tenant"])
+}
+
+func Test_ID(t *testing.T) {
+	w1, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+	w2, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, w1.ID())
+	assert.NotEqual(t, w1.ID(), w2.ID())
+	assert.Equal(t, w1.ID(), From(w1).ID())
+}
+
+func Test_CmdLineEnv(t *testing.T) {
+	cmd := exec.Command("php", "tests/client.php", "echo", "pipes")
+	cmd.Env = []string{"APP_ENV=canary", "DB_PASSWORD=secret", "MY_CUSTOM=value", "BROKEN"}
+
+	w, err := InitBaseWorker(cmd, WithRedactedEnv("custom"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"php", "tests/client.php", "echo", "pipes"}, w.CmdLine())
+	assert.Equal(t, []string{"APP_ENV=canary", "DB_PASSWORD=[REDACTED]", "MY_CUSTOM=[REDACTED]", "BROKEN"}, w.Env())
+
+	// captured at spawn
+	cmd.Env[0] = "APP_ENV=prod"
+	assert.Equal(t, "APP_ENV=canary", w.Env()[0])
+}
+
+type attachmentKey struct{}
+
+func Test_Attachments(t *testing.T) {
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+
+	_, ok := w.Attachment(attachmentKey{})
+	assert.False(t, ok)
+
+	w.SetAttachment(attachmentKey{}, 42)
+	v, ok := w.Attachment(attachmentKey{})
+	assert.True(t, ok)
+	assert.Equal(t, 42, v)
+
+	// other key of the same underlying value
+	_, ok = w.Attachment(struct{}{})
+	assert.False(t, ok)
+
+	// process exit
+	w.clearAttachments()
+	_, ok = w.Attachment(attachmentKey{})
+	assert.False(t, ok)
+}
+
+func Test_MarshalJSON(t *testing.T) {
+	w, err := InitBaseWorker(exec.Command("php", "tests/client.php", "echo", "pipes"))
+	require.NoError(t, err)
+	w.State().RegisterExec()
+
+	data, err := json.Marshal(w)
+	require.NoError(t, err)
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &res))
+	assert.Equal(t, float64(0), res["pid"])
+	assert.Equal(t, "inactive", res["status"])
+	assert.Equal(t, float64(1), res["numExecs"])
+	assert.Equal(t, float64(w.Created().UnixNano()), res["created"])
+	assert.Equal(t, float64(0), res["lastUsed"])
+	assert.Equal(t, float64(0), res["lastResponseAt"])
+	assert.Greater(t, res["uptime"], float64(0))
+}
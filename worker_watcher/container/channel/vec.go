@@ -76,7 +76,13 @@ func (v *Vec) Push(w worker.BaseProcess) {
 	}
 }
 
-func (v *Vec) Remove(_ int64) {}
+func (v *Vec) Remove(_ string) {}
+
+// Replace pushes the new worker, the previous worker (if it's still in the channel) is not in the ready state
+// and will be skipped on the Pop (see worker_watcher.Take)
+func (v *Vec) Replace(_ string, newWorker worker.BaseProcess) {
+	v.Push(newWorker)
+}
 
 func (v *Vec) Pop(ctx context.Context) (worker.BaseProcess, error) {
 	/*
@@ -94,6 +100,13 @@ func (v *Vec) Pop(ctx context.Context) (worker.BaseProcess, error) {
 	v.RLock()
 	defer v.RUnlock()
 
+	// prefer the free worker even if the context is already canceled (TryExec)
+	select {
+	case w := <-v.workers:
+		return w, nil
+	default:
+	}
+
 	select {
 	case w := <-v.workers:
 		return w, nil
@@ -102,6 +115,47 @@ func (v *Vec) Pop(ctx context.Context) (worker.BaseProcess, error) {
 	}
 }
 
+// Len returns number of workers in the vector
+func (v *Vec) Len() uint64 {
+	return uint64(len(v.workers))
+}
+
+// Drain pops all the workers left in the channel without blocking. It doesn't take the lock, the Pop waiting on the
+// empty channel holds the read lock.
+func (v *Vec) Drain() []worker.BaseProcess {
+	workers := make([]worker.BaseProcess, 0, len(v.workers))
+	for {
+		select {
+		case w := <-v.workers:
+			workers = append(workers, w)
+		default:
+			return workers
+		}
+	}
+}
+
+// Extract rotates the channel once looking for the worker with the ID, the other workers are put back at once in the
+// same order. It doesn't take the lock for the same reason as the Drain.
+func (v *Vec) Extract(id string) worker.BaseProcess {
+	var found worker.BaseProcess
+	for i := len(v.workers); i > 0; i-- {
+		var w worker.BaseProcess
+		select {
+		case w = <-v.workers:
+		default:
+			return found
+		}
+
+		if found == nil && w.ID() == id {
+			found = w
+			continue
+		}
+		v.Push(w)
+	}
+
+	return found
+}
+
 func (v *Vec) Destroy() {
 	atomic.StoreUint64(&v.destroy, 1)
 }
@@ -91,7 +91,7 @@ func (q *Queue) Pop(ctx context.Context) (worker.BaseProcess, error) {
 	return w, nil
 }
 
-func (q *Queue) Replace(oldPid int64, newWorker worker.BaseProcess) {
+func (q *Queue) Replace(oldID string, newWorker worker.BaseProcess) {
 
 }
 
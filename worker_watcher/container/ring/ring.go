@@ -0,0 +1,267 @@
+/*
+bounded lock-free MPMC ring buffer (D. Vyukov) used as the ready workers container
+*/
+
+package ring
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// cacheLinePad prevents false sharing between the enqueue and dequeue positions
+type cacheLinePad struct {
+	_ [64]byte
+}
+
+type cell struct {
+	seq uint64
+	w   worker.BaseProcess
+}
+
+type Ring struct {
+	_   cacheLinePad
+	enq uint64
+	_   cacheLinePad
+	deq uint64
+	_   cacheLinePad
+
+	mask  uint64
+	cells []cell
+	// number of workers in the ring
+	len int64
+	// destroy signal
+	destroy uint64
+	// wakes up the waiting Pop, buffered to not miss the wakeup between the empty check and the wait
+	signal chan struct{}
+	// used only for the full ring (see Push)
+	mu sync.Mutex
+}
+
+// NewRing creates the ring, capacity is rounded up to the power of two
+func NewRing(capacity uint64) *Ring {
+	size := uint64(2)
+	for size < capacity {
+		size <<= 1
+	}
+
+	r := &Ring{
+		mask:   size - 1,
+		cells:  make([]cell, size),
+		signal: make(chan struct{}, 1),
+	}
+
+	for i := uint64(0); i < size; i++ {
+		r.cells[i].seq = i
+	}
+
+	return r
+}
+
+// Push is O(1) lock-free operation
+// In case of TTL and full ring O(n) worst case, where n is len of the ring
+func (r *Ring) Push(w worker.BaseProcess) {
+	if !r.enqueue(w) {
+		r.pushFull(w)
+	}
+
+	r.wakeup()
+}
+
+// pushFull replaces the first worker in the bad state with the new one, same as the channel.Vec. If the ring is full
+// of the good workers, the new worker is dropped.
+func (r *Ring) pushFull(w worker.BaseProcess) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := int64(len(r.cells))
+	for !r.enqueue(w) {
+		// really full
+		if atomic.LoadInt64(&r.len) >= size {
+			r.evict(w, size)
+			return
+		}
+		// the cell is not yet released by the concurrent Pop
+		runtime.Gosched()
+	}
+}
+
+// evict scans the ring for the first worker in the bad state and replaces it with the new one
+func (r *Ring) evict(w worker.BaseProcess, size int64) {
+	for i := int64(0); i < size; i++ {
+		wrk, ok := r.dequeue()
+		if !ok {
+			// drained by the concurrent Pop
+			if r.enqueue(w) {
+				return
+			}
+			continue
+		}
+
+		switch wrk.State().Value() {
+		// skip good states, put worker back
+		case worker.StateWorking, worker.StateReady:
+			r.requeue(wrk)
+			continue
+		default:
+			// kill the current worker (just to be sure it's dead)
+			_ = wrk.Kill()
+			if r.enqueue(w) {
+				return
+			}
+			// the cell was taken by the concurrent Push, look for the next bad worker
+		}
+	}
+}
+
+// requeue puts the good worker back, the cell released by the dequeue might be taken by the concurrent Push,
+// in that case it waits for the next Pop (same as the blocking re-send in the channel.Vec)
+func (r *Ring) requeue(w worker.BaseProcess) {
+	for !r.enqueue(w) {
+		runtime.Gosched()
+	}
+}
+
+func (r *Ring) Pop(ctx context.Context) (worker.BaseProcess, error) {
+	for {
+		if atomic.LoadUint64(&r.destroy) == 1 {
+			// pass the destroy signal to the next waiting Pop
+			r.wakeup()
+			return nil, errors.E(errors.WatcherStopped)
+		}
+
+		if w, ok := r.dequeue(); ok {
+			// pass the wakeup to the next waiting Pop
+			if atomic.LoadInt64(&r.len) > 0 {
+				r.wakeup()
+			}
+			return w, nil
+		}
+
+		select {
+		case <-r.signal:
+		case <-ctx.Done():
+			return nil, errors.E(ctx.Err(), errors.NoFreeWorkers)
+		}
+	}
+}
+
+func (r *Ring) Remove(_ string) {}
+
+// Replace pushes the new worker, the previous worker (if it's still in the ring) is not in the ready state
+// and will be skipped on the Pop (see worker_watcher.Take)
+func (r *Ring) Replace(_ string, newWorker worker.BaseProcess) {
+	r.Push(newWorker)
+}
+
+// Len returns number of workers in the ring
+func (r *Ring) Len() uint64 {
+	l := atomic.LoadInt64(&r.len)
+	if l < 0 {
+		return 0
+	}
+	return uint64(l)
+}
+
+// Drain pops all the workers left in the ring without blocking
+func (r *Ring) Drain() []worker.BaseProcess {
+	workers := make([]worker.BaseProcess, 0, r.Len())
+	for {
+		w, ok := r.dequeue()
+		if !ok {
+			return workers
+		}
+		workers = append(workers, w)
+	}
+}
+
+// Extract rotates the ring once looking for the worker with the ID, the other workers are requeued at once in the
+// same order. Serialized with the full ring Push (same as the evict).
+func (r *Ring) Extract(id string) worker.BaseProcess {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found worker.BaseProcess
+	for i := atomic.LoadInt64(&r.len); i > 0; i-- {
+		w, ok := r.dequeue()
+		if !ok {
+			// drained by the concurrent Pop
+			return found
+		}
+
+		if found == nil && w.ID() == id {
+			found = w
+			continue
+		}
+		r.requeue(w)
+	}
+
+	return found
+}
+
+func (r *Ring) Destroy() {
+	atomic.StoreUint64(&r.destroy, 1)
+	// wakeup waiting Pop
+	r.wakeup()
+}
+
+func (r *Ring) wakeup() {
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Ring) enqueue(w worker.BaseProcess) bool {
+	pos := atomic.LoadUint64(&r.enq)
+	for {
+		c := &r.cells[pos&r.mask]
+		seq := atomic.LoadUint64(&c.seq)
+		dif := int64(seq) - int64(pos)
+		switch {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&r.enq, pos, pos+1) {
+				c.w = w
+				atomic.AddInt64(&r.len, 1)
+				atomic.StoreUint64(&c.seq, pos+1)
+				return true
+			}
+			pos = atomic.LoadUint64(&r.enq)
+		case dif < 0:
+			// full
+			return false
+		default:
+			pos = atomic.LoadUint64(&r.enq)
+		}
+	}
+}
+
+func (r *Ring) dequeue() (worker.BaseProcess, bool) {
+	pos := atomic.LoadUint64(&r.deq)
+	for {
+		c := &r.cells[pos&r.mask]
+		seq := atomic.LoadUint64(&c.seq)
+		dif := int64(seq) - int64(pos+1)
+		switch {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&r.deq, pos, pos+1) {
+				w := c.w
+				c.w = nil
+				atomic.AddInt64(&r.len, -1)
+				atomic.StoreUint64(&c.seq, pos+r.mask+1)
+				return w, true
+			}
+			pos = atomic.LoadUint64(&r.deq)
+		case dif < 0:
+			// empty
+			return nil, false
+		default:
+			pos = atomic.LoadUint64(&r.deq)
+		}
+	}
+}
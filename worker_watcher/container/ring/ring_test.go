@@ -0,0 +1,294 @@
+package ring
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/worker"
+	"github.com/spiral/roadrunner/v2/worker_watcher/container/channel"
+	"github.com/spiral/roadrunner/v2/worker_watcher/internal/testworker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type container interface {
+	Push(worker.BaseProcess)
+	Pop(ctx context.Context) (worker.BaseProcess, error)
+}
+
+func testWorkers(num int) []worker.BaseProcess {
+	workers := make([]worker.BaseProcess, 0, num)
+	for i := 0; i < num; i++ {
+		workers = append(workers, testworker.New())
+	}
+	return workers
+}
+
+func TestRing_PushPop(t *testing.T) {
+	r := NewRing(3)
+	// rounded to the power of two
+	assert.Len(t, r.cells, 4)
+
+	workers := testWorkers(4)
+	for i := 0; i < len(workers); i++ {
+		r.Push(workers[i])
+	}
+	assert.Equal(t, uint64(4), r.Len())
+
+	// FIFO
+	for i := 0; i < len(workers); i++ {
+		w, err := r.Pop(context.Background())
+		require.NoError(t, err)
+		assert.Same(t, workers[i], w)
+	}
+	assert.Equal(t, uint64(0), r.Len())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err := r.Pop(ctx)
+	assert.True(t, errors.Is(errors.NoFreeWorkers, err))
+}
+
+func TestRing_PushFull(t *testing.T) {
+	r := NewRing(2)
+	workers := testWorkers(3)
+	r.Push(workers[0])
+	r.Push(workers[1])
+
+	// the first worker in the bad state is replaced with the new one
+	workers[0].State().Set(worker.StateInvalid)
+	r.Push(workers[2])
+	assert.Equal(t, uint64(2), r.Len())
+	assert.True(t, workers[0].(*testworker.Worker).Killed())
+
+	w, err := r.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, workers[1], w)
+	w, err = r.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, workers[2], w)
+}
+
+func TestRing_PushFullGood(t *testing.T) {
+	r := NewRing(2)
+	workers := testWorkers(3)
+	r.Push(workers[0])
+	r.Push(workers[1])
+
+	// the ring is full of the good workers, the new worker is dropped
+	r.Push(workers[2])
+	assert.Equal(t, uint64(2), r.Len())
+	for i := 0; i < len(workers); i++ {
+		assert.False(t, workers[i].(*testworker.Worker).Killed())
+	}
+
+	w, err := r.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, workers[0], w)
+	w, err = r.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, workers[1], w)
+}
+
+func TestRing_Destroy(t *testing.T) {
+	r := NewRing(2)
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := r.Pop(context.Background())
+			assert.True(t, errors.Is(errors.WatcherStopped, err))
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	// all the waiting Pop calls should be released
+	r.Destroy()
+	wg.Wait()
+}
+
+func TestRing_Drain(t *testing.T) {
+	r := NewRing(4)
+	workers := testWorkers(3)
+	for i := 0; i < len(workers); i++ {
+		r.Push(workers[i])
+	}
+
+	// drained after the Destroy, Pop is stopped
+	r.Destroy()
+	assert.Equal(t, workers, r.Drain())
+	assert.Equal(t, uint64(0), r.Len())
+	assert.Len(t, r.Drain(), 0)
+}
+
+func TestRing_Extract(t *testing.T) {
+	containers := map[string]interface {
+		container
+		Extract(id string) worker.BaseProcess
+		Drain() []worker.BaseProcess
+	}{
+		"ring":    NewRing(4),
+		"channel": channel.NewVector(4),
+	}
+
+	for name, c := range containers {
+		t.Run(name, func(t *testing.T) {
+			workers := testWorkers(3)
+			for i := 0; i < len(workers); i++ {
+				c.Push(workers[i])
+			}
+
+			assert.Equal(t, workers[1], c.Extract(workers[1].ID()))
+			assert.Nil(t, c.Extract(workers[1].ID()))
+			// the other workers are kept in order
+			assert.Equal(t, []worker.BaseProcess{workers[0], workers[2]}, c.Drain())
+			assert.Nil(t, c.Extract(workers[0].ID()))
+		})
+	}
+}
+
+func TestRing_Concurrent(t *testing.T) {
+	const goroutines = 16
+	const cycles = 10000
+
+	workers := testWorkers(8)
+	r := NewRing(uint64(len(workers)))
+	for i := 0; i < len(workers); i++ {
+		r.Push(workers[i])
+	}
+
+	ops := uint64(0)
+	wg := sync.WaitGroup{}
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < cycles; j++ {
+				w, err := r.Pop(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				atomic.AddUint64(&ops, 1)
+				r.Push(w)
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, uint64(goroutines*cycles), ops)
+	assert.Equal(t, uint64(len(workers)), r.Len())
+
+	// no duplicates and no lost workers
+	seen := make(map[worker.BaseProcess]struct{}, len(workers))
+	for i := 0; i < len(workers); i++ {
+		w, err := r.Pop(context.Background())
+		require.NoError(t, err)
+		seen[w] = struct{}{}
+	}
+	assert.Len(t, seen, len(workers))
+}
+
+func TestRing_ConcurrentPushFull(t *testing.T) {
+	const goroutines = 8
+	const cycles = 5000
+
+	// good workers are cycling, the bad ones are pushed concurrently and fill the rest of the ring
+	good := testWorkers(6)
+	bad := testWorkers(64)
+	for i := 0; i < len(bad); i++ {
+		bad[i].State().Set(worker.StateInvalid)
+	}
+
+	r := NewRing(8)
+	for i := 0; i < len(good); i++ {
+		r.Push(good[i])
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(goroutines + 1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < cycles; i++ {
+			r.Push(bad[i%len(bad)])
+		}
+	}()
+
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < cycles; j++ {
+				w, err := r.Pop(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// the bad workers are thrown away (see worker_watcher.Take)
+				if w.State().Value() == worker.StateReady {
+					r.Push(w)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// no lost and no killed good workers
+	seen := make(map[worker.BaseProcess]struct{}, len(good))
+	for r.Len() > 0 {
+		w, err := r.Pop(context.Background())
+		require.NoError(t, err)
+		if w.State().Value() == worker.StateReady {
+			seen[w] = struct{}{}
+		}
+	}
+	assert.Len(t, seen, len(good))
+	for i := 0; i < len(good); i++ {
+		assert.False(t, good[i].(*testworker.Worker).Killed())
+	}
+}
+
+func benchmarkContainer(b *testing.B, c container, workers []worker.BaseProcess, goroutines int) {
+	for i := 0; i < len(workers); i++ {
+		c.Push(workers[i])
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	wg := sync.WaitGroup{}
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < b.N/goroutines+1; j++ {
+				w, err := c.Pop(context.Background())
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				c.Push(w)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkContainers(b *testing.B) {
+	workers := testWorkers(16)
+	for _, goroutines := range []int{1, 4, 16, 64} {
+		b.Run("channel/"+strconv.Itoa(goroutines), func(b *testing.B) {
+			benchmarkContainer(b, channel.NewVector(uint64(len(workers))), workers, goroutines)
+		})
+		b.Run("ring/"+strconv.Itoa(goroutines), func(b *testing.B) {
+			benchmarkContainer(b, NewRing(uint64(len(workers))), workers, goroutines)
+		})
+	}
+}
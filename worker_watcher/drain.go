@@ -0,0 +1,161 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// draining worker states
+const (
+	// drainPending - the worker is recycled once idle
+	drainPending uint32 = iota
+	// drainRecycling - the warm replacement is in progress
+	drainRecycling
+)
+
+// Drain marks the worker as draining: it's no longer taken for the new requests and is recycled (warm replacement)
+// once idle. Worker in the middle of the request finishes it first. Idle worker is recycled immediately, if the
+// replacement can't be allocated, the worker keeps serving and the error is returned.
+func (ww *workerWatcher) Drain(pid int64) error {
+	const op = errors.Op("worker_watcher_drain")
+	w := ww.find(pid)
+	if w == nil {
+		return errors.E(op, errors.Errorf("no such worker: %d", pid))
+	}
+
+	state := drainPending
+	st, loaded := ww.draining.LoadOrStore(w, &state)
+	if loaded {
+		// already draining
+		return nil
+	}
+
+	// busy worker is recycled on Release
+	if w.State().Value() == worker.StateWorking {
+		return nil
+	}
+
+	if !atomic.CompareAndSwapUint32(st.(*uint32), drainPending, drainRecycling) {
+		return nil
+	}
+
+	err := ww.Replace(w)
+	ww.draining.Delete(w)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// skipDraining recycles the draining worker taken from the container, returns false if the worker is not draining
+func (ww *workerWatcher) skipDraining(ctx context.Context, w worker.BaseProcess) bool {
+	st, ok := ww.draining.Load(w)
+	if !ok {
+		return false
+	}
+
+	if atomic.CompareAndSwapUint32(st.(*uint32), drainPending, drainRecycling) {
+		go ww.recycleDraining(w)
+		return true
+	}
+
+	// replacement is in progress, leave the worker in the container until it's invalidated
+	ww.container.Push(w)
+	select {
+	case <-ctx.Done():
+	case <-time.After(lenientTakeBackoff):
+	}
+
+	return true
+}
+
+// releaseDraining recycles the draining worker after the request, returns false if the worker is not draining
+func (ww *workerWatcher) releaseDraining(w worker.BaseProcess) bool {
+	st, ok := ww.draining.Load(w)
+	if !ok {
+		return false
+	}
+
+	if !atomic.CompareAndSwapUint32(st.(*uint32), drainPending, drainRecycling) {
+		return false
+	}
+
+	go ww.recycleDraining(w)
+	return true
+}
+
+// recycleDraining replaces the draining worker taken out of the container, the worker is pushed back to keep serving
+// if the replacement can't be allocated
+func (ww *workerWatcher) recycleDraining(w worker.BaseProcess) {
+	const op = errors.Op("worker_watcher_recycle_draining")
+	err := ww.Replace(w)
+	ww.draining.Delete(w)
+	if err != nil {
+		ww.events.Push(events.WorkerEvent{
+			Event:   events.EventWorkerError,
+			Worker:  w,
+			Payload: errors.E(op, err),
+			Labels:  w.Labels(),
+		})
+		ww.container.Push(w)
+		ww.checkExhausted()
+	}
+}
+
+// find returns the worker with the pid, nil if there is no such worker
+func (ww *workerWatcher) find(pid int64) worker.BaseProcess {
+	ww.RLock()
+	defer ww.RUnlock()
+
+	for i := 0; i < len(ww.workers); i++ {
+		if ww.workers[i].Pid() == pid {
+			return ww.workers[i]
+		}
+	}
+
+	return nil
+}
+
+// TakeWorker takes the free worker with the pid (e.g. for the targeted health check) out of the container, the other
+// free workers are left in place. Busy worker is waited for during the ctx.
+func (ww *workerWatcher) TakeWorker(ctx context.Context, pid int64) (worker.BaseProcess, error) {
+	const op = errors.Op("worker_watcher_take_worker")
+	for {
+		target := ww.find(pid)
+		if target == nil {
+			return nil, errors.E(op, errors.Errorf("no such worker: %d", pid))
+		}
+
+		select {
+		case <-ww.stopCh:
+			return nil, errors.E(op, errors.WatcherStopped)
+		default:
+		}
+
+		// the target might be in the middle of the request
+		if w := ww.container.Extract(target.ID()); w != nil {
+			switch {
+			case w.State().Value() != worker.StateReady:
+				// TTL-ed or invalidated, the Take handles it
+				ww.container.Push(w)
+			case ww.skipDraining(ctx, w):
+			default:
+				ww.touch()
+				ww.checkExhausted()
+				return w, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.E(op, errors.TimeOut, errors.Errorf("worker %d is busy: %v", pid, ctx.Err()))
+		case <-time.After(lenientTakeBackoff):
+		}
+	}
+}
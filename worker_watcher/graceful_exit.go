@@ -0,0 +1,36 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// WithGracefulExitCodes sets the exit codes of the workers exiting by design (e.g. "recycle me"), such exits are
+// recycled as the clean ones: no EventWorkerError, not counted by the quarantine, EventWorkerDestruct is pushed
+// before the replacement
+func WithGracefulExitCodes(codes []int) Options {
+	return func(ww *workerWatcher) {
+		if len(codes) == 0 {
+			return
+		}
+
+		ww.gracefulExitCodes = make(map[int]struct{}, len(codes))
+		for i := 0; i < len(codes); i++ {
+			ww.gracefulExitCodes[codes[i]] = struct{}{}
+		}
+	}
+}
+
+// gracefulExit reports whether the Wait error is the exit with one of the graceful exit codes
+func (ww *workerWatcher) gracefulExit(err error) bool {
+	if err == nil || ww.gracefulExitCodes == nil {
+		return false
+	}
+
+	code, ok := worker.ExitCode(err)
+	if !ok {
+		return false
+	}
+
+	_, ok = ww.gracefulExitCodes[code]
+	return ok
+}
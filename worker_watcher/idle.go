@@ -0,0 +1,52 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/roadrunner/v2/events"
+)
+
+// WithIdleTimeout enables the idle detection: EventPoolIdle is pushed when no worker has been taken during the
+// timeout, once per idle period. 0 - disabled (default).
+func WithIdleTimeout(timeout time.Duration) Options {
+	return func(ww *workerWatcher) {
+		ww.idleTimeout = timeout
+	}
+}
+
+// touch records the Take time for the idle detection
+func (ww *workerWatcher) touch() {
+	if ww.idleTimeout == 0 {
+		return
+	}
+
+	atomic.StoreInt64(&ww.lastTake, ww.clock.Now().UnixNano())
+	atomic.StoreUint32(&ww.idleNotified, 0)
+}
+
+// watchIdle pushes the EventPoolIdle after the idle timeout since the last Take, stops on Destroy
+func (ww *workerWatcher) watchIdle() {
+	atomic.StoreInt64(&ww.lastTake, ww.clock.Now().UnixNano())
+	tt := ww.clock.NewTimer(ww.idleTimeout)
+	defer tt.Stop()
+
+	for {
+		select {
+		case <-ww.stopCh:
+			return
+		case <-tt.C():
+			idle := ww.clock.Now().Sub(time.Unix(0, atomic.LoadInt64(&ww.lastTake)))
+			if idle < ww.idleTimeout {
+				// taken meanwhile, wait for the rest of the timeout
+				tt.Reset(ww.idleTimeout - idle)
+				continue
+			}
+
+			if atomic.CompareAndSwapUint32(&ww.idleNotified, 0, 1) {
+				ww.events.Push(events.PoolEvent{Event: events.EventPoolIdle, Payload: idle})
+			}
+			tt.Reset(ww.idleTimeout)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package testworker contains the in-memory worker shared by the worker watcher and container tests.
+package testworker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spiral/goridge/v3/pkg/relay"
+	"github.com/spiral/roadrunner/v2/payload"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+var pids int64 = 1000
+
+// Worker is the in-memory worker.SyncWorker, the process "exits" on Kill or Stop
+type Worker struct {
+	pid     int64
+	id      string
+	created time.Time
+	state   *worker.StateImpl
+	exitCh  chan struct{}
+	once    sync.Once
+	killed  int64
+	mu      sync.Mutex
+	locals  map[string]string
+	exitErr error
+	// Kill doesn't exit the process
+	unkillable int32
+	// returned by the Kill
+	killErr error
+}
+
+// New creates the ready worker with the unique pid
+func New() *Worker {
+	return &Worker{
+		pid:     atomic.AddInt64(&pids, 1),
+		id:      uuid.NewString(),
+		created: time.Now(),
+		state:   worker.NewWorkerState(worker.StateReady),
+		exitCh:  make(chan struct{}),
+	}
+}
+
+func (w *Worker) String() string            { return "test worker" }
+func (w *Worker) Pid() int64                { return w.pid }
+func (w *Worker) ID() string                { return w.id }
+func (w *Worker) Created() time.Time        { return w.created }
+func (w *Worker) State() worker.State       { return w.state }
+func (w *Worker) Start() error              { return nil }
+func (w *Worker) Relay() relay.Relay        { return nil }
+func (w *Worker) AttachRelay(relay.Relay)   {}
+func (w *Worker) BytesSent() uint64         { return 0 }
+func (w *Worker) BytesReceived() uint64     { return 0 }
+func (w *Worker) Killed() bool              { return atomic.LoadInt64(&w.killed) > 0 }
+func (w *Worker) Wait() error               { <-w.exitCh; return w.exitErr }
+func (w *Worker) exit()                     { w.once.Do(func() { close(w.exitCh) }) }
+func (w *Worker) Stop() error               { w.state.Set(worker.StateStopped); w.exit(); return nil }
+func (w *Worker) ClearLocals()              { w.mu.Lock(); w.locals = nil; w.mu.Unlock() }
+func (w *Worker) Labels() map[string]string { return nil }
+func (w *Worker) CmdLine() []string         { return nil }
+func (w *Worker) Transport() string         { return "" }
+func (w *Worker) Env() []string             { return nil }
+func (w *Worker) Stderr() []byte            { return nil }
+
+func (w *Worker) SetAttachment(_, _ interface{})               {}
+func (w *Worker) Attachment(_ interface{}) (interface{}, bool) { return nil, false }
+func (w *Worker) LastRelayActivity() time.Time                 { return time.Time{} }
+func (w *Worker) InFlight() int                                { return 0 }
+
+func (w *Worker) Kill() error {
+	atomic.AddInt64(&w.killed, 1)
+	if w.state.Value() != worker.StateDestroyed {
+		w.state.Set(worker.StateStopped)
+	}
+	if atomic.LoadInt32(&w.unkillable) == 0 {
+		w.exit()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.killErr
+}
+
+// SetPid sets the pid (e.g. reused by the OS), should be called before the worker is watched
+func (w *Worker) SetPid(pid int64) {
+	w.pid = pid
+}
+
+// SetKillErr sets the error returned by the Kill, the process still exits
+func (w *Worker) SetKillErr(err error) {
+	w.mu.Lock()
+	w.killErr = err
+	w.mu.Unlock()
+}
+
+// Unkillable makes the Kill not exit the process (e.g. stuck in the uninterruptible sleep)
+func (w *Worker) Unkillable() {
+	atomic.StoreInt32(&w.unkillable, 1)
+}
+
+// Crash exits the process by itself (the state is not changed), Wait returns the err
+func (w *Worker) Crash(err error) {
+	w.once.Do(func() {
+		w.exitErr = err
+		close(w.exitCh)
+	})
+}
+
+func (w *Worker) SetLocal(key, value string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.locals == nil {
+		w.locals = make(map[string]string)
+	}
+	w.locals[key] = value
+}
+
+func (w *Worker) Locals() map[string]string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	cp := make(map[string]string, len(w.locals))
+	for k, v := range w.locals {
+		cp[k] = v
+	}
+	return cp
+}
+
+func (w *Worker) GetLocal(key string) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	v, ok := w.locals[key]
+	return v, ok
+}
+
+func (w *Worker) Exec(p *payload.Payload) (*payload.Payload, error) {
+	return p, nil
+}
+
+func (w *Worker) ExecWithTTL(_ context.Context, p *payload.Payload) (*payload.Payload, error) {
+	return p, nil
+}
+
+func (w *Worker) Introspect(_ context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{"pid": w.pid}, nil
+}
@@ -0,0 +1,205 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/utils"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// quarantine holds the replacements of the crash-looping workers. After the failures threshold is reached within
+// the window, exited workers are not reallocated during the cooldown. Then a single worker is probed, and the full
+// capacity is restored only if the probe is allocated.
+type quarantine struct {
+	mu sync.Mutex
+
+	threshold uint64
+	window    time.Duration
+	cooldown  time.Duration
+
+	// consecutive failures, the first one within the window
+	failures uint64
+	first    time.Time
+	// number of the held replacements
+	held    uint64
+	active  bool
+	timer   utils.Timer
+	stopped bool
+}
+
+// WithQuarantine holds the worker replacements for the cooldown after the failures consecutive worker failures
+// within the window. Failure is the unexpected worker exit with an error (not the worker killed or stopped by the pool).
+func WithQuarantine(failures uint64, window, cooldown time.Duration) Options {
+	return func(ww *workerWatcher) {
+		if failures == 0 {
+			return
+		}
+
+		ww.quarantine = &quarantine{
+			threshold: failures,
+			window:    window,
+			cooldown:  cooldown,
+		}
+	}
+}
+
+// failed reports whether the worker exit is the failure: the process exited with an error by itself,
+// not killed or stopped by the pool
+func failed(w worker.BaseProcess, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch w.State().Value() {
+	case worker.StateReady, worker.StateWorking, worker.StateErrored:
+		return true
+	default:
+		return false
+	}
+}
+
+// hold records the worker exit, returns true if the replacement is held by the quarantine. Slot of the held
+// replacement is released, so the Destroy doesn't wait for it.
+func (ww *workerWatcher) hold(fail bool) bool {
+	const op = errors.Op("worker_watcher_quarantine")
+	q := ww.quarantine
+	if q == nil {
+		return false
+	}
+
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return false
+	}
+
+	switch {
+	case fail:
+		now := ww.clock.Now()
+		if q.failures == 0 || now.Sub(q.first) > q.window {
+			q.failures = 0
+			q.first = now
+		}
+		q.failures++
+	case !q.active:
+		// clean exit, crash loop is over
+		q.failures = 0
+	}
+
+	entered := false
+	if !q.active && q.failures >= q.threshold {
+		q.active = true
+		q.timer = ww.clock.AfterFunc(q.cooldown, ww.probe)
+		entered = true
+	}
+
+	if !q.active {
+		q.mu.Unlock()
+		return false
+	}
+
+	q.held++
+	atomic.AddUint64(ww.numWorkers, ^uint64(0))
+	info := events.Quarantine{Failures: q.failures, Held: q.held, Until: ww.clock.Now().Add(q.cooldown)}
+	q.mu.Unlock()
+
+	if entered {
+		ww.events.Push(events.PoolEvent{
+			Event:   events.EventPoolQuarantined,
+			Payload: info,
+			Error:   errors.E(op, errors.Errorf("%d consecutive worker failures within %s, replacements are held for %s", info.Failures, q.window, q.cooldown)),
+		})
+	}
+
+	return true
+}
+
+// probe allocates a single worker after the cooldown, on success the held replacements are allocated,
+// otherwise the cooldown is restarted
+func (ww *workerWatcher) probe() {
+	const op = errors.Op("worker_watcher_quarantine_probe")
+	q := ww.quarantine
+
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+
+	atomic.AddUint64(ww.numWorkers, 1)
+	sw, err := ww.allocator()
+	if err != nil {
+		atomic.AddUint64(ww.numWorkers, ^uint64(0))
+		if errors.Is(errors.WatcherStopped, err) {
+			return
+		}
+
+		q.mu.Lock()
+		if q.stopped {
+			q.mu.Unlock()
+			return
+		}
+		q.timer = ww.clock.AfterFunc(q.cooldown, ww.probe)
+		info := events.Quarantine{Failures: q.failures, Held: q.held, Until: ww.clock.Now().Add(q.cooldown)}
+		q.mu.Unlock()
+
+		ww.events.Push(events.PoolEvent{
+			Event:   events.EventPoolQuarantined,
+			Payload: info,
+			Error:   errors.E(op, errors.Errorf("probe worker can't be allocated: %v", err)),
+		})
+		return
+	}
+
+	ww.addToWatch(sw)
+
+	ww.Lock()
+	ww.place(sw)
+	ww.Unlock()
+
+	ww.Release(sw)
+
+	q.mu.Lock()
+	// the probe took one of the held slots
+	held := q.held - 1
+	q.held = 0
+	q.active = false
+	// on probation, the next failure within the window quarantines the replacements again
+	q.failures = q.threshold - 1
+	q.first = ww.clock.Now()
+	q.mu.Unlock()
+
+	for i := uint64(0); i < held; i++ {
+		atomic.AddUint64(ww.numWorkers, 1)
+		err = ww.Allocate()
+		if errors.Is(errors.WatcherStopped, err) {
+			return
+		}
+		if err != nil {
+			ww.events.Push(events.PoolEvent{
+				Event: events.EventWorkerProcessExit,
+				Error: errors.E(op, err),
+			})
+		}
+	}
+}
+
+// stopQuarantine stops the pending probe on Destroy
+func (ww *workerWatcher) stopQuarantine() {
+	q := ww.quarantine
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stopped = true
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+}
@@ -0,0 +1,62 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"time"
+
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// WithReapTimeout makes the watcher wait for the killed worker process to be reaped (Wait returned), up to the
+// timeout, so the recycle storms don't accumulate the zombie processes. 0 - Kill doesn't wait (default).
+func WithReapTimeout(timeout time.Duration) Options {
+	return func(ww *workerWatcher) {
+		ww.reapTimeout = timeout
+	}
+}
+
+// track registers the worker to be awaited after the Kill, should be called before the worker is waited
+func (ww *workerWatcher) track(w worker.BaseProcess) {
+	if ww.reapTimeout == 0 {
+		return
+	}
+
+	ww.reaping.Store(w, make(chan struct{}))
+}
+
+// reaped marks the worker process as reaped
+func (ww *workerWatcher) reaped(w worker.BaseProcess) {
+	if ch, ok := ww.reaping.LoadAndDelete(w); ok {
+		close(ch.(chan struct{}))
+	}
+}
+
+// kill kills the worker and waits for the process to be reaped (see WithReapTimeout)
+func (ww *workerWatcher) kill(w worker.BaseProcess) {
+	_ = w.Kill()
+	ww.awaitReaped(w)
+}
+
+// awaitReaped waits for the workers processes to be reaped, the timeout is shared by all the workers
+func (ww *workerWatcher) awaitReaped(workers ...worker.BaseProcess) {
+	if ww.reapTimeout == 0 {
+		return
+	}
+
+	timer := ww.clock.NewTimer(ww.reapTimeout)
+	defer timer.Stop()
+
+	for i := 0; i < len(workers); i++ {
+		ch, ok := ww.reaping.Load(workers[i])
+		if !ok {
+			// already reaped or not watched
+			continue
+		}
+
+		select {
+		case <-ch.(chan struct{}):
+		case <-timer.C():
+			// unkillable process, don't block forever
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// ScaleDownPolicy is the way the excess workers are removed when the number of workers is decreased
+type ScaleDownPolicy uint8
+
+const (
+	// ScaleDownImmediate removes the free workers right away, the busy workers are waited for during the ctx
+	ScaleDownImmediate ScaleDownPolicy = iota
+	// ScaleDownGraceful removes the free workers right away w/o waiting, the busy workers are marked as retiring and
+	// removed on Release, after the current request is completed
+	ScaleDownGraceful
+)
+
+// ScaleResult is the outcome of the scale down
+type ScaleResult struct {
+	// Removed is the number of the workers removed during the call
+	Removed int
+	// Deferred is the number of the busy workers marked as retiring, removed once they become idle
+	Deferred int
+}
+
+// takenCtx is the done context of the non-blocking Take, the free worker is preferred over the ctx (see the
+// container Pop)
+var takenCtx = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// scaleDownGraceful removes the free workers and marks the busy ones as retiring, up to the excess number of workers.
+// Workers released meanwhile are not marked, the rest of the excess slots are released on the next worker exits.
+// Should be called under the scaleMu.
+func (ww *workerWatcher) scaleDownGraceful(num uint64) ScaleResult {
+	var res ScaleResult
+	for atomic.LoadUint64(ww.numWorkers) > num {
+		// free worker only, busy workers are not waited for
+		w, err := ww.Take(takenCtx)
+		if err != nil {
+			break
+		}
+
+		atomic.AddUint64(ww.numWorkers, ^uint64(0))
+		ww.retire(w)
+		res.Removed++
+	}
+
+	excess := int(atomic.LoadUint64(ww.numWorkers)) - int(num)
+	ww.RLock()
+	for i := 0; i < len(ww.workers) && res.Deferred < excess; i++ {
+		w := ww.workers[i]
+		if w.State().Value() != worker.StateWorking {
+			if _, ok := ww.retiring.Load(w); !ok {
+				continue
+			}
+		}
+		// already retiring workers are counted too
+		ww.retiring.Store(w, struct{}{})
+		res.Deferred++
+	}
+	ww.RUnlock()
+
+	return res
+}
+
+// releaseRetiring removes the retiring worker after the request, returns false if the worker is not retiring or the
+// pool was scaled up meanwhile (the worker keeps serving)
+func (ww *workerWatcher) releaseRetiring(w worker.BaseProcess) bool {
+	if _, ok := ww.retiring.LoadAndDelete(w); !ok {
+		return false
+	}
+
+	if !ww.shrink() {
+		return false
+	}
+
+	go ww.retire(w)
+	return true
+}
+
+// retire stops the scaled down worker, the slot is already released, so the worker is not reallocated after the exit
+func (ww *workerWatcher) retire(w worker.BaseProcess) {
+	// same as the replaced workers, should not be reallocated after the exit
+	ww.replaced.Store(w, struct{}{})
+	w.State().SetReason(worker.StateInvalid, "scale down")
+	err := w.Stop()
+	if err != nil {
+		ww.kill(w)
+	}
+}
@@ -0,0 +1,75 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// Slot returns the worker in the logical slot, nil if the slot is vacant. Slots are numbered from 0 up to
+// the number of workers, the replacement of the exited (or recycled) worker takes its vacated slot.
+func (ww *workerWatcher) Slot(slot int) worker.BaseProcess {
+	ww.RLock()
+	defer ww.RUnlock()
+
+	i := ww.slotIndex(slot)
+	if i < 0 {
+		return nil
+	}
+
+	return ww.workers[i]
+}
+
+// place adds the worker to the lowest vacated slot, the workers slice is kept ordered by slot.
+// Should be called under the lock.
+func (ww *workerWatcher) place(w worker.BaseProcess) {
+	// slots are sorted and unique, the first slot not equal to its index is after the lowest gap
+	i := 0
+	for ; i < len(ww.slots); i++ {
+		if ww.slots[i] != i {
+			break
+		}
+	}
+
+	ww.workers = append(ww.workers, nil)
+	copy(ww.workers[i+1:], ww.workers[i:])
+	ww.workers[i] = w
+
+	ww.slots = append(ww.slots, 0)
+	copy(ww.slots[i+1:], ww.slots[i:])
+	ww.slots[i] = i
+}
+
+// swap puts the replacement to the slot of the previous worker, the previous worker is no longer listed.
+// If the previous worker is not listed, the replacement takes the lowest vacated slot. Should be called under the lock.
+func (ww *workerWatcher) swap(prev, w worker.BaseProcess) {
+	id := prev.ID()
+	for i := 0; i < len(ww.workers); i++ {
+		if ww.workers[i].ID() == id {
+			ww.workers[i] = w
+			return
+		}
+	}
+
+	ww.place(w)
+}
+
+// vacate removes the worker at the index, its slot is reused by the next placed worker. Should be called under the lock.
+func (ww *workerWatcher) vacate(i int) {
+	ww.workers = append(ww.workers[:i], ww.workers[i+1:]...)
+	ww.slots = append(ww.slots[:i], ww.slots[i+1:]...)
+}
+
+// slotIndex returns the workers slice index of the slot, -1 if the slot is vacant. Should be called under the lock.
+func (ww *workerWatcher) slotIndex(slot int) int {
+	if slot < 0 {
+		return -1
+	}
+
+	// slots are sorted and slot >= index, so the slot can't be after its own number
+	for i := 0; i < len(ww.slots) && i <= slot; i++ {
+		if ww.slots[i] == slot {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,111 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/worker"
+)
+
+// WithSpawnRate limits the rate of the workers spawned by the watcher (token bucket): rate spawns per second with
+// up to the burst spawns at once (1 if 0), so the recycle storms and the crash loops don't spike the host load.
+// Allocations over the rate are delayed, not failed. 0 rate - unlimited (default).
+func WithSpawnRate(rate float64, burst int) Options {
+	return func(ww *workerWatcher) {
+		if rate <= 0 {
+			return
+		}
+
+		if burst < 1 {
+			burst = 1
+		}
+
+		ww.spawnLimiter = &spawnLimiter{
+			rate:   rate,
+			burst:  float64(burst),
+			tokens: float64(burst),
+		}
+	}
+}
+
+// spawnLimiter is the token bucket shared by all the watcher allocations
+type spawnLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	// time of the last reservation, zero - the bucket is full
+	last time.Time
+}
+
+// reserve takes the token and returns the delay until it's available. Tokens might go negative, so the
+// delayed spawns are served in the reservation order.
+func (sl *spawnLimiter) reserve(now time.Time) time.Duration {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if !sl.last.IsZero() {
+		sl.tokens += now.Sub(sl.last).Seconds() * sl.rate
+	}
+	if sl.tokens > sl.burst {
+		sl.tokens = sl.burst
+	}
+	sl.last = now
+
+	sl.tokens--
+	if sl.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-sl.tokens / sl.rate * float64(time.Second))
+}
+
+// limitSpawns wraps the allocator with the spawn rate limiter, the delayed allocation is canceled on Destroy
+func (ww *workerWatcher) limitSpawns(allocator worker.Allocator) worker.Allocator {
+	return func() (worker.SyncWorker, error) {
+		const op = errors.Op("worker_watcher_limit_spawns")
+		delay := ww.spawnLimiter.reserve(ww.clock.Now())
+		if delay > 0 {
+			timer := ww.clock.NewTimer(delay)
+			select {
+			case <-timer.C():
+			case <-ww.stopCh:
+				timer.Stop()
+				return nil, errors.E(op, errors.WatcherStopped, errors.Str("watcher is stopped"))
+			}
+		}
+
+		return allocator()
+	}
+}
+
+// WithMaxConcurrentSpawns limits the number of the workers spawned by the watcher at the same time, so the mass exits
+// are replaced in waves instead of forking all the processes at once. Allocations over the limit wait for the running
+// ones (successful or failed). 0 - unlimited (default).
+func WithMaxConcurrentSpawns(num int) Options {
+	return func(ww *workerWatcher) {
+		if num <= 0 {
+			return
+		}
+
+		ww.spawnSem = make(chan struct{}, num)
+	}
+}
+
+// limitConcurrentSpawns wraps the allocator with the spawn semaphore, the waiting allocation is canceled on Destroy
+func (ww *workerWatcher) limitConcurrentSpawns(allocator worker.Allocator) worker.Allocator {
+	return func() (worker.SyncWorker, error) {
+		const op = errors.Op("worker_watcher_limit_concurrent_spawns")
+		select {
+		case ww.spawnSem <- struct{}{}:
+		case <-ww.stopCh:
+			return nil, errors.E(op, errors.WatcherStopped, errors.Str("watcher is stopped"))
+		}
+		defer func() {
+			<-ww.spawnSem
+		}()
+
+		return allocator()
+	}
+}
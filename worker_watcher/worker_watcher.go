@@ -11,6 +11,7 @@ import (
 	"github.com/spiral/roadrunner/v2/utils"
 	"github.com/spiral/roadrunner/v2/worker"
 	"github.com/spiral/roadrunner/v2/worker_watcher/container/channel"
+	"go.uber.org/multierr"
 )
 
 // Vector interface represents vector container
@@ -19,15 +20,30 @@ type Vector interface {
 	Push(worker.BaseProcess)
 	// Pop used to get worker from the vector
 	Pop(ctx context.Context) (worker.BaseProcess, error)
-	// Remove worker with provided pid
-	Remove(pid int64)
+	// Remove worker with provided ID (see worker.BaseProcess.ID)
+	Remove(id string)
+	// Replace replaces the worker with provided ID with the new worker
+	Replace(prevID string, newWorker worker.BaseProcess)
 	// Destroy used to stop releasing the workers
 	Destroy()
-
-	// TODO Add Replace method, and remove `Remove` method. Replace will do removal and allocation
-	// Replace(prevPid int64, newWorker worker.BaseProcess)
+	// Drain pops all the workers left in the vector without blocking (even after the Destroy)
+	Drain() []worker.BaseProcess
+	// Extract pops the worker with provided ID without blocking, the other workers are kept in order.
+	// Returns nil if the worker is not in the vector.
+	Extract(id string) worker.BaseProcess
+	// Len returns number of workers in the vector
+	Len() uint64
 }
 
+// ContainerFactory creates the ready workers container with the provided capacity
+type ContainerFactory func(capacity uint64) Vector
+
+// defaultDestroyProgressInterval is the default interval of the EventDestroyProgress events on Destroy
+const defaultDestroyProgressInterval = time.Second
+
+// lenientTakeBackoff is the pause after pushing back the not ready worker in the lenient Take mode
+const lenientTakeBackoff = time.Millisecond * 10
+
 type workerWatcher struct {
 	sync.RWMutex
 	container Vector
@@ -35,26 +51,186 @@ type workerWatcher struct {
 	numWorkers *uint64
 
 	workers []worker.BaseProcess
+	// logical slots of the workers (sorted, same order as the workers), see Slot
+	slots []int
+	// upper limit for the on-demand allocated workers (atomic, raised by the SetNumWorkers)
+	maxWorkers uint64
+	// number of workers kept after the exits (atomic, set by the SetNumWorkers), on-demand workers are not reallocated
+	targetWorkers uint64
+	// ready workers container capacity, 0 - max workers
+	capacity uint64
+	// kill not ready workers on Take (default), or push them back
+	strictTake bool
+	// creates the container, channel.Vec by default
+	containerFactory ContainerFactory
+	// serializes the SetNumWorkers calls
+	scaleMu sync.Mutex
+	// interval of the EventDestroyProgress events
+	destroyProgressInterval time.Duration
+
+	// 1 - no ready workers in the container (EventPoolExhausted emitted), atomic
+	exhausted uint32
+
+	// idle detection (see WithIdleTimeout), 0 - disabled
+	idleTimeout time.Duration
+	// last Take time (unix nano) and the EventPoolIdle pushed flag, atomic
+	lastTake     int64
+	idleNotified uint32
+	// closed on Destroy, stops the background goroutines
+	stopCh   chan struct{}
+	stopOnce sync.Once
+
+	// holds the replacements of the crash-looping workers, nil - disabled
+	quarantine *quarantine
+
+	// draining workers (see Drain), worker -> *uint32 drain state
+	draining sync.Map
+	// busy workers removed on Release (graceful scale down), worker -> struct{}
+	retiring sync.Map
+
+	// bounded wait for the killed workers to be reaped (see WithReapTimeout), 0 - disabled
+	reapTimeout time.Duration
+	// watched workers not reaped yet, worker -> chan struct{} closed after the Wait
+	reaping sync.Map
+
+	// spawn rate limiter shared by all the allocations (see WithSpawnRate), nil - unlimited
+	spawnLimiter *spawnLimiter
+	// slots of the concurrent spawns (see WithMaxConcurrentSpawns), nil - unlimited
+	spawnSem chan struct{}
+
+	// running wait goroutines (atomic), see WatchGoroutines
+	watching int64
+
+	// workers replaced by the warm replacement, should not be reallocated after the exit
+	replaced sync.Map
+
+	// time source of the watcher timers (see WithClock)
+	clock utils.Clock
+	// decides whether the failed spawn is retried (see WithSpawnClassifier), nil - all the failures are retried
+	spawnClassifier SpawnClassifier
+	// exit codes of the intentional worker exits (see WithGracefulExitCodes), nil - none
+	gracefulExitCodes map[int]struct{}
 
 	allocator       worker.Allocator
 	allocateTimeout time.Duration
 	events          events.Handler
 }
 
+// Options is the watcher options
+type Options func(ww *workerWatcher)
+
+// WithMaxWorkers sets the upper limit for the workers allocated on demand (see TakeOrAllocate)
+func WithMaxWorkers(maxWorkers uint64) Options {
+	return func(ww *workerWatcher) {
+		ww.maxWorkers = maxWorkers
+	}
+}
+
+// WithContainerCapacity sets the ready workers container capacity (max workers by default)
+func WithContainerCapacity(capacity uint64) Options {
+	return func(ww *workerWatcher) {
+		ww.capacity = capacity
+	}
+}
+
+// WithContainer sets the ready workers container factory (channel.Vec by default), e.g. ring.NewRing
+func WithContainer(factory ContainerFactory) Options {
+	return func(ww *workerWatcher) {
+		ww.containerFactory = factory
+	}
+}
+
+// WithDestroyProgressInterval sets the interval of the EventDestroyProgress events (1s by default)
+func WithDestroyProgressInterval(interval time.Duration) Options {
+	return func(ww *workerWatcher) {
+		ww.destroyProgressInterval = interval
+	}
+}
+
+// WithClock sets the time source of the allocation retries, the destroy poll, the spawn rate limiter, the idle
+// detection, the reap wait and the quarantine cooldown (system clock by default), the tests use the fake clock to
+// trigger them deterministically
+func WithClock(clock utils.Clock) Options {
+	return func(ww *workerWatcher) {
+		ww.clock = clock
+	}
+}
+
+// SpawnClassifier decides whether the failed spawn is retried during the allocate timeout (transient failure, e.g.
+// resource temporarily unavailable) or the error is returned immediately (permanent failure, e.g. binary not found)
+type SpawnClassifier func(err error) bool
+
+// RetryAllSpawns is the default SpawnClassifier, all the spawn failures are retried
+func RetryAllSpawns(_ error) bool {
+	return true
+}
+
+// WithSpawnClassifier sets the classifier of the spawn failures (RetryAllSpawns by default), so the permanent
+// misconfigurations fail fast instead of after the allocate timeout of the retries
+func WithSpawnClassifier(classifier SpawnClassifier) Options {
+	return func(ww *workerWatcher) {
+		ww.spawnClassifier = classifier
+	}
+}
+
+// WithStrictTake sets the Take behavior for the not ready workers. Strict (default) kills them,
+// lenient pushes them back to the container (diagnostic mode).
+func WithStrictTake(strict bool) Options {
+	return func(ww *workerWatcher) {
+		ww.strictTake = strict
+	}
+}
+
 // NewSyncWorkerWatcher is a constructor for the Watcher
-func NewSyncWorkerWatcher(allocator worker.Allocator, numWorkers uint64, events events.Handler, allocateTimeout time.Duration) *workerWatcher {
+func NewSyncWorkerWatcher(allocator worker.Allocator, numWorkers uint64, events events.Handler, allocateTimeout time.Duration, options ...Options) *workerWatcher {
 	ww := &workerWatcher{
-		container: channel.NewVector(numWorkers),
-
 		// pass a ptr to the number of workers to avoid blocking in the TTL loop
 		numWorkers:      utils.Uint64(numWorkers),
+		maxWorkers:      numWorkers,
+		targetWorkers:   numWorkers,
+		strictTake:      true,
 		allocateTimeout: allocateTimeout,
 		workers:         make([]worker.BaseProcess, 0, numWorkers),
+		slots:           make([]int, 0, numWorkers),
+
+		destroyProgressInterval: defaultDestroyProgressInterval,
+		stopCh:                  make(chan struct{}),
+		clock:                   utils.SystemClock(),
 
 		allocator: allocator,
 		events:    events,
 	}
 
+	for i := 0; i < len(options); i++ {
+		options[i](ww)
+	}
+
+	if ww.maxWorkers < numWorkers {
+		ww.maxWorkers = numWorkers
+	}
+
+	// the rate delayed allocations don't hold the spawn slots
+	if ww.spawnSem != nil {
+		ww.allocator = ww.limitConcurrentSpawns(ww.allocator)
+	}
+	if ww.spawnLimiter != nil {
+		ww.allocator = ww.limitSpawns(ww.allocator)
+	}
+
+	// container should be able to hold all the workers allocated on demand
+	if ww.capacity < ww.maxWorkers {
+		ww.capacity = ww.maxWorkers
+	}
+	if ww.containerFactory == nil {
+		ww.container = channel.NewVector(ww.capacity)
+	} else {
+		ww.container = ww.containerFactory(ww.capacity)
+	}
+
+	if ww.idleTimeout > 0 {
+		go ww.watchIdle()
+	}
+
 	return ww
 }
 
@@ -62,17 +238,35 @@ func (ww *workerWatcher) Watch(workers []worker.BaseProcess) error {
 	for i := 0; i < len(workers); i++ {
 		ww.container.Push(workers[i])
 		// add worker to watch slice
-		ww.workers = append(ww.workers, workers[i])
+		ww.Lock()
+		ww.place(workers[i])
+		ww.Unlock()
 
-		go func(swc worker.BaseProcess) {
-			ww.wait(swc)
-		}(workers[i])
+		ww.addToWatch(workers[i])
 	}
 	return nil
 }
 
 // Take is not a thread safe operation
 func (ww *workerWatcher) Take(ctx context.Context) (worker.BaseProcess, error) {
+	for {
+		w, err := ww.take(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// draining workers are not taken for the new requests
+		if ww.skipDraining(ctx, w) {
+			continue
+		}
+
+		ww.touch()
+		ww.checkExhausted()
+		return w, nil
+	}
+}
+
+func (ww *workerWatcher) take(ctx context.Context) (worker.BaseProcess, error) {
 	const op = errors.Op("worker_watcher_get_free_worker")
 
 	// thread safe operation
@@ -90,9 +284,13 @@ func (ww *workerWatcher) Take(ctx context.Context) (worker.BaseProcess, error) {
 		return w, nil
 	}
 
+	if !ww.strictTake {
+		return ww.takeLenient(ctx, w)
+	}
+
 	// =========================================================
 	// SLOW PATH
-	_ = w.Kill()
+	ww.kill(w)
 	// no free workers in the container or worker not in the ReadyState (TTL-ed)
 	// try to continuously get free one
 	for {
@@ -127,69 +325,126 @@ func (ww *workerWatcher) Take(ctx context.Context) (worker.BaseProcess, error) {
 			worker.StateStopping:
 			// worker doing no work because it in the container
 			// so we can safely kill it (inconsistent state)
-			_ = w.Kill()
+			ww.kill(w)
 			// try to get new worker
 			continue
 		}
 	}
 }
 
-func (ww *workerWatcher) Allocate() error {
-	const op = errors.Op("worker_watcher_allocate_new")
+// takeLenient is the diagnostic alternative of the Take slow path. Workers in the inconsistent state are not killed,
+// but pushed back to the container and reported via the EventWorkerInconsistentState event.
+// Workers with the exited process (stopped, destroyed, errored) are dropped from the container.
+func (ww *workerWatcher) takeLenient(ctx context.Context, w worker.BaseProcess) (worker.BaseProcess, error) {
+	const op = errors.Op("worker_watcher_get_free_worker_lenient")
+	var err error
+	for {
+		switch w.State().Value() {
+		case worker.StateReady:
+			return w, nil
+		case worker.StateStopped, worker.StateDestroyed, worker.StateErrored:
+			// process is gone, nothing to inspect
+		default:
+			ww.events.Push(events.WorkerEvent{
+				Event:   events.EventWorkerInconsistentState,
+				Worker:  w,
+				Payload: errors.E(op, errors.Errorf("worker is not ready in the container: %s", w.State().String())),
+				Labels:  w.Labels(),
+			})
+			ww.container.Push(w)
+
+			// do not spin on the same worker
+			select {
+			case <-ctx.Done():
+				return nil, errors.E(op, errors.NoFreeWorkers, ctx.Err())
+			case <-time.After(lenientTakeBackoff):
+			}
+		}
+
+		w, err = ww.container.Pop(ctx)
+		if err != nil {
+			if errors.Is(errors.WatcherStopped, err) {
+				return nil, errors.E(op, errors.WatcherStopped)
+			}
+			return nil, errors.E(op, err)
+		}
+	}
+}
+
+// TakeOrAllocate takes the first free worker. If there are no free workers in the container and the number of workers
+// is less than max workers, a new worker is allocated in parallel with waiting on the container. The new worker
+// is pushed to the container, so the caller gets whichever worker arrives first.
+func (ww *workerWatcher) TakeOrAllocate(ctx context.Context) (worker.BaseProcess, error) {
+	if ww.container.Len() == 0 && ww.reserveWorker() {
+		go ww.allocateOnDemand()
+	}
+
+	return ww.Take(ctx)
+}
+
+// reserveWorker reserves a slot for the new worker, returns false if the max workers limit reached
+func (ww *workerWatcher) reserveWorker() bool {
+	for {
+		num := atomic.LoadUint64(ww.numWorkers)
+		if num >= atomic.LoadUint64(&ww.maxWorkers) {
+			return false
+		}
+
+		// CAS here to not overshoot the max workers under the concurrent Takes
+		if atomic.CompareAndSwapUint64(ww.numWorkers, num, num+1) {
+			return true
+		}
+	}
+}
 
+// allocateOnDemand allocates a worker for the already reserved slot, w/o retries
+func (ww *workerWatcher) allocateOnDemand() {
+	const op = errors.Op("worker_watcher_allocate_on_demand")
 	sw, err := ww.allocator()
 	if err != nil {
-		// log incident
+		// release the reserved slot
+		atomic.AddUint64(ww.numWorkers, ^uint64(0))
+		if errors.Is(errors.WatcherStopped, err) {
+			return
+		}
 		ww.events.Push(
 			events.WorkerEvent{
 				Event:   events.EventWorkerError,
 				Payload: errors.E(op, errors.Errorf("can't allocate worker: %v", err)),
 			})
+		return
+	}
 
-		// if no timeout, return error immediately
-		if ww.allocateTimeout == 0 {
-			return errors.E(op, errors.WorkerAllocate, err)
-		}
+	ww.addToWatch(sw)
 
-		// every half of a second
-		allocateFreq := time.NewTicker(time.Millisecond * 500)
+	ww.Lock()
+	ww.place(sw)
+	ww.Unlock()
 
-		tt := time.After(ww.allocateTimeout)
-		for {
-			select {
-			case <-tt:
-				// reduce number of workers
-				atomic.AddUint64(ww.numWorkers, ^uint64(0))
-				allocateFreq.Stop()
-				// timeout exceed, worker can't be allocated
-				return errors.E(op, errors.WorkerAllocate, err)
-
-			case <-allocateFreq.C:
-				sw, err = ww.allocator()
-				if err != nil {
-					// log incident
-					ww.events.Push(
-						events.WorkerEvent{
-							Event:   events.EventWorkerError,
-							Payload: errors.E(op, errors.Errorf("can't allocate worker, retry attempt failed: %v", err)),
-						})
-					continue
-				}
+	ww.Release(sw)
+}
 
-				// reallocated
-				allocateFreq.Stop()
-				goto done
-			}
+func (ww *workerWatcher) Allocate() error {
+	const op = errors.Op("worker_watcher_allocate_new")
+
+	sw, err := ww.spawn()
+	if err != nil {
+		// timeout exceed, worker can't be allocated, reduce number of workers
+		if ww.allocateTimeout != 0 {
+			atomic.AddUint64(ww.numWorkers, ^uint64(0))
 		}
+		if errors.Is(errors.WatcherStopped, err) {
+			return errors.E(op, errors.WatcherStopped, err)
+		}
+		return errors.E(op, errors.WorkerAllocate, err)
 	}
 
-done:
 	// add worker to Wait
 	ww.addToWatch(sw)
 
 	ww.Lock()
-	// add new worker to the workers slice (to get information about workers in parallel)
-	ww.workers = append(ww.workers, sw)
+	// add new worker to the vacated slot (to get information about workers in parallel)
+	ww.place(sw)
 	ww.Unlock()
 
 	// push the worker to the container
@@ -197,18 +452,218 @@ done:
 	return nil
 }
 
+// Replace is the warm replacement for the planned recycles (max jobs, TTL). New worker is allocated and pushed
+// to the container before the outgoing worker is stopped, so the capacity doesn't dip.
+// If the new worker can't be allocated, the outgoing worker keeps serving.
+func (ww *workerWatcher) Replace(prev worker.BaseProcess) error {
+	const op = errors.Op("worker_watcher_replace")
+
+	// already being replaced
+	if _, loaded := ww.replaced.LoadOrStore(prev, struct{}{}); loaded {
+		return nil
+	}
+
+	sw, err := ww.spawn()
+	if err != nil {
+		ww.replaced.Delete(prev)
+		return errors.E(op, errors.WorkerAllocate, err)
+	}
+
+	ww.addToWatch(sw)
+
+	ww.Lock()
+	// the replacement takes the slot of the previous worker
+	ww.swap(prev, sw)
+	ww.Unlock()
+
+	// the previous worker should be invalidated before the push, so the full container could evict it
+	// instead of dropping the new worker
+	working := prev.State().Value() == worker.StateWorking
+	prev.State().Set(worker.StateInvalid)
+
+	ww.container.Replace(prev.ID(), sw)
+
+	// worker in the middle of the request will be killed on Release
+	if working {
+		return nil
+	}
+
+	err = prev.Stop()
+	if err != nil {
+		ww.kill(prev)
+	}
+
+	return nil
+}
+
+// SetNumWorkers scales the number of workers up (allocating new workers) or down (destroying free workers)
+func (ww *workerWatcher) SetNumWorkers(ctx context.Context, num uint64) error {
+	_, err := ww.SetNumWorkersPolicy(ctx, num, ScaleDownImmediate)
+	return err
+}
+
+// SetNumWorkersPolicy scales the number of workers up (allocating new workers) or down, the excess workers are
+// removed according to the policy. The result reports the number of the removed and the deferred (retiring) workers.
+func (ww *workerWatcher) SetNumWorkersPolicy(ctx context.Context, num uint64, policy ScaleDownPolicy) (ScaleResult, error) {
+	const op = errors.Op("worker_watcher_set_num_workers")
+	var res ScaleResult
+	if num == 0 {
+		return res, errors.E(op, errors.Str("number of workers should be greater than 0"))
+	}
+
+	if num > ww.capacity {
+		return res, errors.E(op, errors.Errorf("number of workers (%d) exceeds the container capacity (%d)", num, ww.capacity))
+	}
+
+	ww.scaleMu.Lock()
+	defer ww.scaleMu.Unlock()
+
+	atomic.StoreUint64(&ww.targetWorkers, num)
+	// on-demand allocations should be able to reach the new number of workers
+	if num > atomic.LoadUint64(&ww.maxWorkers) {
+		atomic.StoreUint64(&ww.maxWorkers, num)
+	}
+
+	for atomic.LoadUint64(ww.numWorkers) < num {
+		if ctx.Err() != nil {
+			return res, errors.E(op, errors.TimeOut, ctx.Err())
+		}
+
+		atomic.AddUint64(ww.numWorkers, 1)
+		err := ww.Allocate()
+		if err != nil {
+			return res, errors.E(op, err)
+		}
+	}
+
+	if policy == ScaleDownGraceful {
+		return ww.scaleDownGraceful(num), nil
+	}
+
+	for atomic.LoadUint64(ww.numWorkers) > num {
+		// only free workers are destroyed, busy workers are waited during the ctx
+		w, err := ww.Take(ctx)
+		if err != nil {
+			return res, errors.E(op, err)
+		}
+
+		atomic.AddUint64(ww.numWorkers, ^uint64(0))
+		ww.retire(w)
+		res.Removed++
+	}
+
+	return res, nil
+}
+
+// hasWorking reports whether any of the workers is in the middle of the request, should be called under the lock
+func (ww *workerWatcher) hasWorking() bool {
+	for i := 0; i < len(ww.workers); i++ {
+		if ww.workers[i].State().Value() == worker.StateWorking {
+			return true
+		}
+	}
+	return false
+}
+
+// destroyProgress counts the working and idle workers
+func (ww *workerWatcher) destroyProgress() events.DestroyProgress {
+	ww.RLock()
+	defer ww.RUnlock()
+
+	dp := events.DestroyProgress{Total: len(ww.workers)}
+	for i := 0; i < len(ww.workers); i++ {
+		if ww.workers[i].State().Value() == worker.StateWorking {
+			dp.Working++
+			continue
+		}
+		dp.Idle++
+	}
+
+	return dp
+}
+
+// spawn allocates new worker, retries every half of a second during the allocate timeout
+func (ww *workerWatcher) spawn() (worker.SyncWorker, error) {
+	const op = errors.Op("worker_watcher_spawn")
+
+	sw, err := ww.allocator()
+	if err == nil {
+		return sw, nil
+	}
+
+	// allocator is canceled (shutdown), exit quietly
+	if errors.Is(errors.WatcherStopped, err) {
+		return nil, err
+	}
+
+	// log incident
+	ww.events.Push(
+		events.WorkerEvent{
+			Event:   events.EventWorkerError,
+			Payload: errors.E(op, errors.Errorf("can't allocate worker: %v", err)),
+		})
+
+	// if no timeout or the failure is permanent, return error immediately
+	if ww.allocateTimeout == 0 || !ww.retrySpawn(err) {
+		return nil, err
+	}
+
+	// every half of a second
+	allocateFreq := ww.clock.NewTicker(time.Millisecond * 500)
+	defer allocateFreq.Stop()
+
+	tt := ww.clock.After(ww.allocateTimeout)
+	for {
+		select {
+		case <-tt:
+			// timeout exceed, worker can't be allocated
+			return nil, err
+
+		case <-allocateFreq.C():
+			sw, err = ww.allocator()
+			if err != nil {
+				if errors.Is(errors.WatcherStopped, err) {
+					return nil, err
+				}
+				// log incident
+				ww.events.Push(
+					events.WorkerEvent{
+						Event:   events.EventWorkerError,
+						Payload: errors.E(op, errors.Errorf("can't allocate worker, retry attempt failed: %v", err)),
+					})
+				if !ww.retrySpawn(err) {
+					return nil, err
+				}
+				continue
+			}
+
+			// reallocated
+			return sw, nil
+		}
+	}
+}
+
+// retrySpawn reports whether the failed spawn should be retried
+func (ww *workerWatcher) retrySpawn(err error) bool {
+	if ww.spawnClassifier == nil {
+		return true
+	}
+
+	return ww.spawnClassifier(err)
+}
+
 // Remove worker
 func (ww *workerWatcher) Remove(wb worker.BaseProcess) {
 	ww.Lock()
 	defer ww.Unlock()
 
-	// set remove state
-	pid := wb.Pid()
+	// matched by the ID, the pid of the exited worker might be already reused by the replacement
+	id := wb.ID()
 
 	// worker will be removed on the Get operation
 	for i := 0; i < len(ww.workers); i++ {
-		if ww.workers[i].Pid() == pid {
-			ww.workers = append(ww.workers[:i], ww.workers[i+1:]...)
+		if ww.workers[i].ID() == id {
+			ww.vacate(i)
 			// kill worker, just to be sure it's dead
 			_ = wb.Kill()
 			return
@@ -220,41 +675,128 @@ func (ww *workerWatcher) Remove(wb worker.BaseProcess) {
 func (ww *workerWatcher) Release(w worker.BaseProcess) {
 	switch w.State().Value() {
 	case worker.StateReady:
+		// retiring worker is removed once idle (graceful scale down)
+		if ww.releaseRetiring(w) {
+			return
+		}
+		// draining worker is recycled once idle
+		if ww.releaseDraining(w) {
+			return
+		}
 		ww.container.Push(w)
+		ww.checkExhausted()
 	default:
-		_ = w.Kill()
+		ww.kill(w)
 	}
 }
 
-// Destroy all underlying container (but let them complete the task)
-func (ww *workerWatcher) Destroy(_ context.Context) {
+// checkExhausted emits the EventPoolExhausted when the container becomes empty and the EventPoolRecovered when
+// the ready worker is back. State is re-checked after each transition, so a concurrent Take/Release can't leave it stale.
+func (ww *workerWatcher) checkExhausted() {
+	for {
+		if ww.container.Len() == 0 {
+			if !atomic.CompareAndSwapUint32(&ww.exhausted, 0, 1) {
+				return
+			}
+			ww.events.Push(events.PoolEvent{Event: events.EventPoolExhausted})
+			continue
+		}
+
+		if !atomic.CompareAndSwapUint32(&ww.exhausted, 1, 0) {
+			return
+		}
+		ww.events.Push(events.PoolEvent{Event: events.EventPoolRecovered})
+	}
+}
+
+// Destroy all underlying container (but let them complete the task), if the context is done,
+// all the workers are killed even if they are still working. The container is drained and the workers are killed
+// one by one, returns the combined error of the workers failed to be killed.
+func (ww *workerWatcher) Destroy(ctx context.Context) error {
+	const op = errors.Op("worker_watcher_destroy")
 	// destroy container, we don't use ww mutex here, since we should be able to push worker
 	ww.Lock()
 	// do not release new workers
 	ww.container.Destroy()
 	ww.Unlock()
+	// do not probe the quarantined replacements
+	ww.stopQuarantine()
+	ww.stopOnce.Do(func() {
+		close(ww.stopCh)
+	})
 
-	tt := time.NewTicker(time.Millisecond * 100)
+	tt := ww.clock.NewTicker(time.Millisecond * 100)
 	defer tt.Stop()
-	for { //nolint:gosimple
+	// report the initial state, then periodically while waiting
+	ww.events.Push(events.PoolEvent{Event: events.EventDestroyProgress, Payload: ww.destroyProgress()})
+	progress := ww.clock.NewTicker(ww.destroyProgressInterval)
+	defer progress.Stop()
+	for {
 		select {
-		case <-tt.C:
+		case <-progress.C():
+			ww.events.Push(events.PoolEvent{Event: events.EventDestroyProgress, Payload: ww.destroyProgress()})
+		case <-ctx.Done():
+			ww.Lock()
+			// grace period is over, kill all the workers including the working ones
+			err := ww.killAll(op)
+			workers := append([]worker.BaseProcess(nil), ww.workers...)
+			ww.Unlock()
+			ww.awaitReaped(workers...)
+			return err
+		case <-tt.C():
 			ww.Lock()
 			// that might be one of the workers is working
-			if atomic.LoadUint64(ww.numWorkers) != uint64(len(ww.workers)) {
+			if atomic.LoadUint64(ww.numWorkers) != uint64(len(ww.workers)) || ww.hasWorking() {
 				ww.Unlock()
 				continue
 			}
 			// All container at this moment are in the container
 			// Pop operation is blocked, push can't be done, since it's not possible to pop
-			for i := 0; i < len(ww.workers); i++ {
-				ww.workers[i].State().Set(worker.StateDestroyed)
-				// kill the worker
-				_ = ww.workers[i].Kill()
-			}
+			err := ww.killAll(op)
+			// workers are reaped before they're removed, waiting under the lock doesn't block the reaping
+			ww.awaitReaped(ww.workers...)
+			// the wait goroutines remove the destroyed workers, holding the lock would leak them
+			ww.Unlock()
+			return err
+		}
+	}
+}
+
+// killAll drains the container and kills the drained workers one by one, then the watched workers which are not in
+// the container (working on the destroy timeout). Returns the combined error of the watched workers failed to be
+// killed, the stale container entries (already replaced workers) are killed just to be sure. Should be called under
+// the lock.
+func (ww *workerWatcher) killAll(op errors.Op) error {
+	watched := make(map[worker.BaseProcess]struct{}, len(ww.workers))
+	for i := 0; i < len(ww.workers); i++ {
+		watched[ww.workers[i]] = struct{}{}
+	}
+
+	var err error
+	kill := func(w worker.BaseProcess) {
+		w.State().Set(worker.StateDestroyed)
+		errK := w.Kill()
+		if _, ok := watched[w]; !ok {
 			return
 		}
+		delete(watched, w)
+		if errK != nil {
+			err = multierr.Append(err, errors.E(op, errors.Errorf("worker %d can't be killed: %v", w.Pid(), errK)))
+		}
+	}
+
+	drained := ww.container.Drain()
+	for i := 0; i < len(drained); i++ {
+		kill(drained[i])
 	}
+
+	for i := 0; i < len(ww.workers); i++ {
+		if _, ok := watched[ww.workers[i]]; ok {
+			kill(ww.workers[i])
+		}
+	}
+
+	return err
 }
 
 // List - this is O(n) operation, and it will return copy of the actual workers
@@ -274,19 +816,59 @@ func (ww *workerWatcher) List() []worker.BaseProcess {
 	return base
 }
 
+// Oldest returns the worker with the earliest Created time, nil if no workers
+func (ww *workerWatcher) Oldest() worker.BaseProcess {
+	return ww.pick(func(w, cur worker.BaseProcess) bool { return w.Created().Before(cur.Created()) })
+}
+
+// Newest returns the worker with the latest Created time, nil if no workers
+func (ww *workerWatcher) Newest() worker.BaseProcess {
+	return ww.pick(func(w, cur worker.BaseProcess) bool { return w.Created().After(cur.Created()) })
+}
+
+// pick returns the worker preferred over all the others, O(n) under the read lock
+func (ww *workerWatcher) pick(prefer func(w, cur worker.BaseProcess) bool) worker.BaseProcess {
+	ww.RLock()
+	defer ww.RUnlock()
+
+	var res worker.BaseProcess
+	for i := 0; i < len(ww.workers); i++ {
+		if res == nil || prefer(ww.workers[i], res) {
+			res = ww.workers[i]
+		}
+	}
+
+	return res
+}
+
 func (ww *workerWatcher) wait(w worker.BaseProcess) {
 	const op = errors.Op("worker_watcher_wait")
 	err := w.Wait()
+	ww.reaped(w)
+	// intentional exit, recycled as the clean one
+	graceful := ww.gracefulExit(err)
+	if graceful {
+		err = nil
+	}
+	// should be checked before the worker is removed (killed)
+	fail := failed(w, err)
 	if err != nil {
 		ww.events.Push(events.WorkerEvent{
 			Event:   events.EventWorkerError,
 			Worker:  w,
 			Payload: errors.E(op, err),
+			Labels:  w.Labels(),
 		})
 	}
+	// crash, OOM kill, abort
+	if ee, ok := worker.AsExitError(err); ok {
+		ww.events.Push(events.PoolEvent{Event: events.EventWorkerProcessExit, Payload: w, Error: ee})
+	}
 
 	// remove worker
 	ww.Remove(w)
+	ww.draining.Delete(w)
+	ww.retiring.Delete(w)
 
 	if w.State().Value() == worker.StateDestroyed {
 		// worker was manually destroyed, no need to replace
@@ -294,11 +876,37 @@ func (ww *workerWatcher) wait(w worker.BaseProcess) {
 		return
 	}
 
+	if _, ok := ww.replaced.LoadAndDelete(w); ok {
+		// replacement was allocated before the worker has been stopped
+		ww.events.Push(events.PoolEvent{Event: events.EventWorkerDestruct, Payload: w})
+		return
+	}
+
+	if ww.shrink() {
+		// worker was allocated on demand, shrink back to the number of workers
+		ww.events.Push(events.PoolEvent{Event: events.EventWorkerDestruct, Payload: w})
+		return
+	}
+
+	if ww.hold(fail) {
+		// crash loop, replacement is allocated after the quarantine cooldown
+		ww.events.Push(events.PoolEvent{Event: events.EventWorkerDestruct, Payload: w})
+		return
+	}
+
 	// set state as stopped
 	w.State().Set(worker.StateStopped)
+	if graceful {
+		ww.events.Push(events.PoolEvent{Event: events.EventWorkerDestruct, Payload: w})
+	}
 
 	err = ww.Allocate()
 	if err != nil {
+		// shutdown, no replacement needed
+		if errors.Is(errors.WatcherStopped, err) {
+			return
+		}
+
 		ww.events.Push(events.PoolEvent{
 			Event: events.EventWorkerProcessExit,
 			Error: errors.E(op, err),
@@ -311,8 +919,31 @@ func (ww *workerWatcher) wait(w worker.BaseProcess) {
 	}
 }
 
+// shrink releases the slot of the exited worker if there are more workers than the target number of workers
+func (ww *workerWatcher) shrink() bool {
+	for {
+		num := atomic.LoadUint64(ww.numWorkers)
+		if num <= atomic.LoadUint64(&ww.targetWorkers) {
+			return false
+		}
+
+		if atomic.CompareAndSwapUint64(ww.numWorkers, num, num-1) {
+			return true
+		}
+	}
+}
+
 func (ww *workerWatcher) addToWatch(wb worker.BaseProcess) {
+	ww.track(wb)
+	atomic.AddInt64(&ww.watching, 1)
 	go func() {
+		defer atomic.AddInt64(&ww.watching, -1)
 		ww.wait(wb)
 	}()
 }
+
+// WatchGoroutines returns the number of the running wait goroutines, one per the watched worker process. Exited worker
+// goroutine finishes after its replacement is allocated, so the number might exceed the number of workers meanwhile.
+func (ww *workerWatcher) WatchGoroutines() int {
+	return int(atomic.LoadInt64(&ww.watching))
+}
@@ -0,0 +1,1031 @@
+package worker_watcher //nolint:stylecheck
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spiral/errors"
+	"github.com/spiral/roadrunner/v2/events"
+	"github.com/spiral/roadrunner/v2/internal/testclock"
+	"github.com/spiral/roadrunner/v2/worker"
+	"github.com/spiral/roadrunner/v2/worker_watcher/container/ring"
+	"github.com/spiral/roadrunner/v2/worker_watcher/internal/testworker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAllocator() worker.Allocator {
+	return func() (worker.SyncWorker, error) {
+		return testworker.New(), nil
+	}
+}
+
+func initWatcher(t *testing.T, num uint64, options ...Options) (*workerWatcher, []worker.BaseProcess) {
+	ww := NewSyncWorkerWatcher(testAllocator(), num, events.NewEventsHandler(), time.Second, options...)
+	workers := make([]worker.BaseProcess, 0, num)
+	for i := uint64(0); i < num; i++ {
+		workers = append(workers, testworker.New())
+	}
+	require.NoError(t, ww.Watch(workers))
+	return ww, workers
+}
+
+func TestWatcher_TakeStrict(t *testing.T) {
+	ww, workers := initWatcher(t, 2)
+
+	workers[0].State().Set(worker.StateInvalid)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, workers[1].Pid(), w.Pid())
+	// strict mode kills the not ready worker
+	assert.True(t, workers[0].(*testworker.Worker).Killed())
+}
+
+func TestWatcher_RingContainer(t *testing.T) {
+	ww, workers := initWatcher(t, 2, WithContainer(func(capacity uint64) Vector {
+		return ring.NewRing(capacity)
+	}))
+	assert.IsType(t, &ring.Ring{}, ww.container)
+
+	workers[0].State().Set(worker.StateInvalid)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, workers[1].Pid(), w.Pid())
+	assert.True(t, workers[0].(*testworker.Worker).Killed())
+
+	ww.Release(w)
+	w, err = ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, workers[1].Pid(), w.Pid())
+}
+
+func TestWatcher_SetNumWorkers(t *testing.T) {
+	ww, _ := initWatcher(t, 2, WithContainerCapacity(4))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, ww.SetNumWorkers(ctx, 4))
+	assert.Len(t, ww.List(), 4)
+	assert.Equal(t, uint64(4), ww.container.Len())
+
+	// exceeds the container capacity
+	assert.Error(t, ww.SetNumWorkers(ctx, 5))
+
+	require.NoError(t, ww.SetNumWorkers(ctx, 1))
+	// scaled down workers are not reallocated
+	assert.Eventually(t, func() bool {
+		return len(ww.List()) == 1
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, ww.List(), 1)
+}
+
+func TestWatcher_ShrinkOnDemand(t *testing.T) {
+	ww, workers := initWatcher(t, 1, WithMaxWorkers(3))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	base, err := ww.Take(ctx)
+	require.NoError(t, err)
+	// allocated on demand, the container is empty
+	w1, err := ww.TakeOrAllocate(ctx)
+	require.NoError(t, err)
+	w2, err := ww.TakeOrAllocate(ctx)
+	require.NoError(t, err)
+	assert.Len(t, ww.List(), 3)
+
+	// exited on-demand workers are not reallocated
+	_ = w1.Kill()
+	_ = w2.Kill()
+	assert.Eventually(t, func() bool {
+		return len(ww.List()) == 1
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, ww.List(), 1)
+
+	// the base worker is still reallocated
+	_ = base.Kill()
+	assert.Eventually(t, func() bool {
+		list := ww.List()
+		return len(list) == 1 && list[0].Pid() != workers[0].Pid()
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestWatcher_LocalsRecycle(t *testing.T) {
+	ww, workers := initWatcher(t, 1)
+	workers[0].SetLocal("config", "v1")
+
+	// process exited, the watcher allocates a new one
+	_ = workers[0].Kill()
+	assert.Eventually(t, func() bool {
+		list := ww.List()
+		return len(list) == 1 && list[0].Pid() != workers[0].Pid()
+	}, time.Second, time.Millisecond*10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, w.Locals())
+	_, ok := w.GetLocal("config")
+	assert.False(t, ok)
+}
+
+func TestWatcher_ReplaceFullContainer(t *testing.T) {
+	ww, workers := initWatcher(t, 2)
+	require.Equal(t, uint64(2), ww.container.Len())
+
+	// idle worker in the full container
+	require.NoError(t, ww.Replace(workers[0]))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w1, err := ww.Take(ctx)
+	require.NoError(t, err)
+	w2, err := ww.Take(ctx)
+	require.NoError(t, err)
+
+	pids := []int64{w1.Pid(), w2.Pid()}
+	assert.Contains(t, pids, workers[1].Pid())
+	assert.NotContains(t, pids, workers[0].Pid())
+}
+
+func TestWatcher_DestroyProgress(t *testing.T) {
+	ww, _ := initWatcher(t, 2, WithDestroyProgressInterval(time.Millisecond*10))
+
+	progress := make(chan events.DestroyProgress, 1000)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventDestroyProgress {
+			progress <- ev.Payload.(events.DestroyProgress)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	// in the middle of the request
+	w.State().Set(worker.StateWorking)
+
+	done := make(chan struct{})
+	go func() {
+		ctxD, cancelD := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancelD()
+		ww.Destroy(ctxD)
+		close(done)
+	}()
+
+	// initial event is emitted on entry
+	dp := <-progress
+	assert.Equal(t, events.DestroyProgress{Working: 1, Idle: 1, Total: 2}, dp)
+	assert.Equal(t, "draining 1/2 workers", dp.String())
+
+	// periodic event while the worker is still working
+	dp = <-progress
+	assert.Equal(t, 1, dp.Working)
+
+	select {
+	case <-done:
+		t.Fatal("destroy should wait for the working worker")
+	default:
+	}
+
+	// request completed
+	w.State().Set(worker.StateReady)
+	ww.Release(w)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("destroy should complete after the worker is released")
+	}
+}
+
+func TestWatcher_Quarantine(t *testing.T) {
+	var allocated int64
+	allocator := func() (worker.SyncWorker, error) {
+		atomic.AddInt64(&allocated, 1)
+		return testworker.New(), nil
+	}
+
+	ww := NewSyncWorkerWatcher(allocator, 2, events.NewEventsHandler(), time.Second,
+		WithQuarantine(2, time.Minute, time.Millisecond*100))
+	workers := []*testworker.Worker{testworker.New(), testworker.New()}
+	require.NoError(t, ww.Watch([]worker.BaseProcess{workers[0], workers[1]}))
+
+	quarantined := make(chan events.Quarantine, 10)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolQuarantined {
+			quarantined <- ev.Payload.(events.Quarantine)
+		}
+	})
+
+	// first failure is replaced immediately
+	workers[0].Crash(errors.Str("exit status 255"))
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&allocated) == 1 }, time.Second, time.Millisecond)
+
+	// second one reaches the threshold, replacement is held
+	workers[1].Crash(errors.Str("exit status 255"))
+	select {
+	case q := <-quarantined:
+		assert.Equal(t, uint64(2), q.Failures)
+		assert.Equal(t, uint64(1), q.Held)
+	case <-time.After(time.Second):
+		t.Fatal("EventPoolQuarantined should be emitted")
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&allocated))
+	assert.Equal(t, uint64(1), atomic.LoadUint64(ww.numWorkers))
+
+	// probe after the cooldown restores the capacity
+	require.Eventually(t, func() bool { return len(ww.List()) == 2 }, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&allocated))
+	assert.Equal(t, uint64(2), atomic.LoadUint64(ww.numWorkers))
+
+	// killed by the pool, not a failure
+	for _, w := range ww.List() {
+		_ = w.Kill()
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&allocated) == 4 }, time.Second, time.Millisecond)
+	assert.Len(t, quarantined, 0)
+}
+
+func TestWatcher_QuarantineClock(t *testing.T) {
+	clock := testclock.New(time.Now())
+	var allocated int64
+	allocator := func() (worker.SyncWorker, error) {
+		atomic.AddInt64(&allocated, 1)
+		return testworker.New(), nil
+	}
+
+	ww := NewSyncWorkerWatcher(allocator, 1, events.NewEventsHandler(), time.Second,
+		WithQuarantine(1, time.Minute, time.Hour), WithClock(clock))
+	w := testworker.New()
+	require.NoError(t, ww.Watch([]worker.BaseProcess{w}))
+
+	quarantined := make(chan events.Quarantine, 10)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolQuarantined {
+			quarantined <- ev.Payload.(events.Quarantine)
+		}
+	})
+
+	now := clock.Now()
+	w.Crash(errors.Str("exit status 255"))
+	select {
+	case q := <-quarantined:
+		// the cooldown is measured by the watcher clock
+		assert.Equal(t, now.Add(time.Hour), q.Until)
+	case <-time.After(time.Second):
+		t.Fatal("EventPoolQuarantined should be emitted")
+	}
+
+	// the probe waits for the clock
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&allocated))
+
+	clock.Advance(time.Hour)
+	require.Eventually(t, func() bool { return len(ww.List()) == 1 }, time.Second, time.Millisecond*10)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&allocated))
+}
+
+func TestWatcher_IdleClock(t *testing.T) {
+	clock := testclock.New(time.Now())
+	ww, _ := initWatcher(t, 1, WithIdleTimeout(time.Minute), WithClock(clock))
+
+	idle := make(chan time.Duration, 10)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolIdle {
+			idle <- ev.Payload.(time.Duration)
+		}
+	})
+
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	select {
+	case d := <-idle:
+		assert.Equal(t, time.Minute, d)
+	case <-time.After(time.Second):
+		t.Fatal("EventPoolIdle should be pushed")
+	}
+}
+
+func TestWatcher_Exhausted(t *testing.T) {
+	ww, _ := initWatcher(t, 2)
+
+	evs := make(chan events.P, 10)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && (ev.Event == events.EventPoolExhausted || ev.Event == events.EventPoolRecovered) {
+			evs <- ev.Event
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	w1, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Len(t, evs, 0)
+
+	w2, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, events.EventPoolExhausted, <-evs)
+
+	ww.Release(w1)
+	assert.Equal(t, events.EventPoolRecovered, <-evs)
+
+	// not a transition
+	ww.Release(w2)
+	assert.Len(t, evs, 0)
+}
+
+func TestWatcher_AllocatorStopped(t *testing.T) {
+	var allocated int64
+	allocator := func() (worker.SyncWorker, error) {
+		atomic.AddInt64(&allocated, 1)
+		return nil, errors.E(errors.Op("test_allocator"), errors.WatcherStopped, context.Canceled)
+	}
+
+	ww := NewSyncWorkerWatcher(allocator, 2, events.NewEventsHandler(), time.Second)
+	workers := []worker.BaseProcess{testworker.New(), testworker.New()}
+	require.NoError(t, ww.Watch(workers))
+
+	noise := make(chan interface{}, 10)
+	ww.events.AddListener(func(event interface{}) {
+		switch ev := event.(type) {
+		case events.WorkerEvent:
+			noise <- ev
+		case events.PoolEvent:
+			if ev.Event == events.EventWorkerProcessExit {
+				noise <- ev
+			}
+		}
+	})
+
+	_ = workers[0].Kill()
+	// the slot is released w/o the retries
+	require.Eventually(t, func() bool { return atomic.LoadUint64(ww.numWorkers) == 1 }, time.Millisecond*400, time.Millisecond)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&allocated))
+	assert.Len(t, noise, 0)
+}
+
+func TestWatcher_OldestNewest(t *testing.T) {
+	ww := NewSyncWorkerWatcher(testAllocator(), 3, events.NewEventsHandler(), time.Second)
+	assert.Nil(t, ww.Oldest())
+	assert.Nil(t, ww.Newest())
+
+	workers := make([]worker.BaseProcess, 0, 3)
+	for i := 0; i < 3; i++ {
+		workers = append(workers, testworker.New())
+		time.Sleep(time.Millisecond)
+	}
+	// watch order doesn't matter
+	require.NoError(t, ww.Watch([]worker.BaseProcess{workers[1], workers[2], workers[0]}))
+
+	assert.Equal(t, workers[0].Pid(), ww.Oldest().Pid())
+	assert.Equal(t, workers[2].Pid(), ww.Newest().Pid())
+}
+
+func TestWatcher_Drain(t *testing.T) {
+	ww, workers := initWatcher(t, 2)
+	assert.Error(t, ww.Drain(-1))
+
+	// idle worker is recycled immediately
+	require.NoError(t, ww.Drain(workers[0].Pid()))
+	require.Eventually(t, func() bool { return ww.find(workers[0].Pid()) == nil }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	busy, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, workers[0].Pid(), busy.Pid())
+	busy.State().Set(worker.StateWorking)
+
+	// busy worker finishes the request first
+	require.NoError(t, ww.Drain(busy.Pid()))
+	assert.Equal(t, worker.StateWorking, busy.State().Value())
+
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, busy.Pid(), w.Pid())
+	ww.Release(w)
+
+	// recycled once idle
+	busy.State().Set(worker.StateReady)
+	ww.Release(busy)
+	require.Eventually(t, func() bool { return ww.find(busy.Pid()) == nil }, time.Second, time.Millisecond)
+	assert.Len(t, ww.List(), 2)
+
+	for i := 0; i < 4; i++ {
+		w, err = ww.Take(ctx)
+		require.NoError(t, err)
+		assert.NotEqual(t, busy.Pid(), w.Pid())
+		ww.Release(w)
+	}
+}
+
+func TestWatcher_Idle(t *testing.T) {
+	ww, _ := initWatcher(t, 1, WithIdleTimeout(time.Millisecond*50))
+	defer ww.Destroy(context.Background())
+
+	idle := make(chan time.Duration, 10)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventPoolIdle {
+			idle <- ev.Payload.(time.Duration)
+		}
+	})
+
+	select {
+	case d := <-idle:
+		assert.GreaterOrEqual(t, d, time.Millisecond*50)
+	case <-time.After(time.Second):
+		t.Fatal("EventPoolIdle should be pushed")
+	}
+
+	// once per idle period
+	time.Sleep(time.Millisecond * 120)
+	assert.Len(t, idle, 0)
+
+	// active traffic resets the period
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		w, err := ww.Take(ctx)
+		require.NoError(t, err)
+		ww.Release(w)
+		time.Sleep(time.Millisecond * 20)
+	}
+	assert.Len(t, idle, 0)
+
+	select {
+	case <-idle:
+	case <-time.After(time.Second):
+		t.Fatal("EventPoolIdle should be pushed after the traffic stops")
+	}
+}
+
+func TestWatcher_TakeLenient(t *testing.T) {
+	ww, workers := initWatcher(t, 2, WithStrictTake(false))
+
+	anomalies := int64(0)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.WorkerEvent); ok && ev.Event == events.EventWorkerInconsistentState {
+			atomic.AddInt64(&anomalies, 1)
+		}
+	})
+
+	workers[0].State().Set(worker.StateWorking)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, workers[1].Pid(), w.Pid())
+	// lenient mode keeps the worker and reports the anomaly
+	assert.False(t, workers[0].(*testworker.Worker).Killed())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&anomalies))
+	assert.Equal(t, uint64(1), ww.container.Len())
+}
+
+func TestWatcher_Replace(t *testing.T) {
+	allocated := int64(0)
+	allocator := func() (worker.SyncWorker, error) {
+		atomic.AddInt64(&allocated, 1)
+		return testworker.New(), nil
+	}
+
+	ww := NewSyncWorkerWatcher(allocator, 1, events.NewEventsHandler(), time.Second)
+	prev := testworker.New()
+	require.NoError(t, ww.Watch([]worker.BaseProcess{prev}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+
+	// replacement is in the container before the previous worker is stopped
+	require.NoError(t, ww.Replace(w))
+	assert.Equal(t, uint64(1), ww.container.Len())
+	assert.Equal(t, worker.StateStopped, prev.State().Value())
+
+	// previous worker exited, should not be reallocated
+	assert.Eventually(t, func() bool {
+		return len(ww.List()) == 1 && ww.List()[0].Pid() != prev.Pid()
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&allocated))
+}
+
+func TestWatcher_Slots(t *testing.T) {
+	ww, workers := initWatcher(t, 3)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, workers[i].Pid(), ww.Slot(i).Pid())
+	}
+	assert.Nil(t, ww.Slot(3))
+	assert.Nil(t, ww.Slot(-1))
+
+	// exited worker is reallocated to the same slot
+	require.NoError(t, workers[1].Kill())
+	assert.Eventually(t, func() bool {
+		w := ww.Slot(1)
+		return w != nil && w.Pid() != workers[1].Pid()
+	}, time.Second, time.Millisecond*10)
+
+	list := ww.List()
+	require.Len(t, list, 3)
+	assert.Equal(t, workers[0].Pid(), list[0].Pid())
+	assert.Equal(t, ww.Slot(1).Pid(), list[1].Pid())
+	assert.Equal(t, workers[2].Pid(), list[2].Pid())
+
+	// warm replacement takes the slot of the previous worker
+	require.NoError(t, ww.Replace(workers[0]))
+	assert.NotEqual(t, workers[0].Pid(), ww.Slot(0).Pid())
+	assert.Equal(t, workers[2].Pid(), ww.Slot(2).Pid())
+	assert.Len(t, ww.List(), 3)
+}
+
+func TestWatcher_ReapTimeout(t *testing.T) {
+	ww, workers := initWatcher(t, 2, WithReapTimeout(time.Millisecond*100))
+
+	// killed worker is reaped before the Release returns
+	workers[0].State().Set(worker.StateInvalid)
+	ww.Release(workers[0])
+	_, tracked := ww.reaping.Load(workers[0])
+	assert.False(t, tracked)
+
+	// unkillable worker doesn't block the Release for longer than the timeout
+	stuck := workers[1].(*testworker.Worker)
+	stuck.Unkillable()
+	stuck.State().Set(worker.StateInvalid)
+	start := time.Now()
+	ww.Release(stuck)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*100)
+	assert.Less(t, time.Since(start), time.Second)
+	_, tracked = ww.reaping.Load(stuck)
+	assert.True(t, tracked)
+}
+
+func TestWatcher_SpawnRate(t *testing.T) {
+	ww, _ := initWatcher(t, 0, WithSpawnRate(10, 1))
+
+	// burst is spawned at once, the rest is delayed (not failed)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		atomic.AddUint64(ww.numWorkers, 1)
+		require.NoError(t, ww.Allocate())
+	}
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*190)
+	assert.Len(t, ww.List(), 3)
+
+	// delayed spawn doesn't block the Destroy
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, _ = ww.allocator()
+		}()
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	ww.Destroy(ctx)
+
+	_, err := ww.allocator()
+	assert.True(t, errors.Is(errors.WatcherStopped, err))
+}
+
+func TestWatcher_MaxConcurrentSpawns(t *testing.T) {
+	var running, peak, calls int64
+	block := make(chan struct{})
+	allocator := func() (worker.SyncWorker, error) {
+		n := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		<-block
+		// failed spawns release the slot too
+		if atomic.AddInt64(&calls, 1)%2 == 0 {
+			return nil, errors.Str("spawn failed")
+		}
+		return testworker.New(), nil
+	}
+	ww := NewSyncWorkerWatcher(allocator, 0, events.NewEventsHandler(), time.Second, WithMaxConcurrentSpawns(2))
+
+	done := make(chan struct{}, 6)
+	for i := 0; i < 6; i++ {
+		go func() {
+			_, _ = ww.allocator()
+			done <- struct{}{}
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&running))
+	close(block)
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	assert.Equal(t, int64(2), atomic.LoadInt64(&peak))
+	assert.Equal(t, int64(6), atomic.LoadInt64(&calls))
+}
+
+func TestWatcher_MaxConcurrentSpawnsDestroy(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	ww := NewSyncWorkerWatcher(func() (worker.SyncWorker, error) {
+		<-block
+		return testworker.New(), nil
+	}, 0, events.NewEventsHandler(), time.Second, WithMaxConcurrentSpawns(1))
+
+	// holds the only slot
+	go func() {
+		_, _ = ww.allocator()
+	}()
+	time.Sleep(time.Millisecond * 20)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := ww.allocator()
+		errCh <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	_ = ww.Destroy(ctx)
+
+	// the waiting spawn doesn't block the Destroy
+	select {
+	case err := <-errCh:
+		assert.True(t, errors.Is(errors.WatcherStopped, err))
+	case <-time.After(time.Second):
+		t.Fatal("the waiting spawn is not canceled")
+	}
+}
+
+func TestSpawnLimiter_Reserve(t *testing.T) {
+	now := time.Now()
+	sl := &spawnLimiter{rate: 2, burst: 2, tokens: 2, last: now}
+
+	assert.Equal(t, time.Duration(0), sl.reserve(now))
+	assert.Equal(t, time.Duration(0), sl.reserve(now))
+	assert.Equal(t, time.Millisecond*500, sl.reserve(now))
+	assert.Equal(t, time.Second, sl.reserve(now))
+
+	// refilled up to the burst
+	later := now.Add(time.Hour)
+	assert.Equal(t, time.Duration(0), sl.reserve(later))
+	assert.Equal(t, time.Duration(0), sl.reserve(later))
+	assert.Equal(t, time.Millisecond*500, sl.reserve(later))
+}
+
+func TestWatcher_WatchGoroutines(t *testing.T) {
+	ww, _ := initWatcher(t, 3)
+	assert.Equal(t, 3, ww.WatchGoroutines())
+
+	// churn: crashes (reallocated) and the warm replacements
+	for i := 0; i < 20; i++ {
+		list := ww.List()
+		require.NotEmpty(t, list)
+		if i%2 == 0 {
+			_ = list[0].Kill()
+		} else {
+			require.NoError(t, ww.Replace(list[len(list)-1]))
+		}
+
+		assert.Eventually(t, func() bool {
+			return len(ww.List()) == 3 && ww.WatchGoroutines() == 3
+		}, time.Second, time.Millisecond*10)
+	}
+
+	// no goroutines left after the Destroy
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ww.Destroy(ctx)
+	assert.Eventually(t, func() bool {
+		return ww.WatchGoroutines() == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestWatcher_Clock(t *testing.T) {
+	clock := testclock.New(time.Now())
+	var calls int32
+	allocator := func() (worker.SyncWorker, error) {
+		// the first spawn and the first retry fail
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil, errors.Str("spawn failed")
+		}
+		return testworker.New(), nil
+	}
+
+	ww := NewSyncWorkerWatcher(allocator, 1, events.NewEventsHandler(), time.Minute, WithClock(clock))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ww.Allocate()
+	}()
+
+	// retry ticker and the allocate timeout are waiting
+	require.Eventually(t, func() bool { return clock.Waiters() == 2 }, time.Second, time.Millisecond)
+
+	clock.Advance(time.Millisecond * 500)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 2 }, time.Second, time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("worker should not be allocated before the next retry")
+	default:
+	}
+
+	clock.Advance(time.Millisecond * 500)
+	require.NoError(t, <-done)
+	assert.Len(t, ww.List(), 1)
+	// retry ticker is stopped, the allocate timeout is pending
+	assert.Equal(t, 1, clock.Waiters())
+
+	// allocate timeout without the real wait, the allocator keeps failing
+	atomic.StoreInt32(&calls, -100)
+	atomic.AddUint64(ww.numWorkers, 1)
+	go func() {
+		done <- ww.Allocate()
+	}()
+	require.Eventually(t, func() bool { return clock.Waiters() == 3 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	assert.Error(t, <-done)
+}
+
+func TestWatcher_SpawnClassifier(t *testing.T) {
+	clock := testclock.New(time.Now())
+	permanent := errors.Str("binary not found")
+	var calls int32
+	allocator := func() (worker.SyncWorker, error) {
+		// transient failure of the first spawn, then the permanent one
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.Str("resource temporarily unavailable")
+		}
+		return nil, permanent
+	}
+
+	ww := NewSyncWorkerWatcher(allocator, 1, events.NewEventsHandler(), time.Minute, WithClock(clock),
+		WithSpawnClassifier(func(err error) bool {
+			return err != permanent
+		}))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ww.Allocate()
+	}()
+
+	// transient failure is retried
+	require.Eventually(t, func() bool { return clock.Waiters() == 2 }, time.Second, time.Millisecond)
+	clock.Advance(time.Millisecond * 500)
+
+	// permanent failure is returned without waiting for the allocate timeout
+	assert.Error(t, <-done)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// the first permanent failure is not retried at all
+	atomic.StoreInt32(&calls, 1)
+	atomic.AddUint64(ww.numWorkers, 1)
+	assert.Error(t, ww.Allocate())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestWatcher_DestroyKillErrors(t *testing.T) {
+	ww, workers := initWatcher(t, 3, WithContainerCapacity(4))
+	workers[1].(*testworker.Worker).SetKillErr(errors.Str("operation not permitted"))
+
+	// stale entry of the replaced worker, drained and killed, but not reported
+	stale := testworker.New()
+	stale.SetKillErr(errors.Str("process already finished"))
+	ww.container.Push(stale)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := ww.Destroy(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("worker %d can't be killed", workers[1].Pid()))
+	assert.NotContains(t, err.Error(), "process already finished")
+
+	// container is drained, every worker is killed
+	assert.Equal(t, uint64(0), ww.container.Len())
+	for i := 0; i < len(workers); i++ {
+		assert.True(t, workers[i].(*testworker.Worker).Killed())
+		assert.Equal(t, worker.StateDestroyed, workers[i].State().Value())
+	}
+	assert.True(t, stale.Killed())
+}
+
+func TestWatcher_RemoveReusedPid(t *testing.T) {
+	reused := testworker.New()
+	ww, workers := initWatcher(t, 1)
+	// the exited worker is not removed yet, the replacement got the same pid
+	reused.SetPid(workers[0].Pid())
+	require.NoError(t, ww.Watch([]worker.BaseProcess{reused}))
+
+	ww.Remove(reused)
+	list := ww.List()
+	require.Len(t, list, 1)
+	assert.Same(t, workers[0], list[0])
+}
+
+func TestWatcher_ScaleDownGraceful(t *testing.T) {
+	ww, _ := initWatcher(t, 3, WithContainerCapacity(4))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	busy := make([]worker.BaseProcess, 0, 2)
+	for i := 0; i < 2; i++ {
+		w, err := ww.Take(ctx)
+		require.NoError(t, err)
+		w.State().Set(worker.StateWorking)
+		busy = append(busy, w)
+	}
+
+	// the free worker is removed, one of the busy workers is deferred, the call doesn't wait for it
+	res, err := ww.SetNumWorkersPolicy(ctx, 1, ScaleDownGraceful)
+	require.NoError(t, err)
+	assert.Equal(t, ScaleResult{Removed: 1, Deferred: 1}, res)
+	assert.Eventually(t, func() bool {
+		return len(ww.List()) == 2
+	}, time.Second, time.Millisecond*10)
+
+	// the retiring worker is removed on release, the other one keeps serving
+	for i := 0; i < len(busy); i++ {
+		busy[i].State().Set(worker.StateReady)
+		ww.Release(busy[i])
+	}
+	assert.Eventually(t, func() bool {
+		return len(ww.List()) == 1
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, ww.List(), 1)
+	assert.Equal(t, uint64(1), ww.container.Len())
+}
+
+func TestWatcher_ScaleDownGracefulScaledUp(t *testing.T) {
+	ww, _ := initWatcher(t, 2, WithContainerCapacity(4))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := ww.Take(ctx)
+	require.NoError(t, err)
+	w.State().Set(worker.StateWorking)
+	_, err = ww.Take(ctx)
+	require.NoError(t, err)
+
+	res, err := ww.SetNumWorkersPolicy(ctx, 1, ScaleDownGraceful)
+	require.NoError(t, err)
+	// the second taken worker is not working, it's not marked
+	assert.Equal(t, ScaleResult{Deferred: 1}, res)
+
+	// scaled back up before the release, the retiring worker keeps serving
+	require.NoError(t, ww.SetNumWorkers(ctx, 2))
+	w.State().Set(worker.StateReady)
+	ww.Release(w)
+	time.Sleep(time.Millisecond * 100)
+	assert.Len(t, ww.List(), 2)
+	assert.Equal(t, uint64(1), ww.container.Len())
+}
+
+func TestWatcher_TakeWorker(t *testing.T) {
+	ww, workers := initWatcher(t, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := ww.TakeWorker(ctx, workers[2].Pid())
+	require.NoError(t, err)
+	assert.Equal(t, workers[2].Pid(), w.Pid())
+	// other workers are pushed back
+	assert.Equal(t, uint64(2), ww.container.Len())
+
+	// busy worker is waited for
+	ctxBusy, cancelBusy := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancelBusy()
+	// the other free workers stay in the container while the busy worker is waited for
+	stop := make(chan struct{})
+	minLen := make(chan uint64, 1)
+	go func() {
+		l := ww.container.Len()
+		for {
+			select {
+			case <-stop:
+				minLen <- l
+				return
+			default:
+				if cur := ww.container.Len(); cur < l {
+					l = cur
+				}
+				runtime.Gosched()
+			}
+		}
+	}()
+	_, err = ww.TakeWorker(ctxBusy, workers[2].Pid())
+	close(stop)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+	assert.Equal(t, uint64(2), ww.container.Len())
+	assert.GreaterOrEqual(t, <-minLen, uint64(1))
+
+	time.AfterFunc(time.Millisecond*50, func() { ww.Release(w) })
+	w, err = ww.TakeWorker(ctx, workers[2].Pid())
+	require.NoError(t, err)
+	assert.Equal(t, workers[2].Pid(), w.Pid())
+
+	_, err = ww.TakeWorker(ctx, 1)
+	assert.Error(t, err)
+}
+
+func TestWatcher_ProcessExitSignal(t *testing.T) {
+	ww := NewSyncWorkerWatcher(testAllocator(), 2, events.NewEventsHandler(), time.Second)
+	workers := []*testworker.Worker{testworker.New(), testworker.New()}
+	require.NoError(t, ww.Watch([]worker.BaseProcess{workers[0], workers[1]}))
+
+	exits := make(chan events.PoolEvent, 10)
+	ww.events.AddListener(func(event interface{}) {
+		if ev, ok := event.(events.PoolEvent); ok && ev.Event == events.EventWorkerProcessExit {
+			exits <- ev
+		}
+	})
+
+	ee := &worker.ExitError{Reason: worker.ExitOOMKilled, Signal: 9, Code: -1, Err: errors.Str("signal: killed")}
+	workers[0].Crash(errors.E(errors.Op("worker_process_wait"), ee))
+
+	select {
+	case ev := <-exits:
+		assert.Equal(t, worker.BaseProcess(workers[0]), ev.Payload)
+		assert.Equal(t, ee, ev.Error)
+	case <-time.After(time.Second):
+		t.Fatal("no process exit event")
+	}
+
+	// regular exit
+	workers[1].Crash(errors.Str("exit status 255"))
+	select {
+	case ev := <-exits:
+		t.Fatalf("unexpected process exit event: %v", ev.Error)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestWatcher_GracefulExitCodes(t *testing.T) {
+	ww := NewSyncWorkerWatcher(testAllocator(), 2, events.NewEventsHandler(), time.Second,
+		WithQuarantine(1, time.Minute, time.Minute), WithGracefulExitCodes([]int{3}))
+	workers := []*testworker.Worker{testworker.New(), testworker.New()}
+	require.NoError(t, ww.Watch([]worker.BaseProcess{workers[0], workers[1]}))
+
+	evs := make(chan interface{}, 10)
+	ww.events.AddListener(func(event interface{}) {
+		switch ev := event.(type) {
+		case events.WorkerEvent:
+			evs <- ev.Event
+		case events.PoolEvent:
+			evs <- ev.Event
+		}
+	})
+
+	exitErr := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		require.Error(t, err)
+		return errors.E(errors.Op("worker_process_wait"), err)
+	}
+
+	// intentional exit is recycled, not quarantined
+	workers[0].Crash(exitErr(3))
+	select {
+	case ev := <-evs:
+		assert.Equal(t, events.EventWorkerDestruct, ev)
+	case <-time.After(time.Second):
+		t.Fatal("no EventWorkerDestruct")
+	}
+	require.Eventually(t, func() bool { return len(ww.List()) == 2 && ww.List()[0] != workers[0] }, time.Second, time.Millisecond)
+	assert.Equal(t, worker.StateStopped, workers[0].State().Value())
+
+	// other codes are the errors
+	workers[1].Crash(exitErr(4))
+	select {
+	case ev := <-evs:
+		assert.Equal(t, events.EventWorkerError, ev)
+	case <-time.After(time.Second):
+		t.Fatal("no EventWorkerError")
+	}
+}